@@ -0,0 +1,237 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"notification/internal/backoff"
+)
+
+// idempotencyKeyHeader is the header with the idempotency key of the request.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// idempotentReplayedHeader is set by the service on the responses replayed for the repeated idempotency keys.
+const idempotentReplayedHeader = "Idempotent-Replayed"
+
+// maxErrorBodySize is the maximum size of the error response body read by the Client.
+const maxErrorBodySize = 64 << 10
+
+// New creates and returns a new Client, applies the default values to the zero fields of the config.
+// It returns ErrInvalidConfig if the base URL is not an absolute http(s) URL.
+func New(config Config) (*Client, error) {
+	base, err := url.Parse(config.BaseURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, ErrInvalidConfig
+	}
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	maxRetries := config.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	}
+
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+
+	retryPause := config.RetryPause
+	if retryPause == 0 {
+		retryPause = DefaultRetryPause
+	}
+
+	maxRetryPause := config.MaxRetryPause
+	if maxRetryPause == 0 {
+		maxRetryPause = DefaultMaxRetryPause
+	}
+
+	return &Client{
+		baseURL:    strings.TrimSuffix(base.String(), "/"),
+		apiKey:     config.APIKey,
+		httpClient: httpClient,
+		maxRetries: maxRetries,
+		backoff:    backoff.New(retryPause, backoff.Config{Jitter: backoff.JitterFull, MaxPause: maxRetryPause}),
+	}, nil
+}
+
+// Error returns the status code, the code and the detail of the error.
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("notification: %d %s: %s", e.StatusCode, e.Title, e.Detail)
+	}
+
+	return fmt.Sprintf("notification: %d %s: %s", e.StatusCode, e.Code, e.Detail)
+}
+
+// call is a single API call of the Client.
+// The POST requests are retried only with the idempotency key, the other methods are idempotent by definition.
+type call struct {
+	method         string
+	path           string
+	query          url.Values
+	body           any
+	idempotencyKey string
+	wantStatus     int
+	out            any
+}
+
+// do makes the API call with retries and decodes the response into call.out.
+// It returns *Error for the error responses of the service, and the context error if ctx is done.
+// The returned header is the header of the last response.
+func (c *Client) do(ctx context.Context, cl call) (http.Header, error) {
+	var body []byte
+
+	if cl.body != nil {
+		var err error
+		if body, err = json.Marshal(cl.body); err != nil {
+			return nil, fmt.Errorf("do: cannot encode request: %w", err)
+		}
+	}
+
+	retryable := cl.method != http.MethodPost || cl.idempotencyKey != ""
+
+	var pause time.Duration
+
+	for attempt := 0; ; attempt++ {
+		header, retryAfter, err := c.attempt(ctx, cl, body)
+		if err == nil {
+			return header, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if !retryable || attempt >= c.maxRetries || !shouldRetry(err) {
+			return header, err
+		}
+
+		pause = max(c.backoff.Pause(attempt+1, pause), retryAfter)
+
+		timer := time.NewTimer(pause)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt makes a single request of the API call and returns the response header, the pause requested
+// by the service and the error of the request.
+func (c *Client) attempt(ctx context.Context, cl call, body []byte) (http.Header, time.Duration, error) {
+	target := c.baseURL + cl.path
+	if len(cl.query) > 0 {
+		target += "?" + cl.query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, cl.method, target, reader)
+	if err != nil {
+		return nil, 0, fmt.Errorf("attempt: cannot create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json, application/problem+json")
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	if cl.idempotencyKey != "" {
+		req.Header.Set(idempotencyKeyHeader, cl.idempotencyKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("attempt: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != cl.wantStatus {
+		apiErr := parseError(resp)
+		return resp.Header, apiErr.RetryAfter, apiErr
+	}
+
+	if cl.out != nil {
+		if err = json.NewDecoder(resp.Body).Decode(cl.out); err != nil {
+			return resp.Header, 0, fmt.Errorf("attempt: cannot decode response: %w", err)
+		}
+	}
+
+	return resp.Header, 0, nil
+}
+
+// parseError returns the Error of the response, the problem+json body is decoded,
+// other bodies are used as the detail of the error.
+func parseError(resp *http.Response) *Error {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+
+	apiErr := &Error{}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if contentType != "application/problem+json" || json.Unmarshal(raw, apiErr) != nil {
+		apiErr = &Error{Title: http.StatusText(resp.StatusCode), Detail: strings.TrimSpace(string(raw))}
+	}
+
+	apiErr.StatusCode = resp.StatusCode
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	return apiErr
+}
+
+// shouldRetry reports whether the request can succeed if it is retried: after network errors,
+// rate limiting, unavailable SMTP or gateway errors, and while the request with the same idempotency key is in progress.
+func shouldRetry(err error) bool {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+
+	case http.StatusConflict:
+		return apiErr.Code == CodeIdempotencyInProgress
+
+	default:
+		return false
+	}
+}
+
+// newIdempotencyKey returns a random idempotency key.
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,443 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"notification/internal/SMTPClient"
+	"notification/internal/api"
+	"notification/internal/api/decoder"
+	"notification/internal/api/handlers"
+	"notification/internal/auth"
+	"notification/internal/config"
+	"notification/internal/monitoring"
+	"notification/internal/storage/postgresClient"
+	"notification/internal/storage/redisClient"
+)
+
+// appMetrics are shared by the tests, because the metrics are registered globally.
+var appMetrics = monitoring.NewAppMetrics()
+
+// testAdminKey is the admin key of the service started by the tests.
+const testAdminKey = "admin-secret"
+
+// newTestClient starts the service with the mocks and returns the Client of the service.
+func newTestClient(t *testing.T, sender *SMTPClient.MockEmailSender, redis *redisClient.MockRedisClient,
+	postgres *postgresClient.MockPostgresService) *Client {
+	notificationHandler := handlers.New(
+		zap.NewNop(),
+		sender,
+		redis,
+		postgres,
+		&decoder.Config{},
+		nil,
+		nil,
+		nil,
+		nil,
+		config.AppTimeouts{},
+		3*time.Second,
+	)
+
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Use(middleware.URLFormat)
+	notificationHandler.Routes(router, auth.New(&auth.Config{AdminKey: testAdminKey}, postgres, monitoring.NewNop(), zap.NewNop()), appMetrics)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	c, err := New(Config{BaseURL: server.URL, APIKey: testAdminKey, RetryPause: time.Millisecond})
+	require.NoError(t, err)
+
+	return c
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr error
+	}{
+		{
+			name:   "valid",
+			config: Config{BaseURL: "https://notify.example.com/"},
+		},
+		{
+			name:    "empty",
+			config:  Config{},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "relative",
+			config:  Config{BaseURL: "notify.example.com"},
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "unsupported scheme",
+			config:  Config{BaseURL: "ftp://notify.example.com"},
+			wantErr: ErrInvalidConfig,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := New(tt.config)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "https://notify.example.com", c.baseURL)
+			assert.Equal(t, http.DefaultClient, c.httpClient)
+			assert.Equal(t, DefaultMaxRetries, c.maxRetries)
+		})
+	}
+}
+
+func TestClientSend(t *testing.T) {
+	tests := []struct {
+		name        string
+		msg         *Message
+		sendErrors  []error
+		wantRetries int
+		wantCreated *Created
+		wantErr     *Error
+	}{
+		{
+			name:        "sent",
+			msg:         &Message{To: "test@example.com", Subject: "Subject", Message: "Message", IdempotencyKey: "key-1"},
+			sendErrors:  []error{nil},
+			wantCreated: &Created{ID: 7, Status: StatusSent},
+		},
+		{
+			name:        "retried while smtp is unavailable",
+			msg:         &Message{To: "test@example.com", Subject: "Subject", Message: "Message", IdempotencyKey: "key-1"},
+			sendErrors:  []error{&SMTPClient.CircuitOpenError{}, nil},
+			wantRetries: 1,
+			wantCreated: &Created{ID: 7, Status: StatusSent},
+		},
+		{
+			name:       "recipient rejected",
+			msg:        &Message{To: "test@example.com", Subject: "Subject", Message: "Message", IdempotencyKey: "key-1"},
+			sendErrors: []error{SMTPClient.ErrPermanentFailure},
+			wantErr: &Error{
+				StatusCode: http.StatusUnprocessableEntity,
+				Code:       CodeRecipientRejected,
+				Detail:     "The recipient was rejected by the mail server",
+				Fields:     []string{"to"},
+			},
+		},
+		{
+			name: "invalid message",
+			msg:  &Message{To: "test@example.com", IdempotencyKey: "key-1"},
+			wantErr: &Error{
+				StatusCode: http.StatusBadRequest,
+				Code:       CodeMissingFields,
+				Detail:     "Not all fields in the request body are filled in",
+				Fields:     []string{"subject", "message"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSender := &SMTPClient.MockEmailSender{}
+			mockRedisClient := &redisClient.MockRedisClient{}
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+
+			storageKey := "idempotency:admin:POST:/v2/notifications:key-1"
+
+			mockRedisClient.On("ReserveIdempotencyKey", mock.Anything, storageKey, mock.Anything).Return(true, nil)
+			mockRedisClient.On("ReleaseIdempotencyKey", mock.Anything, storageKey).Return(nil).Maybe()
+			mockRedisClient.On("SaveIdempotentResponse", mock.Anything, storageKey, mock.Anything, mock.Anything).Return(nil).Maybe()
+			mockPostgresClient.On("IsSuppressed", mock.Anything, "test@example.com").Return(false, nil).Maybe()
+			mockPostgresClient.On("SaveEmail", mock.Anything, mock.Anything).Return(7, nil).Maybe()
+			mockPostgresClient.On("UpdateStatus", mock.Anything, 7, mock.Anything, mock.Anything).Return(nil).Maybe()
+
+			mockSender.On("CircuitOpen").Return(nil).Maybe()
+
+			for _, sendErr := range tt.sendErrors {
+				mockSender.On("SendEmail", mock.Anything, mock.Anything).Return(nil, sendErr).Once()
+			}
+
+			c := newTestClient(t, mockSender, mockRedisClient, mockPostgresClient)
+
+			created, err := c.Send(context.Background(), tt.msg)
+
+			if tt.wantErr != nil {
+				var apiErr *Error
+				require.ErrorAs(t, err, &apiErr)
+				assert.Equal(t, tt.wantErr.StatusCode, apiErr.StatusCode)
+				assert.Equal(t, tt.wantErr.Code, apiErr.Code)
+				assert.Equal(t, tt.wantErr.Detail, apiErr.Detail)
+				assert.Equal(t, tt.wantErr.Fields, apiErr.Fields)
+				assert.NotEmpty(t, apiErr.RequestID)
+				assert.Nil(t, created)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantCreated, created)
+			}
+
+			mockSender.AssertNumberOfCalls(t, "SendEmail", len(tt.sendErrors))
+			mockRedisClient.AssertNumberOfCalls(t, "ReserveIdempotencyKey", tt.wantRetries+1)
+			mockRedisClient.AssertNumberOfCalls(t, "ReleaseIdempotencyKey", tt.wantRetries)
+		})
+	}
+}
+
+func TestClientSendReplayed(t *testing.T) {
+	stored := `{"status":201,"header":{"Content-Type":["application/json"]},"body":"eyJpZCI6Nywic3RhdHVzIjoic2VudCJ9Cg=="}`
+
+	mockRedisClient := &redisClient.MockRedisClient{}
+	mockRedisClient.On("ReserveIdempotencyKey", mock.Anything, "idempotency:admin:POST:/v2/notifications:key-1", mock.Anything).Return(false, nil)
+	mockRedisClient.On("FetchIdempotentResponse", mock.Anything, "idempotency:admin:POST:/v2/notifications:key-1").Return([]byte(stored), nil)
+
+	c := newTestClient(t, &SMTPClient.MockEmailSender{}, mockRedisClient, &postgresClient.MockPostgresService{})
+
+	created, err := c.Send(context.Background(), &Message{To: "test@example.com", Subject: "Subject", Message: "Message", IdempotencyKey: "key-1"})
+
+	require.NoError(t, err)
+	assert.Equal(t, &Created{ID: 7, Status: StatusSent, Replayed: true}, created)
+}
+
+func TestClientGet(t *testing.T) {
+	createdAt := time.Date(2025, 5, 23, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		notifications    []*api.Notification
+		postgresError    error
+		wantNotification *Notification
+		wantCode         string
+	}{
+		{
+			name: "found",
+			notifications: []*api.Notification{{
+				ID:        7,
+				Type:      api.KeyForInstantSending,
+				Status:    api.StatusSent,
+				To:        "to",
+				Subject:   "subject",
+				Message:   "message",
+				Client:    "billing",
+				CreatedAt: createdAt,
+			}},
+			wantNotification: &Notification{
+				ID:        7,
+				Type:      TypeInstant,
+				Status:    StatusSent,
+				To:        "to",
+				Subject:   "subject",
+				Message:   "message",
+				Client:    "billing",
+				CreatedAt: createdAt,
+			},
+		},
+		{
+			name:          "not found",
+			postgresError: fmt.Errorf("FetchById: %w", pgx.ErrNoRows),
+			wantCode:      CodeNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+			mockPostgresClient.On("FetchById", mock.Anything, 7).Return(tt.notifications, tt.postgresError)
+
+			c := newTestClient(t, &SMTPClient.MockEmailSender{}, &redisClient.MockRedisClient{}, mockPostgresClient)
+
+			notification, err := c.Get(context.Background(), 7)
+
+			if tt.wantCode != "" {
+				var apiErr *Error
+				require.ErrorAs(t, err, &apiErr)
+				assert.Equal(t, tt.wantCode, apiErr.Code)
+				assert.Nil(t, notification)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantNotification, notification)
+			}
+
+			mockPostgresClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestClientList(t *testing.T) {
+	mockPostgresClient := &postgresClient.MockPostgresService{}
+	mockPostgresClient.On("FetchPage", mock.Anything, &api.ListFilter{Status: api.StatusCanceled, Limit: 1, Desc: true}).
+		Return([]*api.Notification{{ID: 2, Type: api.KeyForDelayedSending, Status: api.StatusCanceled}}, 1, nil)
+
+	c := newTestClient(t, &SMTPClient.MockEmailSender{}, &redisClient.MockRedisClient{}, mockPostgresClient)
+
+	page, err := c.List(context.Background(), &ListOptions{Limit: 1, Desc: true, Status: StatusCanceled})
+
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, 2, page.Items[0].ID)
+	assert.Equal(t, StatusCanceled, page.Items[0].Status)
+	assert.NotEmpty(t, page.NextCursor)
+
+	mockPostgresClient.AssertExpectations(t)
+}
+
+func TestClientCancel(t *testing.T) {
+	mockPostgresClient := &postgresClient.MockPostgresService{}
+	mockPostgresClient.On("CancelEmail", mock.Anything, 2).Return(nil)
+	mockPostgresClient.On("FetchById", mock.Anything, 2).
+		Return([]*api.Notification{{ID: 2, Type: api.KeyForDelayedSending, Status: api.StatusCanceled}}, nil)
+
+	c := newTestClient(t, &SMTPClient.MockEmailSender{}, &redisClient.MockRedisClient{}, mockPostgresClient)
+
+	notification, err := c.Cancel(context.Background(), 2)
+
+	require.NoError(t, err)
+	assert.Equal(t, StatusCanceled, notification.Status)
+
+	mockPostgresClient.AssertExpectations(t)
+}
+
+func TestClientUnauthorized(t *testing.T) {
+	mockPostgresClient := &postgresClient.MockPostgresService{}
+	mockPostgresClient.On("FetchAPIKeyByHash", mock.Anything, auth.HashKey("ntf_unknown")).Return(nil, pgx.ErrNoRows).Once()
+
+	c := newTestClient(t, &SMTPClient.MockEmailSender{}, &redisClient.MockRedisClient{}, mockPostgresClient)
+	c.apiKey = "ntf_unknown"
+
+	notification, err := c.Get(context.Background(), 7)
+
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	assert.Equal(t, CodeUnauthorized, apiErr.Code)
+	assert.Nil(t, notification)
+
+	mockPostgresClient.AssertExpectations(t)
+}
+
+func TestClientRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		maxRetries   int
+		wantAttempts int
+		wantStatus   int
+	}{
+		{
+			name:         "succeeds after gateway errors",
+			statuses:     []int{http.StatusBadGateway, http.StatusGatewayTimeout, http.StatusOK},
+			wantAttempts: 3,
+		},
+		{
+			name:         "retries exhausted",
+			statuses:     []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests},
+			maxRetries:   2,
+			wantAttempts: 3,
+			wantStatus:   http.StatusTooManyRequests,
+		},
+		{
+			name:         "retries disabled",
+			statuses:     []int{http.StatusServiceUnavailable},
+			maxRetries:   -1,
+			wantAttempts: 1,
+			wantStatus:   http.StatusServiceUnavailable,
+		},
+		{
+			name:         "not retryable",
+			statuses:     []int{http.StatusInternalServerError},
+			wantAttempts: 1,
+			wantStatus:   http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[attempts.Add(1)-1]
+				if status != http.StatusOK {
+					http.Error(w, http.StatusText(status), status)
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"items":[]}`))
+			}))
+			defer server.Close()
+
+			c, err := New(Config{BaseURL: server.URL, MaxRetries: tt.maxRetries, RetryPause: time.Millisecond})
+			require.NoError(t, err)
+
+			_, err = c.List(context.Background(), nil)
+
+			assert.Equal(t, tt.wantAttempts, int(attempts.Load()))
+
+			if tt.wantStatus == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			var apiErr *Error
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, tt.wantStatus, apiErr.StatusCode)
+			assert.Equal(t, http.StatusText(tt.wantStatus), apiErr.Title)
+			assert.Empty(t, apiErr.Code)
+		})
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "network error", err: errors.New("connection reset by peer"), want: true},
+		{name: "unavailable", err: &Error{StatusCode: http.StatusServiceUnavailable}, want: true},
+		{name: "wrapped unavailable", err: fmt.Errorf("send: %w", &Error{StatusCode: http.StatusServiceUnavailable}), want: true},
+		{name: "wrapped bad request", err: fmt.Errorf("send: %w", &Error{StatusCode: http.StatusBadRequest}), want: false},
+		{name: "request in progress", err: &Error{StatusCode: http.StatusConflict, Code: CodeIdempotencyInProgress}, want: true},
+		{name: "suppressed", err: &Error{StatusCode: http.StatusConflict, Code: CodeRecipientSuppressed}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, shouldRetry(tt.err))
+		})
+	}
+}
+
+func TestClientCanceledContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c, err := New(Config{BaseURL: server.URL, RetryPause: time.Hour})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = c.Get(ctx, 1)
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
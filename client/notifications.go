@@ -0,0 +1,132 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Send creates the notification that is sent immediately.
+// It returns *Error with CodeRecipientSuppressed or CodeRecipientRejected if the notification was not sent.
+func (c *Client) Send(ctx context.Context, msg *Message) (*Created, error) {
+	return c.create(ctx, msg, nil)
+}
+
+// Schedule creates the notification that is sent at the specified time.
+func (c *Client) Schedule(ctx context.Context, msg *Message, sendAt time.Time) (*Created, error) {
+	return c.create(ctx, msg, &sendAt)
+}
+
+// create creates the notification with the idempotency key of the message, or a random key if it is empty.
+func (c *Client) create(ctx context.Context, msg *Message, sendAt *time.Time) (*Created, error) {
+	key := msg.IdempotencyKey
+	if key == "" {
+		key = newIdempotencyKey()
+	}
+
+	created := &Created{}
+
+	header, err := c.do(ctx, call{
+		method:         http.MethodPost,
+		path:           "/v2/notifications",
+		body:           createRequest{Message: msg, SendAt: sendAt},
+		idempotencyKey: key,
+		wantStatus:     http.StatusCreated,
+		out:            created,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	created.Replayed = header.Get(idempotentReplayedHeader) == "true"
+
+	return created, nil
+}
+
+// Get returns the notification with the ID, or *Error with CodeNotFound.
+func (c *Client) Get(ctx context.Context, id int) (*Notification, error) {
+	notification := &Notification{}
+
+	_, err := c.do(ctx, call{
+		method:     http.MethodGet,
+		path:       "/v2/notifications/" + strconv.Itoa(id),
+		wantStatus: http.StatusOK,
+		out:        notification,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+// List returns a single page of notifications selected with the options, opts can be nil.
+func (c *Client) List(ctx context.Context, opts *ListOptions) (*Page, error) {
+	page := &Page{}
+
+	_, err := c.do(ctx, call{
+		method:     http.MethodGet,
+		path:       "/v2/notifications",
+		query:      opts.query(),
+		wantStatus: http.StatusOK,
+		out:        page,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// Cancel cancels the pending scheduled notification and returns it with the canceled status.
+// It returns *Error with CodeNotCancelable if the notification is sent immediately or is not pending anymore.
+func (c *Client) Cancel(ctx context.Context, id int) (*Notification, error) {
+	notification := &Notification{}
+
+	_, err := c.do(ctx, call{
+		method:     http.MethodDelete,
+		path:       "/v2/notifications/" + strconv.Itoa(id),
+		wantStatus: http.StatusOK,
+		out:        notification,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+// query returns the query parameters of the options.
+func (o *ListOptions) query() url.Values {
+	q := url.Values{}
+
+	if o == nil {
+		return q
+	}
+
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+
+	if o.Desc {
+		q.Set("order", "desc")
+	}
+
+	for name, value := range map[string]string{"cursor": o.Cursor, "type": o.Type, "status": o.Status, "subject": o.Subject} {
+		if value != "" {
+			q.Set(name, value)
+		}
+	}
+
+	if o.SentAfter != nil {
+		q.Set("sent_after", o.SentAfter.Format(time.RFC3339))
+	}
+
+	if o.SentBefore != nil {
+		q.Set("sent_before", o.SentBefore.Format(time.RFC3339))
+	}
+
+	return q
+}
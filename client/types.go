@@ -0,0 +1,236 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"notification/internal/backoff"
+)
+
+const (
+	// DefaultMaxRetries is the number of retries if Config.MaxRetries is not set.
+	DefaultMaxRetries = 3
+
+	// DefaultRetryPause is the base pause between retries if Config.RetryPause is not set.
+	DefaultRetryPause = 200 * time.Millisecond
+
+	// DefaultMaxRetryPause is the longest pause between retries if Config.MaxRetryPause is not set.
+	DefaultMaxRetryPause = 5 * time.Second
+)
+
+const (
+	// TypeInstant is the type of notifications sent immediately.
+	TypeInstant = "instantSending"
+
+	// TypeScheduled is the type of notifications sent at the specified time.
+	TypeScheduled = "delayedSending"
+)
+
+const (
+	// StatusPending is the status of a notification that is saved but not sent yet.
+	StatusPending = "pending"
+
+	// StatusSending is the status of a scheduled notification the worker has taken to send, it cannot be canceled anymore.
+	StatusSending = "sending"
+
+	// StatusSent is the status of a notification accepted by the SMTP server.
+	StatusSent = "sent"
+
+	// StatusFailed is the status of a notification that could not be sent.
+	StatusFailed = "failed"
+
+	// StatusSkipped is the status of a notification that was not sent, because the recipient has unsubscribed.
+	StatusSkipped = "skipped"
+
+	// StatusSuppressed is the status of a notification that was not sent, because the recipient is on the suppression list.
+	StatusSuppressed = "suppressed"
+
+	// StatusBounced is the status of a notification the remote mail server returned with a delivery status notification.
+	StatusBounced = "bounced"
+
+	// StatusCanceled is the status of a scheduled notification that was canceled before it was sent.
+	StatusCanceled = "canceled"
+)
+
+// Codes are the stable identifiers of the errors returned by the service, see Error.
+const (
+	CodeUnsupportedMediaType  = "unsupported_media_type"
+	CodeEmptyBody             = "empty_body"
+	CodeMalformedJSON         = "malformed_json"
+	CodeInvalidType           = "invalid_type"
+	CodeMissingFields         = "missing_fields"
+	CodeInvalidField          = "invalid_field"
+	CodeTooLong               = "too_long"
+	CodeValidationFailed      = "validation_failed"
+	CodeSenderNotAllowed      = "sender_not_allowed"
+	CodeTimeNotInFuture       = "time_not_in_future"
+	CodeInvalidQuery          = "invalid_query"
+	CodeBodyTooLarge          = "body_too_large"
+	CodeUnauthorized          = "unauthorized"
+	CodeForbidden             = "forbidden"
+	CodeNotFound              = "not_found"
+	CodeNotCancelable         = "not_cancelable"
+	CodeRecipientSuppressed   = "recipient_suppressed"
+	CodeRecipientRejected     = "recipient_rejected"
+	CodeSMTPUnavailable       = "smtp_unavailable"
+	CodeInvalidIdempotencyKey = "invalid_idempotency_key"
+	CodeIdempotencyInProgress = "idempotency_in_progress"
+	CodeRequestCanceled       = "request_canceled"
+	CodeTimeout               = "timeout"
+	CodeInternal              = "internal_error"
+)
+
+// ErrInvalidConfig indicates that the base URL of the service is not an absolute http(s) URL.
+var ErrInvalidConfig = errors.New("client: base URL must be an absolute http(s) URL")
+
+// Config defines the settings of the Client.
+// BaseURL is the address of the service, like https://notify.example.com.
+// APIKey is the API key of the client or the JWT issued by the identity provider, it is sent as the bearer token of every request.
+// HTTPClient is used to make the requests, http.DefaultClient if it is nil.
+// MaxRetries is the number of retries of the failed requests, negative disables retries.
+// RetryPause is the base pause between retries, it doubles with each retry up to MaxRetryPause and is randomized.
+// The default values are used for the zero fields.
+type Config struct {
+	BaseURL       string
+	APIKey        string
+	HTTPClient    *http.Client
+	MaxRetries    int
+	RetryPause    time.Duration
+	MaxRetryPause time.Duration
+}
+
+// Client is the client of the notifications API (v2) of the service.
+// The requests are retried after network errors, 429, 502, 503 and 504 responses, honoring Retry-After.
+// Notifications are created with an idempotency key, so the retries never send the same notification twice.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	maxRetries int
+	backoff    backoff.Policy
+}
+
+// Message is the email of the notification.
+// IdempotencyKey identifies the notification across the retries and the repeated calls,
+// a random key is generated for each call if it is empty.
+type Message struct {
+	To       string            `json:"to"`
+	Subject  string            `json:"subject"`
+	Message  string            `json:"message"`
+	From     string            `json:"from,omitempty"`
+	FromName string            `json:"from_name,omitempty"`
+	ReplyTo  string            `json:"reply_to,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+
+	InReplyTo  string   `json:"in_reply_to,omitempty"`
+	References []string `json:"references,omitempty"`
+
+	Category string `json:"category,omitempty"`
+
+	HTML  string `json:"html,omitempty"`
+	Track bool   `json:"track,omitempty"`
+
+	CallbackURL string `json:"callback_url,omitempty"`
+
+	IdempotencyKey string `json:"-"`
+}
+
+// createRequest is the request body of the created notification.
+type createRequest struct {
+	*Message
+	SendAt *time.Time `json:"send_at,omitempty"`
+}
+
+// Created is the notification created by Client.Send or Client.Schedule.
+// Replayed reports whether the notification was created by an earlier request with the same idempotency key.
+type Created struct {
+	ID       int    `json:"id"`
+	Status   string `json:"status"`
+	Replayed bool   `json:"-"`
+}
+
+// Notification is the saved notification with its status, the client that created it and its tenant,
+// the time it was saved and the time it was sent.
+type Notification struct {
+	ID       int               `json:"id"`
+	Type     string            `json:"type"`
+	Status   string            `json:"status"`
+	Time     *time.Time        `json:"time,omitempty"`
+	To       string            `json:"to"`
+	Subject  string            `json:"subject"`
+	Message  string            `json:"message"`
+	From     string            `json:"from,omitempty"`
+	FromName string            `json:"from_name,omitempty"`
+	ReplyTo  string            `json:"reply_to,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+
+	MessageID  string   `json:"message_id,omitempty"`
+	InReplyTo  string   `json:"in_reply_to,omitempty"`
+	References []string `json:"references,omitempty"`
+
+	Category string `json:"category,omitempty"`
+
+	HTML   string          `json:"html,omitempty"`
+	Track  bool            `json:"track,omitempty"`
+	Events []TrackingEvent `json:"events,omitempty"`
+
+	CallbackURL string `json:"callback_url,omitempty"`
+
+	Client string `json:"client,omitempty"`
+	Tenant string `json:"tenant,omitempty"`
+
+	CreatedAt time.Time  `json:"created_at"`
+	SentAt    *time.Time `json:"sent_at,omitempty"`
+}
+
+// TrackingEvent is an open or click of the tracked email, URL is the target of the followed link.
+type TrackingEvent struct {
+	Type      string    `json:"type"`
+	URL       string    `json:"url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListOptions selects a single page of notifications, the zero fields do not restrict the notifications.
+// Cursor is Page.NextCursor of the previous page, Desc lists the newest notifications first,
+// Subject matches a substring of the subject case-insensitively.
+type ListOptions struct {
+	Limit      int
+	Cursor     string
+	Desc       bool
+	Type       string
+	Status     string
+	Subject    string
+	SentAfter  *time.Time
+	SentBefore *time.Time
+}
+
+// Page is a single page of notifications, NextCursor is empty on the last page.
+type Page struct {
+	Items      []*Notification `json:"items"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// Error is the error response of the service in the application/problem+json format, see RFC 7807.
+// Code is the stable identifier of the error, Fields lists the offending fields of the request,
+// Errors lists every validation error of the request body. RetryAfter is the pause requested by the service.
+// Responses that are not problem+json, like the errors of proxies, have only StatusCode, Title and Detail.
+type Error struct {
+	StatusCode int           `json:"status"`
+	Type       string        `json:"type"`
+	Title      string        `json:"title"`
+	Detail     string        `json:"detail"`
+	Instance   string        `json:"instance"`
+	Code       string        `json:"code"`
+	Fields     []string      `json:"fields"`
+	RequestID  string        `json:"request_id"`
+	Errors     []FieldError  `json:"errors"`
+	RetryAfter time.Duration `json:"-"`
+}
+
+// FieldError is a single validation error of the request body.
+type FieldError struct {
+	Code   string   `json:"code"`
+	Detail string   `json:"detail"`
+	Fields []string `json:"fields"`
+}
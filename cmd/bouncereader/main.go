@@ -0,0 +1,117 @@
+// Command bouncereader reads bounce messages from a maildir or an mbox file
+// and posts each of them to the bounce endpoint of the notification service.
+// It is intended for local testing, when bounces are delivered to a local mailbox.
+//
+// Usage:
+//
+//	bouncereader -maildir ~/Maildir [-url http://localhost:8080/bounces] [-api-key key]
+//	bouncereader -mbox /var/mail/noreply [-url http://localhost:8080/bounces] [-api-key key]
+//
+// The bounce endpoint requires the API key with the admin scope, unless the authentication is disabled.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"notification/internal/auth"
+	"notification/internal/bounce"
+)
+
+const (
+	defaultURL     = "http://localhost:8080/bounces"
+	requestTimeout = 10 * time.Second
+)
+
+func main() {
+	maildir := flag.String("maildir", "", "path to the maildir with bounce messages")
+	mbox := flag.String("mbox", "", "path to the mbox file with bounce messages")
+	url := flag.String("url", defaultURL, "URL of the bounce endpoint")
+	apiKey := flag.String("api-key", "", "API key with the admin scope")
+	flag.Parse()
+
+	if (*maildir == "") == (*mbox == "") {
+		log.Fatal("exactly one of -maildir and -mbox must be specified")
+	}
+
+	messages, err := readMessages(*maildir, *mbox)
+	if err != nil {
+		log.Fatalf("cannot read messages: %v", err)
+	}
+
+	client := &http.Client{Timeout: requestTimeout}
+
+	failed := 0
+
+	for i, msg := range messages {
+		status, body, err := post(client, *url, *apiKey, msg)
+		if err != nil {
+			failed++
+			log.Printf("message %d: %v", i+1, err)
+
+			continue
+		}
+
+		if status != http.StatusOK {
+			failed++
+		}
+
+		fmt.Printf("message %d: %d %s\n", i+1, status, strings.TrimSpace(body))
+	}
+
+	fmt.Printf("processed %d messages, %d failed\n", len(messages), failed)
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// readMessages reads the raw messages from the maildir or the mbox file.
+func readMessages(maildir, mbox string) ([][]byte, error) {
+	if maildir != "" {
+		return bounce.ReadMaildir(maildir)
+	}
+
+	f, err := os.Open(mbox)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return bounce.ReadMbox(f)
+}
+
+// post sends the raw message to the bounce endpoint and returns the response status code and body.
+// The API key is sent in the X-API-Key header if it is set.
+func post(client *http.Client, url, apiKey string, msg []byte) (int, string, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(msg))
+	if err != nil {
+		return 0, "", err
+	}
+
+	req.Header.Set("Content-Type", "message/rfc822")
+
+	if apiKey != "" {
+		req.Header.Set(auth.HeaderAPIKey, apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return resp.StatusCode, string(body), nil
+}
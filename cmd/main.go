@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -15,14 +16,22 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 
 	"notification/internal/SMTPClient"
+	"notification/internal/api/grpcServer"
 	"notification/internal/api/handlers"
+	"notification/internal/api/service"
+	"notification/internal/auth"
 	cconfig "notification/internal/config"
+	"notification/internal/events"
 	llogger "notification/internal/logger"
 	"notification/internal/monitoring"
 	ppostgresClient "notification/internal/storage/postgresClient"
 	rredisClient "notification/internal/storage/redisClient"
+	"notification/internal/tracking"
+	"notification/internal/unsubscribe"
+	"notification/internal/webhook"
 	wworker "notification/internal/worker"
 )
 
@@ -64,9 +73,37 @@ func main() {
 		log.Fatalf("cannot initialize postgres client: %v", err)
 	}
 
-	smtpClient := SMTPClient.New(&config.SMTP, appMetrics.SMTPMetrics, logger)
+	tracker, err := tracking.New(&config.SMTP.Tracking)
+	if err != nil {
+		logger.Fatal("cannot initialize tracker", zap.Error(err))
+	}
+
+	unsubscribeTokens, err := unsubscribe.New(&config.SMTP.Unsubscribe)
+	if err != nil {
+		logger.Fatal("cannot initialize unsubscribe tokens", zap.Error(err))
+	}
 
-	worker := wworker.New(redisClient, smtpClient, tickTimeForWorker, appMetrics.WorkerMetrics, logger)
+	smtpClient, err := SMTPClient.New(&config.SMTP, unsubscribeTokens, tracker, appMetrics.SMTPMetrics, logger)
+	if err != nil {
+		logger.Fatal("cannot initialize smtp client", zap.Error(err))
+	}
+
+	dispatcher, err := webhook.New(&config.Webhook, postgresClient, appMetrics.WebhookMetrics, logger)
+	if err != nil {
+		logger.Fatal("cannot initialize webhook dispatcher", zap.Error(err))
+	}
+
+	// The disabled dispatcher is passed as the nil interface, so that the components see that webhooks are disabled.
+	var notifier webhook.Notifier
+	if dispatcher != nil {
+		notifier = dispatcher
+	}
+
+	bus := events.New(&config.Events, redisClient, appMetrics.EventsMetrics, logger)
+
+	go bus.Run(ctx)
+
+	worker := wworker.New(redisClient, postgresClient, smtpClient, notifier, bus, &config.Worker, config.AppTimeouts.SMTPBackoff, tickTimeForWorker, appMetrics.WorkerMetrics, logger)
 
 	go func() {
 		err = worker.Run(ctx)
@@ -92,19 +129,29 @@ func main() {
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.URLFormat)
 
-	notificationHandler := handlers.New(logger, smtpClient, redisClient, postgresClient, config.AppTimeouts, config.HttpServer.TimeoutExtra)
+	notificationHandler := handlers.New(logger, smtpClient, redisClient, postgresClient, &config.Decoder, unsubscribeTokens,
+		tracker, notifier, bus, config.AppTimeouts, config.HttpServer.TimeoutExtra)
 
-	router.Post("/send-notification", notificationHandler.NewSendNotificationHandler(appMetrics.SendNotificationMetrics))
+	if config.Auth.AdminKey == "" {
+		logger.Warn("admin API key is not set, only the stored API keys are accepted")
+	}
+
+	if config.Auth.JWKSURL != "" {
+		logger.Info("JWT bearer tokens are accepted", zap.String("jwks_url", config.Auth.JWKSURL),
+			zap.String("issuer", config.Auth.JWTIssuer), zap.String("audience", config.Auth.JWTAudience))
+	}
 
-	router.Post("/send-notification-via-time", notificationHandler.NewSendNotificationViaTimeHandler(appMetrics.SendNotificationViaTimeMetrics))
+	authenticator := auth.New(&config.Auth, postgresClient, appMetrics.AuthMetrics, logger)
 
-	router.Get("/list", notificationHandler.NewListNotificationHandler(appMetrics.ListNotificationMetrics))
+	notificationHandler.Routes(router, authenticator, appMetrics)
 
 	srv := http.Server{
 		Addr:    fmt.Sprintf("%s:%s", config.HttpServer.Host, config.HttpServer.Port),
 		Handler: router,
 	}
 
+	srv.RegisterOnShutdown(bus.Close)
+
 	go func() {
 		logger.Info("starting http server", zap.String("addr", srv.Addr))
 		if err = srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -112,24 +159,62 @@ func main() {
 		}
 	}()
 
+	notificationService := service.New(smtpClient, redisClient, postgresClient, notifier, bus, logger)
+
+	grpcSrv := grpcServer.New(notificationService, authenticator, &config.Decoder, &config.GRPCServer, config.AppTimeouts,
+		config.HttpServer.TimeoutExtra, appMetrics.GRPCMetrics, logger)
+
+	grpcAddr := fmt.Sprintf("%s:%s", config.GRPCServer.Host, config.GRPCServer.Port)
+
+	listener, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		logger.Fatal("cannot listen for grpc server", zap.Error(err))
+	}
+
+	go func() {
+		logger.Info("starting grpc server", zap.String("addr", grpcAddr))
+		if err := grpcSrv.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			logger.Fatal("cannot start grpc server", zap.Error(err))
+		}
+	}()
+
 	<-ctx.Done()
 
-	gracefulShutdown(logger, &srv, postgresClient, redisClient)
+	gracefulShutdown(logger, &srv, grpcSrv, dispatcher, postgresClient, redisClient)
 }
 
-func gracefulShutdown(logger *zap.Logger, srv *http.Server,
+func gracefulShutdown(logger *zap.Logger, srv *http.Server, grpcSrv *grpc.Server, dispatcher *webhook.Dispatcher,
 	postgresClient ppostgresClient.PostgresClient, redisClient rredisClient.RedisClient) {
 	logger.Info("received shutdown signal")
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTime)
 	defer shutdownCancel()
 
+	logger.Info("shutting down grpc server")
+	stopped := make(chan struct{})
+
+	go func() {
+		grpcSrv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-shutdownCtx.Done():
+		grpcSrv.Stop()
+	}
+
 	logger.Info("shutting down http server")
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		logger.Error("cannot shutdown http server", zap.Error(err))
 		return
 	}
 
+	logger.Info("waiting for webhook deliveries")
+	if err := dispatcher.Close(shutdownCtx); err != nil {
+		logger.Error("cannot finish webhook deliveries", zap.Error(err))
+	}
+
 	postgresClient.Close()
 
 	err := redisClient.Close()
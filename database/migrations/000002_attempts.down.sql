@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS schema_emails.attempts;
+
+ALTER TABLE schema_emails.emails
+    DROP CONSTRAINT IF EXISTS emails_status_check,
+    DROP COLUMN IF EXISTS sent_at,
+    DROP COLUMN IF EXISTS status;
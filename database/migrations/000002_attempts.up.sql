@@ -0,0 +1,22 @@
+ALTER TABLE schema_emails.emails
+    ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'pending',
+    ADD COLUMN IF NOT EXISTS sent_at TIMESTAMP;
+
+ALTER TABLE schema_emails.emails
+    ADD CONSTRAINT emails_status_check CHECK (status IN ('pending', 'sent', 'failed'));
+
+
+CREATE TABLE IF NOT EXISTS schema_emails.attempts
+(
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    email_id BIGINT NOT NULL REFERENCES schema_emails.emails (id) ON DELETE CASCADE,
+    attempt INT NOT NULL,
+    provider TEXT NOT NULL,
+    class TEXT NOT NULL CHECK (class IN ('success', 'transient', 'permanent')),
+    code INT,
+    error TEXT,
+    started_at TIMESTAMP NOT NULL,
+    duration_ms BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_attempts_email_id ON schema_emails.attempts (email_id);
@@ -0,0 +1,5 @@
+ALTER TABLE schema_emails.emails
+    DROP COLUMN IF EXISTS headers,
+    DROP COLUMN IF EXISTS reply_to,
+    DROP COLUMN IF EXISTS from_name,
+    DROP COLUMN IF EXISTS from_address;
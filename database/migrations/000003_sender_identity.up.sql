@@ -0,0 +1,5 @@
+ALTER TABLE schema_emails.emails
+    ADD COLUMN IF NOT EXISTS from_address TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS from_name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS reply_to TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS headers JSONB;
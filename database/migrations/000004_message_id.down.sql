@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS schema_emails.idx_emails_message_id;
+
+ALTER TABLE schema_emails.emails
+    DROP COLUMN IF EXISTS message_references,
+    DROP COLUMN IF EXISTS in_reply_to,
+    DROP COLUMN IF EXISTS message_id;
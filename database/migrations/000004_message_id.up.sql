@@ -0,0 +1,6 @@
+ALTER TABLE schema_emails.emails
+    ADD COLUMN IF NOT EXISTS message_id TEXT,
+    ADD COLUMN IF NOT EXISTS in_reply_to TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS message_references TEXT[];
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_emails_message_id ON schema_emails.emails (message_id);
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS schema_emails.unsubscribes;
+
+UPDATE schema_emails.emails SET status = 'failed' WHERE status = 'skipped';
+
+ALTER TABLE schema_emails.emails
+    DROP CONSTRAINT IF EXISTS emails_status_check;
+
+ALTER TABLE schema_emails.emails
+    ADD CONSTRAINT emails_status_check CHECK (status IN ('pending', 'sent', 'failed'));
+
+ALTER TABLE schema_emails.emails
+    DROP COLUMN IF EXISTS category;
@@ -0,0 +1,18 @@
+ALTER TABLE schema_emails.emails
+    ADD COLUMN IF NOT EXISTS category TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE schema_emails.emails
+    DROP CONSTRAINT IF EXISTS emails_status_check;
+
+ALTER TABLE schema_emails.emails
+    ADD CONSTRAINT emails_status_check CHECK (status IN ('pending', 'sent', 'failed', 'skipped'));
+
+
+CREATE TABLE IF NOT EXISTS schema_emails.unsubscribes
+(
+    recipient TEXT NOT NULL,
+    category TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+
+    PRIMARY KEY (recipient, category)
+);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS schema_emails.suppressions;
+
+UPDATE schema_emails.emails SET status = 'failed' WHERE status = 'suppressed';
+
+ALTER TABLE schema_emails.emails
+    DROP CONSTRAINT IF EXISTS emails_status_check;
+
+ALTER TABLE schema_emails.emails
+    ADD CONSTRAINT emails_status_check CHECK (status IN ('pending', 'sent', 'failed', 'skipped'));
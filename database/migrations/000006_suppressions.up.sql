@@ -0,0 +1,15 @@
+ALTER TABLE schema_emails.emails
+    DROP CONSTRAINT IF EXISTS emails_status_check;
+
+ALTER TABLE schema_emails.emails
+    ADD CONSTRAINT emails_status_check CHECK (status IN ('pending', 'sent', 'failed', 'skipped', 'suppressed'));
+
+
+CREATE TABLE IF NOT EXISTS schema_emails.suppressions
+(
+    address TEXT PRIMARY KEY,
+    reason TEXT NOT NULL CHECK (reason IN ('hard_bounce', 'complaint', 'manual')),
+    source TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    expires_at TIMESTAMP
+);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS schema_emails.tracking_events;
+
+ALTER TABLE schema_emails.emails
+    DROP COLUMN IF EXISTS track,
+    DROP COLUMN IF EXISTS html;
@@ -0,0 +1,15 @@
+ALTER TABLE schema_emails.emails
+    ADD COLUMN IF NOT EXISTS html TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS track BOOLEAN NOT NULL DEFAULT false;
+
+
+CREATE TABLE IF NOT EXISTS schema_emails.tracking_events
+(
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    email_id BIGINT NOT NULL REFERENCES schema_emails.emails (id) ON DELETE CASCADE,
+    type TEXT NOT NULL CHECK (type IN ('open', 'click')),
+    url TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc')
+);
+
+CREATE INDEX IF NOT EXISTS idx_tracking_events_email_id ON schema_emails.tracking_events (email_id);
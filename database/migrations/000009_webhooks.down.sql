@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS schema_emails.webhook_attempts;
+
+ALTER TABLE schema_emails.emails
+    DROP COLUMN IF EXISTS callback_url;
@@ -0,0 +1,18 @@
+ALTER TABLE schema_emails.emails
+    ADD COLUMN IF NOT EXISTS callback_url TEXT NOT NULL DEFAULT '';
+
+
+CREATE TABLE IF NOT EXISTS schema_emails.webhook_attempts
+(
+    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    email_id BIGINT NOT NULL REFERENCES schema_emails.emails (id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    status TEXT NOT NULL,
+    attempt INT NOT NULL,
+    code INT,
+    error TEXT,
+    started_at TIMESTAMP NOT NULL,
+    duration_ms BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_attempts_email_id ON schema_emails.webhook_attempts (email_id);
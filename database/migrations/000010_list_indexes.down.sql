@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS schema_emails.idx_emails_subject_trgm;
+DROP INDEX IF EXISTS schema_emails.idx_emails_sent_at_id;
+DROP INDEX IF EXISTS schema_emails.idx_emails_type_id;
+DROP INDEX IF EXISTS schema_emails.idx_emails_status_id;
@@ -0,0 +1,6 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_emails_status_id ON schema_emails.emails (status, id);
+CREATE INDEX IF NOT EXISTS idx_emails_type_id ON schema_emails.emails (type, id);
+CREATE INDEX IF NOT EXISTS idx_emails_sent_at_id ON schema_emails.emails (sent_at, id);
+CREATE INDEX IF NOT EXISTS idx_emails_subject_trgm ON schema_emails.emails USING gin (subject gin_trgm_ops);
@@ -0,0 +1,2 @@
+ALTER TABLE schema_emails.emails
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc');
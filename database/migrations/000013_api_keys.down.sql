@@ -0,0 +1,5 @@
+ALTER TABLE schema_emails.emails
+    DROP COLUMN IF EXISTS api_key_id,
+    DROP COLUMN IF EXISTS client;
+
+DROP TABLE IF EXISTS schema_emails.api_keys;
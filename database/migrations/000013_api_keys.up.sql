@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS schema_emails.api_keys
+(
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL CHECK (cardinality(scopes) > 0 AND scopes <@ ARRAY['send', 'schedule', 'read', 'admin']),
+    created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+    expires_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+ALTER TABLE schema_emails.emails
+    ADD COLUMN IF NOT EXISTS client TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS api_key_id INTEGER REFERENCES schema_emails.api_keys (id);
@@ -0,0 +1,2 @@
+ALTER TABLE schema_emails.emails
+    DROP COLUMN IF EXISTS tenant;
@@ -0,0 +1,2 @@
+ALTER TABLE schema_emails.emails
+    ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,7 @@
+UPDATE schema_emails.emails SET status = 'pending' WHERE status = 'sending';
+
+ALTER TABLE schema_emails.emails
+    DROP CONSTRAINT IF EXISTS emails_status_check;
+
+ALTER TABLE schema_emails.emails
+    ADD CONSTRAINT emails_status_check CHECK (status IN ('pending', 'sent', 'failed', 'skipped', 'suppressed', 'bounced', 'canceled'));
@@ -0,0 +1,5 @@
+ALTER TABLE schema_emails.emails
+    DROP CONSTRAINT IF EXISTS emails_status_check;
+
+ALTER TABLE schema_emails.emails
+    ADD CONSTRAINT emails_status_check CHECK (status IN ('pending', 'sending', 'sent', 'failed', 'skipped', 'suppressed', 'bounced', 'canceled'));
@@ -1,22 +1,30 @@
 package SMTPClient
 
 import (
+	"bytes"
 	"context"
-	"crypto/tls"
+	"errors"
 	"fmt"
-	"math"
 	"net/mail"
+	"os"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 	"gopkg.in/gomail.v2"
 
+	"notification/internal/backoff"
 	"notification/internal/monitoring"
+	"notification/internal/tracking"
+	"notification/internal/unsubscribe"
 )
 
 // New creates and returns a new SMTPClient instance.
-// If MaxRetries and BasicRetryPause are not set in the configuration, the default values are applied.
-func New(config *Config, metrics monitoring.Monitoring, logger *zap.Logger) *SMTPClient {
+// If retry, backoff, provider health and circuit breaker parameters are not set in the configuration, the default values are applied.
+// The unsubscribe tokens sign the List-Unsubscribe links of non-transactional emails, they are nil if unsubscribe links are disabled.
+// The tracker instruments the HTML bodies of the emails which request tracking, it is nil if tracking is disabled.
+// Returns an error if the TLS certificates or the DKIM private key cannot be loaded, or OAuth2 is misconfigured.
+func New(config *Config, unsubscribeTokens *unsubscribe.Tokens, tracker *tracking.Tracker, metrics monitoring.Monitoring, logger *zap.Logger) (*SMTPClient, error) {
 	if config.MaxRetries == 0 {
 		config.MaxRetries = DefaultMaxRetries
 	}
@@ -25,59 +33,177 @@ func New(config *Config, metrics monitoring.Monitoring, logger *zap.Logger) *SMT
 		config.BasicRetryPause = DefaultBasicRetryPause
 	}
 
-	return &SMTPClient{
-		config:  config,
-		metrics: metrics,
-		logger:  logger,
+	if config.ProviderTimeout == 0 {
+		config.ProviderTimeout = DefaultProviderTimeout
 	}
+
+	if config.ProviderMaxFailures == 0 {
+		config.ProviderMaxFailures = DefaultProviderMaxFailures
+	}
+
+	if config.ProviderCooldown == 0 {
+		config.ProviderCooldown = DefaultProviderCooldown
+	}
+
+	if config.BreakerFailureThreshold == 0 {
+		config.BreakerFailureThreshold = DefaultBreakerFailureThreshold
+	}
+
+	if config.BreakerOpenTimeout == 0 {
+		config.BreakerOpenTimeout = DefaultBreakerOpenTimeout
+	}
+
+	if config.BreakerHalfOpenMaxRequests == 0 {
+		config.BreakerHalfOpenMaxRequests = DefaultBreakerHalfOpenMaxRequests
+	}
+
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := newTokenSource(config)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := newDKIMSigner(config)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SMTPClient{
+		config:    config,
+		providers: newProviderPool(config, tlsConfig, tokens),
+		backoff:   backoff.New(config.BasicRetryPause, config.Backoff),
+		dkim:      signer,
+		tokens:    unsubscribeTokens,
+		tracker:   tracker,
+		metrics:   metrics,
+		logger:    logger,
+	}
+
+	s.breaker = newCircuitBreaker(config, s.onBreakerChange)
+
+	return s, nil
 }
 
 // SendEmail sends the provided email using a Simple Mail Transfer Protocol (SMTP).
-// If sending false, it reties using exponential backoff.
-func (s *SMTPClient) SendEmail(ctx context.Context, email EmailMessage) error {
+// The From header uses the sender identity of the email if it is set, the envelope sender is always
+// the configured sender address, so that bounces are returned to the service mailbox.
+// Emails with the HTML body are sent as multipart/alternative, the HTML part is instrumented with
+// the tracking pixel and tracking links if the email requests tracking.
+// If sending false with a transient error, it reties using jittered exponential backoff,
+// permanent errors (5xx replies) are returned immediately wrapped with ErrPermanentFailure.
+// The message is not sent again if the provider may have accepted it, the error then wraps ErrDeliveryUnknown.
+// It returns the history of all attempts made, together with the result.
+func (s *SMTPClient) SendEmail(ctx context.Context, email EmailMessage) ([]Attempt, error) {
 	if ctx.Err() != nil {
 		s.metrics.IncCanceled("SendEmail")
 		s.logger.Error(ErrContextCanceledBeforeSending.Error(), zap.Error(ctx.Err()))
 
-		return ErrContextCanceledBeforeSending
+		return nil, ErrContextCanceledBeforeSending
 	}
 
 	start := time.Now()
 
 	msg := gomail.NewMessage()
 
-	_, err := mail.ParseAddress(s.config.SenderEmail)
+	sender, err := mail.ParseAddress(s.config.SenderEmail)
 	if err != nil {
 		s.metrics.IncError("SendEmail")
 		s.logger.Error("SendEmail: no valid sender address", zap.Error(err))
 
-		return ErrNoValidSenderAddress
+		return nil, ErrNoValidSenderAddress
+	}
+
+	to, err := mail.ParseAddress(email.To)
+	if err != nil {
+		s.metrics.IncPermanent("SendEmail")
+		s.logger.Error("SendEmail: no valid recipient address", zap.Error(err))
+
+		return nil, ErrNoValidRecipientAddress
 	}
 
-	msg.SetHeader("From", s.config.SenderEmail)
+	from := sender.Address
+	if email.From != "" {
+		identity, err := mail.ParseAddress(email.From)
+		if err != nil {
+			s.metrics.IncPermanent("SendEmail")
+			s.logger.Error("SendEmail: no valid sender identity", zap.Error(err))
+
+			return nil, ErrNoValidSenderAddress
+		}
+
+		from = identity.Address
+	}
+
+	fromName := email.FromName
+	if fromName == "" && email.From == "" {
+		fromName = sender.Name
+	}
+
+	msg.SetAddressHeader("From", from, fromName)
 	msg.SetHeader("To", email.To)
 	msg.SetHeader("Subject", email.Subject)
+
+	if email.ReplyTo != "" {
+		msg.SetHeader("Reply-To", email.ReplyTo)
+	}
+
+	for name, value := range email.Headers {
+		msg.SetHeader(name, value)
+	}
+
+	if email.MessageID != "" {
+		msg.SetHeader("Message-ID", email.MessageID)
+	}
+
+	if email.InReplyTo != "" {
+		msg.SetHeader("In-Reply-To", email.InReplyTo)
+	}
+
+	if references := threadReferences(email); references != "" {
+		msg.SetHeader("References", references)
+	}
+
+	if listUnsubscribe := s.tokens.Header(to.Address, email.Category); listUnsubscribe != "" {
+		msg.SetHeader("List-Unsubscribe", listUnsubscribe)
+		msg.SetHeader("List-Unsubscribe-Post", unsubscribe.OneClick)
+	}
+
 	msg.SetBody("text/plain", email.Message)
 
-	dialer := gomail.NewDialer(
-		s.config.SMTPHost,
-		s.config.SMTPPort,
-		s.config.SenderEmail,
-		s.config.SenderPassword,
-	)
+	if email.HTML != "" {
+		body := email.HTML
+		if email.Track {
+			body = s.tracker.Instrument(email.Id, body)
+		}
+
+		msg.AddAlternative("text/html", body)
+	}
+
+	raw, err := s.render(msg)
+	if err != nil {
+		s.metrics.IncError("SendEmail")
+		s.logger.Error("SendEmail: cannot render message", zap.Error(err))
 
-	dialer.TLSConfig = &tls.Config{
-		ServerName:         s.config.SMTPHost,
-		InsecureSkipVerify: s.config.SkipVerify,
+		return nil, err
 	}
 
 	s.logger.Info(fmt.Sprintf("SendEmail: sending email to %s", email.To))
 
-	if err = s.sendWithRetry(ctx, dialer, msg); err != nil {
-		s.metrics.IncError("SendEmail")
+	attempts, err := s.sendWithRetry(ctx, sender.Address, to.Address, raw)
+	if err != nil {
+		if errors.Is(err, ErrPermanentFailure) {
+			s.metrics.IncPermanent("SendEmail")
+		} else {
+			s.metrics.IncTransient("SendEmail")
+		}
+
 		s.logger.Error(fmt.Sprintf("SendEmail: cannot send message to %s", email.To), zap.Error(err))
 
-		return fmt.Errorf("SendEmail: cannot send message to %s, %w", email.To, err)
+		return attempts, fmt.Errorf("SendEmail: cannot send message to %s, %w", email.To, err)
 	}
 
 	s.logger.Info(fmt.Sprintf("SendEmail: successfully sent message to %s", email.To))
@@ -85,23 +211,49 @@ func (s *SMTPClient) SendEmail(ctx context.Context, email EmailMessage) error {
 	s.metrics.Observe("SendEmail", start)
 	s.metrics.IncSuccess("SendEmail")
 
-	return nil
+	return attempts, nil
+}
+
+// threadReferences returns the References header of the email.
+// If only In-Reply-To is set, it is used as References, as recommended by RFC 5322 section 3.6.4.
+func threadReferences(email EmailMessage) string {
+	if len(email.References) > 0 {
+		return strings.Join(email.References, " ")
+	}
+
+	return email.InReplyTo
 }
 
-// sendWithRetry attempts to send the email using the provided dialer with exponential backoff retries.
-func (s *SMTPClient) sendWithRetry(ctx context.Context, dialer *gomail.Dialer, msg *gomail.Message) error {
+// sendWithRetry attempts to send the email through the configured providers with exponential backoff retries.
+// Retries stop on the first permanent failure, when the result of the delivery is unknown,
+// or when the next pause would exceed the max elapsed retry time.
+func (s *SMTPClient) sendWithRetry(ctx context.Context, from, to string, msg []byte) ([]Attempt, error) {
+	var attempts []Attempt
 	var lastErr error
+	var pause time.Duration
+
+	start := time.Now()
 
 	for i := 0; i < s.config.MaxRetries+1; i++ {
 		if ctx.Err() != nil {
 			s.metrics.IncCanceled("SendEmail")
 			s.logger.Error(ErrContextCanceledBeforeRetry.Error(), zap.Error(ctx.Err()))
 
-			return ErrContextCanceledBeforeRetry
+			return attempts, ErrContextCanceledBeforeRetry
+		}
+
+		if retryAfter, open := s.breaker.openFor(); open {
+			return attempts, s.circuitOpen(retryAfter, lastErr)
 		}
 
 		if i > 0 {
-			pause := s.CreatePause(i)
+			pause = s.CreatePause(i, pause)
+
+			if s.backoff.Exceeded(start, pause) {
+				s.logger.Error(ErrRetryTimeExceeded.Error(), zap.Duration("pause", pause), zap.Error(lastErr))
+				return attempts, fmt.Errorf("%w, last error: %w", ErrRetryTimeExceeded, lastErr)
+			}
+
 			s.logger.Info(
 				"sendWithRetry: retrying send message",
 				zap.Int("attempt", i),
@@ -115,28 +267,202 @@ func (s *SMTPClient) sendWithRetry(ctx context.Context, dialer *gomail.Dialer, m
 				s.metrics.IncCanceled("SendEmail")
 				s.logger.Error(ErrContextCanceledAfterPause.Error(), zap.Error(ctx.Err()))
 
-				return ErrContextCanceledAfterPause
+				return attempts, ErrContextCanceledAfterPause
 			}
 		}
 
-		err := dialer.DialAndSend(msg)
-		if err != nil {
-			lastErr = err
-			continue
+		generation, retryAfter, ok := s.breaker.allow()
+		if !ok {
+			return attempts, s.circuitOpen(retryAfter, lastErr)
 		}
 
-		return nil
+		roundAttempts, err := s.sendViaProviders(ctx, i+1, from, to, msg)
+		attempts = append(attempts, roundAttempts...)
+
+		switch {
+		case err == nil:
+			s.breaker.success(generation)
+			return attempts, nil
+
+		case errors.Is(err, ErrPermanentFailure):
+			s.breaker.success(generation)
+			s.logger.Error("sendWithRetry: permanent failure, message will not be retried", zap.Error(err))
+
+			return attempts, err
+
+		case errors.Is(err, ErrDeliveryUnknown):
+			if ctx.Err() != nil {
+				s.breaker.release(generation)
+			} else {
+				s.breaker.failure(generation)
+			}
+
+			s.logger.Error("sendWithRetry: delivery result unknown, message will not be retried", zap.Error(err))
+
+			return attempts, err
+
+		case ctx.Err() != nil:
+			s.breaker.release(generation)
+
+		default:
+			s.breaker.failure(generation)
+		}
+
+		lastErr = err
 	}
 
 	s.logger.Error("sendWithRetry: all attempts to send message failed, last error:", zap.Error(lastErr))
-	return fmt.Errorf("sendWithRetry: all attempts to send message failed, last error: %w", lastErr)
+	return attempts, fmt.Errorf("sendWithRetry: all attempts to send message failed, last error: %w", lastErr)
+}
+
+// sendViaProviders tries the healthy providers one by one, in the order chosen by the provider pool,
+// until one of them accepts the message or rejects it permanently. The next provider is not tried
+// if the result of the delivery is unknown, see ErrDeliveryUnknown.
+// It returns the attempts made and the error of the last tried provider.
+func (s *SMTPClient) sendViaProviders(ctx context.Context, number int, from, to string, msg []byte) ([]Attempt, error) {
+	var attempts []Attempt
+	var lastErr error
+
+	for _, p := range s.providers.candidates() {
+		if ctx.Err() != nil {
+			return attempts, ctx.Err()
+		}
+
+		start := time.Now()
+
+		err := s.sendViaProvider(ctx, p, from, to, msg)
+
+		attempt := newAttempt(number, p.Name, start, err)
+		attempts = append(attempts, attempt)
+
+		if err == nil || attempt.Class == AttemptPermanent {
+			s.providers.markSuccess(p)
+			s.metrics.SetState("provider:"+p.Name, 1)
+
+			if err != nil {
+				return attempts, fmt.Errorf("%w: %w", ErrPermanentFailure, err)
+			}
+
+			return attempts, nil
+		}
+
+		lastErr = err
+
+		if s.providers.markFailure(p) {
+			s.metrics.SetState("provider:"+p.Name, 0)
+			s.logger.Warn(
+				"sendViaProviders: provider marked unhealthy",
+				zap.String("provider", p.Name),
+				zap.Duration("cooldown", s.config.ProviderCooldown),
+			)
+		}
+
+		if errors.Is(err, ErrDeliveryUnknown) {
+			return attempts, err
+		}
+
+		s.logger.Warn("sendViaProviders: provider failed, trying next", zap.String("provider", p.Name), zap.Error(err))
+	}
+
+	return attempts, lastErr
+}
+
+// sendViaProvider sends the message through a single provider, the session is aborted
+// when the provider does not respond within ProviderTimeout or ctx is done.
+// It returns only once the session is closed, so that the message is never sent through two providers at once.
+// The error wraps ErrDeliveryUnknown if the session was aborted after the message had been transferred.
+func (s *SMTPClient) sendViaProvider(ctx context.Context, p *providerState, from, to string, msg []byte) error {
+	operation := "Provider:" + p.Name
+	start := time.Now()
+
+	sendCtx, cancel := context.WithTimeout(ctx, s.config.ProviderTimeout)
+	defer cancel()
+
+	err := p.dialer.send(sendCtx, from, to, msg)
+
+	switch {
+	case err == nil:
+		s.metrics.Observe(operation, start)
+		s.metrics.IncSuccess(operation)
+
+		return nil
+
+	case ctx.Err() != nil:
+		s.metrics.IncCanceled(operation)
+
+		if errors.Is(err, ErrDeliveryUnknown) {
+			return fmt.Errorf("%w: %s: %w", ErrDeliveryUnknown, p.Name, ctx.Err())
+		}
+
+		return ctx.Err()
+
+	case sendCtx.Err() != nil, errors.Is(err, os.ErrDeadlineExceeded):
+		s.metrics.IncTimeout(operation)
+
+		if errors.Is(err, ErrDeliveryUnknown) {
+			return fmt.Errorf("%w: %w: %s", ErrDeliveryUnknown, ErrProviderTimeout, p.Name)
+		}
+
+		return fmt.Errorf("%w: %s", ErrProviderTimeout, p.Name)
+
+	default:
+		if class, _ := classifyError(err); class == AttemptPermanent {
+			s.metrics.IncPermanent(operation)
+		} else {
+			s.metrics.IncTransient(operation)
+		}
+
+		return fmt.Errorf("sendViaProvider: %s: %w", p.Name, err)
+	}
+}
+
+// render writes the message with all its headers and, if DKIM is configured, signs the result.
+// The same rendered message is sent on every attempt, so that its DKIM signature stays valid.
+func (s *SMTPClient) render(msg *gomail.Message) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if _, err := msg.WriteTo(&buf); err != nil {
+		return nil, fmt.Errorf("render: cannot write message: %w", err)
+	}
+
+	if s.dkim == nil {
+		return buf.Bytes(), nil
+	}
+
+	return s.dkim.sign(buf.Bytes())
+}
+
+// CircuitOpen returns *CircuitOpenError while the circuit breaker is open, so that the caller can reject the email
+// before saving it. It does not take an attempt slot of the half-open breaker.
+func (s *SMTPClient) CircuitOpen() error {
+	if retryAfter, open := s.breaker.openFor(); open {
+		return &CircuitOpenError{RetryAfter: retryAfter}
+	}
+
+	return nil
 }
 
-// CreatePause calculates the delay before the next retry attempt using the formula:
-// basePause * 2^(retryAttempt - 1), implementing exponential backoff.
-func (s *SMTPClient) CreatePause(i int) time.Duration {
-	pauseFloat := s.config.BasicRetryPause.Seconds() * math.Pow(2, float64(i-1))
-	pause := time.Duration(pauseFloat) * time.Second
+// circuitOpen logs that the sending is stopped by the open circuit breaker and returns the CircuitOpenError.
+func (s *SMTPClient) circuitOpen(retryAfter time.Duration, lastErr error) error {
+	s.metrics.IncCanceled("SendEmail")
+	s.logger.Warn("sendWithRetry: circuit breaker is open", zap.Duration("retry_after", retryAfter), zap.Error(lastErr))
+
+	return &CircuitOpenError{RetryAfter: retryAfter}
+}
+
+// onBreakerChange logs the circuit breaker transitions and exports the new state as a gauge.
+func (s *SMTPClient) onBreakerChange(from, to breakerState) {
+	s.metrics.SetState("circuit_breaker", float64(to))
+	s.logger.Warn(
+		"circuit breaker state changed",
+		zap.String("from", from.String()),
+		zap.String("to", to.String()),
+	)
+}
 
-	return pause
+// CreatePause calculates the delay before the retry attempt i using the backoff policy:
+// basePause * 2^(i - 1) randomized with the configured jitter and capped with the max pause.
+// prev is the previous pause, it is used by the decorrelated jitter.
+func (s *SMTPClient) CreatePause(i int, prev time.Duration) time.Duration {
+	return s.backoff.Pause(i, prev)
 }
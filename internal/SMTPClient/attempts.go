@@ -0,0 +1,88 @@
+package SMTPClient
+
+import (
+	"errors"
+	"net/textproto"
+	"time"
+)
+
+const (
+	// AttemptSuccess marks the attempt that delivered the message to the provider.
+	AttemptSuccess = "success"
+
+	// AttemptTransient marks the failed attempt that is worth retrying: 4xx replies, network errors and timeouts.
+	AttemptTransient = "transient"
+
+	// AttemptPermanent marks the failed attempt that must not be retried: 5xx replies to the message or its recipients.
+	AttemptPermanent = "permanent"
+)
+
+// Attempt describes a single attempt to send the email through one of the providers.
+// Code contains the SMTP reply code, if the server replied with one.
+type Attempt struct {
+	Number    int
+	Provider  string
+	Class     string
+	Code      int
+	Error     string
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+// dialError wraps the errors that occur while connecting and authenticating to the provider.
+// They describe the provider rather than the message, so even 5xx replies are considered transient
+// and the message is sent through the next provider.
+type dialError struct {
+	err error
+}
+
+// Error implements the error interface.
+func (e *dialError) Error() string {
+	return "dial: " + e.err.Error()
+}
+
+// Unwrap returns the original error.
+func (e *dialError) Unwrap() error {
+	return e.err
+}
+
+// newAttempt creates the Attempt record for the finished attempt and classifies its error.
+func newAttempt(number int, provider string, start time.Time, err error) Attempt {
+	class, code := classifyError(err)
+
+	attempt := Attempt{
+		Number:    number,
+		Provider:  provider,
+		Class:     class,
+		Code:      code,
+		StartedAt: start,
+		Duration:  time.Since(start),
+	}
+
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+
+	return attempt
+}
+
+// classifyError returns the class of the attempt and the SMTP reply code, if present.
+// 5xx replies to MAIL, RCPT and DATA commands are permanent,
+// everything else, including 4xx replies, network errors and timeouts, is transient.
+func classifyError(err error) (string, int) {
+	if err == nil {
+		return AttemptSuccess, 0
+	}
+
+	var protoErr *textproto.Error
+	if !errors.As(err, &protoErr) {
+		return AttemptTransient, 0
+	}
+
+	var dialErr *dialError
+	if protoErr.Code >= 500 && protoErr.Code < 600 && !errors.As(err, &dialErr) {
+		return AttemptPermanent, protoErr.Code
+	}
+
+	return AttemptTransient, protoErr.Code
+}
@@ -0,0 +1,258 @@
+package SMTPClient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// AuthPlain is the PLAIN SASL mechanism, it requires an encrypted connection.
+	AuthPlain AuthMechanism = "plain"
+
+	// AuthLogin is the LOGIN SASL mechanism, it requires an encrypted connection.
+	AuthLogin AuthMechanism = "login"
+
+	// AuthCRAMMD5 is the CRAM-MD5 SASL mechanism.
+	AuthCRAMMD5 AuthMechanism = "cram-md5"
+
+	// AuthXOAUTH2 is the XOAUTH2 SASL mechanism using OAuth2 access tokens instead of passwords.
+	AuthXOAUTH2 AuthMechanism = "xoauth2"
+)
+
+// tokenExpiryDelta is how long before its expiry an access token is refreshed.
+const tokenExpiryDelta = 30 * time.Second
+
+// AuthMechanism defines which SASL mechanism is used to authenticate to the SMTP server.
+// The empty mechanism means CRAM-MD5 if the server offers it and PLAIN otherwise.
+type AuthMechanism string
+
+// SetValue implements the cleanenv.Setter interface and validates the authentication mechanism.
+func (m *AuthMechanism) SetValue(s string) error {
+	switch v := AuthMechanism(strings.ToLower(s)); v {
+	case "", AuthPlain, AuthLogin, AuthCRAMMD5, AuthXOAUTH2:
+		*m = v
+		return nil
+
+	default:
+		return fmt.Errorf("AuthMechanism: unknown authentication mechanism %q", s)
+	}
+}
+
+// name returns the name of the mechanism as advertised in the EHLO response.
+func (m AuthMechanism) name() string {
+	return strings.ToUpper(string(m))
+}
+
+// newAuth returns the smtp.Auth for the mechanism.
+func (d *smtpDialer) newAuth(mechanism AuthMechanism) smtp.Auth {
+	switch mechanism {
+	case AuthLogin:
+		return &loginAuth{username: d.username, password: d.password, host: d.host}
+
+	case AuthCRAMMD5:
+		return smtp.CRAMMD5Auth(d.username, d.password)
+
+	case AuthXOAUTH2:
+		return &xoauth2Auth{username: d.username, host: d.host, tokens: d.tokens}
+
+	default:
+		return smtp.PlainAuth("", d.username, d.password, d.host)
+	}
+}
+
+// loginAuth implements the LOGIN SASL mechanism, which is still required by some providers.
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+// Start implements the smtp.Auth interface.
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if err := checkEncrypted(server, a.host); err != nil {
+		return "", nil, err
+	}
+
+	return "LOGIN", nil, nil
+}
+
+// Next implements the smtp.Auth interface and answers the username and password prompts.
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+
+	case "password:":
+		return []byte(a.password), nil
+
+	default:
+		return nil, fmt.Errorf("loginAuth: unexpected server challenge %q", fromServer)
+	}
+}
+
+// xoauth2Auth implements the XOAUTH2 SASL mechanism with access tokens taken from the token source.
+type xoauth2Auth struct {
+	username string
+	host     string
+	tokens   *tokenSource
+}
+
+// Start implements the smtp.Auth interface.
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if err := checkEncrypted(server, a.host); err != nil {
+		return "", nil, err
+	}
+
+	token, err := a.tokens.token()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return "XOAUTH2", []byte("user=" + a.username + "\x01auth=Bearer " + token + "\x01\x01"), nil
+}
+
+// Next implements the smtp.Auth interface.
+// On failure the server sends an error challenge, which must be answered with an empty response,
+// the access token is dropped, so that a new one is requested on the next attempt.
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		a.tokens.invalidate()
+		return []byte{}, nil
+	}
+
+	return nil, nil
+}
+
+// checkEncrypted refuses to send credentials over an unencrypted connection to a remote server,
+// the same way smtp.PlainAuth does.
+func checkEncrypted(server *smtp.ServerInfo, host string) error {
+	if server.Name != host {
+		return errors.New("wrong host name")
+	}
+
+	if !server.TLS && host != "localhost" && host != "127.0.0.1" && host != "::1" {
+		return errors.New("unencrypted connection")
+	}
+
+	return nil
+}
+
+// tokenSource requests OAuth2 access tokens from the token endpoint and caches them until they expire.
+// The refresh token grant is used if a refresh token is configured, the client credentials grant otherwise.
+type tokenSource struct {
+	url          string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	client       *http.Client
+
+	mu           sync.Mutex
+	refreshToken string
+	accessToken  string
+	expiry       time.Time
+}
+
+// tokenResponse is the successful or error response of the token endpoint (RFC 6749 sections 5.1 and 5.2).
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// newTokenSource creates the token source from the configuration.
+// It returns nil if the XOAUTH2 mechanism is not used.
+func newTokenSource(config *Config) (*tokenSource, error) {
+	if config.AuthMechanism != AuthXOAUTH2 {
+		return nil, nil
+	}
+
+	if config.OAuth2TokenURL == "" || config.OAuth2ClientID == "" {
+		return nil, ErrOAuth2Config
+	}
+
+	return &tokenSource{
+		url:          config.OAuth2TokenURL,
+		clientID:     config.OAuth2ClientID,
+		clientSecret: config.OAuth2ClientSecret,
+		refreshToken: config.OAuth2RefreshToken,
+		scopes:       config.OAuth2Scopes,
+		client:       &http.Client{Timeout: config.ProviderTimeout},
+	}, nil
+}
+
+// token returns the cached access token or requests a new one if it is missing or about to expire.
+// The token is cached for expires_in of the response, or for DefaultTokenLifetime if the response has none.
+func (ts *tokenSource) token() (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.accessToken != "" && time.Now().Add(tokenExpiryDelta).Before(ts.expiry) {
+		return ts.accessToken, nil
+	}
+
+	form := url.Values{
+		"client_id":     {ts.clientID},
+		"client_secret": {ts.clientSecret},
+	}
+
+	if ts.refreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", ts.refreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+
+	if len(ts.scopes) > 0 {
+		form.Set("scope", strings.Join(ts.scopes, " "))
+	}
+
+	resp, err := ts.client.PostForm(ts.url, form)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrTokenRefresh, err)
+	}
+	defer resp.Body.Close()
+
+	var tr tokenResponse
+	if err = json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return "", fmt.Errorf("%w: status %d: cannot decode response: %w", ErrTokenRefresh, resp.StatusCode, err)
+	}
+
+	if resp.StatusCode != http.StatusOK || tr.AccessToken == "" {
+		return "", fmt.Errorf("%w: status %d: %s %s", ErrTokenRefresh, resp.StatusCode, tr.Error, tr.ErrorDescription)
+	}
+
+	lifetime := time.Duration(tr.ExpiresIn) * time.Second
+	if tr.ExpiresIn <= 0 {
+		lifetime = DefaultTokenLifetime
+	}
+
+	ts.accessToken = tr.AccessToken
+	ts.expiry = time.Now().Add(lifetime)
+
+	if tr.RefreshToken != "" {
+		ts.refreshToken = tr.RefreshToken
+	}
+
+	return ts.accessToken, nil
+}
+
+// invalidate drops the cached access token after it was rejected by the server.
+func (ts *tokenSource) invalidate() {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.accessToken = ""
+}
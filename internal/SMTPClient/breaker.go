@@ -0,0 +1,178 @@
+package SMTPClient
+
+import (
+	"sync"
+	"time"
+)
+
+// breakerState is the state of the circuit breaker, its numeric value is exported as the Prometheus gauge value.
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// String returns a human-readable name of the state.
+func (bs breakerState) String() string {
+	switch bs {
+	case breakerClosed:
+		return "closed"
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// circuitBreaker stops sending attempts after FailureThreshold consecutive failures.
+// While open, every attempt fails fast. After OpenTimeout it lets up to HalfOpenMaxRequests trial attempts through,
+// and closes again when all of them succeed, or opens again on the first failure.
+// Every state transition starts a new generation, the attempts are completed with the generation they were
+// allowed in, and the completions of the attempts allowed in an earlier generation are ignored.
+type circuitBreaker struct {
+	mu                  sync.Mutex
+	state               breakerState
+	generation          uint64
+	failures            int
+	successes           int
+	inFlight            int
+	openedAt            time.Time
+	failureThreshold    int
+	halfOpenMaxRequests int
+	openTimeout         time.Duration
+	onChange            func(from, to breakerState)
+}
+
+// newCircuitBreaker creates a closed circuit breaker, onChange is called on every state transition.
+func newCircuitBreaker(config *Config, onChange func(from, to breakerState)) *circuitBreaker {
+	return &circuitBreaker{
+		state:               breakerClosed,
+		failureThreshold:    config.BreakerFailureThreshold,
+		halfOpenMaxRequests: config.BreakerHalfOpenMaxRequests,
+		openTimeout:         config.BreakerOpenTimeout,
+		onChange:            onChange,
+	}
+}
+
+// allow reports whether an attempt may be made and returns the generation the attempt is allowed in.
+// If not, it returns the time left until the breaker becomes half-open.
+// Every allowed attempt must be completed with success, failure or release with the returned generation.
+func (cb *circuitBreaker) allow() (uint64, time.Duration, bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state == breakerOpen {
+		left := cb.openTimeout - time.Since(cb.openedAt)
+		if left > 0 {
+			return 0, left, false
+		}
+
+		cb.setState(breakerHalfOpen)
+	}
+
+	if cb.state == breakerHalfOpen {
+		if cb.inFlight >= cb.halfOpenMaxRequests {
+			return 0, cb.openTimeout, false
+		}
+
+		cb.inFlight++
+	}
+
+	return cb.generation, 0, true
+}
+
+// openFor reports whether the breaker is open without taking an attempt slot,
+// and how long it remains open.
+func (cb *circuitBreaker) openFor() (time.Duration, bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.state != breakerOpen {
+		return 0, false
+	}
+
+	left := cb.openTimeout - time.Since(cb.openedAt)
+
+	return left, left > 0
+}
+
+// success records a successful attempt allowed in the generation.
+func (cb *circuitBreaker) success(generation uint64) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if generation != cb.generation {
+		return
+	}
+
+	switch cb.state {
+	case breakerClosed:
+		cb.failures = 0
+
+	case breakerHalfOpen:
+		cb.inFlight--
+		cb.successes++
+
+		if cb.successes >= cb.halfOpenMaxRequests {
+			cb.setState(breakerClosed)
+		}
+	}
+}
+
+// failure records a failed attempt allowed in the generation and opens the breaker when the threshold is reached.
+func (cb *circuitBreaker) failure(generation uint64) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if generation != cb.generation {
+		return
+	}
+
+	switch cb.state {
+	case breakerClosed:
+		cb.failures++
+
+		if cb.failures >= cb.failureThreshold {
+			cb.setState(breakerOpen)
+		}
+
+	case breakerHalfOpen:
+		cb.inFlight--
+		cb.setState(breakerOpen)
+	}
+}
+
+// release completes an attempt allowed in the generation that neither succeeded nor failed,
+// for example canceled by context.
+func (cb *circuitBreaker) release(generation uint64) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if generation == cb.generation && cb.state == breakerHalfOpen {
+		cb.inFlight--
+	}
+}
+
+// setState switches the breaker to the new state, starts a new generation and resets the counters,
+// must be called with mu held.
+func (cb *circuitBreaker) setState(state breakerState) {
+	from := cb.state
+
+	cb.state = state
+	cb.generation++
+	cb.failures = 0
+	cb.successes = 0
+	cb.inFlight = 0
+
+	if state == breakerOpen {
+		cb.openedAt = time.Now()
+	}
+
+	if cb.onChange != nil && from != state {
+		cb.onChange(from, state)
+	}
+}
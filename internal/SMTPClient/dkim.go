@@ -0,0 +1,119 @@
+package SMTPClient
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/emersion/go-msgauth/dkim"
+)
+
+// DefaultDKIMHeaders is the default list of headers signed with DKIM, see RFC 6376 section 5.4.1.
+// List-Unsubscribe headers must be signed for one-click unsubscribe, see RFC 8058 section 4.
+var DefaultDKIMHeaders = []string{"From", "To", "Subject", "Date", "Message-ID", "Reply-To", "MIME-Version", "Content-Type",
+	"List-Unsubscribe", "List-Unsubscribe-Post"}
+
+// dkimSigner adds the DKIM-Signature header to fully rendered messages.
+type dkimSigner struct {
+	options dkim.SignOptions
+}
+
+// newDKIMSigner loads the DKIM private key and returns the signer.
+// It returns nil if DKIM signing is not configured.
+func newDKIMSigner(config *Config) (*dkimSigner, error) {
+	if config.DKIMPrivateKeyFile == "" {
+		return nil, nil
+	}
+
+	if config.DKIMDomain == "" || config.DKIMSelector == "" {
+		return nil, ErrDKIMConfig
+	}
+
+	key, err := loadDKIMKey(config.DKIMPrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := config.DKIMHeaders
+	if len(headers) == 0 {
+		headers = DefaultDKIMHeaders
+	}
+
+	hasFrom := false
+	for _, h := range headers {
+		if strings.EqualFold(h, "From") {
+			hasFrom = true
+			break
+		}
+	}
+
+	if !hasFrom {
+		return nil, fmt.Errorf("%w: From must be signed", ErrDKIMConfig)
+	}
+
+	return &dkimSigner{
+		options: dkim.SignOptions{
+			Domain:                 config.DKIMDomain,
+			Selector:               config.DKIMSelector,
+			Signer:                 key,
+			HeaderCanonicalization: dkim.CanonicalizationRelaxed,
+			BodyCanonicalization:   dkim.CanonicalizationRelaxed,
+			HeaderKeys:             headers,
+		},
+	}, nil
+}
+
+// loadDKIMKey reads the PEM encoded RSA (PKCS #1 or PKCS #8) or Ed25519 (PKCS #8) private key from the file.
+func loadDKIMKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("loadDKIMKey: cannot read private key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("loadDKIMKey: no PEM data found in %s", path)
+	}
+
+	if block.Type == "RSA PRIVATE KEY" {
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("loadDKIMKey: cannot parse RSA private key: %w", err)
+		}
+
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("loadDKIMKey: cannot parse private key: %w", err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+
+	case ed25519.PrivateKey:
+		return k, nil
+
+	default:
+		return nil, fmt.Errorf("loadDKIMKey: unsupported private key type %T", key)
+	}
+}
+
+// sign returns the message with the DKIM-Signature header prepended.
+func (d *dkimSigner) sign(msg []byte) ([]byte, error) {
+	var signed bytes.Buffer
+
+	if err := dkim.Sign(&signed, bytes.NewReader(msg), &d.options); err != nil {
+		return nil, fmt.Errorf("sign: cannot sign message with DKIM: %w", err)
+	}
+
+	return signed.Bytes(), nil
+}
@@ -0,0 +1,286 @@
+package SMTPClient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// TLSNone sends the mail over a plain connection, even if the server offers STARTTLS.
+	TLSNone TLSMode = "none"
+
+	// TLSStartTLS upgrades the connection with STARTTLS and fails if the server does not offer it.
+	TLSStartTLS TLSMode = "starttls"
+
+	// TLSStartTLSOpportunistic upgrades the connection with STARTTLS only if the server offers it.
+	TLSStartTLSOpportunistic TLSMode = "starttls-opportunistic"
+
+	// TLSImplicit connects with TLS from the start (SMTPS, usually port 465).
+	TLSImplicit TLSMode = "tls"
+)
+
+// TLSMode defines whether and how the connection to the SMTP server is encrypted.
+// The empty mode means TLSImplicit for port 465 and TLSStartTLSOpportunistic for other ports.
+type TLSMode string
+
+// SetValue implements the cleanenv.Setter interface and validates the TLS mode.
+func (m *TLSMode) SetValue(s string) error {
+	switch v := TLSMode(s); v {
+	case "", TLSNone, TLSStartTLS, TLSStartTLSOpportunistic, TLSImplicit:
+		*m = v
+		return nil
+
+	default:
+		return fmt.Errorf("TLSMode: unknown TLS mode %q", s)
+	}
+}
+
+// resolve returns the effective TLS mode for the port.
+func (m TLSMode) resolve(port int) TLSMode {
+	if m != "" {
+		return m
+	}
+
+	if port == 465 {
+		return TLSImplicit
+	}
+
+	return TLSStartTLSOpportunistic
+}
+
+// TLSVersion is the minimum TLS version decoded from strings like "1.2".
+type TLSVersion uint16
+
+// SetValue implements the cleanenv.Setter interface and parses the TLS version.
+func (v *TLSVersion) SetValue(s string) error {
+	switch s {
+	case "":
+		*v = 0
+	case "1.0":
+		*v = tls.VersionTLS10
+	case "1.1":
+		*v = tls.VersionTLS11
+	case "1.2":
+		*v = tls.VersionTLS12
+	case "1.3":
+		*v = tls.VersionTLS13
+	default:
+		return fmt.Errorf("TLSVersion: unknown TLS version %q", s)
+	}
+
+	return nil
+}
+
+// newTLSConfig builds the TLS configuration shared by all providers:
+// CA bundle appended to the system roots, client certificate and minimum TLS version.
+func newTLSConfig(config *Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.SkipVerify,
+		MinVersion:         uint16(config.TLSMinVersion),
+	}
+
+	if tlsConfig.MinVersion == 0 {
+		tlsConfig.MinVersion = tls.VersionTLS12
+	}
+
+	if config.TLSCAFile != "" {
+		pem, err := os.ReadFile(config.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("newTLSConfig: cannot read CA bundle: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("newTLSConfig: no certificates found in %s", config.TLSCAFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.TLSCertFile != "" || config.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("newTLSConfig: cannot load client certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// smtpDialer opens SMTP sessions to a single provider according to its TLS mode.
+type smtpDialer struct {
+	host      string
+	port      int
+	username  string
+	password  string
+	mode      TLSMode
+	tlsConfig *tls.Config
+	mechanism AuthMechanism
+	tokens    *tokenSource
+	timeout   time.Duration
+}
+
+// send delivers the rendered message to the recipient in a single SMTP session.
+// The session is aborted as soon as ctx is done, send returns only once the connection is closed.
+// Errors occurring before the MAIL command are wrapped in dialError.
+// If the message has been transferred, but the reply to it is not received, the error wraps ErrDeliveryUnknown.
+func (d *smtpDialer) send(ctx context.Context, from, to string, msg []byte) error {
+	conn, err := d.dial(ctx)
+	if err != nil {
+		return &dialError{err: err}
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	c, err := d.open(conn)
+	if err != nil {
+		return &dialError{err: err}
+	}
+	defer c.Close()
+
+	if err = c.Mail(from); err != nil {
+		return err
+	}
+
+	if err = c.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+
+	if err = w.Close(); err != nil {
+		var protoErr *textproto.Error
+		if !errors.As(err, &protoErr) {
+			return fmt.Errorf("%w: %w", ErrDeliveryUnknown, err)
+		}
+
+		return err
+	}
+
+	// The message is accepted at this point, the error of QUIT does not matter.
+	_ = c.Quit()
+
+	return nil
+}
+
+// dial connects to the provider, the whole session is limited by the dialer timeout.
+func (d *smtpDialer) dial(ctx context.Context) (net.Conn, error) {
+	addr := net.JoinHostPort(d.host, strconv.Itoa(d.port))
+
+	dialer := &net.Dialer{Timeout: d.timeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(d.timeout))
+	}
+
+	return conn, nil
+}
+
+// open starts the SMTP session on the connection, negotiates TLS and authenticates.
+// The connection is closed if the session cannot be started.
+func (d *smtpDialer) open(conn net.Conn) (*smtp.Client, error) {
+	if d.mode == TLSImplicit {
+		conn = tls.Client(conn, d.tlsConfig)
+	}
+
+	c, err := smtp.NewClient(conn, d.host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err = d.startTLS(c); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	if err = d.auth(c); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// startTLS upgrades the connection with STARTTLS according to the TLS mode.
+func (d *smtpDialer) startTLS(c *smtp.Client) error {
+	if d.mode != TLSStartTLS && d.mode != TLSStartTLSOpportunistic {
+		return nil
+	}
+
+	if ok, _ := c.Extension("STARTTLS"); !ok {
+		if d.mode == TLSStartTLS {
+			return fmt.Errorf("%w: %s", ErrStartTLSNotOffered, d.host)
+		}
+
+		return nil
+	}
+
+	return c.StartTLS(d.tlsConfig)
+}
+
+// auth authenticates if the provider has credentials and the server supports authentication.
+// Without the configured mechanism CRAM-MD5 is preferred when offered, otherwise PLAIN is used,
+// which requires an encrypted connection. The configured mechanism must be offered by the server.
+func (d *smtpDialer) auth(c *smtp.Client) error {
+	if d.username == "" {
+		return nil
+	}
+
+	ok, offered := c.Extension("AUTH")
+	if !ok {
+		if d.mechanism != "" {
+			return fmt.Errorf("%w: %s", ErrAuthNotOffered, d.mechanism.name())
+		}
+
+		return nil
+	}
+
+	mechanisms := strings.Fields(strings.ToUpper(offered))
+
+	mechanism := d.mechanism
+	switch {
+	case mechanism == "" && slices.Contains(mechanisms, AuthCRAMMD5.name()):
+		mechanism = AuthCRAMMD5
+
+	case mechanism == "":
+		mechanism = AuthPlain
+
+	case !slices.Contains(mechanisms, mechanism.name()):
+		return fmt.Errorf("%w: %s", ErrAuthNotOffered, mechanism.name())
+	}
+
+	return c.Auth(d.newAuth(mechanism))
+}
@@ -8,7 +8,11 @@ import (
 	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
 
+	"notification/internal/api"
+	"notification/internal/backoff"
 	"notification/internal/monitoring"
+	"notification/internal/tracking"
+	"notification/internal/unsubscribe"
 )
 
 const (
@@ -17,6 +21,27 @@ const (
 
 	// DefaultBasicRetryPause is the default value for BasicRetryPause.
 	DefaultBasicRetryPause = 5 * time.Second
+
+	// DefaultProviderTimeout is the default value for ProviderTimeout.
+	DefaultProviderTimeout = 30 * time.Second
+
+	// DefaultProviderMaxFailures is the default value for ProviderMaxFailures.
+	DefaultProviderMaxFailures = 3
+
+	// DefaultProviderCooldown is the default value for ProviderCooldown.
+	DefaultProviderCooldown = 1 * time.Minute
+
+	// DefaultBreakerFailureThreshold is the default value for BreakerFailureThreshold.
+	DefaultBreakerFailureThreshold = 5
+
+	// DefaultBreakerOpenTimeout is the default value for BreakerOpenTimeout.
+	DefaultBreakerOpenTimeout = 30 * time.Second
+
+	// DefaultBreakerHalfOpenMaxRequests is the default value for BreakerHalfOpenMaxRequests.
+	DefaultBreakerHalfOpenMaxRequests = 1
+
+	// DefaultTokenLifetime is the lifetime of the OAuth2 access token whose response has no expires_in.
+	DefaultTokenLifetime = 1 * time.Hour
 )
 
 var (
@@ -31,49 +56,207 @@ var (
 
 	// ErrContextCanceledAfterPause indicates that the context was canceled after the retry pause but before sending.
 	ErrContextCanceledAfterPause = fmt.Errorf("sendWithRetry: context canceled after pause")
+
+	// ErrProviderTimeout indicates that the SMTP provider did not respond within ProviderTimeout.
+	ErrProviderTimeout = fmt.Errorf("sendViaProvider: provider timeout")
+
+	// ErrDeliveryUnknown indicates that the message was transferred to the provider, but the session broke
+	// before the provider replied to it. The provider may have accepted the message, so it must not be sent again.
+	ErrDeliveryUnknown = fmt.Errorf("send: delivery result unknown")
+
+	// ErrNoValidRecipientAddress indicates that the recipient address is invalid.
+	ErrNoValidRecipientAddress = fmt.Errorf("SendEmail: no valid recipient address")
+
+	// ErrPermanentFailure indicates that the SMTP server permanently rejected the message, it must not be retried.
+	ErrPermanentFailure = fmt.Errorf("sendWithRetry: permanent failure")
+
+	// ErrRetryTimeExceeded indicates that the next retry would exceed the max elapsed time of the backoff policy.
+	ErrRetryTimeExceeded = fmt.Errorf("sendWithRetry: max elapsed retry time exceeded")
+
+	// ErrDKIMConfig indicates that DKIM signing is configured without domain or selector.
+	ErrDKIMConfig = fmt.Errorf("newDKIMSigner: DKIM domain and selector must be set")
+
+	// ErrStartTLSNotOffered indicates that the TLS mode requires STARTTLS, but the server does not offer it.
+	ErrStartTLSNotOffered = fmt.Errorf("startTLS: STARTTLS is required but not offered by the server")
+
+	// ErrAuthNotOffered indicates that the configured authentication mechanism is not offered by the server.
+	ErrAuthNotOffered = fmt.Errorf("auth: authentication mechanism is not offered by the server")
+
+	// ErrOAuth2Config indicates that the XOAUTH2 mechanism is configured without token URL or client ID.
+	ErrOAuth2Config = fmt.Errorf("newTokenSource: OAuth2 token URL and client ID must be set")
+
+	// ErrTokenRefresh indicates that an OAuth2 access token cannot be obtained from the token endpoint.
+	ErrTokenRefresh = fmt.Errorf("token: cannot refresh OAuth2 access token")
+
+	// ErrCircuitOpen indicates that the circuit breaker is open and emails are not being sent.
+	ErrCircuitOpen = fmt.Errorf("sendWithRetry: circuit breaker is open")
 )
 
+// CircuitOpenError is returned while the circuit breaker is open,
+// RetryAfter reports how long it takes for the breaker to let attempts through again.
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface.
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrCircuitOpen, e.RetryAfter)
+}
+
+// Unwrap allows to match CircuitOpenError with ErrCircuitOpen using errors.Is.
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
 // Config defines the configuration parameters for the SMTPClient,
-// including sender credentials, timeout and retry configuration.
+// including sender credentials, SMTP providers, timeout, retry backoff, circuit breaker and DKIM configuration.
+// If Providers is empty, SMTPHost and SMTPPort are used as the only provider.
+// TLSMode defines how the connection is encrypted, TLSCAFile adds trusted CAs to the system ones,
+// TLSCertFile and TLSKeyFile set the client certificate, TLSMinVersion defaults to TLS 1.2.
+// AuthMechanism selects the SASL mechanism, XOAUTH2 takes access tokens from the OAuth2 token endpoint,
+// using the refresh token grant if OAuth2RefreshToken is set and the client credentials grant otherwise.
+// DKIM signing is enabled when DKIMPrivateKeyFile is set, DKIMHeaders defaults to DefaultDKIMHeaders.
+// Unsubscribe configures List-Unsubscribe headers for non-transactional emails and Tracking configures
+// open and click tracking of HTML emails which request it, the tokens and the tracker are built by the caller of New.
 type Config struct {
-	SenderEmail     string        `env:"SENDER_EMAIL"`
-	SenderPassword  string        `env:"SENDER_PASSWORD"`
-	SMTPHost        string        `env:"SMTP_HOST"`
-	SMTPPort        int           `env:"SMTP_PORT"`
-	SkipVerify      bool          `env:"SKIP_VERIFY"`
-	MaxRetries      int           `env:"MAX_RETRIES"`
-	BasicRetryPause time.Duration `env:"BASIC_RETRY_PAUSE"`
+	SenderEmail         string        `env:"SENDER_EMAIL"`
+	SenderPassword      string        `env:"SENDER_PASSWORD"`
+	SMTPHost            string        `env:"SMTP_HOST"`
+	SMTPPort            int           `env:"SMTP_PORT"`
+	SkipVerify          bool          `env:"SKIP_VERIFY"`
+	TLSMode             TLSMode       `env:"SMTP_TLS_MODE"`
+	TLSCAFile           string        `env:"SMTP_TLS_CA_FILE"`
+	TLSCertFile         string        `env:"SMTP_TLS_CERT_FILE"`
+	TLSKeyFile          string        `env:"SMTP_TLS_KEY_FILE"`
+	TLSMinVersion       TLSVersion    `env:"SMTP_TLS_MIN_VERSION"`
+	AuthMechanism       AuthMechanism `env:"SMTP_AUTH_MECHANISM"`
+	MaxRetries          int           `env:"MAX_RETRIES"`
+	BasicRetryPause     time.Duration `env:"BASIC_RETRY_PAUSE"`
+	Providers           Providers     `env:"SMTP_PROVIDERS"`
+	ProviderTimeout     time.Duration `env:"SMTP_PROVIDER_TIMEOUT"`
+	ProviderMaxFailures int           `env:"SMTP_PROVIDER_MAX_FAILURES"`
+	ProviderCooldown    time.Duration `env:"SMTP_PROVIDER_COOLDOWN"`
+
+	BreakerFailureThreshold    int           `env:"SMTP_BREAKER_FAILURE_THRESHOLD"`
+	BreakerOpenTimeout         time.Duration `env:"SMTP_BREAKER_OPEN_TIMEOUT"`
+	BreakerHalfOpenMaxRequests int           `env:"SMTP_BREAKER_HALF_OPEN_MAX_REQUESTS"`
+
+	Backoff backoff.Config
+
+	DKIMDomain         string   `env:"DKIM_DOMAIN"`
+	DKIMSelector       string   `env:"DKIM_SELECTOR"`
+	DKIMPrivateKeyFile string   `env:"DKIM_PRIVATE_KEY_FILE"`
+	DKIMHeaders        []string `env:"DKIM_HEADERS" env-separator:","`
+
+	OAuth2TokenURL     string   `env:"SMTP_OAUTH2_TOKEN_URL"`
+	OAuth2ClientID     string   `env:"SMTP_OAUTH2_CLIENT_ID"`
+	OAuth2ClientSecret string   `env:"SMTP_OAUTH2_CLIENT_SECRET"`
+	OAuth2RefreshToken string   `env:"SMTP_OAUTH2_REFRESH_TOKEN"`
+	OAuth2Scopes       []string `env:"SMTP_OAUTH2_SCOPES" env-separator:","`
+
+	Unsubscribe unsubscribe.Config
+	Tracking    tracking.Config
 }
 
 // TempEmailMessage is used as an intermediate structure for decode from/to JSON.
+// Id, Tenant and Retry are only set in the Redis entries of the scheduled emails.
 type TempEmailMessage struct {
-	Type    string `json:"type"`
-	Time    string `json:"time"`
-	To      string `json:"to"`
-	Subject string `json:"subject"`
-	Message string `json:"message"`
+	Id       int               `json:"id,omitempty"`
+	Type     string            `json:"type"`
+	Time     string            `json:"time"`
+	To       string            `json:"to"`
+	Subject  string            `json:"subject"`
+	Message  string            `json:"message"`
+	From     string            `json:"from,omitempty"`
+	FromName string            `json:"from_name,omitempty"`
+	ReplyTo  string            `json:"reply_to,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+
+	MessageID  string   `json:"message_id,omitempty"`
+	InReplyTo  string   `json:"in_reply_to,omitempty"`
+	References []string `json:"references,omitempty"`
+
+	Category string `json:"category,omitempty"`
+
+	HTML  string `json:"html,omitempty"`
+	Track bool   `json:"track,omitempty"`
+
+	CallbackURL string `json:"callback_url,omitempty"`
+
+	Tenant string `json:"tenant,omitempty"`
+
+	Retry *RetryState `json:"retry,omitempty"`
 }
 
 // EmailMessage contains the email details, including an optional Time field for delayed delivery.
+// Id is the PostgreSQL ID of the notification, it is set once the email is saved.
+// From overrides the configured sender and must be one of the verified identities, FromName is its display name,
+// Headers contains custom X-* headers.
+// MessageID is generated when the email is saved, InReplyTo and References contain Message-IDs of previous emails in the thread.
+// Category is used for unsubscribe, the empty category means a transactional email.
+// HTML is the optional HTML alternative of Message, Track enables open and click tracking of the HTML body,
+// Events contains the recorded tracking events and is only filled when listing saved emails.
+// CallbackURL is the URL the delivery status webhooks of the email are sent to.
+// Client and APIKeyID identify the authenticated client that created the email and Tenant is the tenant of the client,
+// they are only stored in PostgreSQL.
+// Retry is set for delayed emails rescheduled by the worker after a transient failure.
 type EmailMessage struct {
-	Type    string     `json:"type"`
-	Time    *time.Time `json:"time,omitempty"`
-	To      string     `json:"to"`
-	Subject string     `json:"subject"`
-	Message string     `json:"message"`
+	Id       int               `json:"-"`
+	Type     string            `json:"type"`
+	Time     *time.Time        `json:"time,omitempty"`
+	To       string            `json:"to"`
+	Subject  string            `json:"subject"`
+	Message  string            `json:"message"`
+	From     string            `json:"from,omitempty"`
+	FromName string            `json:"from_name,omitempty"`
+	ReplyTo  string            `json:"reply_to,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+
+	MessageID  string   `json:"message_id,omitempty"`
+	InReplyTo  string   `json:"in_reply_to,omitempty"`
+	References []string `json:"references,omitempty"`
+
+	Category string `json:"category,omitempty"`
+
+	HTML   string              `json:"html,omitempty"`
+	Track  bool                `json:"track,omitempty"`
+	Events []api.TrackingEvent `json:"events,omitempty"`
+
+	CallbackURL string `json:"callback_url,omitempty"`
+
+	Client   string `json:"-"`
+	APIKeyID int    `json:"-"`
+	Tenant   string `json:"-"`
+
+	Retry *RetryState `json:"-"`
+}
+
+// RetryState keeps the progress of the worker retries of a delayed email between Redis round-trips:
+// the number of retries made, the last pause and the time of the first failed attempt.
+type RetryState struct {
+	Number int           `json:"number"`
+	Pause  time.Duration `json:"pause"`
+	Start  time.Time     `json:"start"`
 }
 
 // SMTPClient implements the EmailSender interface and sends email messages using SMTP.
 type SMTPClient struct {
-	config  *Config
-	metrics monitoring.Monitoring
-	logger  *zap.Logger
+	config    *Config
+	providers *providerPool
+	breaker   *circuitBreaker
+	backoff   backoff.Policy
+	dkim      *dkimSigner
+	tokens    *unsubscribe.Tokens
+	tracker   *tracking.Tracker
+	metrics   monitoring.Monitoring
+	logger    *zap.Logger
 }
 
 // EmailSender defines an interface for sending email messages to recipient.
+// CircuitOpen returns *CircuitOpenError while no email can be sent because the circuit breaker is open, otherwise nil.
 type EmailSender interface {
-	SendEmail(context.Context, EmailMessage) error
-	CreatePause(int) time.Duration
+	SendEmail(context.Context, EmailMessage) ([]Attempt, error)
+	CircuitOpen() error
 }
 
 // MockEmailSender is a mock implementation of the EmailSender interface,
@@ -83,12 +266,14 @@ type MockEmailSender struct {
 }
 
 // SendEmail is a mock implementation.
-func (m *MockEmailSender) SendEmail(ctx context.Context, email EmailMessage) error {
+func (m *MockEmailSender) SendEmail(ctx context.Context, email EmailMessage) ([]Attempt, error) {
 	args := m.Called(ctx, email)
-	return args.Error(0)
+	attempts, _ := args.Get(0).([]Attempt)
+	return attempts, args.Error(1)
 }
 
-// CreatePause is a mock implementation.
-func (m *MockEmailSender) CreatePause(i int) time.Duration {
-	return time.Second
+// CircuitOpen is a mock implementation.
+func (m *MockEmailSender) CircuitOpen() error {
+	args := m.Called()
+	return args.Error(0)
 }
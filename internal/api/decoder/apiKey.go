@@ -0,0 +1,97 @@
+package decoder
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	"notification/internal/api"
+	"notification/internal/api/problem"
+)
+
+// maxAPIKeyNameLength is the maximum length of the client name of the API key.
+const maxAPIKeyNameLength = 64
+
+var (
+	errNoAPIKeyName      = errors.New("checkAPIKey: no client name")
+	errAPIKeyNameTooLong = errors.New("checkAPIKey: client name too long")
+	errInvalidScopes     = errors.New("checkAPIKey: invalid scopes")
+)
+
+// DecodeAPIKey parses and validates the request body of the new API key.
+// The name is trimmed, duplicate scopes are removed, and the fields set by the service are ignored.
+// On success, it returns the parsed APIKey struct.
+// On failure, it returns the corresponding error and writes an error message to the HTTP client.
+func DecodeAPIKey(logger *zap.Logger, r *http.Request, w http.ResponseWriter) (*api.APIKey, error) {
+	d := decoder{
+		logger: logger,
+		r:      r,
+		w:      w,
+	}
+
+	if err := d.checkHeaders(); err != nil {
+		return nil, err
+	}
+
+	key := &api.APIKey{}
+
+	if err := d.decodeBody(key); err != nil {
+		return nil, d.errDuringParse(err)
+	}
+
+	if err := d.checkAPIKey(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// checkAPIKey validates the client name, the scopes and the expiry time of the API key.
+// All validation errors are collected and written to the HTTP client together, see report.
+func (d *decoder) checkAPIKey(key *api.APIKey) error {
+	v := &validation{}
+
+	key.Name = strings.TrimSpace(key.Name)
+
+	switch {
+	case key.Name == "":
+		v.add(errNoAPIKeyName, problem.CodeMissingFields, "The name of the client is required", "name")
+
+	case utf8.RuneCountInString(key.Name) > maxAPIKeyNameLength:
+		v.add(errAPIKeyNameTooLong, problem.CodeTooLong, "The name must not be longer than 64 characters", "name")
+	}
+
+	var scopes []string
+
+	for _, scope := range key.Scopes {
+		if !api.ValidScope(scope) {
+			scopes = nil
+			break
+		}
+
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if len(scopes) == 0 {
+		v.add(errInvalidScopes, problem.CodeInvalidField, "Scopes must be one or more of send, schedule, read and admin", "scopes")
+	}
+
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now()) {
+		v.add(errExpiryNotAtFuture, problem.CodeTimeNotInFuture, "The specified expiry time is not in the future", "expires_at")
+	}
+
+	if err := d.report(v); err != nil {
+		return err
+	}
+
+	*key = api.APIKey{Name: key.Name, Scopes: scopes, ExpiresAt: key.ExpiresAt}
+
+	return nil
+}
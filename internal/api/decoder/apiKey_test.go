@@ -0,0 +1,115 @@
+package decoder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"notification/internal/api"
+)
+
+func TestDecodeAPIKey(t *testing.T) {
+	expiresAt := time.Date(2035, 5, 24, 0, 33, 10, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		headerValue  string
+		body         string
+		want         *api.APIKey
+		wantErr      error
+		wantStatus   int
+		wantResponse string
+	}{
+		{
+			name:        "success",
+			headerValue: "application/json",
+			body:        `{"name": " billing ", "scopes": ["send", "read", "send"]}`,
+			want: &api.APIKey{
+				Name:   "billing",
+				Scopes: []string{api.ScopeSend, api.ScopeRead},
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "fields set by the service are ignored",
+			headerValue: "application/json",
+			body: `{"id": 7, "name": "reports", "prefix": "ntf_1234", "key": "ntf_secret", "scopes": ["read"],
+				"created_at": "2020-01-01T00:00:00Z", "expires_at": "2035-05-24T00:33:10Z", "revoked_at": "2020-01-01T00:00:00Z"}`,
+			want: &api.APIKey{
+				Name:      "reports",
+				Scopes:    []string{api.ScopeRead},
+				ExpiresAt: &expiresAt,
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:         "no name",
+			headerValue:  "application/json",
+			body:         `{"name": " ", "scopes": ["read"]}`,
+			wantErr:      errNoAPIKeyName,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "The name of the client is required",
+		},
+		{
+			name:         "name too long",
+			headerValue:  "application/json",
+			body:         `{"name": "` + strings.Repeat("a", maxAPIKeyNameLength+1) + `", "scopes": ["read"]}`,
+			wantErr:      errAPIKeyNameTooLong,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "The name must not be longer than 64 characters",
+		},
+		{
+			name:         "no scopes",
+			headerValue:  "application/json",
+			body:         `{"name": "billing", "scopes": []}`,
+			wantErr:      errInvalidScopes,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "Scopes must be one or more of send, schedule, read and admin",
+		},
+		{
+			name:         "unknown scope",
+			headerValue:  "application/json",
+			body:         `{"name": "billing", "scopes": ["send", "delete"]}`,
+			wantErr:      errInvalidScopes,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "Scopes must be one or more of send, schedule, read and admin",
+		},
+		{
+			name:         "expiry in the past",
+			headerValue:  "application/json",
+			body:         `{"name": "billing", "scopes": ["send"], "expires_at": "2020-01-01T00:00:00Z"}`,
+			wantErr:      errExpiryNotAtFuture,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "The specified expiry time is not in the future",
+		},
+		{
+			name:         "wrong content type",
+			headerValue:  "text/plain",
+			body:         `{"name": "billing", "scopes": ["send"]}`,
+			wantErr:      errHeaderNotJSON,
+			wantStatus:   http.StatusUnsupportedMediaType,
+			wantResponse: "Content-Type must be application/json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/api-keys", strings.NewReader(tt.body))
+
+			r.Header.Set("Content-Type", tt.headerValue)
+
+			got, err := DecodeAPIKey(zap.NewNop(), r, w)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantResponse, problemDetail(t, w))
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -5,20 +5,41 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"net/mail"
+	"slices"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"go.uber.org/zap"
 
 	"notification/internal/SMTPClient"
 	"notification/internal/api"
+	"notification/internal/api/problem"
+	"notification/internal/webhook"
 )
 
 // emailTimeLayout required a time layout for checkTime function.
 const emailTimeLayout = "2006-01-02 15:04:05"
 
+// maxCategoryLength is the maximum length of the category.
+const maxCategoryLength = 64
+
+const (
+	// defaultMaxSubjectLength is the maximum number of characters in the subject if Config.MaxSubjectLength is not set,
+	// it is the line length limit of RFC 5322 section 2.1.1.
+	defaultMaxSubjectLength = 998
+
+	// defaultMaxMessageSize is the maximum size of the text and HTML bodies if Config.MaxMessageSize is not set.
+	defaultMaxMessageSize = 1 << 20
+
+	// defaultMaxRequestSize is the maximum size of the request body if Config.MaxRequestSize is not set,
+	// it leaves room for both bodies of the maximum size and the JSON escaping.
+	defaultMaxRequestSize = 4 << 20
+)
+
 var (
 	errNotAllFields            = errors.New("checkFields: request body not all required fields are filled")
 	errNoValidRecipientAddress = errors.New("checkFields: no valid recipient address found")
@@ -29,21 +50,61 @@ var (
 	errTimeNotAtFuture         = errors.New("checkTime: time not at future")
 	errNoValidTimeField        = errors.New("checkTime: no valid time field")
 	errUnknownError            = errors.New("unknown error")
+	errSenderNotAllowed        = errors.New("checkSender: sender is not in the list of allowed senders")
+	errNoValidReplyToAddress   = errors.New("checkSender: no valid reply-to address found")
+	errInvalidFromName         = errors.New("checkSender: sender name contains line breaks")
+	errInvalidHeader           = errors.New("checkCustomHeaders: invalid custom header")
+	errInvalidMessageID        = errors.New("checkThread: invalid Message-ID in in_reply_to or references")
+	errInvalidCategory         = errors.New("checkCategory: invalid category")
+	errTrackingWithoutHTML     = errors.New("checkFields: tracking requires the html body")
+	errTrackingDisabled        = errors.New("checkFields: tracking is requested, but it is disabled")
+	errInvalidCallbackURL      = errors.New("checkFields: invalid callback URL")
+	errWebhooksDisabled        = errors.New("checkFields: callback URL is set, but webhooks are disabled")
+	errInvalidSubject          = errors.New("checkFields: subject contains line breaks")
+	errSubjectTooLong          = errors.New("checkLimits: subject is too long")
+	errMessageTooLarge         = errors.New("checkLimits: message is too large")
+	errBodyTooLarge            = errors.New("decodeBody: request body is too large")
 )
 
+// Config defines the validation settings of the decoder.
+// AllowedSenders is the list of verified sender identities which can be used in the "from" field,
+// if it is empty, all emails are sent from the sender configured for the SMTP client.
+// MaxSubjectLength is the maximum number of characters in the subject, MaxMessageSize is the maximum size
+// of the text and HTML bodies in bytes, and MaxRequestSize is the maximum size of the request body in bytes,
+// the default limits are used if they are not set.
+// Webhooks reports whether the delivery status webhooks are enabled, it is set by config.New,
+// the callback URL is rejected if they are disabled. Tracking reports whether open and click tracking is enabled,
+// it is set by config.New, the track flag is rejected if it is disabled.
+type Config struct {
+	AllowedSenders   []string `env:"ALLOWED_SENDERS" env-separator:","`
+	MaxSubjectLength int      `env:"MAX_SUBJECT_LENGTH"`
+	MaxMessageSize   int      `env:"MAX_MESSAGE_SIZE"`
+	MaxRequestSize   int64    `env:"MAX_REQUEST_SIZE"`
+
+	Webhooks bool
+	Tracking bool
+}
+
 // decoder handles decoding and validation of HTTP requests.
+// The errors are written to the HTTP client as application/problem+json, see problem.Write,
+// r and w are nil if the notification is only validated, see ValidateNotification.
+// sendAtField is the name of the sending time field of the request, "time" if it is empty.
 type decoder struct {
-	logger *zap.Logger
-	r      *http.Request
-	w      http.ResponseWriter
+	config      *Config
+	logger      *zap.Logger
+	r           *http.Request
+	w           http.ResponseWriter
+	sendAtField string
 }
 
 // DecodeRequest parses and validates the incoming HTTP request body.
-// It checks the headers, required fields, recipient email address, and an optional time field (if needed).
+// It checks the headers, required fields, recipient email address, sender identity, custom headers,
+// and an optional time field (if needed).
 // On success, it returns a parsed EmailMessage struct.
 // On failure, it returns the corresponding error and writes an error message to the HTTP client.
-func DecodeRequest(logger *zap.Logger, r *http.Request, w http.ResponseWriter, sendingType string) (*SMTPClient.EmailMessage, error) {
+func DecodeRequest(config *Config, logger *zap.Logger, r *http.Request, w http.ResponseWriter, sendingType string) (*SMTPClient.EmailMessage, error) {
 	d := decoder{
+		config: config,
 		logger: logger,
 		r:      r,
 		w:      w,
@@ -72,7 +133,7 @@ func (d *decoder) checkHeaders() error {
 	ct := d.r.Header.Get("Content-Type")
 	if ct != "application/json" {
 		d.logger.Error(errHeaderNotJSON.Error())
-		http.Error(d.w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		problem.Write(d.w, d.r, http.StatusUnsupportedMediaType, problem.CodeUnsupportedMediaType, "Content-Type must be application/json")
 
 		return errHeaderNotJSON
 	}
@@ -80,20 +141,32 @@ func (d *decoder) checkHeaders() error {
 	return nil
 }
 
-// decodeBody reads and decodes the request body into a TempEmailMessage struct.
-// It returns an error if the body is empty or contains invalid JSON.
-func (d *decoder) decodeBody(email *SMTPClient.TempEmailMessage) error {
-	bodyBytes, err := io.ReadAll(d.r.Body)
+// decodeBody reads and decodes the request body into v (TempEmailMessage or Suppression).
+// It returns an error if the body is empty, larger than the limit of the Config or contains invalid JSON.
+func (d *decoder) decodeBody(v any) error {
+	limit := d.config.maxRequestSize()
+
+	bodyBytes, err := io.ReadAll(http.MaxBytesReader(d.w, d.r.Body, limit))
+
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		d.logger.Error(errBodyTooLarge.Error(), zap.Int64("limit", limit))
+		problem.Write(d.w, d.r, http.StatusRequestEntityTooLarge, problem.CodeBodyTooLarge,
+			fmt.Sprintf("Request body must not be larger than %d bytes", limit))
+
+		return errBodyTooLarge
+	}
+
 	if err != nil {
 		d.logger.Error("decodeBody: failed to read request body", zap.Error(err))
-		http.Error(d.w, "Failed to read request body", http.StatusInternalServerError)
+		problem.Write(d.w, d.r, http.StatusInternalServerError, problem.CodeInternal, "Failed to read request body")
 		return errUnknownError
 	}
 	defer d.r.Body.Close()
 
 	if len(bodyBytes) == 0 {
 		d.logger.Error(errEmptyBody.Error())
-		http.Error(d.w, "Request body must not be empty", http.StatusBadRequest)
+		problem.Write(d.w, d.r, http.StatusBadRequest, problem.CodeEmptyBody, "Request body must not be empty")
 		return errEmptyBody
 	}
 
@@ -101,66 +174,108 @@ func (d *decoder) decodeBody(email *SMTPClient.TempEmailMessage) error {
 
 	dec := json.NewDecoder(d.r.Body)
 	dec.DisallowUnknownFields()
-	return dec.Decode(email)
+	return dec.Decode(v)
 }
 
 // errDuringParse analyzes errors that occurred during JSON decoding,
 // returns the corresponding wrapped error.
+// The errors of reading the body are returned as is, they have already been written to the HTTP client.
 func (d *decoder) errDuringParse(err error) error {
-	if errors.Is(err, errEmptyBody) {
-		return errEmptyBody
+	if errors.Is(err, errEmptyBody) || errors.Is(err, errBodyTooLarge) || errors.Is(err, errUnknownError) {
+		return err
 	}
 
 	var syntaxError *json.SyntaxError
 	var unmarshalTypeError *json.UnmarshalTypeError
+	var timeError *time.ParseError
 
 	switch {
 	case errors.As(err, &syntaxError):
 		d.logger.Error(errSyntaxError.Error())
-		http.Error(d.w,
-			fmt.Sprintf("Request body contains badly-formed JSON (at position %d)", syntaxError.Offset),
-			http.StatusBadRequest)
+		problem.Write(d.w, d.r, http.StatusBadRequest, problem.CodeMalformedJSON,
+			fmt.Sprintf("Request body contains badly-formed JSON (at position %d)", syntaxError.Offset))
 
 		return errSyntaxError
 
 	case errors.As(err, &unmarshalTypeError):
 		d.logger.Error(errInvalidType.Error())
-		http.Error(d.w,
+		problem.Write(d.w, d.r, http.StatusBadRequest, problem.CodeInvalidType,
 			fmt.Sprintf(
 				"Request body contains an invalid value for the %q field (at position %d)",
 				unmarshalTypeError.Field, unmarshalTypeError.Offset),
-			http.StatusBadRequest)
+			unmarshalTypeError.Field)
 
 		return errInvalidType
 
+	case errors.As(err, &timeError):
+		d.logger.Error(errNoValidTimeField.Error())
+		problem.Write(d.w, d.r, http.StatusBadRequest, problem.CodeInvalidField, "The specified time is not a valid", d.timeField())
+
+		return errNoValidTimeField
+
 	default:
 		d.logger.Error(errUnknownError.Error())
-		http.Error(d.w, http.StatusText(500), http.StatusInternalServerError)
+		problem.Write(d.w, d.r, http.StatusInternalServerError, problem.CodeInternal, http.StatusText(500))
 
 		return errUnknownError
 	}
 }
 
-// checkFields checks that the fields in TempEmailMessage are not empty,
-// and validates recipient email address.
+// checkFields checks that the fields in TempEmailMessage are not empty and do not exceed the limits,
+// and validates recipient email address, sender identity, custom headers, threading Message-IDs and category.
+// Tracking is accepted only for emails with the HTML body if it is enabled, the callback URL must be an absolute http(s) URL
+// of a public host and is only accepted if webhooks are enabled.
+// All validation errors are collected and written to the HTTP client together, see report.
 func (d *decoder) checkFields(email *SMTPClient.TempEmailMessage, sendingType string) (*SMTPClient.TempEmailMessage, error) {
-	if email.To == "" || email.Subject == "" || email.Message == "" {
-		d.logger.Error(errNotAllFields.Error())
-		http.Error(d.w, "Not all fields in the request body are filled in", http.StatusBadRequest)
-		return nil, errNotAllFields
+	v := &validation{}
+
+	if missing := missingFields(email); len(missing) > 0 {
+		v.add(errNotAllFields, problem.CodeMissingFields, "Not all fields in the request body are filled in", missing...)
+	}
+
+	if email.To != "" {
+		if _, err := mail.ParseAddress(email.To); err != nil || strings.ContainsAny(email.To, "\r\n") {
+			v.add(errNoValidRecipientAddress, problem.CodeInvalidField, "No valid recipient address found", "to")
+		}
+	}
+
+	if strings.ContainsAny(email.Subject, "\r\n") {
+		v.add(errInvalidSubject, problem.CodeInvalidField, "The subject must not contain line breaks", "subject")
 	}
 
-	if _, err := mail.ParseAddress(email.To); err != nil {
-		d.logger.Error(errNoValidRecipientAddress.Error())
-		http.Error(d.w, "No valid recipient address found", http.StatusBadRequest)
-		return nil, errNoValidRecipientAddress
+	d.checkLimits(v, email)
+	d.checkSender(v, email)
+	d.checkCustomHeaders(v, email.Headers)
+	d.checkThread(v, email)
+	d.checkCategory(v, email.Category)
+
+	switch {
+	case !email.Track:
+
+	case !d.config.Tracking:
+		v.add(errTrackingDisabled, problem.CodeInvalidField, "Tracking cannot be enabled, it is disabled", "track")
+
+	case email.HTML == "":
+		v.add(errTrackingWithoutHTML, problem.CodeInvalidField, "Tracking can only be enabled for emails with the html body", "track", "html")
+	}
+
+	switch {
+	case email.CallbackURL == "":
+
+	case !d.config.Webhooks:
+		v.add(errWebhooksDisabled, problem.CodeInvalidField, "Callback URL cannot be set, webhooks are disabled", "callback_url")
+
+	case !webhook.ValidCallbackURL(email.CallbackURL):
+		v.add(errInvalidCallbackURL, problem.CodeInvalidField,
+			"Callback URL must be an absolute http(s) URL of a public host", "callback_url")
 	}
 
 	if sendingType == api.KeyForDelayedSending {
-		err := d.checkTime(email.Time)
-		if err != nil {
-			return nil, err
-		}
+		d.checkTime(v, email.Time)
+	}
+
+	if err := d.report(v); err != nil {
+		return nil, err
 	}
 
 	email.Type = sendingType
@@ -168,33 +283,205 @@ func (d *decoder) checkFields(email *SMTPClient.TempEmailMessage, sendingType st
 	return email, nil
 }
 
+// checkLimits checks that the subject, the text and the HTML bodies do not exceed the limits of the Config.
+func (d *decoder) checkLimits(v *validation, email *SMTPClient.TempEmailMessage) {
+	if limit := d.config.maxSubjectLength(); utf8.RuneCountInString(email.Subject) > limit {
+		v.add(errSubjectTooLong, problem.CodeTooLong,
+			fmt.Sprintf("The subject must not be longer than %d characters", limit), "subject")
+	}
+
+	limit := d.config.maxMessageSize()
+
+	if len(email.Message) > limit {
+		v.add(errMessageTooLarge, problem.CodeTooLong,
+			fmt.Sprintf("The message must not be larger than %d bytes", limit), "message")
+	}
+
+	if len(email.HTML) > limit {
+		v.add(errMessageTooLarge, problem.CodeTooLong,
+			fmt.Sprintf("The html body must not be larger than %d bytes", limit), "html")
+	}
+}
+
+// checkSender checks that the sender is one of the allowed identities,
+// the Reply-To address is valid and the display name does not contain line breaks.
+func (d *decoder) checkSender(v *validation, email *SMTPClient.TempEmailMessage) {
+	if email.From != "" {
+		from, err := mail.ParseAddress(email.From)
+		if err != nil || !d.isAllowedSender(from.Address) {
+			v.add(errSenderNotAllowed, problem.CodeSenderNotAllowed, "The specified sender is not allowed", "from")
+		} else {
+			email.From = from.Address
+		}
+	}
+
+	if strings.ContainsAny(email.FromName, "\r\n") {
+		v.add(errInvalidFromName, problem.CodeInvalidField, "The sender name must not contain line breaks", "from_name")
+	}
+
+	if email.ReplyTo != "" {
+		if _, err := mail.ParseAddress(email.ReplyTo); err != nil || strings.ContainsAny(email.ReplyTo, "\r\n") {
+			v.add(errNoValidReplyToAddress, problem.CodeInvalidField, "No valid reply-to address found", "reply_to")
+		}
+	}
+}
+
+// isAllowedSender reports whether the address is in the list of allowed senders, ignoring case.
+func (d *decoder) isAllowedSender(address string) bool {
+	for _, allowed := range d.config.AllowedSenders {
+		if strings.EqualFold(strings.TrimSpace(allowed), address) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkCustomHeaders checks that all custom headers have the X- prefix, valid names and single line values.
+// The headers are checked in the order of their names, so that the errors are reported in a stable order.
+func (d *decoder) checkCustomHeaders(v *validation, headers map[string]string) {
+	for _, name := range slices.Sorted(maps.Keys(headers)) {
+		if !isCustomHeaderName(name) {
+			v.add(errInvalidHeader, problem.CodeInvalidField,
+				fmt.Sprintf("Header %q is not allowed, only X-* headers can be set", name), "headers")
+
+			continue
+		}
+
+		if strings.ContainsAny(headers[name], "\r\n") {
+			v.add(errInvalidHeader, problem.CodeInvalidField,
+				fmt.Sprintf("Value of header %q must not contain line breaks", name), "headers")
+		}
+	}
+}
+
+// isCustomHeaderName reports whether the name starts with X- and consists of printable characters except colon,
+// see RFC 5322 section 2.2.
+func isCustomHeaderName(name string) bool {
+	if len(name) <= 2 || !strings.EqualFold(name[:2], "x-") {
+		return false
+	}
+
+	for _, c := range name {
+		if c <= ' ' || c > '~' || c == ':' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkThread checks that in_reply_to and references contain valid Message-IDs,
+// and encloses them in angle brackets if the client omitted them.
+func (d *decoder) checkThread(v *validation, email *SMTPClient.TempEmailMessage) {
+	if email.InReplyTo != "" {
+		if id, ok := normalizeMessageID(email.InReplyTo); ok {
+			email.InReplyTo = id
+		} else {
+			v.add(errInvalidMessageID, problem.CodeInvalidField,
+				fmt.Sprintf("Invalid Message-ID %q in in_reply_to or references", email.InReplyTo), "in_reply_to")
+		}
+	}
+
+	for i, ref := range email.References {
+		if id, ok := normalizeMessageID(ref); ok {
+			email.References[i] = id
+		} else {
+			v.add(errInvalidMessageID, problem.CodeInvalidField,
+				fmt.Sprintf("Invalid Message-ID %q in in_reply_to or references", ref), "references")
+		}
+	}
+}
+
+// normalizeMessageID returns the Message-ID enclosed in angle brackets
+// and reports whether it has the form <left@right> without whitespace, see RFC 5322 section 3.6.4.
+func normalizeMessageID(id string) (string, bool) {
+	id = strings.TrimSpace(id)
+	id = strings.TrimSuffix(strings.TrimPrefix(id, "<"), ">")
+
+	left, right, found := strings.Cut(id, "@")
+	if !found || left == "" || right == "" || strings.ContainsAny(left+right, "<>@ \t\r\n") {
+		return id, false
+	}
+
+	return "<" + id + ">", true
+}
+
+// checkCategory checks that the category consists of up to 64 lowercase letters, digits, '-' and '_'.
+func (d *decoder) checkCategory(v *validation, category string) {
+	valid := len(category) <= maxCategoryLength
+
+	for _, c := range category {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			valid = false
+			break
+		}
+	}
+
+	if !valid {
+		v.add(errInvalidCategory, problem.CodeInvalidField,
+			"Category must contain up to 64 lowercase letters, digits, '-' and '_'", "category")
+	}
+}
+
+// timeField returns the name of the sending time field of the request.
+func (d *decoder) timeField() string {
+	if d.sendAtField == "" {
+		return "time"
+	}
+
+	return d.sendAtField
+}
+
+// missingFields returns the names of the empty required fields of the email.
+func missingFields(email *SMTPClient.TempEmailMessage) []string {
+	var missing []string
+
+	if email.To == "" {
+		missing = append(missing, "to")
+	}
+
+	if email.Subject == "" {
+		missing = append(missing, "subject")
+	}
+
+	if email.Message == "" {
+		missing = append(missing, "message")
+	}
+
+	return missing
+}
+
 // checkTime checks the correctness of the time field and that it is in the future.
-func (d *decoder) checkTime(t string) error {
+func (d *decoder) checkTime(v *validation, t string) {
 	UTCTime, err := time.ParseInLocation(emailTimeLayout, t, time.UTC)
 	if err != nil {
-		d.logger.Info(errNoValidTimeField.Error())
-		http.Error(d.w, "The specified time is not a valid", http.StatusBadRequest)
-
-		return errNoValidTimeField
+		v.add(errNoValidTimeField, problem.CodeInvalidField, "The specified time is not a valid", d.timeField())
+		return
 	}
 
 	if !UTCTime.After(time.Now()) {
-		d.logger.Info(errTimeNotAtFuture.Error())
-		http.Error(d.w, "The specified time is not in the future", http.StatusBadRequest)
-
-		return errTimeNotAtFuture
+		v.add(errTimeNotAtFuture, problem.CodeTimeNotInFuture, "The specified time is not in the future", d.timeField())
 	}
-
-	return nil
 }
 
 // convert converts data from temporary struct TempEmailMessage to EmailMessage.
 func (d *decoder) convert(email *SMTPClient.TempEmailMessage) (*SMTPClient.EmailMessage, error) {
 	res := &SMTPClient.EmailMessage{
-		Type:    email.Type,
-		To:      email.To,
-		Subject: email.Subject,
-		Message: email.Message,
+		Type:        email.Type,
+		To:          email.To,
+		Subject:     email.Subject,
+		Message:     email.Message,
+		From:        email.From,
+		FromName:    email.FromName,
+		ReplyTo:     email.ReplyTo,
+		Headers:     email.Headers,
+		InReplyTo:   email.InReplyTo,
+		References:  email.References,
+		Category:    email.Category,
+		HTML:        email.HTML,
+		Track:       email.Track,
+		CallbackURL: email.CallbackURL,
 	}
 
 	if email.Time != "" {
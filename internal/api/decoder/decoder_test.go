@@ -1,6 +1,7 @@
 package decoder
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -8,16 +9,21 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
 	"notification/internal/SMTPClient"
 	"notification/internal/api"
+	"notification/internal/api/problem"
 )
 
 func TestDecoderEmailMessage(t *testing.T) {
+	config := &Config{AllowedSenders: []string{"support@example.com", " NoReply@Example.com"}, Webhooks: true, Tracking: true}
+
 	timeForSuccessDecodingWithTime, _ := time.ParseInLocation("2006-01-02 15:04:05", "2035-05-24 00:33:10", time.UTC)
 
 	tests := []struct {
+		config       *Config
 		name         string
 		headerKey    string
 		headerValue  string
@@ -60,7 +66,7 @@ func TestDecoderEmailMessage(t *testing.T) {
 			want:         nil,
 			wantErr:      errNotAllFields,
 			wantStatus:   http.StatusBadRequest,
-			wantResponse: "Not all fields in the request body are filled in\n",
+			wantResponse: "Not all fields in the request body are filled in",
 		},
 		{
 			name:         "empty body",
@@ -71,7 +77,7 @@ func TestDecoderEmailMessage(t *testing.T) {
 			want:         nil,
 			wantErr:      errEmptyBody,
 			wantStatus:   http.StatusBadRequest,
-			wantResponse: "Request body must not be empty\n",
+			wantResponse: "Request body must not be empty",
 		},
 		{
 			name:        "non json header",
@@ -86,7 +92,7 @@ func TestDecoderEmailMessage(t *testing.T) {
 			want:         nil,
 			wantErr:      errHeaderNotJSON,
 			wantStatus:   http.StatusUnsupportedMediaType,
-			wantResponse: "Content-Type must be application/json\n",
+			wantResponse: "Content-Type must be application/json",
 		},
 		{
 			name:        "non content-type header",
@@ -101,7 +107,7 @@ func TestDecoderEmailMessage(t *testing.T) {
 			want:         nil,
 			wantErr:      errHeaderNotJSON,
 			wantStatus:   http.StatusUnsupportedMediaType,
-			wantResponse: "Content-Type must be application/json\n",
+			wantResponse: "Content-Type must be application/json",
 		},
 		{
 			name:        "empty header",
@@ -116,7 +122,7 @@ func TestDecoderEmailMessage(t *testing.T) {
 			want:         nil,
 			wantErr:      errHeaderNotJSON,
 			wantStatus:   http.StatusUnsupportedMediaType,
-			wantResponse: "Content-Type must be application/json\n",
+			wantResponse: "Content-Type must be application/json",
 		},
 		{
 			name:        "invalid type",
@@ -131,7 +137,7 @@ func TestDecoderEmailMessage(t *testing.T) {
 			want:         nil,
 			wantErr:      errInvalidType,
 			wantStatus:   http.StatusBadRequest,
-			wantResponse: "Request body contains an invalid value for the \"to\" field (at position 16)\n",
+			wantResponse: "Request body contains an invalid value for the \"to\" field (at position 16)",
 		},
 		{
 			name:        "wrong syntax",
@@ -146,7 +152,7 @@ func TestDecoderEmailMessage(t *testing.T) {
 			want:         nil,
 			wantErr:      errSyntaxError,
 			wantStatus:   http.StatusBadRequest,
-			wantResponse: "Request body contains badly-formed JSON (at position 7)\n",
+			wantResponse: "Request body contains badly-formed JSON (at position 7)",
 		},
 		{
 			name:        "unknown error",
@@ -162,7 +168,7 @@ func TestDecoderEmailMessage(t *testing.T) {
 			want:         nil,
 			wantErr:      errUnknownError,
 			wantStatus:   http.StatusInternalServerError,
-			wantResponse: "Internal Server Error\n",
+			wantResponse: "Internal Server Error",
 		},
 		{
 			name:        "no valid to",
@@ -177,7 +183,7 @@ func TestDecoderEmailMessage(t *testing.T) {
 			want:         nil,
 			wantErr:      errNoValidRecipientAddress,
 			wantStatus:   http.StatusBadRequest,
-			wantResponse: "No valid recipient address found\n",
+			wantResponse: "No valid recipient address found",
 		},
 		{
 			name:        "success decoding with time",
@@ -215,7 +221,7 @@ func TestDecoderEmailMessage(t *testing.T) {
 			want:         nil,
 			wantErr:      errTimeNotAtFuture,
 			wantStatus:   http.StatusBadRequest,
-			wantResponse: "The specified time is not in the future\n",
+			wantResponse: "The specified time is not in the future",
 		},
 		{
 			name:        "no valid time field",
@@ -231,7 +237,7 @@ func TestDecoderEmailMessage(t *testing.T) {
 			want:         nil,
 			wantErr:      errNoValidTimeField,
 			wantStatus:   http.StatusBadRequest,
-			wantResponse: "The specified time is not a valid\n",
+			wantResponse: "The specified time is not a valid",
 		},
 		{
 			name:        "invalid field time",
@@ -247,7 +253,7 @@ func TestDecoderEmailMessage(t *testing.T) {
 			want:         nil,
 			wantErr:      errInvalidType,
 			wantStatus:   http.StatusBadRequest,
-			wantResponse: "Request body contains an invalid value for the \"time\" field (at position 18)\n",
+			wantResponse: "Request body contains an invalid value for the \"time\" field (at position 18)",
 		},
 		{
 			name:        "three fields with time",
@@ -262,7 +268,321 @@ func TestDecoderEmailMessage(t *testing.T) {
 			want:         nil,
 			wantErr:      errNotAllFields,
 			wantStatus:   http.StatusBadRequest,
-			wantResponse: "Not all fields in the request body are filled in\n",
+			wantResponse: "Not all fields in the request body are filled in",
+		},
+		{
+			name:        "success decoding with sender identity and custom headers",
+			headerKey:   "Content-Type",
+			headerValue: "application/json",
+			key:         api.KeyForInstantSending,
+			email: `{
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message",
+				"from": "noreply@example.com",
+				"from_name": "Example Team",
+				"reply_to": "Support <support@example.com>",
+				"headers": {"X-Campaign": "spring", "x-priority": "1"}
+			}`,
+			want: &SMTPClient.EmailMessage{
+				Type:     "instantSending",
+				To:       "example@gmail.com",
+				Subject:  "Subject",
+				Message:  "Message",
+				From:     "noreply@example.com",
+				FromName: "Example Team",
+				ReplyTo:  "Support <support@example.com>",
+				Headers:  map[string]string{"X-Campaign": "spring", "x-priority": "1"},
+			},
+			wantErr:      nil,
+			wantStatus:   http.StatusOK,
+			wantResponse: "",
+		},
+		{
+			name:        "sender not allowed",
+			headerKey:   "Content-Type",
+			headerValue: "application/json",
+			key:         api.KeyForInstantSending,
+			email: `{
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message",
+				"from": "ceo@example.com"
+			}`,
+			want:         nil,
+			wantErr:      errSenderNotAllowed,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "The specified sender is not allowed",
+		},
+		{
+			name:        "sender name with line breaks",
+			headerKey:   "Content-Type",
+			headerValue: "application/json",
+			key:         api.KeyForInstantSending,
+			email: `{
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message",
+				"from_name": "Team\r\nBcc: victim@example.com"
+			}`,
+			want:         nil,
+			wantErr:      errInvalidFromName,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "The sender name must not contain line breaks",
+		},
+		{
+			name:        "no valid reply-to address",
+			headerKey:   "Content-Type",
+			headerValue: "application/json",
+			key:         api.KeyForInstantSending,
+			email: `{
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message",
+				"reply_to": "support"
+			}`,
+			want:         nil,
+			wantErr:      errNoValidReplyToAddress,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "No valid reply-to address found",
+		},
+		{
+			name:        "non custom header",
+			headerKey:   "Content-Type",
+			headerValue: "application/json",
+			key:         api.KeyForInstantSending,
+			email: `{
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message",
+				"headers": {"Bcc": "victim@example.com"}
+			}`,
+			want:         nil,
+			wantErr:      errInvalidHeader,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "Header \"Bcc\" is not allowed, only X-* headers can be set",
+		},
+		{
+			name:        "custom header value with line breaks",
+			headerKey:   "Content-Type",
+			headerValue: "application/json",
+			key:         api.KeyForInstantSending,
+			email: `{
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message",
+				"headers": {"X-Campaign": "spring\nBcc: victim@example.com"}
+			}`,
+			want:         nil,
+			wantErr:      errInvalidHeader,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "Value of header \"X-Campaign\" must not contain line breaks",
+		},
+		{
+			name:        "success decoding with thread",
+			headerKey:   "Content-Type",
+			headerValue: "application/json",
+			key:         api.KeyForInstantSending,
+			email: `{
+				"to": "example@gmail.com",
+				"subject": "Re: Subject",
+				"message": "Message",
+				"in_reply_to": "notification.2@example.com",
+				"references": ["<notification.1@example.com>", "notification.2@example.com"]
+			}`,
+			want: &SMTPClient.EmailMessage{
+				Type:       "instantSending",
+				To:         "example@gmail.com",
+				Subject:    "Re: Subject",
+				Message:    "Message",
+				InReplyTo:  "<notification.2@example.com>",
+				References: []string{"<notification.1@example.com>", "<notification.2@example.com>"},
+			},
+			wantErr:      nil,
+			wantStatus:   http.StatusOK,
+			wantResponse: "",
+		},
+		{
+			name:        "invalid in_reply_to",
+			headerKey:   "Content-Type",
+			headerValue: "application/json",
+			key:         api.KeyForInstantSending,
+			email: `{
+				"to": "example@gmail.com",
+				"subject": "Re: Subject",
+				"message": "Message",
+				"in_reply_to": "notification.2"
+			}`,
+			want:         nil,
+			wantErr:      errInvalidMessageID,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "Invalid Message-ID \"notification.2\" in in_reply_to or references",
+		},
+		{
+			name:        "invalid references",
+			headerKey:   "Content-Type",
+			headerValue: "application/json",
+			key:         api.KeyForInstantSending,
+			email: `{
+				"to": "example@gmail.com",
+				"subject": "Re: Subject",
+				"message": "Message",
+				"references": ["<a@example.com> <b@example.com>"]
+			}`,
+			want:         nil,
+			wantErr:      errInvalidMessageID,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "Invalid Message-ID \"<a@example.com> <b@example.com>\" in in_reply_to or references",
+		},
+		{
+			name:        "success decoding with category",
+			headerKey:   "Content-Type",
+			headerValue: "application/json",
+			key:         api.KeyForInstantSending,
+			email: `{
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message",
+				"category": "weekly-digest_2"
+			}`,
+			want: &SMTPClient.EmailMessage{
+				Type:     "instantSending",
+				To:       "example@gmail.com",
+				Subject:  "Subject",
+				Message:  "Message",
+				Category: "weekly-digest_2",
+			},
+			wantErr:      nil,
+			wantStatus:   http.StatusOK,
+			wantResponse: "",
+		},
+		{
+			name:        "invalid category",
+			headerKey:   "Content-Type",
+			headerValue: "application/json",
+			key:         api.KeyForInstantSending,
+			email: `{
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message",
+				"category": "Weekly Digest"
+			}`,
+			want:         nil,
+			wantErr:      errInvalidCategory,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "Category must contain up to 64 lowercase letters, digits, '-' and '_'",
+		},
+		{
+			name:        "success decoding with tracked html",
+			headerKey:   "Content-Type",
+			headerValue: "application/json",
+			key:         api.KeyForInstantSending,
+			email: `{
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message",
+				"html": "<p>Message</p>",
+				"track": true
+			}`,
+			want: &SMTPClient.EmailMessage{
+				Type:    "instantSending",
+				To:      "example@gmail.com",
+				Subject: "Subject",
+				Message: "Message",
+				HTML:    "<p>Message</p>",
+				Track:   true,
+			},
+			wantErr:      nil,
+			wantStatus:   http.StatusOK,
+			wantResponse: "",
+		},
+		{
+			name:        "tracking without html",
+			headerKey:   "Content-Type",
+			headerValue: "application/json",
+			key:         api.KeyForInstantSending,
+			email: `{
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message",
+				"track": true
+			}`,
+			want:         nil,
+			wantErr:      errTrackingWithoutHTML,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "Tracking can only be enabled for emails with the html body",
+		},
+		{
+			name:        "tracking disabled",
+			config:      &Config{},
+			headerKey:   "Content-Type",
+			headerValue: "application/json",
+			key:         api.KeyForInstantSending,
+			email: `{
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message",
+				"html": "<p>Message</p>",
+				"track": true
+			}`,
+			want:         nil,
+			wantErr:      errTrackingDisabled,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "Tracking cannot be enabled, it is disabled",
+		},
+		{
+			name:        "success decoding with callback url",
+			headerKey:   "Content-Type",
+			headerValue: "application/json",
+			key:         api.KeyForInstantSending,
+			email: `{
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message",
+				"callback_url": "https://example.com/hooks/notifications"
+			}`,
+			want: &SMTPClient.EmailMessage{
+				Type:        "instantSending",
+				To:          "example@gmail.com",
+				Subject:     "Subject",
+				Message:     "Message",
+				CallbackURL: "https://example.com/hooks/notifications",
+			},
+			wantErr:      nil,
+			wantStatus:   http.StatusOK,
+			wantResponse: "",
+		},
+		{
+			name:        "invalid callback url",
+			headerKey:   "Content-Type",
+			headerValue: "application/json",
+			key:         api.KeyForInstantSending,
+			email: `{
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message",
+				"callback_url": "example.com/hooks"
+			}`,
+			want:         nil,
+			wantErr:      errInvalidCallbackURL,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "Callback URL must be an absolute http(s) URL of a public host",
+		},
+		{
+			name:        "callback url in the internal network",
+			headerKey:   "Content-Type",
+			headerValue: "application/json",
+			key:         api.KeyForInstantSending,
+			email: `{
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message",
+				"callback_url": "http://169.254.169.254/latest/meta-data"
+			}`,
+			want:         nil,
+			wantErr:      errInvalidCallbackURL,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "Callback URL must be an absolute http(s) URL of a public host",
 		},
 	}
 
@@ -273,12 +593,187 @@ func TestDecoderEmailMessage(t *testing.T) {
 
 			r.Header.Set(tt.headerKey, tt.headerValue)
 
-			got, err := DecodeRequest(zap.NewNop(), r, w, tt.key)
+			cfg := config
+			if tt.config != nil {
+				cfg = tt.config
+			}
+
+			got, err := DecodeRequest(cfg, zap.NewNop(), r, w, tt.key)
 
 			assert.Equal(t, tt.wantStatus, w.Code)
-			assert.Equal(t, tt.wantResponse, w.Body.String())
+			assert.Equal(t, tt.wantResponse, problemDetail(t, w))
 			assert.ErrorIs(t, tt.wantErr, err)
 			assert.Equal(t, tt.want, got)
 		})
 	}
 }
+
+func TestDecoderProblem(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        string
+		email      string
+		wantStatus int
+		wantCode   string
+		wantFields []string
+	}{
+		{
+			name:       "missing fields",
+			key:        api.KeyForInstantSending,
+			email:      `{"to": "example@gmail.com"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   problem.CodeMissingFields,
+			wantFields: []string{"subject", "message"},
+		},
+		{
+			name:       "invalid type",
+			key:        api.KeyForInstantSending,
+			email:      `{"to": 1}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   problem.CodeInvalidType,
+			wantFields: []string{"to"},
+		},
+		{
+			name:       "invalid reference",
+			key:        api.KeyForInstantSending,
+			email:      `{"to": "example@gmail.com", "subject": "Subject", "message": "Message", "references": ["notification.2"]}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   problem.CodeInvalidField,
+			wantFields: []string{"references"},
+		},
+		{
+			name:       "time in the past",
+			key:        api.KeyForDelayedSending,
+			email:      `{"to": "example@gmail.com", "subject": "Subject", "message": "Message", "time": "2020-05-24 00:33:10"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   problem.CodeTimeNotInFuture,
+			wantFields: []string{"time"},
+		},
+		{
+			name:       "callback url with webhooks disabled",
+			key:        api.KeyForInstantSending,
+			email:      `{"to": "example@gmail.com", "subject": "Subject", "message": "Message", "callback_url": "https://example.com/hook"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   problem.CodeInvalidField,
+			wantFields: []string{"callback_url"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/send-notification", strings.NewReader(tt.email))
+			r.Header.Set("Content-Type", "application/json")
+
+			_, err := DecodeRequest(&Config{}, zap.NewNop(), r, w, tt.key)
+			require.Error(t, err)
+
+			var got problem.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.wantStatus, got.Status)
+			assert.Equal(t, tt.wantCode, got.Code)
+			assert.Equal(t, tt.wantFields, got.Fields)
+			assert.Equal(t, "/send-notification", got.Instance)
+		})
+	}
+}
+
+func TestDecoderValidation(t *testing.T) {
+	config := &Config{MaxSubjectLength: 10, MaxMessageSize: 16, MaxRequestSize: 256}
+
+	tests := []struct {
+		name       string
+		key        string
+		email      string
+		wantErrs   []error
+		wantStatus int
+		wantCode   string
+		wantCodes  []string
+		wantFields []string
+	}{
+		{
+			name: "all errors at once",
+			key:  api.KeyForDelayedSending,
+			email: `{"to": "no-valid", "subject": "Subject\r\nBcc: x@example.com", "references": ["notification.2"],
+				"headers": {"Bcc": "x@example.com", "X-Campaign": "a\r\nb"}, "time": "2020-05-24 00:33:10"}`,
+			wantErrs: []error{errNotAllFields, errNoValidRecipientAddress, errInvalidSubject, errSubjectTooLong,
+				errInvalidHeader, errInvalidMessageID, errTimeNotAtFuture},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   problem.CodeValidationFailed,
+			wantCodes: []string{problem.CodeMissingFields, problem.CodeInvalidField, problem.CodeInvalidField, problem.CodeTooLong,
+				problem.CodeInvalidField, problem.CodeInvalidField, problem.CodeInvalidField, problem.CodeTimeNotInFuture},
+			wantFields: []string{"message", "to", "subject", "headers", "references", "time"},
+		},
+		{
+			name:       "subject too long",
+			key:        api.KeyForInstantSending,
+			email:      `{"to": "example@gmail.com", "subject": "Тема письма", "message": "Message"}`,
+			wantErrs:   []error{errSubjectTooLong},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   problem.CodeTooLong,
+			wantCodes:  []string{problem.CodeTooLong},
+			wantFields: []string{"subject"},
+		},
+		{
+			name:       "message and html too large",
+			key:        api.KeyForInstantSending,
+			email:      `{"to": "example@gmail.com", "subject": "Subject", "message": "Message is too large", "html": "<p>HTML is too large</p>"}`,
+			wantErrs:   []error{errMessageTooLarge},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   problem.CodeValidationFailed,
+			wantCodes:  []string{problem.CodeTooLong, problem.CodeTooLong},
+			wantFields: []string{"message", "html"},
+		},
+		{
+			name:       "request body too large",
+			key:        api.KeyForInstantSending,
+			email:      `{"to": "example@gmail.com", "subject": "Subject", "message": "` + strings.Repeat("a", 256) + `"}`,
+			wantErrs:   []error{errBodyTooLarge},
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantCode:   problem.CodeBodyTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/send-notification", strings.NewReader(tt.email))
+			r.Header.Set("Content-Type", "application/json")
+
+			got, err := DecodeRequest(config, zap.NewNop(), r, w, tt.key)
+			assert.Nil(t, got)
+
+			for _, wantErr := range tt.wantErrs {
+				assert.ErrorIs(t, err, wantErr)
+			}
+
+			var p problem.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+
+			var codes []string
+			for _, e := range p.Errors {
+				codes = append(codes, e.Code)
+			}
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantCode, p.Code)
+			assert.Equal(t, tt.wantCodes, codes)
+			assert.Equal(t, tt.wantFields, p.Fields)
+		})
+	}
+}
+
+// problemDetail returns the detail of the problem+json error response, or the body of other responses.
+func problemDetail(t *testing.T, w *httptest.ResponseRecorder) string {
+	if w.Header().Get("Content-Type") != problem.ContentType {
+		return w.Body.String()
+	}
+
+	var p problem.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+
+	return p.Detail
+}
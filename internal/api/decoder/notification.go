@@ -0,0 +1,111 @@
+package decoder
+
+import (
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"notification/internal/SMTPClient"
+	"notification/internal/api"
+)
+
+// notificationRequest is the request body of the notification created with the v2 API.
+// It has the same fields as the v1 request, except that the sending time is an optional RFC 3339 timestamp,
+// the notification is sent immediately if SendAt is omitted.
+type notificationRequest struct {
+	To       string            `json:"to"`
+	Subject  string            `json:"subject"`
+	Message  string            `json:"message"`
+	From     string            `json:"from,omitempty"`
+	FromName string            `json:"from_name,omitempty"`
+	ReplyTo  string            `json:"reply_to,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+
+	InReplyTo  string   `json:"in_reply_to,omitempty"`
+	References []string `json:"references,omitempty"`
+
+	Category string `json:"category,omitempty"`
+
+	HTML  string `json:"html,omitempty"`
+	Track bool   `json:"track,omitempty"`
+
+	CallbackURL string `json:"callback_url,omitempty"`
+
+	SendAt *time.Time `json:"send_at,omitempty"`
+}
+
+// DecodeNotification parses and validates the request body of the notification created with the v2 API.
+// The notification is scheduled if send_at is set, otherwise it is sent immediately,
+// the fields are validated the same way as by DecodeRequest.
+// On success, it returns a parsed EmailMessage struct.
+// On failure, it returns the corresponding error and writes an error message to the HTTP client.
+func DecodeNotification(config *Config, logger *zap.Logger, r *http.Request, w http.ResponseWriter) (*SMTPClient.EmailMessage, error) {
+	d := decoder{
+		config:      config,
+		logger:      logger,
+		r:           r,
+		w:           w,
+		sendAtField: "send_at",
+	}
+
+	if err := d.checkHeaders(); err != nil {
+		return nil, err
+	}
+
+	req := &notificationRequest{}
+
+	if err := d.decodeBody(req); err != nil {
+		return nil, d.errDuringParse(err)
+	}
+
+	email := &SMTPClient.TempEmailMessage{
+		To:          req.To,
+		Subject:     req.Subject,
+		Message:     req.Message,
+		From:        req.From,
+		FromName:    req.FromName,
+		ReplyTo:     req.ReplyTo,
+		Headers:     req.Headers,
+		InReplyTo:   req.InReplyTo,
+		References:  req.References,
+		Category:    req.Category,
+		HTML:        req.HTML,
+		Track:       req.Track,
+		CallbackURL: req.CallbackURL,
+	}
+
+	return d.validateNotification(email, req.SendAt)
+}
+
+// ValidateNotification validates the notification received without an HTTP request, like the gRPC API,
+// the same way as DecodeNotification: the notification is scheduled if sendAt is set, otherwise it is sent immediately.
+// On success, it returns a parsed EmailMessage struct.
+// On failure, it returns *ValidationError with all validation errors of the notification.
+func ValidateNotification(config *Config, logger *zap.Logger, email *SMTPClient.TempEmailMessage,
+	sendAt *time.Time) (*SMTPClient.EmailMessage, error) {
+	d := decoder{
+		config:      config,
+		logger:      logger,
+		sendAtField: "send_at",
+	}
+
+	return d.validateNotification(email, sendAt)
+}
+
+// validateNotification checks the fields of the notification and the optional sending time, and converts it to EmailMessage.
+func (d *decoder) validateNotification(email *SMTPClient.TempEmailMessage, sendAt *time.Time) (*SMTPClient.EmailMessage, error) {
+	sendingType := api.KeyForInstantSending
+
+	if sendAt != nil {
+		sendingType = api.KeyForDelayedSending
+		email.Time = sendAt.UTC().Format(emailTimeLayout)
+	}
+
+	email, err := d.checkFields(email, sendingType)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.convert(email)
+}
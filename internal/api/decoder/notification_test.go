@@ -0,0 +1,191 @@
+package decoder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"notification/internal/SMTPClient"
+	"notification/internal/api"
+	"notification/internal/api/problem"
+)
+
+func TestDecodeNotification(t *testing.T) {
+	config := &Config{AllowedSenders: []string{"support@example.com"}}
+
+	sendAt := time.Date(2035, 5, 24, 0, 33, 10, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		headerValue  string
+		body         string
+		want         *SMTPClient.EmailMessage
+		wantErr      error
+		wantStatus   int
+		wantResponse string
+	}{
+		{
+			name:        "instant notification",
+			headerValue: "application/json",
+			body:        `{"to": "example@gmail.com", "subject": "Subject", "message": "Message", "from": "support@example.com"}`,
+			want: &SMTPClient.EmailMessage{
+				Type:    api.KeyForInstantSending,
+				To:      "example@gmail.com",
+				Subject: "Subject",
+				Message: "Message",
+				From:    "support@example.com",
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "scheduled notification",
+			headerValue: "application/json",
+			body:        `{"to": "example@gmail.com", "subject": "Subject", "message": "Message", "send_at": "2035-05-24T03:33:10+03:00"}`,
+			want: &SMTPClient.EmailMessage{
+				Type:    api.KeyForDelayedSending,
+				Time:    &sendAt,
+				To:      "example@gmail.com",
+				Subject: "Subject",
+				Message: "Message",
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:         "send_at in the past",
+			headerValue:  "application/json",
+			body:         `{"to": "example@gmail.com", "subject": "Subject", "message": "Message", "send_at": "2020-05-24T00:33:10Z"}`,
+			wantErr:      errTimeNotAtFuture,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "The specified time is not in the future",
+		},
+		{
+			name:         "invalid send_at",
+			headerValue:  "application/json",
+			body:         `{"to": "example@gmail.com", "subject": "Subject", "message": "Message", "send_at": "2035-05-24 00:33:10"}`,
+			wantErr:      errNoValidTimeField,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "The specified time is not a valid",
+		},
+		{
+			name:         "v1 time field",
+			headerValue:  "application/json",
+			body:         `{"to": "example@gmail.com", "subject": "Subject", "message": "Message", "time": "2035-05-24 00:33:10"}`,
+			wantErr:      errUnknownError,
+			wantStatus:   http.StatusInternalServerError,
+			wantResponse: "Internal Server Error",
+		},
+		{
+			name:         "missing fields",
+			headerValue:  "application/json",
+			body:         `{"to": "example@gmail.com"}`,
+			wantErr:      errNotAllFields,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "Not all fields in the request body are filled in",
+		},
+		{
+			name:         "non json header",
+			headerValue:  "text/plain",
+			body:         `{"to": "example@gmail.com", "subject": "Subject", "message": "Message"}`,
+			wantErr:      errHeaderNotJSON,
+			wantStatus:   http.StatusUnsupportedMediaType,
+			wantResponse: "Content-Type must be application/json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/v2/notifications", strings.NewReader(tt.body))
+
+			r.Header.Set("Content-Type", tt.headerValue)
+
+			got, err := DecodeNotification(config, zap.NewNop(), r, w)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantResponse, problemDetail(t, w))
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestValidateNotification(t *testing.T) {
+	config := &Config{AllowedSenders: []string{"support@example.com"}}
+
+	sendAt := time.Date(2035, 5, 24, 0, 33, 10, 0, time.UTC)
+	past := time.Date(2020, 5, 24, 0, 33, 10, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		email        *SMTPClient.TempEmailMessage
+		sendAt       *time.Time
+		want         *SMTPClient.EmailMessage
+		wantErr      error
+		wantProblems []problem.Error
+	}{
+		{
+			name:  "instant notification",
+			email: &SMTPClient.TempEmailMessage{To: "example@gmail.com", Subject: "Subject", Message: "Message"},
+			want: &SMTPClient.EmailMessage{
+				Type:    api.KeyForInstantSending,
+				To:      "example@gmail.com",
+				Subject: "Subject",
+				Message: "Message",
+			},
+		},
+		{
+			name:   "scheduled notification",
+			email:  &SMTPClient.TempEmailMessage{To: "example@gmail.com", Subject: "Subject", Message: "Message"},
+			sendAt: &sendAt,
+			want: &SMTPClient.EmailMessage{
+				Type:    api.KeyForDelayedSending,
+				Time:    &sendAt,
+				To:      "example@gmail.com",
+				Subject: "Subject",
+				Message: "Message",
+			},
+		},
+		{
+			name:    "send_at in the past",
+			email:   &SMTPClient.TempEmailMessage{To: "example@gmail.com", Subject: "Subject", Message: "Message"},
+			sendAt:  &past,
+			wantErr: errTimeNotAtFuture,
+			wantProblems: []problem.Error{
+				{Code: problem.CodeTimeNotInFuture, Detail: "The specified time is not in the future", Fields: []string{"send_at"}},
+			},
+		},
+		{
+			name:    "several errors",
+			email:   &SMTPClient.TempEmailMessage{To: "example", Message: "Message", From: "other@example.com"},
+			wantErr: errNotAllFields,
+			wantProblems: []problem.Error{
+				{Code: problem.CodeMissingFields, Detail: "Not all fields in the request body are filled in", Fields: []string{"subject"}},
+				{Code: problem.CodeInvalidField, Detail: "No valid recipient address found", Fields: []string{"to"}},
+				{Code: problem.CodeSenderNotAllowed, Detail: "The specified sender is not allowed", Fields: []string{"from"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateNotification(config, zap.NewNop(), tt.email, tt.sendAt)
+
+			assert.Equal(t, tt.want, got)
+			assert.ErrorIs(t, err, tt.wantErr)
+
+			if tt.wantErr == nil {
+				return
+			}
+
+			var validationErr *ValidationError
+			if assert.ErrorAs(t, err, &validationErr) {
+				assert.Equal(t, tt.wantProblems, validationErr.Problems)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package decoder
+
+import (
+	"errors"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"notification/internal/api"
+	"notification/internal/api/problem"
+)
+
+var (
+	errNoValidSuppressionAddress = errors.New("checkSuppression: no valid suppressed address found")
+	errInvalidSuppressionReason  = errors.New("checkSuppression: invalid suppression reason")
+	errExpiryNotAtFuture         = errors.New("checkSuppression: expiry time not at future")
+)
+
+// DecodeSuppression parses and validates the request body of the suppression list entry.
+// The address is normalized to lowercase, the source defaults to "api", and the creation time is ignored.
+// On success, it returns the parsed Suppression struct.
+// On failure, it returns the corresponding error and writes an error message to the HTTP client.
+func DecodeSuppression(logger *zap.Logger, r *http.Request, w http.ResponseWriter) (*api.Suppression, error) {
+	d := decoder{
+		logger: logger,
+		r:      r,
+		w:      w,
+	}
+
+	if err := d.checkHeaders(); err != nil {
+		return nil, err
+	}
+
+	suppression := &api.Suppression{}
+
+	if err := d.decodeBody(suppression); err != nil {
+		return nil, d.errDuringParse(err)
+	}
+
+	if err := d.checkSuppression(suppression); err != nil {
+		return nil, err
+	}
+
+	return suppression, nil
+}
+
+// checkSuppression validates the address, reason and expiry time of the suppression list entry.
+// All validation errors are collected and written to the HTTP client together, see report.
+func (d *decoder) checkSuppression(suppression *api.Suppression) error {
+	v := &validation{}
+
+	if addr, err := mail.ParseAddress(suppression.Address); err != nil {
+		v.add(errNoValidSuppressionAddress, problem.CodeInvalidField, "No valid address found", "address")
+	} else {
+		suppression.Address = strings.ToLower(addr.Address)
+	}
+
+	switch suppression.Reason {
+	case api.SuppressionReasonHardBounce, api.SuppressionReasonComplaint, api.SuppressionReasonManual:
+
+	default:
+		v.add(errInvalidSuppressionReason, problem.CodeInvalidField, "Reason must be one of hard_bounce, complaint or manual", "reason")
+	}
+
+	if suppression.ExpiresAt != nil && !suppression.ExpiresAt.After(time.Now()) {
+		v.add(errExpiryNotAtFuture, problem.CodeTimeNotInFuture, "The specified expiry time is not in the future", "expires_at")
+	}
+
+	if err := d.report(v); err != nil {
+		return err
+	}
+
+	if suppression.Source == "" {
+		suppression.Source = api.SuppressionSourceAPI
+	}
+
+	suppression.CreatedAt = time.Time{}
+
+	return nil
+}
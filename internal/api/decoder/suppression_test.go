@@ -0,0 +1,101 @@
+package decoder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"notification/internal/api"
+)
+
+func TestDecodeSuppression(t *testing.T) {
+	expiresAt := time.Date(2035, 5, 24, 0, 33, 10, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		headerValue  string
+		body         string
+		want         *api.Suppression
+		wantErr      error
+		wantStatus   int
+		wantResponse string
+	}{
+		{
+			name:        "success",
+			headerValue: "application/json",
+			body:        `{"address": "User <User@Example.com>", "reason": "hard_bounce"}`,
+			want: &api.Suppression{
+				Address: "user@example.com",
+				Reason:  api.SuppressionReasonHardBounce,
+				Source:  api.SuppressionSourceAPI,
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:        "with source and expiry",
+			headerValue: "application/json",
+			body: `{"address": "user@example.com", "reason": "manual", "source": "support",
+				"created_at": "2020-01-01T00:00:00Z", "expires_at": "2035-05-24T00:33:10Z"}`,
+			want: &api.Suppression{
+				Address:   "user@example.com",
+				Reason:    api.SuppressionReasonManual,
+				Source:    "support",
+				ExpiresAt: &expiresAt,
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:         "invalid address",
+			headerValue:  "application/json",
+			body:         `{"address": "user", "reason": "manual"}`,
+			wantErr:      errNoValidSuppressionAddress,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "No valid address found",
+		},
+		{
+			name:         "invalid reason",
+			headerValue:  "application/json",
+			body:         `{"address": "user@example.com", "reason": "soft_bounce"}`,
+			wantErr:      errInvalidSuppressionReason,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "Reason must be one of hard_bounce, complaint or manual",
+		},
+		{
+			name:         "expiry in the past",
+			headerValue:  "application/json",
+			body:         `{"address": "user@example.com", "reason": "complaint", "expires_at": "2020-01-01T00:00:00Z"}`,
+			wantErr:      errExpiryNotAtFuture,
+			wantStatus:   http.StatusBadRequest,
+			wantResponse: "The specified expiry time is not in the future",
+		},
+		{
+			name:         "wrong content type",
+			headerValue:  "text/plain",
+			body:         `{"address": "user@example.com", "reason": "manual"}`,
+			wantErr:      errHeaderNotJSON,
+			wantStatus:   http.StatusUnsupportedMediaType,
+			wantResponse: "Content-Type must be application/json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/suppressions", strings.NewReader(tt.body))
+
+			r.Header.Set("Content-Type", tt.headerValue)
+
+			got, err := DecodeSuppression(zap.NewNop(), r, w)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantResponse, problemDetail(t, w))
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
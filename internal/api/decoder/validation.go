@@ -0,0 +1,90 @@
+package decoder
+
+import (
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"notification/internal/api/problem"
+)
+
+// validation collects the validation errors of the request body, so that all of them are reported at once.
+type validation struct {
+	errs     []error
+	problems []problem.Error
+}
+
+// add records the validation error with the problem code, human-readable detail and the offending fields.
+func (v *validation) add(err error, code, detail string, fields ...string) {
+	v.errs = append(v.errs, err)
+	v.problems = append(v.problems, problem.Error{Code: code, Detail: detail, Fields: fields})
+}
+
+// ValidationError is returned by ValidateNotification if the notification is not valid,
+// Problems lists every validation error with its problem code, detail and the offending fields.
+type ValidationError struct {
+	Err      error
+	Problems []problem.Error
+}
+
+// Error returns the description of the validation errors.
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the single validation error, or the joined validation errors.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// report writes the collected validation errors to the HTTP client with 400 Bad Request, see problem.WriteErrors.
+// It returns the single error as is, several errors joined, or nil if the request body is valid.
+// Without the HTTP client, the errors are returned as *ValidationError.
+func (d *decoder) report(v *validation) error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+
+	err := v.errs[0]
+	if len(v.errs) > 1 {
+		err = errors.Join(v.errs...)
+	}
+
+	d.logger.Error("report: request body is not valid", zap.Error(err))
+
+	if d.w == nil {
+		return &ValidationError{Err: err, Problems: v.problems}
+	}
+
+	problem.WriteErrors(d.w, d.r, http.StatusBadRequest, v.problems)
+
+	return err
+}
+
+// maxSubjectLength returns the maximum number of characters in the subject.
+func (c *Config) maxSubjectLength() int {
+	if c == nil || c.MaxSubjectLength <= 0 {
+		return defaultMaxSubjectLength
+	}
+
+	return c.MaxSubjectLength
+}
+
+// maxMessageSize returns the maximum size of the text and HTML bodies in bytes.
+func (c *Config) maxMessageSize() int {
+	if c == nil || c.MaxMessageSize <= 0 {
+		return defaultMaxMessageSize
+	}
+
+	return c.MaxMessageSize
+}
+
+// maxRequestSize returns the maximum size of the request body in bytes.
+func (c *Config) maxRequestSize() int64 {
+	if c == nil || c.MaxRequestSize <= 0 {
+		return defaultMaxRequestSize
+	}
+
+	return c.MaxRequestSize
+}
@@ -0,0 +1,128 @@
+package grpcServer
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"notification/internal/SMTPClient"
+	"notification/internal/api"
+	"notification/internal/api/problem"
+	"notification/internal/api/service"
+	notificationv1 "notification/proto/notification/v1"
+)
+
+// tempEmail converts the message of the request to TempEmailMessage to be validated,
+// the missing message results in the missing fields error.
+func tempEmail(msg *notificationv1.Message) *SMTPClient.TempEmailMessage {
+	return &SMTPClient.TempEmailMessage{
+		To:          msg.GetTo(),
+		Subject:     msg.GetSubject(),
+		Message:     msg.GetMessage(),
+		From:        msg.GetFrom(),
+		FromName:    msg.GetFromName(),
+		ReplyTo:     msg.GetReplyTo(),
+		Headers:     msg.GetHeaders(),
+		InReplyTo:   msg.GetInReplyTo(),
+		References:  msg.GetReferences(),
+		Category:    msg.GetCategory(),
+		HTML:        msg.GetHtml(),
+		Track:       msg.GetTrack(),
+		CallbackURL: msg.GetCallbackUrl(),
+	}
+}
+
+// notificationProto converts the saved notification to the response message.
+func notificationProto(n *api.Notification) *notificationv1.Notification {
+	res := &notificationv1.Notification{
+		Id:          int64(n.ID),
+		Type:        n.Type,
+		Status:      n.Status,
+		To:          n.To,
+		Subject:     n.Subject,
+		Message:     n.Message,
+		From:        n.From,
+		FromName:    n.FromName,
+		ReplyTo:     n.ReplyTo,
+		Headers:     n.Headers,
+		MessageId:   n.MessageID,
+		InReplyTo:   n.InReplyTo,
+		References:  n.References,
+		Category:    n.Category,
+		Html:        n.HTML,
+		Track:       n.Track,
+		CallbackUrl: n.CallbackURL,
+		Client:      n.Client,
+		Tenant:      n.Tenant,
+		CreatedAt:   timestamppb.New(n.CreatedAt),
+	}
+
+	if n.Time != nil {
+		res.Time = timestamppb.New(*n.Time)
+	}
+
+	if n.SentAt != nil {
+		res.SentAt = timestamppb.New(*n.SentAt)
+	}
+
+	for _, event := range n.Events {
+		res.Events = append(res.Events, &notificationv1.TrackingEvent{
+			Type:      event.Type,
+			Url:       event.URL,
+			CreatedAt: timestamppb.New(event.CreatedAt),
+		})
+	}
+
+	return res
+}
+
+// listFilter returns the filter of the page of notifications, it is validated the same way
+// as the query parameters of the HTTP API.
+func listFilter(req *notificationv1.ListRequest) (*api.ListFilter, error) {
+	filter := &api.ListFilter{
+		Type:    req.GetType(),
+		Status:  req.GetStatus(),
+		Subject: req.GetSubject(),
+		Desc:    req.GetDesc(),
+		Limit:   api.DefaultListLimit,
+	}
+
+	if req.GetLimit() != 0 {
+		if req.GetLimit() < 1 || req.GetLimit() > api.MaxListLimit {
+			return nil, problemError(codes.InvalidArgument, problem.CodeInvalidQuery,
+				fmt.Sprintf("limit must be an integer from 1 to %d", api.MaxListLimit), "limit")
+		}
+
+		filter.Limit = int(req.GetLimit())
+	}
+
+	if req.GetCursor() != "" {
+		id, err := service.DecodeCursor(req.GetCursor())
+		if err != nil {
+			return nil, problemError(codes.InvalidArgument, problem.CodeInvalidQuery, "invalid cursor", "cursor")
+		}
+
+		filter.AfterID = id
+	}
+
+	if filter.Type != "" && !api.ValidType(filter.Type) {
+		return nil, problemError(codes.InvalidArgument, problem.CodeInvalidQuery, "unknown type", "type")
+	}
+
+	if filter.Status != "" && !api.ValidStatus(filter.Status) {
+		return nil, problemError(codes.InvalidArgument, problem.CodeInvalidQuery, "unknown status", "status")
+	}
+
+	if req.GetSentAfter() != nil {
+		t := req.GetSentAfter().AsTime()
+		filter.SentAfter = &t
+	}
+
+	if req.GetSentBefore() != nil {
+		t := req.GetSentBefore().AsTime()
+		filter.SentBefore = &t
+	}
+
+	return filter, nil
+}
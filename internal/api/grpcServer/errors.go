@@ -0,0 +1,189 @@
+package grpcServer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"notification/internal/SMTPClient"
+	"notification/internal/api"
+	"notification/internal/api/decoder"
+	"notification/internal/api/problem"
+	"notification/internal/api/service"
+	notificationv1 "notification/proto/notification/v1"
+)
+
+// internalDetail is the message of the Internal errors, the cause is only logged.
+const internalDetail = "Internal error"
+
+// problemError returns the gRPC status error with the problem code of the HTTP API in google.rpc.ErrorInfo,
+// and the offending fields of the request in google.rpc.BadRequest.
+func problemError(code codes.Code, problemCode, detail string, fields ...string) error {
+	return newStatus(code, problemCode, detail, []problem.Error{{Code: problemCode, Detail: detail, Fields: fields}})
+}
+
+// newStatus returns the gRPC status error with the problem code and the fields of every problem,
+// the extra details are appended to the status details.
+func newStatus(code codes.Code, problemCode, detail string, problems []problem.Error, extra ...protoadapt.MessageV1) error {
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: problemCode, Domain: errorDomain}}
+
+	badRequest := &errdetails.BadRequest{}
+
+	for _, p := range problems {
+		for _, field := range p.Fields {
+			badRequest.FieldViolations = append(badRequest.FieldViolations,
+				&errdetails.BadRequest_FieldViolation{Field: field, Description: p.Detail})
+		}
+	}
+
+	if len(badRequest.FieldViolations) > 0 {
+		details = append(details, badRequest)
+	}
+
+	st := status.New(code, detail)
+
+	withDetails, err := st.WithDetails(append(details, extra...)...)
+	if err != nil {
+		return st.Err()
+	}
+
+	return withDetails.Err()
+}
+
+// problemDetails returns the problem code, the message and the offending fields of the gRPC status error.
+func problemDetails(err error) (string, string, []string) {
+	st := status.Convert(err)
+
+	code := problem.CodeInternal
+
+	var fields []string
+
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			code = d.GetReason()
+
+		case *errdetails.BadRequest:
+			for _, violation := range d.GetFieldViolations() {
+				if !slices.Contains(fields, violation.GetField()) {
+					fields = append(fields, violation.GetField())
+				}
+			}
+		}
+	}
+
+	return code, st.Message(), fields
+}
+
+// validationError returns InvalidArgument with every validation error of decoder.ValidationError,
+// the code and the message are the same as in the problem+json response of the HTTP API, see problem.WriteErrors.
+func validationError(err error) error {
+	var validationErr *decoder.ValidationError
+	if !errors.As(err, &validationErr) {
+		return problemError(codes.Internal, problem.CodeInternal, internalDetail)
+	}
+
+	problems := validationErr.Problems
+
+	if len(problems) == 1 {
+		return newStatus(codes.InvalidArgument, problems[0].Code, problems[0].Detail, problems)
+	}
+
+	return newStatus(codes.InvalidArgument, problem.CodeValidationFailed,
+		fmt.Sprintf("Request contains %d validation errors", len(problems)), problems)
+}
+
+// sendError returns the error of the notification that was not sent by service.Service.Send the same way
+// as the HTTP handlers: suppressed and rejected recipients result in FailedPrecondition,
+// an open SMTP circuit breaker in Unavailable with google.rpc.RetryInfo. It returns nil if the notification was sent.
+func (s *notificationServer) sendError(ctx context.Context, id int, status string, err error) error {
+	var circuitOpen *SMTPClient.CircuitOpenError
+
+	switch {
+	case status == api.StatusSuppressed:
+		s.logger.Warn("sendError: Recipient is suppressed, notification rejected", zap.Int("id", id))
+		return problemError(codes.FailedPrecondition, problem.CodeRecipientSuppressed, "The recipient is on the suppression list", "to")
+
+	case errors.As(err, &circuitOpen):
+		s.logger.Warn("sendError: SMTP circuit breaker is open", zap.Error(err))
+		return newStatus(codes.Unavailable, problem.CodeSMTPUnavailable, "SMTP is unavailable", nil,
+			&errdetails.RetryInfo{RetryDelay: durationpb.New(circuitOpen.RetryAfter)})
+
+	case errors.Is(err, SMTPClient.ErrPermanentFailure):
+		s.logger.Error("sendError: Notification rejected permanently", zap.Error(err))
+		return problemError(codes.FailedPrecondition, problem.CodeRecipientRejected, "The recipient was rejected by the mail server", "to")
+
+	case status == "" || err != nil:
+		s.logger.Error("sendError: Cannot send notification", zap.Error(err))
+		return contextOrInternalError(ctx, err)
+
+	default:
+		return nil
+	}
+}
+
+// notificationError returns the error of fetching, listing or canceling notifications the same way as the HTTP handlers.
+func (s *notificationServer) notificationError(err error, method string) error {
+	switch {
+	case errors.Is(err, errInvalidID):
+		s.logger.Warn(method+": invalid request", zap.Error(err))
+		return problemError(codes.InvalidArgument, problem.CodeInvalidQuery, err.Error(), "id")
+
+	case errors.Is(err, service.ErrNotFound):
+		s.logger.Warn(method+": notification not found", zap.Error(err))
+		return problemError(codes.NotFound, problem.CodeNotFound, "Notification not found")
+
+	case errors.Is(err, service.ErrNotCancelable):
+		s.logger.Warn(method+": notification cannot be canceled", zap.Error(err))
+		return problemError(codes.FailedPrecondition, problem.CodeNotCancelable, "Only pending scheduled notifications can be canceled")
+
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		s.logger.Info(method+": Context ended", zap.Error(err))
+		return contextError(err)
+
+	default:
+		s.logger.Error(method+": cannot access postgres", zap.Error(err))
+		return problemError(codes.Internal, problem.CodeInternal, internalDetail)
+	}
+}
+
+// contextOrInternalError returns the context error if ctx is done, otherwise Internal.
+func contextOrInternalError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return contextError(ctx.Err())
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return contextError(err)
+	}
+
+	return problemError(codes.Internal, problem.CodeInternal, internalDetail)
+}
+
+// withResults attaches the results of the notifications of the broken BatchSend stream processed so far
+// to the status details of the gRPC error, so that the client can tell which notifications were saved.
+func withResults(err error, resp *notificationv1.BatchSendResponse) error {
+	withDetails, detailsErr := status.Convert(err).WithDetails(resp)
+	if detailsErr != nil {
+		return err
+	}
+
+	return withDetails.Err()
+}
+
+// contextError returns Canceled or DeadlineExceeded for the context error.
+func contextError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return problemError(codes.DeadlineExceeded, problem.CodeTimeout, "Deadline exceeded")
+	}
+
+	return problemError(codes.Canceled, problem.CodeRequestCanceled, "Request canceled")
+}
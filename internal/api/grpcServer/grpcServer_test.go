@@ -0,0 +1,749 @@
+package grpcServer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"notification/internal/SMTPClient"
+	"notification/internal/api"
+	"notification/internal/api/decoder"
+	"notification/internal/api/problem"
+	"notification/internal/api/service"
+	"notification/internal/auth"
+	"notification/internal/config"
+	"notification/internal/monitoring"
+	"notification/internal/storage/postgresClient"
+	"notification/internal/storage/redisClient"
+	notificationv1 "notification/proto/notification/v1"
+)
+
+// testMetrics are shared by the tests, because the metrics are registered globally.
+var testMetrics = monitoring.New("GRPCTest")
+
+// testAdminKey is the admin key the test clients are authenticated with, unless another key is specified.
+const testAdminKey = "admin-secret"
+
+// mocks are the dependencies of the service layer of the test server.
+type mocks struct {
+	sender   *SMTPClient.MockEmailSender
+	redis    *redisClient.MockRedisClient
+	postgres *postgresClient.MockPostgresService
+}
+
+// newTestClient starts the gRPC server with the mocks and returns the client connected to it with the admin key.
+func newTestClient(t *testing.T, m *mocks, serverConfig *api.GRPCServer) notificationv1.NotificationServiceClient {
+	return newTestClientWithKey(t, m, serverConfig, testAdminKey)
+}
+
+// newTestClientWithKey starts the gRPC server with the mocks and returns the client connected to it,
+// the calls of the client are sent with the API key, unless it is empty.
+func newTestClientWithKey(t *testing.T, m *mocks, serverConfig *api.GRPCServer, key string) notificationv1.NotificationServiceClient {
+	svc := service.New(m.sender, m.redis, m.postgres, nil, nil, zap.NewNop())
+
+	authenticator := auth.New(&auth.Config{AdminKey: testAdminKey}, m.postgres, monitoring.NewNop(), zap.NewNop())
+
+	srv := New(svc, authenticator, &decoder.Config{}, serverConfig, config.AppTimeouts{}, 3*time.Second, testMetrics, zap.NewNop())
+
+	listener := bufconn.Listen(1 << 20)
+
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn,
+			invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(withKey(ctx, key), method, req, reply, cc, opts...)
+		}),
+		grpc.WithStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string,
+			streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return streamer(withKey(ctx, key), desc, cc, method, opts...)
+		}),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return notificationv1.NewNotificationServiceClient(conn)
+}
+
+// withKey returns the context with the API key in the outgoing metadata, unless the key is empty.
+func withKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+key)
+}
+
+// newMocks returns the new mocks of the service layer dependencies.
+func newMocks() *mocks {
+	return &mocks{
+		sender:   &SMTPClient.MockEmailSender{},
+		redis:    &redisClient.MockRedisClient{},
+		postgres: &postgresClient.MockPostgresService{},
+	}
+}
+
+// assertProblem checks the gRPC status code, the problem code and the offending fields of the error.
+func assertProblem(t *testing.T, err error, wantCode codes.Code, wantProblem string, wantFields []string) {
+	t.Helper()
+
+	require.Error(t, err)
+	assert.Equal(t, wantCode, status.Code(err))
+
+	code, _, fields := problemDetails(err)
+	assert.Equal(t, wantProblem, code)
+	assert.Equal(t, wantFields, fields)
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name         string
+		msg          *notificationv1.Message
+		suppressed   bool
+		sendError    error
+		circuitError error
+		wantSend     bool
+		wantCreated  *notificationv1.CreatedNotification
+		wantCode     codes.Code
+		wantProblem  string
+		wantFields   []string
+	}{
+		{
+			name:        "sent",
+			msg:         &notificationv1.Message{To: "test@example.com", Subject: "Subject", Message: "Message"},
+			wantSend:    true,
+			wantCreated: &notificationv1.CreatedNotification{Id: 7, Status: api.StatusSent},
+		},
+		{
+			name:        "invalid message",
+			msg:         &notificationv1.Message{To: "test", Message: "Message"},
+			wantCode:    codes.InvalidArgument,
+			wantProblem: problem.CodeValidationFailed,
+			wantFields:  []string{"subject", "to"},
+		},
+		{
+			name:        "missing message",
+			wantCode:    codes.InvalidArgument,
+			wantProblem: problem.CodeMissingFields,
+			wantFields:  []string{"to", "subject", "message"},
+		},
+		{
+			name:        "suppressed recipient",
+			msg:         &notificationv1.Message{To: "test@example.com", Subject: "Subject", Message: "Message"},
+			suppressed:  true,
+			wantCode:    codes.FailedPrecondition,
+			wantProblem: problem.CodeRecipientSuppressed,
+			wantFields:  []string{"to"},
+		},
+		{
+			name:        "permanent failure",
+			msg:         &notificationv1.Message{To: "test@example.com", Subject: "Subject", Message: "Message"},
+			sendError:   SMTPClient.ErrPermanentFailure,
+			wantSend:    true,
+			wantCode:    codes.FailedPrecondition,
+			wantProblem: problem.CodeRecipientRejected,
+			wantFields:  []string{"to"},
+		},
+		{
+			name:         "smtp unavailable",
+			msg:          &notificationv1.Message{To: "test@example.com", Subject: "Subject", Message: "Message"},
+			circuitError: &SMTPClient.CircuitOpenError{RetryAfter: 20 * time.Second},
+			wantCode:     codes.Unavailable,
+			wantProblem:  problem.CodeSMTPUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMocks()
+			m.postgres.On("IsSuppressed", mock.Anything, "test@example.com").Return(tt.suppressed, nil)
+			m.postgres.On("SaveEmail", mock.Anything, mock.Anything).Return(7, nil)
+			m.postgres.On("UpdateStatus", mock.Anything, 7, mock.Anything, mock.Anything).Return(nil)
+			m.sender.On("CircuitOpen").Return(tt.circuitError).Maybe()
+			m.sender.On("SendEmail", mock.Anything, mock.Anything).Return(nil, tt.sendError)
+
+			c := newTestClient(t, m, nil)
+
+			created, err := c.Send(context.Background(), &notificationv1.SendRequest{Message: tt.msg})
+
+			if tt.wantCode != codes.OK {
+				assertProblem(t, err, tt.wantCode, tt.wantProblem, tt.wantFields)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantCreated.GetId(), created.GetId())
+				assert.Equal(t, tt.wantCreated.GetStatus(), created.GetStatus())
+			}
+
+			if tt.wantSend {
+				m.sender.AssertCalled(t, "SendEmail", mock.Anything, mock.Anything)
+			} else {
+				m.sender.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestSendRetryInfo(t *testing.T) {
+	m := newMocks()
+	m.postgres.On("IsSuppressed", mock.Anything, "test@example.com").Return(false, nil)
+	m.sender.On("CircuitOpen").Return(&SMTPClient.CircuitOpenError{RetryAfter: 20 * time.Second})
+
+	c := newTestClient(t, m, nil)
+
+	_, err := c.Send(context.Background(), &notificationv1.SendRequest{
+		Message: &notificationv1.Message{To: "test@example.com", Subject: "Subject", Message: "Message"},
+	})
+
+	var retryInfo *errdetails.RetryInfo
+	for _, detail := range status.Convert(err).Details() {
+		if d, ok := detail.(*errdetails.RetryInfo); ok {
+			retryInfo = d
+		}
+	}
+
+	require.NotNil(t, retryInfo)
+	assert.Equal(t, 20*time.Second, retryInfo.GetRetryDelay().AsDuration())
+}
+
+func TestSchedule(t *testing.T) {
+	sendAt := time.Date(2035, 5, 24, 0, 33, 10, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		sendAt      *timestamppb.Timestamp
+		wantCreated *notificationv1.CreatedNotification
+		wantCode    codes.Code
+		wantProblem string
+		wantFields  []string
+	}{
+		{
+			name:        "scheduled",
+			sendAt:      timestamppb.New(sendAt),
+			wantCreated: &notificationv1.CreatedNotification{Id: 7, Status: api.StatusPending},
+		},
+		{
+			name:        "send_at in the past",
+			sendAt:      timestamppb.New(time.Date(2020, 5, 24, 0, 33, 10, 0, time.UTC)),
+			wantCode:    codes.InvalidArgument,
+			wantProblem: problem.CodeTimeNotInFuture,
+			wantFields:  []string{"send_at"},
+		},
+		{
+			name:        "missing send_at",
+			wantCode:    codes.InvalidArgument,
+			wantProblem: problem.CodeMissingFields,
+			wantFields:  []string{"send_at"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMocks()
+			m.postgres.On("SaveEmail", mock.Anything, mock.Anything).Return(7, nil)
+			m.redis.On("AddDelayedEmail", mock.Anything, mock.MatchedBy(func(email *SMTPClient.EmailMessage) bool {
+				return email.Type == api.KeyForDelayedSending && email.Time.Equal(sendAt)
+			})).Return(nil)
+
+			c := newTestClient(t, m, nil)
+
+			created, err := c.Schedule(context.Background(), &notificationv1.ScheduleRequest{
+				Message: &notificationv1.Message{To: "test@example.com", Subject: "Subject", Message: "Message"},
+				SendAt:  tt.sendAt,
+			})
+
+			if tt.wantCode != codes.OK {
+				assertProblem(t, err, tt.wantCode, tt.wantProblem, tt.wantFields)
+				m.redis.AssertNotCalled(t, "AddDelayedEmail", mock.Anything, mock.Anything)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCreated.GetId(), created.GetId())
+			assert.Equal(t, tt.wantCreated.GetStatus(), created.GetStatus())
+			m.redis.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	createdAt := time.Date(2025, 5, 23, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		id            int64
+		notifications []*api.Notification
+		postgresError error
+		wantCode      codes.Code
+		wantProblem   string
+	}{
+		{
+			name: "found",
+			id:   7,
+			notifications: []*api.Notification{{
+				ID:        7,
+				Type:      api.KeyForInstantSending,
+				Status:    api.StatusSent,
+				To:        "to",
+				Subject:   "subject",
+				Message:   "message",
+				Headers:   map[string]string{"X-Campaign": "spring"},
+				Events:    []api.TrackingEvent{{Type: "open", CreatedAt: createdAt}},
+				CreatedAt: createdAt,
+				SentAt:    &createdAt,
+			}},
+		},
+		{
+			name:          "not found",
+			id:            7,
+			postgresError: fmt.Errorf("FetchById: %w", pgx.ErrNoRows),
+			wantCode:      codes.NotFound,
+			wantProblem:   problem.CodeNotFound,
+		},
+		{
+			name:        "invalid id",
+			wantCode:    codes.InvalidArgument,
+			wantProblem: problem.CodeInvalidQuery,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMocks()
+
+			if tt.notifications != nil || tt.postgresError != nil {
+				m.postgres.On("FetchById", mock.Anything, 7).Return(tt.notifications, tt.postgresError)
+			}
+
+			c := newTestClient(t, m, nil)
+
+			notification, err := c.Get(context.Background(), &notificationv1.GetRequest{Id: tt.id})
+
+			if tt.wantCode != codes.OK {
+				require.Error(t, err)
+				assert.Equal(t, tt.wantCode, status.Code(err))
+
+				code, _, _ := problemDetails(err)
+				assert.Equal(t, tt.wantProblem, code)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, int64(7), notification.GetId())
+				assert.Equal(t, api.StatusSent, notification.GetStatus())
+				assert.Equal(t, map[string]string{"X-Campaign": "spring"}, notification.GetHeaders())
+				assert.Equal(t, createdAt, notification.GetCreatedAt().AsTime())
+				assert.Equal(t, createdAt, notification.GetSentAt().AsTime())
+				assert.Nil(t, notification.GetTime())
+				require.Len(t, notification.GetEvents(), 1)
+				assert.Equal(t, "open", notification.GetEvents()[0].GetType())
+			}
+
+			m.postgres.AssertExpectations(t)
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	sentAfter := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		req            *notificationv1.ListRequest
+		wantFilter     *api.ListFilter
+		wantNextCursor string
+		wantProblem    string
+		wantFields     []string
+	}{
+		{
+			name:           "default page",
+			req:            &notificationv1.ListRequest{},
+			wantFilter:     &api.ListFilter{Limit: api.DefaultListLimit},
+			wantNextCursor: service.EncodeCursor(3),
+		},
+		{
+			name: "filtered page",
+			req: &notificationv1.ListRequest{
+				Limit:     2,
+				Cursor:    service.EncodeCursor(5),
+				Desc:      true,
+				Status:    api.StatusCanceled,
+				SentAfter: timestamppb.New(sentAfter),
+			},
+			wantFilter:     &api.ListFilter{Status: api.StatusCanceled, SentAfter: &sentAfter, AfterID: 5, Desc: true, Limit: 2},
+			wantNextCursor: service.EncodeCursor(3),
+		},
+		{
+			name:        "invalid limit",
+			req:         &notificationv1.ListRequest{Limit: api.MaxListLimit + 1},
+			wantProblem: problem.CodeInvalidQuery,
+			wantFields:  []string{"limit"},
+		},
+		{
+			name:        "invalid cursor",
+			req:         &notificationv1.ListRequest{Cursor: "!"},
+			wantProblem: problem.CodeInvalidQuery,
+			wantFields:  []string{"cursor"},
+		},
+		{
+			name:        "unknown status",
+			req:         &notificationv1.ListRequest{Status: "unknown"},
+			wantProblem: problem.CodeInvalidQuery,
+			wantFields:  []string{"status"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMocks()
+
+			if tt.wantFilter != nil {
+				m.postgres.On("FetchPage", mock.Anything, tt.wantFilter).
+					Return([]*api.Notification{{ID: 2, Type: api.KeyForDelayedSending, Status: api.StatusCanceled}}, 3, nil)
+			}
+
+			c := newTestClient(t, m, nil)
+
+			resp, err := c.List(context.Background(), tt.req)
+
+			if tt.wantProblem != "" {
+				assertProblem(t, err, codes.InvalidArgument, tt.wantProblem, tt.wantFields)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, resp.GetItems(), 1)
+			assert.Equal(t, int64(2), resp.GetItems()[0].GetId())
+			assert.Equal(t, tt.wantNextCursor, resp.GetNextCursor())
+
+			m.postgres.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCancel(t *testing.T) {
+	tests := []struct {
+		name        string
+		cancelError error
+		wantCode    codes.Code
+		wantProblem string
+	}{
+		{
+			name: "canceled",
+		},
+		{
+			name:        "not cancelable",
+			cancelError: pgx.ErrNoRows,
+			wantCode:    codes.FailedPrecondition,
+			wantProblem: problem.CodeNotCancelable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMocks()
+			m.postgres.On("CancelEmail", mock.Anything, 2).Return(tt.cancelError)
+			m.postgres.On("FetchById", mock.Anything, 2).
+				Return([]*api.Notification{{ID: 2, Type: api.KeyForDelayedSending, Status: api.StatusCanceled}}, nil)
+
+			c := newTestClient(t, m, nil)
+
+			notification, err := c.Cancel(context.Background(), &notificationv1.CancelRequest{Id: 2})
+
+			if tt.wantCode != codes.OK {
+				assertProblem(t, err, tt.wantCode, tt.wantProblem, nil)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, api.StatusCanceled, notification.GetStatus())
+		})
+	}
+}
+
+func TestBatchSend(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxBatchSize int
+		requests     []*notificationv1.BatchSendRequest
+		wantResults  []*notificationv1.BatchSendResult
+	}{
+		{
+			name: "sent, scheduled and invalid",
+			requests: []*notificationv1.BatchSendRequest{
+				{Message: &notificationv1.Message{To: "test@example.com", Subject: "Subject", Message: "Message"}},
+				{Message: &notificationv1.Message{To: "test@example.com", Subject: "Subject"}},
+				{
+					Message: &notificationv1.Message{To: "test@example.com", Subject: "Subject", Message: "Message"},
+					SendAt:  timestamppb.New(time.Date(2035, 5, 24, 0, 33, 10, 0, time.UTC)),
+				},
+			},
+			wantResults: []*notificationv1.BatchSendResult{
+				{Index: 0, Id: 7, Status: api.StatusSent},
+				{Index: 1, Code: problem.CodeMissingFields, Detail: "Not all fields in the request body are filled in", Fields: []string{"message"}},
+				{Index: 2, Id: 7, Status: api.StatusPending},
+			},
+		},
+		{
+			name:         "batch too large",
+			maxBatchSize: 1,
+			requests: []*notificationv1.BatchSendRequest{
+				{Message: &notificationv1.Message{To: "test@example.com", Subject: "Subject", Message: "Message"}},
+				{Message: &notificationv1.Message{To: "test@example.com", Subject: "Subject", Message: "Message"}},
+				{Message: &notificationv1.Message{To: "test@example.com", Subject: "Subject"}},
+			},
+			wantResults: []*notificationv1.BatchSendResult{
+				{Index: 0, Id: 7, Status: api.StatusSent},
+				{Index: 1, Code: problem.CodeBodyTooLarge, Detail: "The batch must not have more than 1 notifications"},
+				{Index: 2, Code: problem.CodeBodyTooLarge, Detail: "The batch must not have more than 1 notifications"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMocks()
+			m.postgres.On("IsSuppressed", mock.Anything, "test@example.com").Return(false, nil)
+			m.postgres.On("SaveEmail", mock.Anything, mock.Anything).Return(7, nil)
+			m.postgres.On("UpdateStatus", mock.Anything, 7, mock.Anything, mock.Anything).Return(nil)
+			m.sender.On("CircuitOpen").Return(nil).Maybe()
+			m.sender.On("SendEmail", mock.Anything, mock.Anything).Return(nil, nil)
+			m.redis.On("AddDelayedEmail", mock.Anything, mock.Anything).Return(nil)
+
+			c := newTestClient(t, m, &api.GRPCServer{MaxBatchSize: tt.maxBatchSize})
+
+			stream, err := c.BatchSend(context.Background())
+			require.NoError(t, err)
+
+			for _, req := range tt.requests {
+				require.NoError(t, stream.Send(req))
+			}
+
+			resp, err := stream.CloseAndRecv()
+			require.NoError(t, err)
+			require.Len(t, resp.GetResults(), len(tt.wantResults))
+
+			for i, want := range tt.wantResults {
+				got := resp.GetResults()[i]
+				assert.Equal(t, want.GetIndex(), got.GetIndex())
+				assert.Equal(t, want.GetId(), got.GetId())
+				assert.Equal(t, want.GetStatus(), got.GetStatus())
+				assert.Equal(t, want.GetCode(), got.GetCode())
+				assert.Equal(t, want.GetDetail(), got.GetDetail())
+				assert.Equal(t, want.GetFields(), got.GetFields())
+			}
+		})
+	}
+}
+
+func TestWithResults(t *testing.T) {
+	resp := &notificationv1.BatchSendResponse{Results: []*notificationv1.BatchSendResult{{Index: 0, Id: 7, Status: api.StatusSent}}}
+
+	err := withResults(contextError(context.DeadlineExceeded), resp)
+
+	st := status.Convert(err)
+	assert.Equal(t, codes.DeadlineExceeded, st.Code())
+
+	code, _, _ := problemDetails(err)
+	assert.Equal(t, problem.CodeTimeout, code)
+
+	var got *notificationv1.BatchSendResponse
+
+	for _, detail := range st.Details() {
+		if r, ok := detail.(*notificationv1.BatchSendResponse); ok {
+			got = r
+		}
+	}
+
+	require.NotNil(t, got)
+	require.Len(t, got.GetResults(), 1)
+	assert.Equal(t, int64(7), got.GetResults()[0].GetId())
+}
+
+func TestInterceptorMetrics(t *testing.T) {
+	m := newMocks()
+	m.postgres.On("FetchById", mock.Anything, 7).Return([]*api.Notification(nil), fmt.Errorf("FetchById: %w", pgx.ErrNoRows))
+
+	c := newTestClient(t, m, nil)
+
+	success := testutil.ToFloat64(testMetrics.Counter.WithLabelValues("List", monitoring.StatusSuccess))
+	failed := testutil.ToFloat64(testMetrics.Counter.WithLabelValues("Get", monitoring.StatusError))
+
+	m.postgres.On("FetchPage", mock.Anything, mock.Anything).Return([]*api.Notification{}, 0, nil)
+
+	_, err := c.List(context.Background(), &notificationv1.ListRequest{})
+	require.NoError(t, err)
+
+	_, err = c.Get(context.Background(), &notificationv1.GetRequest{Id: 7})
+	require.Error(t, err)
+
+	assert.Equal(t, success+1, testutil.ToFloat64(testMetrics.Counter.WithLabelValues("List", monitoring.StatusSuccess)))
+	assert.Equal(t, failed+1, testutil.ToFloat64(testMetrics.Counter.WithLabelValues("Get", monitoring.StatusError)))
+}
+
+func TestAuthentication(t *testing.T) {
+	sendKey := "ntf_send"
+	readKey := "ntf_read"
+	revokedKey := "ntf_revoked"
+
+	revokedAt := time.Date(2025, 5, 23, 10, 0, 0, 0, time.UTC)
+
+	msg := &notificationv1.Message{To: "test@example.com", Subject: "Subject", Message: "Message"}
+	sendAt := timestamppb.New(time.Date(2035, 5, 24, 0, 33, 10, 0, time.UTC))
+
+	tests := []struct {
+		name        string
+		key         string
+		call        func(c notificationv1.NotificationServiceClient) error
+		wantCode    codes.Code
+		wantProblem string
+	}{
+		{
+			name: "send with the send scope",
+			key:  sendKey,
+			call: func(c notificationv1.NotificationServiceClient) error {
+				_, err := c.Send(context.Background(), &notificationv1.SendRequest{Message: msg})
+				return err
+			},
+		},
+		{
+			name: "get with the read scope",
+			key:  readKey,
+			call: func(c notificationv1.NotificationServiceClient) error {
+				got, err := c.Get(context.Background(), &notificationv1.GetRequest{Id: 7})
+				if err == nil {
+					assert.Equal(t, "billing", got.GetClient())
+					assert.Equal(t, "acme", got.GetTenant())
+				}
+
+				return err
+			},
+		},
+		{
+			name: "no key",
+			call: func(c notificationv1.NotificationServiceClient) error {
+				_, err := c.Get(context.Background(), &notificationv1.GetRequest{Id: 7})
+				return err
+			},
+			wantCode:    codes.Unauthenticated,
+			wantProblem: problem.CodeUnauthorized,
+		},
+		{
+			name: "unknown key",
+			key:  "ntf_unknown",
+			call: func(c notificationv1.NotificationServiceClient) error {
+				_, err := c.Get(context.Background(), &notificationv1.GetRequest{Id: 7})
+				return err
+			},
+			wantCode:    codes.Unauthenticated,
+			wantProblem: problem.CodeUnauthorized,
+		},
+		{
+			name: "revoked key",
+			key:  revokedKey,
+			call: func(c notificationv1.NotificationServiceClient) error {
+				_, err := c.Get(context.Background(), &notificationv1.GetRequest{Id: 7})
+				return err
+			},
+			wantCode:    codes.Unauthenticated,
+			wantProblem: problem.CodeUnauthorized,
+		},
+		{
+			name: "send without the send scope",
+			key:  readKey,
+			call: func(c notificationv1.NotificationServiceClient) error {
+				_, err := c.Send(context.Background(), &notificationv1.SendRequest{Message: msg})
+				return err
+			},
+			wantCode:    codes.PermissionDenied,
+			wantProblem: problem.CodeForbidden,
+		},
+		{
+			name: "batch without any scope",
+			key:  readKey,
+			call: func(c notificationv1.NotificationServiceClient) error {
+				stream, err := c.BatchSend(context.Background())
+				require.NoError(t, err)
+
+				_, err = stream.CloseAndRecv()
+				return err
+			},
+			wantCode:    codes.PermissionDenied,
+			wantProblem: problem.CodeForbidden,
+		},
+		{
+			name: "batch schedules without the schedule scope",
+			key:  sendKey,
+			call: func(c notificationv1.NotificationServiceClient) error {
+				stream, err := c.BatchSend(context.Background())
+				require.NoError(t, err)
+
+				require.NoError(t, stream.Send(&notificationv1.BatchSendRequest{Message: msg}))
+				require.NoError(t, stream.Send(&notificationv1.BatchSendRequest{Message: msg, SendAt: sendAt}))
+
+				resp, err := stream.CloseAndRecv()
+				require.NoError(t, err)
+				require.Len(t, resp.GetResults(), 2)
+
+				assert.Equal(t, api.StatusSent, resp.GetResults()[0].GetStatus())
+				assert.Equal(t, problem.CodeForbidden, resp.GetResults()[1].GetCode())
+
+				return nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMocks()
+			m.postgres.On("FetchAPIKeyByHash", mock.Anything, auth.HashKey(sendKey)).
+				Return(&api.APIKey{ID: 1, Name: "billing", Scopes: []string{api.ScopeSend}}, nil).Maybe()
+			m.postgres.On("FetchAPIKeyByHash", mock.Anything, auth.HashKey(readKey)).
+				Return(&api.APIKey{ID: 2, Name: "reports", Scopes: []string{api.ScopeRead}}, nil).Maybe()
+			m.postgres.On("FetchAPIKeyByHash", mock.Anything, auth.HashKey(revokedKey)).
+				Return(&api.APIKey{ID: 3, Name: "old", Scopes: []string{api.ScopeRead}, RevokedAt: &revokedAt}, nil).Maybe()
+			m.postgres.On("FetchAPIKeyByHash", mock.Anything, mock.Anything).Return(nil, pgx.ErrNoRows).Maybe()
+			m.postgres.On("FetchById", mock.Anything, 7).Return([]*api.Notification{{ID: 7, Client: "billing", Tenant: "acme"}}, nil).Maybe()
+			m.postgres.On("IsSuppressed", mock.Anything, "test@example.com").Return(false, nil).Maybe()
+			m.postgres.On("SaveEmail", mock.Anything, mock.MatchedBy(func(email *SMTPClient.EmailMessage) bool {
+				return email.Client == "billing" && email.APIKeyID == 1
+			})).Return(7, nil).Maybe()
+			m.postgres.On("UpdateStatus", mock.Anything, 7, mock.Anything, mock.Anything).Return(nil).Maybe()
+			m.sender.On("CircuitOpen").Return(nil).Maybe()
+			m.sender.On("SendEmail", mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+
+			c := newTestClientWithKey(t, m, nil, tt.key)
+
+			err := tt.call(c)
+
+			if tt.wantCode == codes.OK {
+				require.NoError(t, err)
+				return
+			}
+
+			assertProblem(t, err, tt.wantCode, tt.wantProblem, nil)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package grpcServer
+
+import (
+	"context"
+	"path"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"notification/internal/api/problem"
+	"notification/internal/auth"
+)
+
+// unary authenticates, logs and records the metrics of the unary calls, and recovers the panics of the handlers.
+func (i *interceptor) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (resp any, err error) {
+	start := time.Now()
+
+	i.begin(ctx, info.FullMethod)
+
+	defer func() {
+		if r := recover(); r != nil {
+			i.logger.Error("unary: panic recovered", zap.String("method", info.FullMethod), zap.Any("panic", r), zap.Stack("stack"))
+			err = problemError(codes.Internal, problem.CodeInternal, internalDetail)
+		}
+
+		i.end(info.FullMethod, start, err)
+	}()
+
+	ctx, err = i.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// stream authenticates, logs and records the metrics of the streaming calls, and recovers the panics of the handlers.
+func (i *interceptor) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) (err error) {
+	start := time.Now()
+
+	i.begin(ss.Context(), info.FullMethod)
+
+	defer func() {
+		if r := recover(); r != nil {
+			i.logger.Error("stream: panic recovered", zap.String("method", info.FullMethod), zap.Any("panic", r), zap.Stack("stack"))
+			err = problemError(codes.Internal, problem.CodeInternal, internalDetail)
+		}
+
+		i.end(info.FullMethod, start, err)
+	}()
+
+	ctx, err := i.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticate authenticates the call with the API key or the JWT of the metadata, see keyFromMetadata,
+// and returns the context with the identity of the client, if the client is granted any scope of the method.
+// The calls without a valid key fail with Unauthenticated, and the calls of the clients without the scopes
+// fail with PermissionDenied.
+func (i *interceptor) authenticate(ctx context.Context, method string) (context.Context, error) {
+	identity, err := i.authenticator.Authenticate(ctx, keyFromMetadata(ctx))
+
+	switch {
+	case auth.IsUnauthorized(err):
+		i.logger.Warn("authenticate: authentication failed", zap.String("method", method), zap.Error(err))
+		return nil, problemError(codes.Unauthenticated, problem.CodeUnauthorized, auth.Detail(err))
+
+	case err != nil:
+		i.logger.Error("authenticate: cannot authenticate call", zap.String("method", method), zap.Error(err))
+		return nil, contextOrInternalError(ctx, err)
+	}
+
+	scopes := methodScopes[method]
+
+	if !identity.HasAnyScope(scopes...) {
+		i.logger.Warn("authenticate: scope not granted", zap.String("method", method), zap.String("client", identity.Client))
+		return nil, problemError(codes.PermissionDenied, problem.CodeForbidden,
+			"The API key does not grant any of the scopes: "+strings.Join(scopes, ", "))
+	}
+
+	return auth.NewContext(ctx, identity), nil
+}
+
+// keyFromMetadata returns the API key or the JWT of the call, sent as the bearer token of the authorization metadata,
+// or the API key in the x-api-key metadata, the same way as the HTTP headers, see auth.KeyFromRequest.
+func keyFromMetadata(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	for _, value := range md.Get("authorization") {
+		if scheme, token, ok := strings.Cut(value, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+
+	if keys := md.Get(auth.HeaderAPIKey); len(keys) > 0 {
+		return keys[0]
+	}
+
+	return ""
+}
+
+// Context returns the context with the identity of the client.
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// begin logs the new call with the address of the client.
+func (i *interceptor) begin(ctx context.Context, method string) {
+	remoteAddr := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		remoteAddr = p.Addr.String()
+	}
+
+	i.logger.Info("new request", zap.String("method", method), zap.String("remote_addr", remoteAddr))
+}
+
+// end logs the completed call and records its status in the metrics, the operation is the name of the method.
+// The duration is observed only for the successful calls, the same way as in the HTTP handlers.
+func (i *interceptor) end(method string, start time.Time, err error) {
+	operation := path.Base(method)
+
+	code := status.Code(err)
+
+	switch code {
+	case codes.OK:
+		i.metrics.Observe(operation, start)
+		i.metrics.IncSuccess(operation)
+
+	case codes.Canceled:
+		i.metrics.IncCanceled(operation)
+
+	case codes.DeadlineExceeded:
+		i.metrics.IncTimeout(operation)
+
+	default:
+		i.metrics.IncError(operation)
+	}
+
+	i.logger.Info("request completed", zap.String("method", method), zap.String("code", code.String()),
+		zap.Duration("duration", time.Since(start)))
+}
@@ -0,0 +1,256 @@
+package grpcServer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"notification/internal/api"
+	"notification/internal/api/decoder"
+	"notification/internal/api/problem"
+	"notification/internal/api/service"
+	"notification/internal/auth"
+	"notification/internal/config"
+	"notification/internal/monitoring"
+	notificationv1 "notification/proto/notification/v1"
+)
+
+// New creates and returns a new gRPC server with the NotificationService registered.
+// The calls share the service layer with the HTTP handlers, they are logged and recorded in the metrics
+// with the method name as the operation, panics are recovered and reported as Internal errors.
+// The calls are authenticated with the API key of the metadata and require the same scopes as the HTTP API.
+func New(svc *service.Service, authenticator *auth.Authenticator, decoderConfig *decoder.Config, serverConfig *api.GRPCServer,
+	timeouts config.AppTimeouts, extraTimeout time.Duration, metrics monitoring.Monitoring, logger *zap.Logger) *grpc.Server {
+	i := &interceptor{
+		authenticator: authenticator,
+		metrics:       metrics,
+		logger:        logger,
+	}
+
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(i.unary),
+		grpc.ChainStreamInterceptor(i.stream),
+	)
+
+	notificationv1.RegisterNotificationServiceServer(srv, &notificationServer{
+		service:       svc,
+		decoderConfig: decoderConfig,
+		serverConfig:  serverConfig,
+		timeouts:      timeouts,
+		extraTimeout:  extraTimeout,
+		logger:        logger,
+	})
+
+	return srv
+}
+
+// Send validates the notification, saves it and sends it immediately.
+func (s *notificationServer) Send(ctx context.Context, req *notificationv1.SendRequest) (*notificationv1.CreatedNotification, error) {
+	return s.create(ctx, req.GetMessage(), nil)
+}
+
+// Schedule validates the notification and saves it to be sent at send_at by the worker.
+func (s *notificationServer) Schedule(ctx context.Context, req *notificationv1.ScheduleRequest) (*notificationv1.CreatedNotification, error) {
+	if req.GetSendAt() == nil {
+		return nil, problemError(codes.InvalidArgument, problem.CodeMissingFields, "Not all fields in the request body are filled in", "send_at")
+	}
+
+	sendAt := req.GetSendAt().AsTime()
+
+	return s.create(ctx, req.GetMessage(), &sendAt)
+}
+
+// Get returns the notification with the ID.
+func (s *notificationServer) Get(ctx context.Context, req *notificationv1.GetRequest) (*notificationv1.Notification, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeoutForList())
+	defer cancel()
+
+	if req.GetId() <= 0 {
+		return nil, s.notificationError(errInvalidID, "Get")
+	}
+
+	notification, err := s.service.Get(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, s.notificationError(err, "Get")
+	}
+
+	return notificationProto(notification), nil
+}
+
+// List returns a single page of notifications selected by the request.
+func (s *notificationServer) List(ctx context.Context, req *notificationv1.ListRequest) (*notificationv1.ListResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeoutForList())
+	defer cancel()
+
+	filter, err := listFilter(req)
+	if err != nil {
+		return nil, err
+	}
+
+	notifications, next, err := s.service.List(ctx, filter)
+	if err != nil {
+		return nil, s.notificationError(err, "List")
+	}
+
+	resp := &notificationv1.ListResponse{Items: make([]*notificationv1.Notification, 0, len(notifications))}
+
+	for _, notification := range notifications {
+		resp.Items = append(resp.Items, notificationProto(notification))
+	}
+
+	if next != 0 {
+		resp.NextCursor = service.EncodeCursor(next)
+	}
+
+	return resp, nil
+}
+
+// Cancel cancels the pending scheduled notification and returns it with the canceled status.
+func (s *notificationServer) Cancel(ctx context.Context, req *notificationv1.CancelRequest) (*notificationv1.Notification, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeoutForList())
+	defer cancel()
+
+	if req.GetId() <= 0 {
+		return nil, s.notificationError(errInvalidID, "Cancel")
+	}
+
+	notification, err := s.service.Cancel(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, s.notificationError(err, "Cancel")
+	}
+
+	s.logger.Info("Cancel: Notification canceled", zap.Int64("id", req.GetId()))
+
+	return notificationProto(notification), nil
+}
+
+// BatchSend sends or schedules every notification of the stream as it is received, one at a time.
+// The errors of the notifications are reported in their results, the notifications over api.GRPCServer.MaxBatchSize
+// are rejected without being processed. The call fails only if the stream breaks or the context ends,
+// the results of the notifications processed so far are then attached to the error, see withResults.
+func (s *notificationServer) BatchSend(stream grpc.ClientStreamingServer[notificationv1.BatchSendRequest, notificationv1.BatchSendResponse]) error {
+	resp := &notificationv1.BatchSendResponse{}
+
+	for index := 0; ; index++ {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(resp)
+		}
+
+		if err != nil {
+			s.logger.Warn("BatchSend: cannot receive notification", zap.Error(err), zap.Int("processed", len(resp.Results)))
+			return withResults(err, resp)
+		}
+
+		if index >= s.maxBatchSize() {
+			if index == s.maxBatchSize() {
+				s.logger.Warn("BatchSend: batch is too large, the rest of the stream is rejected", zap.Int("limit", s.maxBatchSize()))
+			}
+
+			resp.Results = append(resp.Results, &notificationv1.BatchSendResult{
+				Index:  int32(index),
+				Code:   problem.CodeBodyTooLarge,
+				Detail: fmt.Sprintf("The batch must not have more than %d notifications", s.maxBatchSize()),
+			})
+
+			continue
+		}
+
+		var sendAt *time.Time
+		if req.GetSendAt() != nil {
+			t := req.GetSendAt().AsTime()
+			sendAt = &t
+		}
+
+		result := &notificationv1.BatchSendResult{Index: int32(index)}
+
+		created, err := s.create(stream.Context(), req.GetMessage(), sendAt)
+		if err != nil {
+			result.Code, result.Detail, result.Fields = problemDetails(err)
+		} else {
+			result.Id, result.Status = created.GetId(), created.GetStatus()
+		}
+
+		resp.Results = append(resp.Results, result)
+
+		if ctxErr := stream.Context().Err(); ctxErr != nil {
+			s.logger.Warn("BatchSend: Context ended", zap.Error(ctxErr), zap.Int("processed", len(resp.Results)))
+			return withResults(contextError(ctxErr), resp)
+		}
+	}
+}
+
+// create validates the notification, and sends it immediately or schedules it if sendAt is set.
+// The client must be granted the schedule scope to schedule and the send scope to send immediately.
+func (s *notificationServer) create(ctx context.Context, msg *notificationv1.Message,
+	sendAt *time.Time) (*notificationv1.CreatedNotification, error) {
+	email, err := decoder.ValidateNotification(s.decoderConfig, s.logger, tempEmail(msg), sendAt)
+	if err != nil {
+		return nil, validationError(err)
+	}
+
+	scope := api.ScopeSend
+	if sendAt != nil {
+		scope = api.ScopeSchedule
+	}
+
+	if identity, ok := auth.FromContext(ctx); ok && !identity.HasScope(scope) {
+		s.logger.Warn("create: scope not granted", zap.String("client", identity.Client), zap.String("scope", scope))
+		return nil, problemError(codes.PermissionDenied, problem.CodeForbidden, "The API key does not grant the scope: "+scope)
+	}
+
+	if sendAt != nil {
+		ctx, cancel := context.WithTimeout(ctx, s.timeoutForSchedule())
+		defer cancel()
+
+		if err = s.service.Schedule(ctx, email); err != nil {
+			s.logger.Error("create: Cannot schedule notification", zap.Error(err))
+			return nil, contextOrInternalError(ctx, err)
+		}
+
+		return &notificationv1.CreatedNotification{Id: int64(email.Id), Status: api.StatusPending}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeoutForSend())
+	defer cancel()
+
+	status, err := s.service.Send(ctx, email)
+	if err = s.sendError(ctx, email.Id, status, err); err != nil {
+		return nil, err
+	}
+
+	return &notificationv1.CreatedNotification{Id: int64(email.Id), Status: status}, nil
+}
+
+// maxBatchSize returns the maximum number of notifications in a single BatchSend stream.
+func (s *notificationServer) maxBatchSize() int {
+	if s.serverConfig == nil || s.serverConfig.MaxBatchSize <= 0 {
+		return DefaultMaxBatchSize
+	}
+
+	return s.serverConfig.MaxBatchSize
+}
+
+// timeoutForSend calculates the timeout of sending a notification the same way as the HTTP handlers,
+// including the longest SMTP retry delays allowed by the backoff policy, PostgreSQL timeout, and additional buffer time.
+func (s *notificationServer) timeoutForSend() time.Duration {
+	return s.timeouts.SMTPBackoff.Budget(s.timeouts.SMTPQuantityOfRetries) + s.timeouts.PostgresTimeout + s.extraTimeout
+}
+
+// timeoutForSchedule calculates the timeout of scheduling a notification,
+// including Redis timeout, PostgreSQL timeout, and additional buffer time.
+func (s *notificationServer) timeoutForSchedule() time.Duration {
+	return s.timeouts.RedisTimeout + s.timeouts.PostgresTimeout + s.extraTimeout
+}
+
+// timeoutForList calculates the timeout of fetching, listing and canceling notifications,
+// including PostgreSQL timeout, and additional buffer time.
+func (s *notificationServer) timeoutForList() time.Duration {
+	return s.timeouts.PostgresTimeout + s.extraTimeout
+}
@@ -0,0 +1,67 @@
+package grpcServer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	"notification/internal/api"
+	"notification/internal/api/decoder"
+	"notification/internal/api/service"
+	"notification/internal/auth"
+	"notification/internal/config"
+	"notification/internal/monitoring"
+	notificationv1 "notification/proto/notification/v1"
+)
+
+// DefaultMaxBatchSize is the maximum number of notifications in a single BatchSend stream
+// if api.GRPCServer.MaxBatchSize is not set.
+const DefaultMaxBatchSize = 1000
+
+// errorDomain is the domain of google.rpc.ErrorInfo in the status details of the errors.
+const errorDomain = "notification"
+
+var (
+	// errInvalidID indicates that the notification ID of the request is not positive.
+	errInvalidID = errors.New("invalid notification ID")
+)
+
+// notificationServer implements notificationv1.NotificationServiceServer on top of service.Service,
+// the notifications are validated with decoder.ValidateNotification the same way as in the HTTP API.
+type notificationServer struct {
+	notificationv1.UnimplementedNotificationServiceServer
+
+	service       *service.Service
+	decoderConfig *decoder.Config
+	serverConfig  *api.GRPCServer
+	timeouts      config.AppTimeouts
+	extraTimeout  time.Duration
+	logger        *zap.Logger
+}
+
+// methodScopes are the scopes of the methods, the client must be granted any scope of the method.
+// The scope of every notification of BatchSend is checked separately, once the notification is validated.
+var methodScopes = map[string][]string{
+	notificationv1.NotificationService_Send_FullMethodName:      {api.ScopeSend},
+	notificationv1.NotificationService_Schedule_FullMethodName:  {api.ScopeSchedule},
+	notificationv1.NotificationService_Get_FullMethodName:       {api.ScopeRead},
+	notificationv1.NotificationService_List_FullMethodName:      {api.ScopeRead},
+	notificationv1.NotificationService_Cancel_FullMethodName:    {api.ScopeSchedule},
+	notificationv1.NotificationService_BatchSend_FullMethodName: {api.ScopeSend, api.ScopeSchedule},
+}
+
+// interceptor authenticates the gRPC calls, records their metrics and logs them, see unary and stream.
+type interceptor struct {
+	authenticator *auth.Authenticator
+	metrics       monitoring.Monitoring
+	logger        *zap.Logger
+}
+
+// authenticatedStream is the server stream with the context carrying the identity of the client.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
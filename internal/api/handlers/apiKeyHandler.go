@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"notification/internal/api/decoder"
+	"notification/internal/api/problem"
+	"notification/internal/auth"
+	"notification/internal/monitoring"
+)
+
+// errInvalidAPIKeyID indicates that the API key ID in the path is not a positive integer.
+var errInvalidAPIKeyID = errors.New("invalid API key ID")
+
+// NewCreateAPIKeyHandler returns an HTTP handler that issues a new API key to the client and writes the saved key.
+// The key itself is returned only in this response, only its hash is stored.
+func (nh *NotificationHandler) NewCreateAPIKeyHandler(metrics monitoring.Monitoring) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), nh.calculateTimeoutForList())
+		defer cancel()
+
+		start := time.Now()
+
+		handlerName := "CreateAPIKey"
+
+		if nh.checkCtxError(ctx, w, r, metrics, handlerName) {
+			return
+		}
+
+		apiKey, err := decoder.DecodeAPIKey(nh.logger, r, w)
+		if err != nil {
+			metrics.IncError(handlerName)
+			nh.logger.Error("NewCreateAPIKeyHandler: Failed to decode request", zap.Error(err))
+			return
+		}
+
+		key, prefix, hash, err := auth.GenerateKey()
+		if err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, http.StatusText(500))
+			metrics.IncError(handlerName)
+			nh.logger.Error("NewCreateAPIKeyHandler: Cannot generate API key", zap.Error(err))
+
+			return
+		}
+
+		apiKey.Prefix = prefix
+
+		if err = nh.postgresClient.SaveAPIKey(ctx, apiKey, hash); err != nil {
+			nh.processStorageError(err, handlerName, metrics, w, r)
+			return
+		}
+
+		apiKey.Key = key
+
+		nh.logger.Info("NewCreateAPIKeyHandler: API key created", zap.Int("id", apiKey.ID), zap.String("name", apiKey.Name),
+			zap.Strings("scopes", apiKey.Scopes))
+		nh.writeJSON(w, http.StatusCreated, metrics, handlerName, apiKey)
+
+		metrics.Observe(handlerName, start)
+		metrics.IncSuccess(handlerName)
+	}
+}
+
+// NewListAPIKeysHandler returns an HTTP handler that lists all API keys without the keys themselves,
+// including the revoked and expired ones.
+func (nh *NotificationHandler) NewListAPIKeysHandler(metrics monitoring.Monitoring) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), nh.calculateTimeoutForList())
+		defer cancel()
+
+		start := time.Now()
+
+		handlerName := "ListAPIKeys"
+
+		if nh.checkCtxError(ctx, w, r, metrics, handlerName) {
+			return
+		}
+
+		keys, err := nh.postgresClient.FetchAPIKeys(ctx)
+		if err != nil {
+			nh.processStorageError(err, handlerName, metrics, w, r)
+			return
+		}
+
+		nh.writeJSON(w, http.StatusOK, metrics, handlerName, keys)
+
+		metrics.Observe(handlerName, start)
+		metrics.IncSuccess(handlerName)
+	}
+}
+
+// NewRevokeAPIKeyHandler returns an HTTP handler that revokes the API key with the ID from the path,
+// the requests with the revoked key are rejected with 401 Unauthorized.
+func (nh *NotificationHandler) NewRevokeAPIKeyHandler(metrics monitoring.Monitoring) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), nh.calculateTimeoutForList())
+		defer cancel()
+
+		start := time.Now()
+
+		handlerName := "RevokeAPIKey"
+
+		if nh.checkCtxError(ctx, w, r, metrics, handlerName) {
+			return
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil || id <= 0 {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, errInvalidAPIKeyID.Error(), "id")
+			metrics.IncError(handlerName)
+			nh.logger.Warn("NewRevokeAPIKeyHandler: invalid request", zap.Error(errInvalidAPIKeyID))
+
+			return
+		}
+
+		err = nh.postgresClient.RevokeAPIKey(ctx, id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			problem.Write(w, r, http.StatusNotFound, problem.CodeNotFound, "The API key does not exist or is already revoked")
+			metrics.IncError(handlerName)
+			nh.logger.Warn("NewRevokeAPIKeyHandler: API key not found", zap.Int("id", id))
+
+			return
+		}
+
+		if err != nil {
+			nh.processStorageError(err, handlerName, metrics, w, r)
+			return
+		}
+
+		nh.logger.Info("NewRevokeAPIKeyHandler: API key revoked", zap.Int("id", id))
+		w.WriteHeader(http.StatusNoContent)
+
+		metrics.Observe(handlerName, start)
+		metrics.IncSuccess(handlerName)
+	}
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"notification/internal/SMTPClient"
+	"notification/internal/api"
+	"notification/internal/api/problem"
+	"notification/internal/bounce"
+	"notification/internal/monitoring"
+	"notification/internal/unsubscribe"
+)
+
+// maxBounceSize is the maximum size of the bounce message accepted by NewBounceHandler.
+const maxBounceSize = 10 << 20
+
+// bounceResponse is the result of processing the delivery status notification.
+// Id is the ID of the bounced notification, it is omitted if the notification is not found.
+type bounceResponse struct {
+	Message    string   `json:"message"`
+	Id         int      `json:"id,omitempty"`
+	Suppressed []string `json:"suppressed,omitempty"`
+}
+
+// NewBounceHandler returns an HTTP handler that processes delivery status notifications (RFC 3464).
+// The request body is the raw bounce message. The notification is matched by the Message-ID of the returned
+// original message, and if its recipient is among the failed recipients of the report and it is sent,
+// it gets the bounced status and its recipient is added to the suppression list if it is hard-bounced.
+// The other recipients of the report are ignored, because anyone who can send a message to the bounce
+// mailbox controls them. Reports without failed recipients, like delayed delivery warnings, are accepted and ignored.
+func (nh *NotificationHandler) NewBounceHandler(metrics monitoring.Monitoring) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), nh.calculateTimeoutForList())
+		defer cancel()
+
+		start := time.Now()
+
+		handlerName := "Bounce"
+
+		if nh.checkCtxError(ctx, w, r, metrics, handlerName) {
+			return
+		}
+
+		raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBounceSize))
+		if err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				problem.Write(w, r, http.StatusRequestEntityTooLarge, problem.CodeBodyTooLarge, "Bounce message is too large")
+			} else {
+				problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidReport, "Failed to read request body")
+			}
+
+			metrics.IncError(handlerName)
+			nh.logger.Error("NewBounceHandler: Failed to read request body", zap.Error(err))
+
+			return
+		}
+
+		report, err := bounce.Parse(raw)
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidReport, "The message is not a valid delivery status notification")
+			metrics.IncError(handlerName)
+			nh.logger.Warn("NewBounceHandler: Failed to parse bounce", zap.Error(err))
+
+			return
+		}
+
+		failed := report.Failed()
+		if len(failed) == 0 {
+			nh.writeBounceResponse(w, metrics, handlerName, bounceResponse{Message: "No failed recipients in the report"})
+
+			metrics.Observe(handlerName, start)
+			metrics.IncSuccess(handlerName)
+
+			return
+		}
+
+		resp := bounceResponse{Message: "Bounce processed"}
+
+		notification, err := nh.findBounced(ctx, report.MessageID)
+		if err != nil {
+			nh.processStorageError(err, handlerName, metrics, w, r)
+			return
+		}
+
+		if notification != nil {
+			failed = nh.recipientsOf(notification, failed)
+		}
+
+		if notification == nil || len(failed) == 0 {
+			nh.writeBounceResponse(w, metrics, handlerName, resp)
+
+			metrics.Observe(handlerName, start)
+			metrics.IncSuccess(handlerName)
+
+			return
+		}
+
+		bounced, err := nh.markBounced(ctx, report.MessageID)
+		if err != nil {
+			nh.processStorageError(err, handlerName, metrics, w, r)
+			return
+		}
+
+		if !bounced {
+			nh.writeBounceResponse(w, metrics, handlerName, resp)
+
+			metrics.Observe(handlerName, start)
+			metrics.IncSuccess(handlerName)
+
+			return
+		}
+
+		resp.Id = notification.ID
+
+		for _, rcpt := range failed {
+			nh.logger.Info("NewBounceHandler: Notification bounced",
+				zap.Int("id", resp.Id),
+				zap.String("message_id", report.MessageID),
+				zap.String("recipient", rcpt.Address),
+				zap.String("status", rcpt.Status),
+				zap.String("diagnostic_code", rcpt.DiagnosticCode),
+			)
+
+			if !rcpt.Hard() || slices.Contains(resp.Suppressed, rcpt.Address) {
+				continue
+			}
+
+			suppression := &api.Suppression{
+				Address: rcpt.Address,
+				Reason:  api.SuppressionReasonHardBounce,
+				Source:  api.SuppressionSourceBounce,
+			}
+
+			if err = nh.postgresClient.SaveSuppression(ctx, suppression); err != nil {
+				nh.processStorageError(err, handlerName, metrics, w, r)
+				return
+			}
+
+			resp.Suppressed = append(resp.Suppressed, rcpt.Address)
+		}
+
+		nh.writeBounceResponse(w, metrics, handlerName, resp)
+
+		metrics.Observe(handlerName, start)
+		metrics.IncSuccess(handlerName)
+	}
+}
+
+// findBounced returns the notification with the Message-ID of the bounce.
+// It returns nil without an error if the report has no Message-ID or the notification is not found,
+// because bounces of emails sent by other systems land in the same mailbox.
+func (nh *NotificationHandler) findBounced(ctx context.Context, messageID string) (*api.Notification, error) {
+	if messageID == "" {
+		nh.logger.Warn("findBounced: bounce does not contain the original Message-ID")
+		return nil, nil
+	}
+
+	notifications, err := nh.postgresClient.FetchByMessageID(ctx, messageID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		nh.logger.Warn("findBounced: notification not found", zap.String("message_id", messageID))
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return notifications[0], nil
+}
+
+// recipientsOf returns the failed recipients of the report which are the recipient of the notification.
+// The other recipients are ignored, because anyone who can send a message to the bounce mailbox controls them.
+func (nh *NotificationHandler) recipientsOf(notification *api.Notification, failed []bounce.Recipient) []bounce.Recipient {
+	recipient := unsubscribe.Recipient(notification.To)
+
+	var matched []bounce.Recipient
+
+	for _, rcpt := range failed {
+		if rcpt.Address != recipient {
+			nh.logger.Warn("recipientsOf: Recipient of the report is not the recipient of the notification, ignored",
+				zap.Int("id", notification.ID),
+				zap.String("recipient", rcpt.Address),
+			)
+
+			continue
+		}
+
+		matched = append(matched, rcpt)
+	}
+
+	return matched
+}
+
+// markBounced sets the bounced status of the sent notification with the Message-ID, notifies the caller
+// and reports whether the status was set. It returns false without an error if the notification is not sent,
+// because the same bounce may be reported twice.
+func (nh *NotificationHandler) markBounced(ctx context.Context, messageID string) (bool, error) {
+	notification, err := nh.postgresClient.UpdateStatusByMessageID(ctx, messageID, api.StatusBounced)
+	if errors.Is(err, pgx.ErrNoRows) {
+		nh.logger.Warn("markBounced: notification is not sent", zap.String("message_id", messageID))
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	nh.service.Notify(ctx, &SMTPClient.EmailMessage{
+		Id:          notification.ID,
+		To:          notification.To,
+		MessageID:   notification.MessageID,
+		CallbackURL: notification.CallbackURL,
+		Tenant:      notification.Tenant,
+	}, api.StatusBounced)
+
+	return true, nil
+}
+
+// writeBounceResponse writes the result of processing the bounce as JSON to the HTTP client.
+func (nh *NotificationHandler) writeBounceResponse(w http.ResponseWriter, metrics monitoring.Monitoring,
+	handlerName string, resp bounceResponse) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		metrics.IncError(handlerName)
+		nh.logger.Error("NewBounceHandler: cannot send response to caller", zap.Error(err))
+	}
+}
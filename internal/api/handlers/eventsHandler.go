@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"notification/internal/api"
+	"notification/internal/api/problem"
+	"notification/internal/api/service"
+	"notification/internal/events"
+	"notification/internal/monitoring"
+)
+
+// heartbeatInterval is the interval of the comments written to idle event streams,
+// so that proxies and load balancers do not close the connection.
+const heartbeatInterval = 15 * time.Second
+
+// NewEventsHandler returns an HTTP handler that streams the status changes of notifications as Server-Sent Events.
+// The stream can be limited to a single notification with the id query parameter
+// and to a single recipient with the recipient query parameter.
+// The clients with a tenant only receive the events of their tenant.
+// Each status change is written as the "status" event with the JSON encoded api.StatusEvent as data.
+// The stream lasts until the client disconnects or the service shuts down.
+func (nh *NotificationHandler) NewEventsHandler(metrics monitoring.Monitoring) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		handlerName := "Events"
+
+		filter, err := parseEventsFilter(r)
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, "The id query parameter must be a positive integer", "id")
+			metrics.IncError(handlerName)
+			nh.logger.Warn("NewEventsHandler: invalid filter", zap.Error(err))
+
+			return
+		}
+
+		filter.Tenant = service.Tenant(r.Context())
+
+		flusher, ok := w.(http.Flusher)
+		if !ok || nh.bus == nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, "Event streaming is not supported")
+			metrics.IncError(handlerName)
+			nh.logger.Error("NewEventsHandler: event streaming is not supported")
+
+			return
+		}
+
+		statusEvents, unsubscribe := nh.bus.Subscribe(filter)
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		_, _ = fmt.Fprint(w, ": connected\n\n")
+		flusher.Flush()
+
+		nh.logger.Info("NewEventsHandler: client subscribed to events",
+			zap.Int("id", filter.ID), zap.String("recipient", filter.Recipient))
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				nh.logger.Info("NewEventsHandler: client disconnected")
+
+				metrics.Observe(handlerName, start)
+				metrics.IncSuccess(handlerName)
+
+				return
+
+			case event, ok := <-statusEvents:
+				if !ok {
+					nh.logger.Info("NewEventsHandler: event stream closed")
+
+					metrics.Observe(handlerName, start)
+					metrics.IncSuccess(handlerName)
+
+					return
+				}
+
+				if err = writeEvent(w, event); err != nil {
+					metrics.IncError(handlerName)
+					nh.logger.Error("NewEventsHandler: cannot write event", zap.Error(err))
+
+					return
+				}
+
+				flusher.Flush()
+
+			case <-heartbeat.C:
+				if _, err = fmt.Fprint(w, ": ping\n\n"); err != nil {
+					metrics.IncError(handlerName)
+					nh.logger.Error("NewEventsHandler: cannot write heartbeat", zap.Error(err))
+
+					return
+				}
+
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// parseEventsFilter returns the filter of the event stream from the optional id and recipient query parameters.
+func parseEventsFilter(r *http.Request) (events.Filter, error) {
+	filter := events.Filter{Recipient: r.URL.Query().Get("recipient")}
+
+	if id := r.URL.Query().Get("id"); id != "" {
+		n, err := strconv.Atoi(id)
+		if err != nil || n <= 0 {
+			return events.Filter{}, fmt.Errorf("parseEventsFilter: invalid id %q", id)
+		}
+
+		filter.ID = n
+	}
+
+	return filter, nil
+}
+
+// writeEvent writes the status event in the Server-Sent Events format.
+func writeEvent(w http.ResponseWriter, event api.StatusEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "event: status\ndata: %s\n\n", data)
+
+	return err
+}
@@ -2,13 +2,19 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -17,12 +23,23 @@ import (
 
 	"notification/internal/SMTPClient"
 	"notification/internal/api"
+	"notification/internal/api/decoder"
+	"notification/internal/api/openapi"
+	"notification/internal/api/problem"
+	"notification/internal/auth"
 	"notification/internal/config"
+	"notification/internal/events"
 	"notification/internal/monitoring"
 	"notification/internal/storage/postgresClient"
 	"notification/internal/storage/redisClient"
+	"notification/internal/tracking"
+	"notification/internal/unsubscribe"
+	"notification/internal/webhook"
 )
 
+// appMetrics are shared by the tests of the routes, because the metrics are registered globally.
+var appMetrics = monitoring.NewAppMetrics()
+
 func TestNewSendNotificationHandler(t *testing.T) {
 	tests := []struct {
 		name                string
@@ -32,6 +49,7 @@ func TestNewSendNotificationHandler(t *testing.T) {
 		id                  int
 		postgresError       error
 		senderError         error
+		circuitError        error
 		wantStatusCode      int
 		wantResponseMessage string
 	}{
@@ -62,7 +80,7 @@ func TestNewSendNotificationHandler(t *testing.T) {
 			body:                ``,
 			senderError:         nil,
 			wantStatusCode:      http.StatusBadRequest,
-			wantResponseMessage: "Request body must not be empty\n",
+			wantResponseMessage: "Request body must not be empty",
 		},
 		{
 			name:           "error in SendEmail",
@@ -81,7 +99,7 @@ func TestNewSendNotificationHandler(t *testing.T) {
 			},
 			senderError:         fmt.Errorf("SendEmail: cannot send message to"),
 			wantStatusCode:      http.StatusInternalServerError,
-			wantResponseMessage: http.StatusText(500) + "\n",
+			wantResponseMessage: http.StatusText(500),
 		},
 		{
 			name:           "error in SavingInstantSending",
@@ -102,7 +120,7 @@ func TestNewSendNotificationHandler(t *testing.T) {
 			postgresError:       fmt.Errorf("SavingInstantSending: failed to add email to database"),
 			senderError:         nil,
 			wantStatusCode:      http.StatusInternalServerError,
-			wantResponseMessage: http.StatusText(500) + "\n",
+			wantResponseMessage: http.StatusText(500),
 		},
 		{
 			name: "context canceled before processing",
@@ -118,7 +136,7 @@ func TestNewSendNotificationHandler(t *testing.T) {
 			}`,
 			senderError:         nil,
 			wantStatusCode:      http.StatusBadRequest,
-			wantResponseMessage: http.StatusText(400) + "\n",
+			wantResponseMessage: http.StatusText(400),
 		},
 		{
 			name:           "context canceled during processing",
@@ -137,7 +155,7 @@ func TestNewSendNotificationHandler(t *testing.T) {
 			},
 			senderError:         context.Canceled,
 			wantStatusCode:      http.StatusInternalServerError,
-			wantResponseMessage: http.StatusText(500) + "\n",
+			wantResponseMessage: http.StatusText(500),
 		},
 		{
 			name: "context timeout before processing",
@@ -160,7 +178,7 @@ func TestNewSendNotificationHandler(t *testing.T) {
 			},
 			senderError:         nil,
 			wantStatusCode:      http.StatusInternalServerError,
-			wantResponseMessage: http.StatusText(500) + "\n",
+			wantResponseMessage: http.StatusText(500),
 		},
 		{
 			name:           "context timeout during processing",
@@ -179,7 +197,46 @@ func TestNewSendNotificationHandler(t *testing.T) {
 			},
 			senderError:         context.DeadlineExceeded,
 			wantStatusCode:      http.StatusInternalServerError,
-			wantResponseMessage: http.StatusText(500) + "\n",
+			wantResponseMessage: http.StatusText(500),
+		},
+		{
+			name:           "permanent failure in SendEmail",
+			requestContext: context.Background(),
+			body: `{
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message"
+			}`,
+			email: SMTPClient.EmailMessage{
+				Type:    api.KeyForInstantSending,
+				Time:    nil,
+				To:      "example@gmail.com",
+				Subject: "Subject",
+				Message: "Message",
+			},
+			id:                  3,
+			senderError:         fmt.Errorf("SendEmail: %w: 550 mailbox unavailable", SMTPClient.ErrPermanentFailure),
+			wantStatusCode:      http.StatusUnprocessableEntity,
+			wantResponseMessage: "The recipient was rejected by the mail server",
+		},
+		{
+			name:           "circuit breaker is open",
+			requestContext: context.Background(),
+			body: `{
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message"
+			}`,
+			email: SMTPClient.EmailMessage{
+				Type:    api.KeyForInstantSending,
+				Time:    nil,
+				To:      "example@gmail.com",
+				Subject: "Subject",
+				Message: "Message",
+			},
+			circuitError:        &SMTPClient.CircuitOpenError{RetryAfter: 1500 * time.Millisecond},
+			wantStatusCode:      http.StatusServiceUnavailable,
+			wantResponseMessage: http.StatusText(503),
 		},
 	}
 
@@ -198,672 +255,2995 @@ func TestNewSendNotificationHandler(t *testing.T) {
 				mockSender,
 				mockRedisClient,
 				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
 				config.AppTimeouts{},
 				3*time.Second,
 			)
 
-			mockSender.On("SendEmail", mock.Anything, tt.email).Return(tt.senderError)
+			sentEmail := tt.email
+			sentEmail.Id = tt.id
+
+			sent := false
+
+			mockSender.On("CircuitOpen").Return(tt.circuitError)
+			mockSender.On("SendEmail", mock.Anything, sentEmail).Return(nil, tt.senderError).Run(func(mock.Arguments) { sent = true })
+			mockPostgresClient.On("IsSuppressed", mock.Anything, "example@gmail.com").Return(false, nil)
 			mockPostgresClient.On("SaveEmail", mock.Anything, &tt.email).Return(tt.id, tt.postgresError)
+			mockPostgresClient.On("UpdateStatus", mock.Anything, tt.id, mock.Anything, mock.Anything).Return(nil)
 
 			handler := notificationHandler.NewSendNotificationHandler(monitoring.NewNop())
 			handler.ServeHTTP(w, r)
 
+			assertDocumented(t, "POST", "/send-notification", w)
+
 			assert.Equal(t, tt.wantStatusCode, w.Code)
-			assert.Equal(t, tt.wantResponseMessage, w.Body.String())
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+
+			if tt.wantStatusCode == http.StatusServiceUnavailable {
+				assert.Equal(t, "2", w.Header().Get("Retry-After"))
+			}
+
+			if tt.circuitError != nil {
+				mockPostgresClient.AssertNotCalled(t, "SaveEmail", mock.Anything, mock.Anything)
+			}
+
+			switch {
+			case !sent:
+				mockPostgresClient.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+			case tt.wantStatusCode == http.StatusOK:
+				mockPostgresClient.AssertCalled(t, "UpdateStatus", mock.Anything, tt.id, api.StatusSent, mock.Anything)
+
+			default:
+				mockPostgresClient.AssertCalled(t, "UpdateStatus", mock.Anything, tt.id, api.StatusFailed, mock.Anything)
+			}
 		})
 	}
 }
 
-func TestNewSendNotificationViaTimeHandler(t *testing.T) {
-	testTime, err := time.ParseInLocation("2006-01-02 15:04:05", "2035-05-24 00:33:10", time.UTC)
-	require.NoError(t, err)
-
+func TestNewSendNotificationHandlerChecksRecipient(t *testing.T) {
 	tests := []struct {
 		name                string
-		requestContext      context.Context
-		body                string
-		email               SMTPClient.EmailMessage
-		id                  int
+		suppressed          bool
+		unsubscribed        bool
 		postgresError       error
-		redisError          error
 		wantStatusCode      int
 		wantResponseMessage string
+		wantStatus          string
 	}{
 		{
-			name:           "success",
-			requestContext: context.Background(),
-			body: `{
-				"time": "2035-05-24 00:33:10",
-				"to": "example@gmail.com",
-				"subject": "Subject",
-				"message": "Message"
-			}`,
-			email: SMTPClient.EmailMessage{
-				Type:    api.KeyForDelayedSending,
-				Time:    &testTime,
-				To:      "example@gmail.com",
-				Subject: "Subject",
-				Message: "Message",
-			},
-			id:                  1,
-			postgresError:       nil,
-			redisError:          nil,
+			name:                "recipient unsubscribed",
+			unsubscribed:        true,
 			wantStatusCode:      http.StatusOK,
-			wantResponseMessage: "{\"message\":\"Successfully saved your mail\",\"id\":1}\n",
-		},
-		{
-			name:                "error in decoder",
-			requestContext:      context.Background(),
-			body:                ``,
-			redisError:          nil,
-			wantStatusCode:      http.StatusBadRequest,
-			wantResponseMessage: "Request body must not be empty\n",
-		},
-		{
-			name:           "error in SendEmail",
-			requestContext: context.Background(),
-			body: `{
-				"time": "2035-05-24 00:33:10",
-				"to": "example@gmail.com",
-				"subject": "Subject",
-				"message": "Message"
-			}`,
-			email: SMTPClient.EmailMessage{
-				Type:    api.KeyForDelayedSending,
-				Time:    &testTime,
-				To:      "example@gmail.com",
-				Subject: "Subject",
-				Message: "Message",
-			},
-			redisError:          fmt.Errorf("SendEmail: cannot send message to"),
-			wantStatusCode:      http.StatusInternalServerError,
-			wantResponseMessage: http.StatusText(500) + "\n",
-		},
-		{
-			name:           "error in SavingInstantSending",
-			requestContext: context.Background(),
-			body: `{
-				"time": "2035-05-24 00:33:10",
-				"to": "example@gmail.com",
-				"subject": "Subject",
-				"message": "Message"
-			}`,
-			email: SMTPClient.EmailMessage{
-				Type:    api.KeyForDelayedSending,
-				Time:    &testTime,
-				To:      "example@gmail.com",
-				Subject: "Subject",
-				Message: "Message",
-			},
-			id:                  0,
-			postgresError:       fmt.Errorf("SavingInstantSending: failed to add email to database"),
-			redisError:          nil,
-			wantStatusCode:      http.StatusInternalServerError,
-			wantResponseMessage: http.StatusText(500) + "\n",
-		},
-		{
-			name: "context canceled before sending",
-			requestContext: func() context.Context {
-				canceledCtx, cancel := context.WithCancel(context.Background())
-				cancel()
-				return canceledCtx
-			}(),
-			body: `{
-				"time": "2035-05-24 00:33:10",
-				"to": "example@gmail.com",
-				"subject": "Subject",
-				"message": "Message"
-			}`,
-			redisError:          nil,
-			wantStatusCode:      http.StatusBadRequest,
-			wantResponseMessage: http.StatusText(400) + "\n",
+			wantResponseMessage: "{\"message\":\"Recipient has unsubscribed, notification skipped\",\"id\":5}\n",
+			wantStatus:          api.StatusSkipped,
 		},
 		{
-			name:           "context canceled during sending",
-			requestContext: context.Background(),
-			body: `{
-				"time": "2035-05-24 00:33:10",
-				"to": "example@gmail.com",
-				"subject": "Subject",
-				"message": "Message"
-			}`,
-			email: SMTPClient.EmailMessage{
-				Type:    api.KeyForDelayedSending,
-				Time:    &testTime,
-				To:      "example@gmail.com",
-				Subject: "Subject",
-				Message: "Message",
-			},
-			redisError:          context.Canceled,
-			wantStatusCode:      http.StatusInternalServerError,
-			wantResponseMessage: http.StatusText(500) + "\n",
+			name:                "recipient suppressed",
+			suppressed:          true,
+			wantStatusCode:      http.StatusConflict,
+			wantResponseMessage: "The recipient is on the suppression list",
+			wantStatus:          api.StatusSuppressed,
 		},
 		{
-			name: "context timeout before sending",
-			requestContext: func() context.Context {
-				canceledCtx, _ := context.WithTimeout(context.Background(), 1*time.Nanosecond)
-				time.Sleep(2 * time.Nanosecond)
-				return canceledCtx
-			}(),
-			body: `{
-				"time": "2035-05-24 00:33:10",
-				"to": "example@gmail.com",
-				"subject": "Subject",
-				"message": "Message"
-			}`,
-			redisError:          nil,
-			wantStatusCode:      http.StatusInternalServerError,
-			wantResponseMessage: http.StatusText(500) + "\n",
+			name:                "recipient subscribed",
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "{\"message\":\"Successfully sent notification\",\"id\":5}\n",
+			wantStatus:          api.StatusSent,
 		},
 		{
-			name:           "context timeout during sending",
-			requestContext: context.Background(),
-			body: `{
-				"time": "2035-05-24 00:33:10",
-				"to": "example@gmail.com",
-				"subject": "Subject",
-				"message": "Message"
-			}`,
-			email: SMTPClient.EmailMessage{
-				Type:    api.KeyForDelayedSending,
-				Time:    &testTime,
-				To:      "example@gmail.com",
-				Subject: "Subject",
-				Message: "Message",
-			},
-			redisError:          context.DeadlineExceeded,
+			name:                "cannot check unsubscribe",
+			postgresError:       fmt.Errorf("connection refused"),
 			wantStatusCode:      http.StatusInternalServerError,
-			wantResponseMessage: http.StatusText(500) + "\n",
+			wantResponseMessage: http.StatusText(500),
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			r := httptest.NewRequest("POST", "/send-notification-via-time", strings.NewReader(tt.body)).WithContext(tt.requestContext)
+			body := `{"to": "User <User@example.com>", "subject": "Subject", "message": "Message", "category": "newsletter",
+				"callback_url": "https://example.com/hook"}`
+
+			r := httptest.NewRequest("POST", "/send-notification", strings.NewReader(body))
 			w := httptest.NewRecorder()
 			r.Header.Set("content-type", "application/json")
 
 			mockSender := &SMTPClient.MockEmailSender{}
-			mockRedisClient := &redisClient.MockRedisClient{}
 			mockPostgresClient := &postgresClient.MockPostgresService{}
+			mockNotifier := &webhook.MockNotifier{}
 
 			notificationHandler := New(
 				zap.NewNop(),
 				mockSender,
-				mockRedisClient,
+				&redisClient.MockRedisClient{},
 				mockPostgresClient,
+				&decoder.Config{Webhooks: true},
+				nil,
+				nil,
+				mockNotifier,
+				nil,
 				config.AppTimeouts{},
 				3*time.Second,
 			)
 
-			mockRedisClient.On("AddDelayedEmail", mock.Anything, &tt.email).Return(tt.redisError)
-			mockPostgresClient.On("SaveEmail", mock.Anything, &tt.email).Return(tt.id, tt.postgresError)
+			mockPostgresClient.On("IsSuppressed", mock.Anything, "user@example.com").Return(tt.suppressed, nil)
+			mockPostgresClient.On("IsUnsubscribed", mock.Anything, "user@example.com", "newsletter").
+				Return(tt.unsubscribed, tt.postgresError)
+			mockPostgresClient.On("SaveEmail", mock.Anything, mock.Anything).Return(5, nil)
+			mockPostgresClient.On("UpdateStatus", mock.Anything, 5, mock.Anything, mock.Anything).Return(nil)
+			mockSender.On("CircuitOpen").Return(nil).Maybe()
+			mockSender.On("SendEmail", mock.Anything, mock.Anything).Return(nil, nil)
+			mockNotifier.On("Notify", mock.Anything, mock.Anything, mock.Anything)
 
-			handler := notificationHandler.NewSendNotificationViaTimeHandler(monitoring.NewNop())
+			handler := notificationHandler.NewSendNotificationHandler(monitoring.NewNop())
 			handler.ServeHTTP(w, r)
 
+			assertDocumented(t, "POST", "/send-notification", w)
+
 			assert.Equal(t, tt.wantStatusCode, w.Code)
-			assert.Equal(t, tt.wantResponseMessage, w.Body.String())
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+
+			if tt.wantStatus == "" {
+				mockPostgresClient.AssertNotCalled(t, "SaveEmail", mock.Anything, mock.Anything)
+				mockNotifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			mockPostgresClient.AssertCalled(t, "UpdateStatus", mock.Anything, 5, tt.wantStatus, mock.Anything)
+			mockNotifier.AssertCalled(t, "Notify", mock.Anything, "https://example.com/hook",
+				mock.MatchedBy(func(event webhook.Event) bool {
+					return event.ID == 5 && event.Status == tt.wantStatus && event.To == "User <User@example.com>"
+				}))
+
+			if tt.wantStatus != api.StatusSent {
+				mockSender.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything)
+			}
 		})
 	}
 }
 
-func TestNewListNotificationHandler(t *testing.T) {
+func TestNewUnsubscribeHandler(t *testing.T) {
+	tokens, err := unsubscribe.New(&unsubscribe.Config{Secret: "secret", BaseURL: "https://notify.example.com"})
+	require.NoError(t, err)
+
 	tests := []struct {
 		name                string
-		requestContext      context.Context
-		wantEmail           []*SMTPClient.EmailMessage
-		query               string
-		id                  int
+		tokens              *unsubscribe.Tokens
+		token               string
 		postgresError       error
 		wantStatusCode      int
 		wantResponseMessage string
+		wantUnsubscribe     bool
 	}{
 		{
-			name:           "invalid query",
-			requestContext: context.Background(),
-			wantEmail: []*SMTPClient.EmailMessage{{
-				Type:    "instantSending",
-				Time:    nil,
-				To:      "to",
-				Subject: "subject",
-				Message: "message",
-			}},
-			query:               "/list?by=invalid",
-			id:                  1,
-			postgresError:       ErrInvalidQuery,
-			wantStatusCode:      http.StatusBadRequest,
-			wantResponseMessage: "invalid query\n",
+			name:                "success",
+			tokens:              tokens,
+			token:               tokens.Token("user@example.com", "newsletter"),
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "You have been unsubscribed\n",
+			wantUnsubscribe:     true,
 		},
 		{
-			name: "context canceled before fetching",
-			requestContext: func() context.Context {
-				ctx, cancel := context.WithCancel(context.Background())
-				cancel()
-
-				return ctx
-			}(),
-			wantEmail: []*SMTPClient.EmailMessage{{
-				Type:    "instantSending",
-				Time:    nil,
-				To:      "to",
-				Subject: "subject",
-				Message: "message",
-			}},
-			query:               "/list?by=id&id=1",
-			id:                  1,
-			postgresError:       nil,
+			name:                "invalid token",
+			tokens:              tokens,
+			token:               "invalid",
 			wantStatusCode:      http.StatusBadRequest,
-			wantResponseMessage: http.StatusText(400) + "\n",
+			wantResponseMessage: "Invalid unsubscribe token",
 		},
 		{
-			name:           "context canceled during fetching",
-			requestContext: context.Background(),
-			wantEmail: []*SMTPClient.EmailMessage{{
-				Type:    "instantSending",
-				Time:    nil,
-				To:      "to",
-				Subject: "subject",
-				Message: "message",
-			}},
-			query:               "/list?by=id&id=1",
-			id:                  1,
-			postgresError:       context.Canceled,
+			name:                "unsubscribe disabled",
+			tokens:              nil,
+			token:               tokens.Token("user@example.com", "newsletter"),
 			wantStatusCode:      http.StatusBadRequest,
-			wantResponseMessage: http.StatusText(400) + "\n",
+			wantResponseMessage: "Invalid unsubscribe token",
 		},
 		{
-			name:           "context timeout before fetching",
-			requestContext: context.Background(),
-			wantEmail: []*SMTPClient.EmailMessage{{
-				Type:    "instantSending",
-				Time:    nil,
-				To:      "to",
-				Subject: "subject",
-				Message: "message",
-			}},
-			query:               "/list?by=id&id=1",
-			id:                  1,
-			postgresError:       context.DeadlineExceeded,
+			name:                "postgres error",
+			tokens:              tokens,
+			token:               tokens.Token("user@example.com", "newsletter"),
+			postgresError:       fmt.Errorf("connection refused"),
 			wantStatusCode:      http.StatusInternalServerError,
-			wantResponseMessage: http.StatusText(http.StatusInternalServerError) + "\n",
-		},
-		{
-			name: "context timeout during fetching",
-			requestContext: func() context.Context {
-				ctx, _ := context.WithTimeout(context.Background(), 1*time.Nanosecond)
-				time.Sleep(2 * time.Nanosecond)
-				return ctx
-			}(),
-			wantEmail: []*SMTPClient.EmailMessage{{
-				Type:    "instantSending",
-				Time:    nil,
-				To:      "to",
-				Subject: "subject",
-				Message: "message",
-			}},
-			query:               "/list?by=id&id=1",
-			id:                  1,
-			postgresError:       context.DeadlineExceeded,
-			wantStatusCode:      http.StatusInternalServerError,
-			wantResponseMessage: http.StatusText(http.StatusInternalServerError) + "\n",
-		},
-
-		{
-			name:           "something went wrong",
-			requestContext: context.Background(),
-			wantEmail: []*SMTPClient.EmailMessage{{
-				Type:    "instantSending",
-				Time:    nil,
-				To:      "to",
-				Subject: "subject",
-				Message: "message",
-			}},
-			query:               "/list?by=id&id=1",
-			id:                  1,
-			postgresError:       fmt.Errorf("something went wrong"),
-			wantStatusCode:      http.StatusInternalServerError,
-			wantResponseMessage: http.StatusText(500) + "\n",
+			wantResponseMessage: http.StatusText(500),
+			wantUnsubscribe:     true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			r := httptest.NewRequest("GET", tt.query, nil).WithContext(tt.requestContext)
+			r := httptest.NewRequest("POST", "/unsubscribe/"+tt.token, strings.NewReader("List-Unsubscribe=One-Click"))
 			w := httptest.NewRecorder()
-			r.Header.Set("content-type", "application/json")
+			r.Header.Set("content-type", "application/x-www-form-urlencoded")
 
-			mockSender := &SMTPClient.MockEmailSender{}
-			mockRedisClient := &redisClient.MockRedisClient{}
 			mockPostgresClient := &postgresClient.MockPostgresService{}
 
 			notificationHandler := New(
 				zap.NewNop(),
-				mockSender,
-				mockRedisClient,
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
 				mockPostgresClient,
+				&decoder.Config{},
+				tt.tokens,
+				nil,
+				nil,
+				nil,
 				config.AppTimeouts{},
 				3*time.Second,
 			)
 
-			mockPostgresClient.On("FetchById", mock.Anything, tt.id).Return(tt.wantEmail, tt.postgresError)
+			mockPostgresClient.On("Unsubscribe", mock.Anything, "user@example.com", "newsletter").Return(tt.postgresError)
 
-			handler := notificationHandler.NewListNotificationHandler(monitoring.NewNop())
-			handler.ServeHTTP(w, r)
+			router := chi.NewRouter()
+			router.Use(middleware.URLFormat)
+			router.Post("/unsubscribe/{token}", notificationHandler.NewUnsubscribeHandler(monitoring.NewNop()))
+			router.ServeHTTP(w, r)
+
+			assertDocumented(t, "POST", "/unsubscribe/{token}", w)
 
 			assert.Equal(t, tt.wantStatusCode, w.Code)
-			assert.Equal(t, tt.wantResponseMessage, w.Body.String())
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+
+			if tt.wantUnsubscribe {
+				mockPostgresClient.AssertCalled(t, "Unsubscribe", mock.Anything, "user@example.com", "newsletter")
+			} else {
+				mockPostgresClient.AssertNotCalled(t, "Unsubscribe", mock.Anything, mock.Anything, mock.Anything)
+			}
 		})
 	}
 }
 
-func TestNewListNotificationHandlerFetchById(t *testing.T) {
-	testTime, err := time.ParseInLocation("2006-01-02 15:04:05", "2035-05-24 00:33:10", time.UTC)
+func TestNewOpenTrackingHandler(t *testing.T) {
+	tracker, err := tracking.New(&tracking.Config{Secret: "secret", BaseURL: "https://notify.example.com"})
 	require.NoError(t, err)
 
+	token := strings.TrimPrefix(tracker.OpenURL(1), "https://notify.example.com/track/open/")
+
 	tests := []struct {
-		name                string
-		requestContext      context.Context
-		wantEmail           []*SMTPClient.EmailMessage
-		query               string
-		id                  int
-		postgresError       error
-		wantStatusCode      int
-		wantResponseMessage string
+		name           string
+		tracker        *tracking.Tracker
+		token          string
+		postgresError  error
+		wantStatusCode int
+		wantPixel      bool
+		wantSave       bool
 	}{
 		{
-			name:           "success for instantSending",
-			requestContext: context.Background(),
-			wantEmail: []*SMTPClient.EmailMessage{{
-				Type:    "instantSending",
-				Time:    nil,
-				To:      "to",
-				Subject: "subject",
-				Message: "message",
-			}},
-			query:               "/list?by=id&id=1",
-			id:                  1,
-			postgresError:       nil,
-			wantStatusCode:      http.StatusOK,
-			wantResponseMessage: "[{\"type\":\"instantSending\",\"to\":\"to\",\"subject\":\"subject\",\"message\":\"message\"}]\n",
+			name:           "success",
+			tracker:        tracker,
+			token:          token,
+			wantStatusCode: http.StatusOK,
+			wantPixel:      true,
+			wantSave:       true,
 		},
 		{
-			name:           "success for delayedSending",
-			requestContext: context.Background(),
-			wantEmail: []*SMTPClient.EmailMessage{{
-				Type:    "delayedSending",
-				Time:    &testTime,
-				To:      "to",
-				Subject: "subject",
-				Message: "message",
-			}},
-			query:               "/list?by=id&id=2",
-			id:                  2,
-			postgresError:       nil,
-			wantStatusCode:      http.StatusOK,
-			wantResponseMessage: "[{\"type\":\"delayedSending\",\"time\":\"2035-05-24T00:33:10Z\",\"to\":\"to\",\"subject\":\"subject\",\"message\":\"message\"}]\n",
+			name:           "postgres error",
+			tracker:        tracker,
+			token:          token,
+			postgresError:  pgx.ErrNoRows,
+			wantStatusCode: http.StatusOK,
+			wantPixel:      true,
+			wantSave:       true,
 		},
 		{
-			name:                "id not found",
-			requestContext:      context.Background(),
-			wantEmail:           nil,
-			query:               "/list?by=id&id=12",
-			id:                  12,
-			postgresError:       pgx.ErrNoRows,
-			wantStatusCode:      http.StatusBadRequest,
-			wantResponseMessage: "There are no results for the specified param\n\n",
+			name:           "invalid token",
+			tracker:        tracker,
+			token:          "invalid",
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "tracking disabled",
+			tracker:        nil,
+			token:          token,
+			wantStatusCode: http.StatusNotFound,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			r := httptest.NewRequest("GET", tt.query, nil).WithContext(tt.requestContext)
+			r := httptest.NewRequest("GET", "/track/open/"+tt.token, nil)
 			w := httptest.NewRecorder()
-			r.Header.Set("content-type", "application/json")
 
-			mockSender := &SMTPClient.MockEmailSender{}
-			mockRedisClient := &redisClient.MockRedisClient{}
 			mockPostgresClient := &postgresClient.MockPostgresService{}
 
 			notificationHandler := New(
 				zap.NewNop(),
-				mockSender,
-				mockRedisClient,
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
 				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				tt.tracker,
+				nil,
+				nil,
 				config.AppTimeouts{},
 				3*time.Second,
 			)
 
-			mockPostgresClient.On("FetchById", mock.Anything, tt.id).Return(tt.wantEmail, tt.postgresError)
+			mockPostgresClient.On("SaveTrackingEvent", mock.Anything, 1, api.EventOpen, "").Return(tt.postgresError)
 
-			handler := notificationHandler.NewListNotificationHandler(monitoring.NewNop())
-			handler.ServeHTTP(w, r)
+			router := chi.NewRouter()
+			router.Use(middleware.URLFormat)
+			router.Get("/track/open/{token}", notificationHandler.NewOpenTrackingHandler(monitoring.NewNop()))
+			router.ServeHTTP(w, r)
+
+			assertDocumented(t, "GET", "/track/open/{token}", w)
 
 			assert.Equal(t, tt.wantStatusCode, w.Code)
-			assert.Equal(t, tt.wantResponseMessage, w.Body.String())
+
+			if tt.wantPixel {
+				assert.Equal(t, "image/gif", w.Header().Get("Content-Type"))
+				assert.Equal(t, tracking.Pixel, w.Body.Bytes())
+			}
+
+			if tt.wantSave {
+				mockPostgresClient.AssertCalled(t, "SaveTrackingEvent", mock.Anything, 1, api.EventOpen, "")
+			} else {
+				mockPostgresClient.AssertNotCalled(t, "SaveTrackingEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
 		})
 	}
 }
 
-func TestNewListNotificationHandlerFetchByEmail(t *testing.T) {
-	testTime, err := time.ParseInLocation("2006-01-02 15:04:05", "2035-05-24 00:33:10", time.UTC)
+func TestNewClickTrackingHandler(t *testing.T) {
+	tracker, err := tracking.New(&tracking.Config{Secret: "secret", BaseURL: "https://notify.example.com"})
 	require.NoError(t, err)
 
+	target := "https://example.com/page?a=1"
+	token := strings.TrimPrefix(tracker.ClickURL(1, target), "https://notify.example.com/track/click/")
+
 	tests := []struct {
 		name                string
-		requestContext      context.Context
-		wantEmail           []*SMTPClient.EmailMessage
-		query               string
-		email               string
+		token               string
 		postgresError       error
 		wantStatusCode      int
 		wantResponseMessage string
+		wantSave            bool
 	}{
 		{
-			name:           "success for instantSending",
-			requestContext: context.Background(),
-			wantEmail: []*SMTPClient.EmailMessage{{
-				Type:    "instantSending",
-				Time:    nil,
-				To:      "to",
-				Subject: "subject",
-				Message: "message",
-			}},
-			query:               "/list?by=email&email=to",
-			email:               "to",
-			postgresError:       nil,
-			wantStatusCode:      http.StatusOK,
-			wantResponseMessage: "[{\"type\":\"instantSending\",\"to\":\"to\",\"subject\":\"subject\",\"message\":\"message\"}]\n",
-		},
-		{
-			name:           "success for delayedSending",
-			requestContext: context.Background(),
-			wantEmail: []*SMTPClient.EmailMessage{{
-				Type:    "delayedSending",
-				Time:    &testTime,
-				To:      "to",
-				Subject: "subject",
-				Message: "message",
-			}},
-			query:               "/list?by=email&email=to",
-			email:               "to",
-			postgresError:       nil,
-			wantStatusCode:      http.StatusOK,
-			wantResponseMessage: "[{\"type\":\"delayedSending\",\"time\":\"2035-05-24T00:33:10Z\",\"to\":\"to\",\"subject\":\"subject\",\"message\":\"message\"}]\n",
+			name:           "success",
+			token:          token,
+			wantStatusCode: http.StatusFound,
+			wantSave:       true,
 		},
 		{
-			name:           "multiple",
-			requestContext: context.Background(),
-			wantEmail: []*SMTPClient.EmailMessage{
-				{
-					Type:    "delayedSending",
-					Time:    &testTime,
-					To:      "common",
-					Subject: "subject",
-					Message: "message",
-				},
-				{
-					Type:    "instantSending",
-					Time:    nil,
-					To:      "common",
-					Subject: "subject",
-					Message: "message",
-				},
-			},
-			query:          "/list?by=email&email=common",
-			email:          "common",
-			postgresError:  nil,
-			wantStatusCode: http.StatusOK,
-			wantResponseMessage: "[{\"type\":\"delayedSending\",\"time\":\"2035-05-24T00:33:10Z\",\"to\":\"common\",\"subject\":\"subject\",\"message\":\"message\"}," +
-				"{\"type\":\"instantSending\",\"to\":\"common\",\"subject\":\"subject\",\"message\":\"message\"}]\n",
+			name:           "postgres error",
+			token:          token,
+			postgresError:  fmt.Errorf("connection refused"),
+			wantStatusCode: http.StatusFound,
+			wantSave:       true,
 		},
 		{
-			name:                "email not found",
-			requestContext:      context.Background(),
-			wantEmail:           nil,
-			query:               "/list?by=email&email=notExists",
-			email:               "notExists",
-			postgresError:       pgx.ErrNoRows,
+			name:                "invalid token",
+			token:               "invalid",
 			wantStatusCode:      http.StatusBadRequest,
-			wantResponseMessage: "There are no results for the specified param\n\n",
+			wantResponseMessage: "Invalid tracking link",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			r := httptest.NewRequest("GET", tt.query, nil).WithContext(tt.requestContext)
+			r := httptest.NewRequest("GET", "/track/click/"+tt.token, nil)
 			w := httptest.NewRecorder()
-			r.Header.Set("content-type", "application/json")
 
-			mockSender := &SMTPClient.MockEmailSender{}
-			mockRedisClient := &redisClient.MockRedisClient{}
 			mockPostgresClient := &postgresClient.MockPostgresService{}
 
 			notificationHandler := New(
 				zap.NewNop(),
-				mockSender,
-				mockRedisClient,
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
 				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				tracker,
+				nil,
+				nil,
 				config.AppTimeouts{},
 				3*time.Second,
 			)
 
-			mockPostgresClient.On("FetchByEmail", mock.Anything, tt.email).Return(tt.wantEmail, tt.postgresError)
+			mockPostgresClient.On("SaveTrackingEvent", mock.Anything, 1, api.EventClick, target).Return(tt.postgresError)
 
-			handler := notificationHandler.NewListNotificationHandler(monitoring.NewNop())
-			handler.ServeHTTP(w, r)
+			router := chi.NewRouter()
+			router.Use(middleware.URLFormat)
+			router.Get("/track/click/{token}", notificationHandler.NewClickTrackingHandler(monitoring.NewNop()))
+			router.ServeHTTP(w, r)
+
+			assertDocumented(t, "GET", "/track/click/{token}", w)
 
 			assert.Equal(t, tt.wantStatusCode, w.Code)
-			assert.Equal(t, tt.wantResponseMessage, w.Body.String())
+
+			if tt.wantSave {
+				assert.Equal(t, target, w.Header().Get("Location"))
+				mockPostgresClient.AssertCalled(t, "SaveTrackingEvent", mock.Anything, 1, api.EventClick, target)
+			} else {
+				assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+				mockPostgresClient.AssertNotCalled(t, "SaveTrackingEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
 		})
 	}
 }
 
-func TestNewListNotificationHandlerFetchByAll(t *testing.T) {
-	testTime, err := time.ParseInLocation("2006-01-02 15:04:05", "2035-05-24 00:33:10", time.UTC)
-	require.NoError(t, err)
-
+func TestNewEventsHandler(t *testing.T) {
 	tests := []struct {
 		name                string
-		requestContext      context.Context
-		wantEmail           []*SMTPClient.EmailMessage
 		query               string
-		postgresError       error
 		wantStatusCode      int
 		wantResponseMessage string
 	}{
 		{
-			name:           "success for instantSending",
-			requestContext: context.Background(),
-			wantEmail: []*SMTPClient.EmailMessage{{
-				Type:    "instantSending",
-				Time:    nil,
-				To:      "to",
-				Subject: "subject",
-				Message: "message",
-			}},
-			query:               "/list?by=all",
-			postgresError:       nil,
-			wantStatusCode:      http.StatusOK,
-			wantResponseMessage: "[{\"type\":\"instantSending\",\"to\":\"to\",\"subject\":\"subject\",\"message\":\"message\"}]\n",
+			name:           "all events",
+			query:          "",
+			wantStatusCode: http.StatusOK,
+			wantResponseMessage: ": connected\n\n" +
+				"event: status\ndata: {\"id\":1,\"status\":\"sent\",\"to\":\"user@example.com\",\"created_at\":\"2025-07-13T11:58:00Z\"}\n\n" +
+				"event: status\ndata: {\"id\":2,\"status\":\"failed\",\"to\":\"other@example.com\",\"created_at\":\"2025-07-13T11:58:00Z\"}\n\n",
 		},
 		{
-			name:           "success for delayedSending",
-			requestContext: context.Background(),
-			wantEmail: []*SMTPClient.EmailMessage{{
-				Type:    "delayedSending",
-				Time:    &testTime,
-				To:      "to",
-				Subject: "subject",
-				Message: "message",
-			}},
-			query:               "/list?by=all",
-			postgresError:       nil,
-			wantStatusCode:      http.StatusOK,
-			wantResponseMessage: "[{\"type\":\"delayedSending\",\"time\":\"2035-05-24T00:33:10Z\",\"to\":\"to\",\"subject\":\"subject\",\"message\":\"message\"}]\n",
+			name:           "by id",
+			query:          "?id=2",
+			wantStatusCode: http.StatusOK,
+			wantResponseMessage: ": connected\n\n" +
+				"event: status\ndata: {\"id\":2,\"status\":\"failed\",\"to\":\"other@example.com\",\"created_at\":\"2025-07-13T11:58:00Z\"}\n\n",
 		},
 		{
-			name:           "multiple",
-			requestContext: context.Background(),
-			wantEmail: []*SMTPClient.EmailMessage{
-				{
-					Type:    "delayedSending",
-					Time:    &testTime,
-					To:      "common",
-					Subject: "subject",
-					Message: "message",
-				},
-				{
-					Type:    "instantSending",
-					Time:    nil,
-					To:      "common",
-					Subject: "subject",
-					Message: "message",
-				},
-			},
-			query:          "/list?by=all",
-			postgresError:  nil,
+			name:           "by recipient",
+			query:          "?recipient=User@example.com",
 			wantStatusCode: http.StatusOK,
-			wantResponseMessage: "[{\"type\":\"delayedSending\",\"time\":\"2035-05-24T00:33:10Z\",\"to\":\"common\",\"subject\":\"subject\",\"message\":\"message\"}," +
-				"{\"type\":\"instantSending\",\"to\":\"common\",\"subject\":\"subject\",\"message\":\"message\"}]\n",
+			wantResponseMessage: ": connected\n\n" +
+				"event: status\ndata: {\"id\":1,\"status\":\"sent\",\"to\":\"user@example.com\",\"created_at\":\"2025-07-13T11:58:00Z\"}\n\n",
 		},
 		{
-			name:                "email not found",
-			requestContext:      context.Background(),
-			wantEmail:           nil,
-			query:               "/list?by=all",
-			postgresError:       pgx.ErrNoRows,
+			name:                "invalid id",
+			query:               "?id=abc",
 			wantStatusCode:      http.StatusBadRequest,
-			wantResponseMessage: "There are no results for the specified param\n\n",
+			wantResponseMessage: "The id query parameter must be a positive integer",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			r := httptest.NewRequest("GET", tt.query, nil).WithContext(tt.requestContext)
-			w := httptest.NewRecorder()
-			r.Header.Set("content-type", "application/json")
-
-			mockSender := &SMTPClient.MockEmailSender{}
-			mockRedisClient := &redisClient.MockRedisClient{}
-			mockPostgresClient := &postgresClient.MockPostgresService{}
+			bus := events.New(&events.Config{}, nil, monitoring.NewNop(), zap.NewNop())
 
 			notificationHandler := New(
 				zap.NewNop(),
-				mockSender,
-				mockRedisClient,
-				mockPostgresClient,
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
+				&postgresClient.MockPostgresService{},
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				bus,
 				config.AppTimeouts{},
 				3*time.Second,
 			)
 
-			mockPostgresClient.On("FetchByAll", mock.Anything).Return(tt.wantEmail, tt.postgresError)
+			server := httptest.NewServer(notificationHandler.NewEventsHandler(monitoring.NewNop()))
+			defer server.Close()
 
-			handler := notificationHandler.NewListNotificationHandler(monitoring.NewNop())
-			handler.ServeHTTP(w, r)
+			resp, err := http.Get(server.URL + "/notifications/events" + tt.query)
+			require.NoError(t, err)
+			defer resp.Body.Close()
 
-			assert.Equal(t, tt.wantStatusCode, w.Code)
-			assert.Equal(t, tt.wantResponseMessage, w.Body.String())
+			assert.Equal(t, tt.wantStatusCode, resp.StatusCode)
+
+			if tt.wantStatusCode == http.StatusOK {
+				assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+				assert.NoError(t, openapi.ValidateResponse("GET", "/notifications/events", resp.StatusCode, resp.Header, nil))
+
+				connected := make([]byte, len(": connected\n\n"))
+				_, err = io.ReadFull(resp.Body, connected)
+				require.NoError(t, err)
+
+				createdAt := time.Date(2025, 7, 13, 11, 58, 0, 0, time.UTC)
+
+				bus.Publish(context.Background(), api.StatusEvent{ID: 1, Status: api.StatusSent, To: "user@example.com", CreatedAt: createdAt})
+				bus.Publish(context.Background(), api.StatusEvent{ID: 2, Status: api.StatusFailed, To: "other@example.com", CreatedAt: createdAt})
+
+				bus.Close()
+
+				rest, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+
+				assert.Equal(t, tt.wantResponseMessage, string(connected)+string(rest))
+
+				return
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			var got problem.Problem
+			require.NoError(t, json.Unmarshal(body, &got))
+
+			assert.Equal(t, problem.ContentType, resp.Header.Get("Content-Type"))
+			assert.NoError(t, openapi.ValidateResponse("GET", "/notifications/events", resp.StatusCode, resp.Header, body))
+			assert.Equal(t, tt.wantResponseMessage, got.Detail)
 		})
 	}
 }
+
+func TestNewSendNotificationViaTimeHandler(t *testing.T) {
+	testTime, err := time.ParseInLocation("2006-01-02 15:04:05", "2035-05-24 00:33:10", time.UTC)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name                string
+		requestContext      context.Context
+		body                string
+		email               SMTPClient.EmailMessage
+		id                  int
+		postgresError       error
+		redisError          error
+		wantStatusCode      int
+		wantResponseMessage string
+	}{
+		{
+			name:           "success",
+			requestContext: context.Background(),
+			body: `{
+				"time": "2035-05-24 00:33:10",
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message"
+			}`,
+			email: SMTPClient.EmailMessage{
+				Type:    api.KeyForDelayedSending,
+				Time:    &testTime,
+				To:      "example@gmail.com",
+				Subject: "Subject",
+				Message: "Message",
+			},
+			id:                  1,
+			postgresError:       nil,
+			redisError:          nil,
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "{\"message\":\"Successfully saved your mail\",\"id\":1}\n",
+		},
+		{
+			name:                "error in decoder",
+			requestContext:      context.Background(),
+			body:                ``,
+			redisError:          nil,
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "Request body must not be empty",
+		},
+		{
+			name:           "error in SendEmail",
+			requestContext: context.Background(),
+			body: `{
+				"time": "2035-05-24 00:33:10",
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message"
+			}`,
+			email: SMTPClient.EmailMessage{
+				Type:    api.KeyForDelayedSending,
+				Time:    &testTime,
+				To:      "example@gmail.com",
+				Subject: "Subject",
+				Message: "Message",
+			},
+			redisError:          fmt.Errorf("SendEmail: cannot send message to"),
+			wantStatusCode:      http.StatusInternalServerError,
+			wantResponseMessage: http.StatusText(500),
+		},
+		{
+			name:           "error in SavingInstantSending",
+			requestContext: context.Background(),
+			body: `{
+				"time": "2035-05-24 00:33:10",
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message"
+			}`,
+			email: SMTPClient.EmailMessage{
+				Type:    api.KeyForDelayedSending,
+				Time:    &testTime,
+				To:      "example@gmail.com",
+				Subject: "Subject",
+				Message: "Message",
+			},
+			id:                  0,
+			postgresError:       fmt.Errorf("SavingInstantSending: failed to add email to database"),
+			redisError:          nil,
+			wantStatusCode:      http.StatusInternalServerError,
+			wantResponseMessage: http.StatusText(500),
+		},
+		{
+			name: "context canceled before sending",
+			requestContext: func() context.Context {
+				canceledCtx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return canceledCtx
+			}(),
+			body: `{
+				"time": "2035-05-24 00:33:10",
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message"
+			}`,
+			redisError:          nil,
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: http.StatusText(400),
+		},
+		{
+			name:           "context canceled during sending",
+			requestContext: context.Background(),
+			body: `{
+				"time": "2035-05-24 00:33:10",
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message"
+			}`,
+			email: SMTPClient.EmailMessage{
+				Type:    api.KeyForDelayedSending,
+				Time:    &testTime,
+				To:      "example@gmail.com",
+				Subject: "Subject",
+				Message: "Message",
+			},
+			redisError:          context.Canceled,
+			wantStatusCode:      http.StatusInternalServerError,
+			wantResponseMessage: http.StatusText(500),
+		},
+		{
+			name: "context timeout before sending",
+			requestContext: func() context.Context {
+				canceledCtx, _ := context.WithTimeout(context.Background(), 1*time.Nanosecond)
+				time.Sleep(2 * time.Nanosecond)
+				return canceledCtx
+			}(),
+			body: `{
+				"time": "2035-05-24 00:33:10",
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message"
+			}`,
+			redisError:          nil,
+			wantStatusCode:      http.StatusInternalServerError,
+			wantResponseMessage: http.StatusText(500),
+		},
+		{
+			name:           "context timeout during sending",
+			requestContext: context.Background(),
+			body: `{
+				"time": "2035-05-24 00:33:10",
+				"to": "example@gmail.com",
+				"subject": "Subject",
+				"message": "Message"
+			}`,
+			email: SMTPClient.EmailMessage{
+				Type:    api.KeyForDelayedSending,
+				Time:    &testTime,
+				To:      "example@gmail.com",
+				Subject: "Subject",
+				Message: "Message",
+			},
+			redisError:          context.DeadlineExceeded,
+			wantStatusCode:      http.StatusInternalServerError,
+			wantResponseMessage: http.StatusText(500),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/send-notification-via-time", strings.NewReader(tt.body)).WithContext(tt.requestContext)
+			w := httptest.NewRecorder()
+			r.Header.Set("content-type", "application/json")
+
+			mockSender := &SMTPClient.MockEmailSender{}
+			mockRedisClient := &redisClient.MockRedisClient{}
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+
+			notificationHandler := New(
+				zap.NewNop(),
+				mockSender,
+				mockRedisClient,
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			scheduledEmail := tt.email
+			scheduledEmail.Id = tt.id
+
+			mockRedisClient.On("AddDelayedEmail", mock.Anything, &scheduledEmail).Return(tt.redisError)
+			mockPostgresClient.On("SaveEmail", mock.Anything, &tt.email).Return(tt.id, tt.postgresError)
+			mockPostgresClient.On("UpdateStatus", mock.Anything, tt.id, api.StatusFailed, mock.Anything).Return(nil)
+
+			handler := notificationHandler.NewSendNotificationViaTimeHandler(monitoring.NewNop())
+			handler.ServeHTTP(w, r)
+
+			assertDocumented(t, "POST", "/send-notification-via-time", w)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+		})
+	}
+}
+
+func TestNewListNotificationHandler(t *testing.T) {
+	tests := []struct {
+		name                string
+		requestContext      context.Context
+		wantEmail           []*api.Notification
+		query               string
+		id                  int
+		postgresError       error
+		wantStatusCode      int
+		wantResponseMessage string
+	}{
+		{
+			name:           "invalid query",
+			requestContext: context.Background(),
+			wantEmail: []*api.Notification{{
+				Type:    "instantSending",
+				Time:    nil,
+				To:      "to",
+				Subject: "subject",
+				Message: "message",
+			}},
+			query:               "/list?by=invalid",
+			id:                  1,
+			postgresError:       ErrInvalidQuery,
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "invalid query",
+		},
+		{
+			name: "context canceled before fetching",
+			requestContext: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				return ctx
+			}(),
+			wantEmail: []*api.Notification{{
+				Type:    "instantSending",
+				Time:    nil,
+				To:      "to",
+				Subject: "subject",
+				Message: "message",
+			}},
+			query:               "/list?by=id&id=1",
+			id:                  1,
+			postgresError:       nil,
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: http.StatusText(400),
+		},
+		{
+			name:           "context canceled during fetching",
+			requestContext: context.Background(),
+			wantEmail: []*api.Notification{{
+				Type:    "instantSending",
+				Time:    nil,
+				To:      "to",
+				Subject: "subject",
+				Message: "message",
+			}},
+			query:               "/list?by=id&id=1",
+			id:                  1,
+			postgresError:       context.Canceled,
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: http.StatusText(400),
+		},
+		{
+			name:           "context timeout before fetching",
+			requestContext: context.Background(),
+			wantEmail: []*api.Notification{{
+				Type:    "instantSending",
+				Time:    nil,
+				To:      "to",
+				Subject: "subject",
+				Message: "message",
+			}},
+			query:               "/list?by=id&id=1",
+			id:                  1,
+			postgresError:       context.DeadlineExceeded,
+			wantStatusCode:      http.StatusInternalServerError,
+			wantResponseMessage: http.StatusText(http.StatusInternalServerError),
+		},
+		{
+			name: "context timeout during fetching",
+			requestContext: func() context.Context {
+				ctx, _ := context.WithTimeout(context.Background(), 1*time.Nanosecond)
+				time.Sleep(2 * time.Nanosecond)
+				return ctx
+			}(),
+			wantEmail: []*api.Notification{{
+				Type:    "instantSending",
+				Time:    nil,
+				To:      "to",
+				Subject: "subject",
+				Message: "message",
+			}},
+			query:               "/list?by=id&id=1",
+			id:                  1,
+			postgresError:       context.DeadlineExceeded,
+			wantStatusCode:      http.StatusInternalServerError,
+			wantResponseMessage: http.StatusText(http.StatusInternalServerError),
+		},
+
+		{
+			name:           "something went wrong",
+			requestContext: context.Background(),
+			wantEmail: []*api.Notification{{
+				Type:    "instantSending",
+				Time:    nil,
+				To:      "to",
+				Subject: "subject",
+				Message: "message",
+			}},
+			query:               "/list?by=id&id=1",
+			id:                  1,
+			postgresError:       fmt.Errorf("something went wrong"),
+			wantStatusCode:      http.StatusInternalServerError,
+			wantResponseMessage: http.StatusText(500),
+		},
+		{
+			name:                "notification of other tenant",
+			requestContext:      auth.NewContext(context.Background(), &auth.Identity{Client: "user-1", Tenant: "other"}),
+			wantEmail:           []*api.Notification{{ID: 1, To: "to", Tenant: "acme"}},
+			query:               "/list?by=id&id=1",
+			id:                  1,
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "There are no results for the specified param",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.query, nil).WithContext(tt.requestContext)
+			w := httptest.NewRecorder()
+			r.Header.Set("content-type", "application/json")
+
+			mockSender := &SMTPClient.MockEmailSender{}
+			mockRedisClient := &redisClient.MockRedisClient{}
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+
+			notificationHandler := New(
+				zap.NewNop(),
+				mockSender,
+				mockRedisClient,
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			mockPostgresClient.On("FetchById", mock.Anything, tt.id).Return(tt.wantEmail, tt.postgresError)
+
+			handler := notificationHandler.NewListNotificationHandler(monitoring.NewNop())
+			handler.ServeHTTP(w, r)
+
+			assertDocumented(t, "GET", "/list", w)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+		})
+	}
+}
+
+func TestNewListNotificationHandlerFetchById(t *testing.T) {
+	testTime, err := time.ParseInLocation("2006-01-02 15:04:05", "2035-05-24 00:33:10", time.UTC)
+	require.NoError(t, err)
+
+	createdAt := time.Date(2025, 5, 23, 10, 0, 0, 0, time.UTC)
+	sentAt := time.Date(2025, 5, 23, 10, 0, 1, 0, time.UTC)
+
+	tests := []struct {
+		name                string
+		requestContext      context.Context
+		wantEmail           []*api.Notification
+		query               string
+		id                  int
+		postgresError       error
+		wantStatusCode      int
+		wantResponseMessage string
+	}{
+		{
+			name:           "success for instantSending",
+			requestContext: context.Background(),
+			wantEmail: []*api.Notification{{
+				ID:        1,
+				Status:    api.StatusSent,
+				Type:      "instantSending",
+				Time:      nil,
+				To:        "to",
+				Subject:   "subject",
+				Message:   "message",
+				CreatedAt: createdAt,
+				SentAt:    &sentAt,
+			}},
+			query:               "/list?by=id&id=1",
+			id:                  1,
+			postgresError:       nil,
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "[{\"id\":1,\"type\":\"instantSending\",\"status\":\"sent\",\"to\":\"to\",\"subject\":\"subject\",\"message\":\"message\",\"created_at\":\"2025-05-23T10:00:00Z\",\"sent_at\":\"2025-05-23T10:00:01Z\"}]\n",
+		},
+		{
+			name:           "success for delayedSending",
+			requestContext: context.Background(),
+			wantEmail: []*api.Notification{{
+				ID:        2,
+				Status:    api.StatusPending,
+				Type:      "delayedSending",
+				Time:      &testTime,
+				To:        "to",
+				Subject:   "subject",
+				Message:   "message",
+				CreatedAt: createdAt,
+			}},
+			query:               "/list?by=id&id=2",
+			id:                  2,
+			postgresError:       nil,
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "[{\"id\":2,\"type\":\"delayedSending\",\"status\":\"pending\",\"time\":\"2035-05-24T00:33:10Z\",\"to\":\"to\",\"subject\":\"subject\",\"message\":\"message\",\"created_at\":\"2025-05-23T10:00:00Z\"}]\n",
+		},
+		{
+			name:                "id not found",
+			requestContext:      context.Background(),
+			wantEmail:           nil,
+			query:               "/list?by=id&id=12",
+			id:                  12,
+			postgresError:       pgx.ErrNoRows,
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "There are no results for the specified param",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.query, nil).WithContext(tt.requestContext)
+			w := httptest.NewRecorder()
+			r.Header.Set("content-type", "application/json")
+
+			mockSender := &SMTPClient.MockEmailSender{}
+			mockRedisClient := &redisClient.MockRedisClient{}
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+
+			notificationHandler := New(
+				zap.NewNop(),
+				mockSender,
+				mockRedisClient,
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			mockPostgresClient.On("FetchById", mock.Anything, tt.id).Return(tt.wantEmail, tt.postgresError)
+
+			handler := notificationHandler.NewListNotificationHandler(monitoring.NewNop())
+			handler.ServeHTTP(w, r)
+
+			assertDocumented(t, "GET", "/list", w)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+		})
+	}
+}
+
+func TestNewListNotificationHandlerFetchByMessageID(t *testing.T) {
+	createdAt := time.Date(2025, 5, 23, 10, 0, 0, 0, time.UTC)
+	sentAt := time.Date(2025, 5, 23, 10, 0, 1, 0, time.UTC)
+
+	tests := []struct {
+		name                string
+		wantEmail           []*api.Notification
+		query               string
+		messageID           string
+		postgresError       error
+		wantStatusCode      int
+		wantResponseMessage string
+	}{
+		{
+			name: "success",
+			wantEmail: []*api.Notification{{
+				ID:        1,
+				Status:    api.StatusSent,
+				Type:      "instantSending",
+				To:        "to",
+				Subject:   "subject",
+				Message:   "message",
+				MessageID: "<notification.1@example.com>",
+				CreatedAt: createdAt,
+				SentAt:    &sentAt,
+			}},
+			query:          "/list?by=message_id&message_id=" + url.QueryEscape("<notification.1@example.com>"),
+			messageID:      "<notification.1@example.com>",
+			wantStatusCode: http.StatusOK,
+			wantResponseMessage: "[{\"id\":1,\"type\":\"instantSending\",\"status\":\"sent\",\"to\":\"to\",\"subject\":\"subject\",\"message\":\"message\"," +
+				"\"message_id\":\"\\u003cnotification.1@example.com\\u003e\",\"created_at\":\"2025-05-23T10:00:00Z\",\"sent_at\":\"2025-05-23T10:00:01Z\"}]\n",
+		},
+		{
+			name: "success without angle brackets",
+			wantEmail: []*api.Notification{{
+				ID:        1,
+				Status:    api.StatusSent,
+				Type:      "instantSending",
+				To:        "to",
+				Subject:   "subject",
+				Message:   "message",
+				MessageID: "<notification.2@example.com>",
+				InReplyTo: "<notification.1@example.com>",
+				CreatedAt: createdAt,
+				SentAt:    &sentAt,
+			}},
+			query:          "/list?by=message_id&message_id=notification.2@example.com",
+			messageID:      "<notification.2@example.com>",
+			wantStatusCode: http.StatusOK,
+			wantResponseMessage: "[{\"id\":1,\"type\":\"instantSending\",\"status\":\"sent\",\"to\":\"to\",\"subject\":\"subject\",\"message\":\"message\"," +
+				"\"message_id\":\"\\u003cnotification.2@example.com\\u003e\",\"in_reply_to\":\"\\u003cnotification.1@example.com\\u003e\",\"created_at\":\"2025-05-23T10:00:00Z\",\"sent_at\":\"2025-05-23T10:00:01Z\"}]\n",
+		},
+		{
+			name:                "empty Message-ID",
+			query:               "/list?by=message_id",
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "invalid query",
+		},
+		{
+			name:                "Message-ID not found",
+			query:               "/list?by=message_id&message_id=notification.3@example.com",
+			messageID:           "<notification.3@example.com>",
+			postgresError:       pgx.ErrNoRows,
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "There are no results for the specified param",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.query, nil)
+			w := httptest.NewRecorder()
+
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+
+			notificationHandler := New(
+				zap.NewNop(),
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			mockPostgresClient.On("FetchByMessageID", mock.Anything, tt.messageID).Return(tt.wantEmail, tt.postgresError)
+
+			handler := notificationHandler.NewListNotificationHandler(monitoring.NewNop())
+			handler.ServeHTTP(w, r)
+
+			assertDocumented(t, "GET", "/list", w)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+		})
+	}
+}
+
+func TestNewListNotificationHandlerFetchByEmail(t *testing.T) {
+	testTime, err := time.ParseInLocation("2006-01-02 15:04:05", "2035-05-24 00:33:10", time.UTC)
+	require.NoError(t, err)
+
+	createdAt := time.Date(2025, 5, 23, 10, 0, 0, 0, time.UTC)
+	sentAt := time.Date(2025, 5, 23, 10, 0, 1, 0, time.UTC)
+
+	tests := []struct {
+		name                string
+		identity            *auth.Identity
+		query               string
+		wantFilter          *api.ListFilter
+		wantEmail           []*api.Notification
+		next                int
+		postgresError       error
+		wantStatusCode      int
+		wantResponseMessage string
+	}{
+		{
+			name:       "first page",
+			query:      "/list?by=email&email=common",
+			wantFilter: &api.ListFilter{To: "common", Limit: api.DefaultListLimit},
+			wantEmail: []*api.Notification{
+				{
+					ID:        1,
+					Status:    api.StatusPending,
+					Type:      "delayedSending",
+					Time:      &testTime,
+					To:        "common",
+					Subject:   "subject",
+					Message:   "message",
+					CreatedAt: createdAt,
+				},
+				{
+					ID:        2,
+					Status:    api.StatusSent,
+					Type:      "instantSending",
+					To:        "common",
+					Subject:   "subject",
+					Message:   "message",
+					CreatedAt: createdAt,
+					SentAt:    &sentAt,
+				},
+			},
+			next:           2,
+			wantStatusCode: http.StatusOK,
+			wantResponseMessage: "{\"items\":[{\"id\":1,\"type\":\"delayedSending\",\"status\":\"pending\",\"time\":\"2035-05-24T00:33:10Z\",\"to\":\"common\",\"subject\":\"subject\",\"message\":\"message\",\"created_at\":\"2025-05-23T10:00:00Z\"}," +
+				"{\"id\":2,\"type\":\"instantSending\",\"status\":\"sent\",\"to\":\"common\",\"subject\":\"subject\",\"message\":\"message\",\"created_at\":\"2025-05-23T10:00:00Z\",\"sent_at\":\"2025-05-23T10:00:01Z\"}],\"next_cursor\":\"Mg\"}\n",
+		},
+		{
+			name:       "last page of the tenant",
+			identity:   &auth.Identity{Client: "billing", Scopes: []string{api.ScopeRead}, Tenant: "acme"},
+			query:      "/list?by=email&email=to&limit=10&cursor=Mg&status=sent",
+			wantFilter: &api.ListFilter{To: "to", Status: api.StatusSent, AfterID: 2, Limit: 10, Tenant: "acme"},
+			wantEmail: []*api.Notification{{
+				ID:        3,
+				Status:    api.StatusSent,
+				Type:      "instantSending",
+				To:        "to",
+				Subject:   "subject",
+				Message:   "message",
+				CreatedAt: createdAt,
+				SentAt:    &sentAt,
+				Tenant:    "acme",
+			}},
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "{\"items\":[{\"id\":3,\"type\":\"instantSending\",\"status\":\"sent\",\"to\":\"to\",\"subject\":\"subject\",\"message\":\"message\",\"tenant\":\"acme\",\"created_at\":\"2025-05-23T10:00:00Z\",\"sent_at\":\"2025-05-23T10:00:01Z\"}]}\n",
+		},
+		{
+			name:                "email not found",
+			query:               "/list?by=email&email=notExists",
+			wantFilter:          &api.ListFilter{To: "notExists", Limit: api.DefaultListLimit},
+			wantEmail:           []*api.Notification{},
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "{\"items\":[]}\n",
+		},
+		{
+			name:                "missing email",
+			query:               "/list?by=email",
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "invalid query: email is required with by=email",
+		},
+		{
+			name:                "something went wrong",
+			query:               "/list?by=email&email=to",
+			wantFilter:          &api.ListFilter{To: "to", Limit: api.DefaultListLimit},
+			postgresError:       fmt.Errorf("something went wrong"),
+			wantStatusCode:      http.StatusInternalServerError,
+			wantResponseMessage: http.StatusText(500),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.query, nil)
+			w := httptest.NewRecorder()
+
+			if tt.identity != nil {
+				r = r.WithContext(auth.NewContext(r.Context(), tt.identity))
+			}
+
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+
+			notificationHandler := New(
+				zap.NewNop(),
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			if tt.wantFilter != nil {
+				mockPostgresClient.On("FetchPage", mock.Anything, tt.wantFilter).Return(tt.wantEmail, tt.next, tt.postgresError)
+			}
+
+			handler := notificationHandler.NewListNotificationHandler(monitoring.NewNop())
+			handler.ServeHTTP(w, r)
+
+			assertDocumented(t, "GET", "/list", w)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+
+			mockPostgresClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewListNotificationHandlerFetchPage(t *testing.T) {
+	testTime, err := time.ParseInLocation("2006-01-02 15:04:05", "2035-05-24 00:33:10", time.UTC)
+	require.NoError(t, err)
+
+	sentAfter := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	createdAt := time.Date(2025, 5, 23, 10, 0, 0, 0, time.UTC)
+	sentAt := time.Date(2025, 5, 23, 10, 0, 1, 0, time.UTC)
+
+	tests := []struct {
+		name                string
+		query               string
+		wantFilter          *api.ListFilter
+		wantEmail           []*api.Notification
+		next                int
+		postgresError       error
+		wantStatusCode      int
+		wantResponseMessage string
+	}{
+		{
+			name:       "first page",
+			query:      "/list?by=all",
+			wantFilter: &api.ListFilter{Limit: api.DefaultListLimit},
+			wantEmail: []*api.Notification{
+				{
+					ID:        1,
+					Status:    api.StatusPending,
+					Type:      "delayedSending",
+					Time:      &testTime,
+					To:        "common",
+					Subject:   "subject",
+					Message:   "message",
+					CreatedAt: createdAt,
+				},
+				{
+					ID:        2,
+					Status:    api.StatusSent,
+					Type:      "instantSending",
+					To:        "common",
+					Subject:   "subject",
+					Message:   "message",
+					CreatedAt: createdAt,
+					SentAt:    &sentAt,
+				},
+			},
+			next:           2,
+			wantStatusCode: http.StatusOK,
+			wantResponseMessage: "{\"items\":[{\"id\":1,\"type\":\"delayedSending\",\"status\":\"pending\",\"time\":\"2035-05-24T00:33:10Z\",\"to\":\"common\",\"subject\":\"subject\",\"message\":\"message\",\"created_at\":\"2025-05-23T10:00:00Z\"}," +
+				"{\"id\":2,\"type\":\"instantSending\",\"status\":\"sent\",\"to\":\"common\",\"subject\":\"subject\",\"message\":\"message\",\"created_at\":\"2025-05-23T10:00:00Z\",\"sent_at\":\"2025-05-23T10:00:01Z\"}],\"next_cursor\":\"Mg\"}\n",
+		},
+		{
+			name:  "last page with filters",
+			query: "/list?by=all&limit=10&cursor=Mg&order=desc&type=instantSending&status=sent&subject=news&sent_after=2025-07-01T00:00:00Z",
+			wantFilter: &api.ListFilter{
+				Type:      "instantSending",
+				Status:    "sent",
+				Subject:   "news",
+				SentAfter: &sentAfter,
+				AfterID:   2,
+				Desc:      true,
+				Limit:     10,
+			},
+			wantEmail: []*api.Notification{{
+				ID:        1,
+				Status:    api.StatusSent,
+				Type:      "instantSending",
+				To:        "to",
+				Subject:   "news",
+				Message:   "message",
+				CreatedAt: createdAt,
+				SentAt:    &sentAt,
+			}},
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "{\"items\":[{\"id\":1,\"type\":\"instantSending\",\"status\":\"sent\",\"to\":\"to\",\"subject\":\"news\",\"message\":\"message\",\"created_at\":\"2025-05-23T10:00:00Z\",\"sent_at\":\"2025-05-23T10:00:01Z\"}]}\n",
+		},
+		{
+			name:                "empty page",
+			query:               "/list?by=all&status=bounced",
+			wantFilter:          &api.ListFilter{Status: "bounced", Limit: api.DefaultListLimit},
+			wantEmail:           []*api.Notification{},
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "{\"items\":[]}\n",
+		},
+		{
+			name:                "invalid limit",
+			query:               "/list?by=all&limit=501",
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "invalid query: limit must be an integer from 1 to 500",
+		},
+		{
+			name:                "invalid cursor",
+			query:               "/list?by=all&cursor=abc",
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "invalid query: invalid cursor",
+		},
+		{
+			name:                "invalid order",
+			query:               "/list?by=all&order=random",
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "invalid query: order must be asc or desc",
+		},
+		{
+			name:                "unknown status",
+			query:               "/list?by=all&status=unknown",
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "invalid query: unknown status",
+		},
+		{
+			name:                "unknown type",
+			query:               "/list?by=all&type=unknown",
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "invalid query: unknown type",
+		},
+		{
+			name:                "invalid time",
+			query:               "/list?by=all&sent_before=yesterday",
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "invalid query: sent_before must be an RFC 3339 timestamp",
+		},
+		{
+			name:                "something went wrong",
+			query:               "/list?by=all",
+			wantFilter:          &api.ListFilter{Limit: api.DefaultListLimit},
+			postgresError:       fmt.Errorf("something went wrong"),
+			wantStatusCode:      http.StatusInternalServerError,
+			wantResponseMessage: http.StatusText(500),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.query, nil)
+			w := httptest.NewRecorder()
+
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+
+			notificationHandler := New(
+				zap.NewNop(),
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			if tt.wantFilter != nil {
+				mockPostgresClient.On("FetchPage", mock.Anything, tt.wantFilter).Return(tt.wantEmail, tt.next, tt.postgresError)
+			}
+
+			handler := notificationHandler.NewListNotificationHandler(monitoring.NewNop())
+			handler.ServeHTTP(w, r)
+
+			assertDocumented(t, "GET", "/list", w)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+
+			mockPostgresClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewCreateNotificationHandler(t *testing.T) {
+	tests := []struct {
+		name                string
+		body                string
+		identity            *auth.Identity
+		suppressed          bool
+		sendError           error
+		wantSend            bool
+		wantSchedule        bool
+		wantStatusCode      int
+		wantLocation        string
+		wantResponseMessage string
+	}{
+		{
+			name:                "sent immediately",
+			body:                `{"to": "test@example.com", "subject": "Subject", "message": "Message"}`,
+			wantSend:            true,
+			wantStatusCode:      http.StatusCreated,
+			wantLocation:        "/v2/notifications/7",
+			wantResponseMessage: "{\"id\":7,\"status\":\"sent\"}\n",
+		},
+		{
+			name:                "scheduled",
+			body:                `{"to": "test@example.com", "subject": "Subject", "message": "Message", "send_at": "2035-05-24T00:33:10Z"}`,
+			wantSchedule:        true,
+			wantStatusCode:      http.StatusCreated,
+			wantLocation:        "/v2/notifications/7",
+			wantResponseMessage: "{\"id\":7,\"status\":\"pending\"}\n",
+		},
+		{
+			name:                "suppressed recipient",
+			body:                `{"to": "test@example.com", "subject": "Subject", "message": "Message"}`,
+			suppressed:          true,
+			wantStatusCode:      http.StatusConflict,
+			wantResponseMessage: "The recipient is on the suppression list",
+		},
+		{
+			name:                "permanent failure",
+			body:                `{"to": "test@example.com", "subject": "Subject", "message": "Message"}`,
+			sendError:           SMTPClient.ErrPermanentFailure,
+			wantSend:            true,
+			wantStatusCode:      http.StatusUnprocessableEntity,
+			wantResponseMessage: "The recipient was rejected by the mail server",
+		},
+		{
+			name:                "invalid body",
+			body:                `{"to": "test@example.com"}`,
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "Not all fields in the request body are filled in",
+		},
+		{
+			name:                "sent with the send scope",
+			body:                `{"to": "test@example.com", "subject": "Subject", "message": "Message"}`,
+			identity:            &auth.Identity{Client: "billing", KeyID: 3, Scopes: []string{api.ScopeSend}},
+			wantSend:            true,
+			wantStatusCode:      http.StatusCreated,
+			wantLocation:        "/v2/notifications/7",
+			wantResponseMessage: "{\"id\":7,\"status\":\"sent\"}\n",
+		},
+		{
+			name:                "scheduled without the schedule scope",
+			body:                `{"to": "test@example.com", "subject": "Subject", "message": "Message", "send_at": "2035-05-24T00:33:10Z"}`,
+			identity:            &auth.Identity{Client: "billing", KeyID: 3, Scopes: []string{api.ScopeSend}},
+			wantStatusCode:      http.StatusForbidden,
+			wantResponseMessage: "The API key does not grant the scope: schedule",
+		},
+		{
+			name:                "sent without the send scope",
+			body:                `{"to": "test@example.com", "subject": "Subject", "message": "Message"}`,
+			identity:            &auth.Identity{Client: "billing", KeyID: 3, Scopes: []string{api.ScopeSchedule}},
+			wantStatusCode:      http.StatusForbidden,
+			wantResponseMessage: "The API key does not grant the scope: send",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/v2/notifications", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			if tt.identity != nil {
+				r = r.WithContext(auth.NewContext(r.Context(), tt.identity))
+			}
+
+			mockSender := &SMTPClient.MockEmailSender{}
+			mockRedisClient := &redisClient.MockRedisClient{}
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+
+			notificationHandler := New(
+				zap.NewNop(),
+				mockSender,
+				mockRedisClient,
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			mockPostgresClient.On("IsSuppressed", mock.Anything, "test@example.com").Return(tt.suppressed, nil)
+			mockPostgresClient.On("SaveEmail", mock.Anything, mock.Anything).Return(7, nil)
+			mockPostgresClient.On("UpdateStatus", mock.Anything, 7, mock.Anything, mock.Anything).Return(nil)
+			mockSender.On("CircuitOpen").Return(nil).Maybe()
+			mockSender.On("SendEmail", mock.Anything, mock.Anything).Return(nil, tt.sendError)
+			mockRedisClient.On("AddDelayedEmail", mock.Anything, mock.Anything).Return(nil)
+
+			handler := notificationHandler.NewCreateNotificationHandler(monitoring.NewNop())
+			handler.ServeHTTP(w, r)
+
+			assertDocumented(t, "POST", "/v2/notifications", w)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantLocation, w.Header().Get("Location"))
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+
+			if tt.wantSend {
+				mockSender.AssertCalled(t, "SendEmail", mock.Anything, mock.Anything)
+			} else {
+				mockSender.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything)
+			}
+
+			if tt.wantSchedule {
+				mockRedisClient.AssertCalled(t, "AddDelayedEmail", mock.Anything, mock.Anything)
+			} else {
+				mockRedisClient.AssertNotCalled(t, "AddDelayedEmail", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestNewGetNotificationHandler(t *testing.T) {
+	createdAt := time.Date(2025, 5, 23, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name                string
+		path                string
+		wantEmail           []*api.Notification
+		postgresError       error
+		wantStatusCode      int
+		wantResponseMessage string
+	}{
+		{
+			name: "found",
+			path: "/v2/notifications/7",
+			wantEmail: []*api.Notification{{
+				ID:        7,
+				Type:      api.KeyForInstantSending,
+				Status:    api.StatusSent,
+				To:        "to",
+				Subject:   "subject",
+				Message:   "message",
+				CreatedAt: createdAt,
+			}},
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "{\"id\":7,\"type\":\"instantSending\",\"status\":\"sent\",\"to\":\"to\",\"subject\":\"subject\",\"message\":\"message\",\"created_at\":\"2025-05-23T10:00:00Z\"}\n",
+		},
+		{
+			name:                "not found",
+			path:                "/v2/notifications/7",
+			wantEmail:           []*api.Notification(nil),
+			postgresError:       fmt.Errorf("FetchById: %w", pgx.ErrNoRows),
+			wantStatusCode:      http.StatusNotFound,
+			wantResponseMessage: "Notification not found",
+		},
+		{
+			name:                "invalid id",
+			path:                "/v2/notifications/abc",
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "invalid notification ID",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.path, nil)
+			w := httptest.NewRecorder()
+
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+
+			notificationHandler := New(
+				zap.NewNop(),
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			if tt.wantEmail != nil || tt.postgresError != nil {
+				mockPostgresClient.On("FetchById", mock.Anything, 7).Return(tt.wantEmail, tt.postgresError)
+			}
+
+			router := chi.NewRouter()
+			router.Get("/v2/notifications/{id}", notificationHandler.NewGetNotificationHandler(monitoring.NewNop()))
+			router.ServeHTTP(w, r)
+
+			assertDocumented(t, "GET", "/v2/notifications/{id}", w)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+
+			mockPostgresClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewListNotificationsHandler(t *testing.T) {
+	tests := []struct {
+		name                string
+		query               string
+		wantFilter          *api.ListFilter
+		next                int
+		wantStatusCode      int
+		wantResponseMessage string
+	}{
+		{
+			name:                "first page",
+			query:               "/v2/notifications?status=canceled&limit=1",
+			wantFilter:          &api.ListFilter{Status: api.StatusCanceled, Limit: 1},
+			next:                3,
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "{\"items\":[{\"id\":2,\"type\":\"delayedSending\",\"status\":\"canceled\",\"to\":\"to\",\"subject\":\"subject\",\"message\":\"message\",\"created_at\":\"0001-01-01T00:00:00Z\"}],\"next_cursor\":\"Mw\"}\n",
+		},
+		{
+			name:                "invalid query",
+			query:               "/v2/notifications?order=random",
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "invalid query: order must be asc or desc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.query, nil)
+			w := httptest.NewRecorder()
+
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+
+			notificationHandler := New(
+				zap.NewNop(),
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			if tt.wantFilter != nil {
+				mockPostgresClient.On("FetchPage", mock.Anything, tt.wantFilter).Return([]*api.Notification{{
+					ID:      2,
+					Type:    api.KeyForDelayedSending,
+					Status:  api.StatusCanceled,
+					To:      "to",
+					Subject: "subject",
+					Message: "message",
+				}}, tt.next, nil)
+			}
+
+			handler := notificationHandler.NewListNotificationsHandler(monitoring.NewNop())
+			handler.ServeHTTP(w, r)
+
+			assertDocumented(t, "GET", "/v2/notifications", w)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+
+			mockPostgresClient.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewCancelNotificationHandler(t *testing.T) {
+	tests := []struct {
+		name                string
+		identity            *auth.Identity
+		cancelError         error
+		wantEmail           []*api.Notification
+		fetchError          error
+		wantCancel          bool
+		wantStatusCode      int
+		wantResponseMessage string
+	}{
+		{
+			name: "canceled",
+			wantEmail: []*api.Notification{{
+				ID:      7,
+				Type:    api.KeyForDelayedSending,
+				Status:  api.StatusCanceled,
+				To:      "to",
+				Subject: "subject",
+				Message: "message",
+			}},
+			wantCancel:          true,
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "{\"id\":7,\"type\":\"delayedSending\",\"status\":\"canceled\",\"to\":\"to\",\"subject\":\"subject\",\"message\":\"message\",\"created_at\":\"0001-01-01T00:00:00Z\"}\n",
+		},
+		{
+			name:                "already sent",
+			cancelError:         fmt.Errorf("CancelEmail: %w", pgx.ErrNoRows),
+			wantEmail:           []*api.Notification{{ID: 7, Status: api.StatusSent}},
+			wantCancel:          true,
+			wantStatusCode:      http.StatusConflict,
+			wantResponseMessage: "Only pending scheduled notifications can be canceled",
+		},
+		{
+			name:                "not found",
+			wantEmail:           []*api.Notification(nil),
+			fetchError:          fmt.Errorf("FetchById: %w", pgx.ErrNoRows),
+			wantStatusCode:      http.StatusNotFound,
+			wantResponseMessage: "Notification not found",
+		},
+		{
+			name:                "other tenant",
+			identity:            &auth.Identity{Client: "user-1", Scopes: []string{api.ScopeSchedule}, Tenant: "other"},
+			wantEmail:           []*api.Notification{{ID: 7, Status: api.StatusPending, Tenant: "acme"}},
+			wantStatusCode:      http.StatusNotFound,
+			wantResponseMessage: "Notification not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("DELETE", "/v2/notifications/7", nil)
+			w := httptest.NewRecorder()
+
+			if tt.identity != nil {
+				r = r.WithContext(auth.NewContext(r.Context(), tt.identity))
+			}
+
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+
+			notificationHandler := New(
+				zap.NewNop(),
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			mockPostgresClient.On("CancelEmail", mock.Anything, 7).Return(tt.cancelError)
+			mockPostgresClient.On("FetchById", mock.Anything, 7).Return(tt.wantEmail, tt.fetchError)
+
+			router := chi.NewRouter()
+			router.Delete("/v2/notifications/{id}", notificationHandler.NewCancelNotificationHandler(monitoring.NewNop()))
+			router.ServeHTTP(w, r)
+
+			assertDocumented(t, "DELETE", "/v2/notifications/{id}", w)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+
+			if tt.wantCancel {
+				mockPostgresClient.AssertCalled(t, "CancelEmail", mock.Anything, 7)
+			} else {
+				mockPostgresClient.AssertNotCalled(t, "CancelEmail", mock.Anything, 7)
+			}
+		})
+	}
+}
+
+func TestNewSaveSuppressionHandler(t *testing.T) {
+	createdAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name                string
+		body                string
+		postgresError       error
+		wantStatusCode      int
+		wantResponseMessage string
+		wantSave            bool
+	}{
+		{
+			name:                "success",
+			body:                `{"address": "User@Example.com", "reason": "complaint"}`,
+			wantStatusCode:      http.StatusCreated,
+			wantResponseMessage: "{\"address\":\"user@example.com\",\"reason\":\"complaint\",\"source\":\"api\",\"created_at\":\"2025-06-01T12:00:00Z\"}\n",
+			wantSave:            true,
+		},
+		{
+			name:                "invalid reason",
+			body:                `{"address": "user@example.com", "reason": "unknown"}`,
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "Reason must be one of hard_bounce, complaint or manual",
+		},
+		{
+			name:                "postgres error",
+			body:                `{"address": "user@example.com", "reason": "manual"}`,
+			postgresError:       fmt.Errorf("connection refused"),
+			wantStatusCode:      http.StatusInternalServerError,
+			wantResponseMessage: http.StatusText(500),
+			wantSave:            true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/suppressions", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			r.Header.Set("content-type", "application/json")
+
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+
+			notificationHandler := New(
+				zap.NewNop(),
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			mockPostgresClient.On("SaveSuppression", mock.Anything, mock.Anything).Return(tt.postgresError).Run(func(args mock.Arguments) {
+				args.Get(1).(*api.Suppression).CreatedAt = createdAt
+			})
+
+			handler := notificationHandler.NewSaveSuppressionHandler(monitoring.NewNop())
+			handler.ServeHTTP(w, r)
+
+			assertDocumented(t, "POST", "/suppressions", w)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+
+			if !tt.wantSave {
+				mockPostgresClient.AssertNotCalled(t, "SaveSuppression", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestNewListSuppressionHandler(t *testing.T) {
+	createdAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	expiresAt := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	suppression := &api.Suppression{
+		Address:   "user@example.com",
+		Reason:    api.SuppressionReasonHardBounce,
+		Source:    "bounce",
+		CreatedAt: createdAt,
+		ExpiresAt: &expiresAt,
+	}
+
+	tests := []struct {
+		name                string
+		query               string
+		suppressions        []*api.Suppression
+		postgresError       error
+		wantStatusCode      int
+		wantResponseMessage string
+	}{
+		{
+			name:           "all entries",
+			suppressions:   []*api.Suppression{suppression},
+			wantStatusCode: http.StatusOK,
+			wantResponseMessage: "[{\"address\":\"user@example.com\",\"reason\":\"hard_bounce\",\"source\":\"bounce\"," +
+				"\"created_at\":\"2025-06-01T12:00:00Z\",\"expires_at\":\"2025-07-01T12:00:00Z\"}]\n",
+		},
+		{
+			name:                "empty list",
+			suppressions:        []*api.Suppression{},
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "[]\n",
+		},
+		{
+			name:           "single address",
+			query:          "?address=" + url.QueryEscape("User@Example.com"),
+			wantStatusCode: http.StatusOK,
+			wantResponseMessage: "[{\"address\":\"user@example.com\",\"reason\":\"hard_bounce\",\"source\":\"bounce\"," +
+				"\"created_at\":\"2025-06-01T12:00:00Z\",\"expires_at\":\"2025-07-01T12:00:00Z\"}]\n",
+		},
+		{
+			name:                "address not suppressed",
+			query:               "?address=user@example.com",
+			postgresError:       fmt.Errorf("FetchSuppression: %w", pgx.ErrNoRows),
+			wantStatusCode:      http.StatusNotFound,
+			wantResponseMessage: "The address is not on the suppression list",
+		},
+		{
+			name:                "postgres error",
+			suppressions:        []*api.Suppression{},
+			postgresError:       fmt.Errorf("connection refused"),
+			wantStatusCode:      http.StatusInternalServerError,
+			wantResponseMessage: http.StatusText(500),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/suppressions"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+
+			notificationHandler := New(
+				zap.NewNop(),
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			if tt.postgresError != nil {
+				mockPostgresClient.On("FetchSuppression", mock.Anything, "user@example.com").Return(nil, tt.postgresError)
+			} else {
+				mockPostgresClient.On("FetchSuppression", mock.Anything, "user@example.com").Return(suppression, nil)
+			}
+
+			mockPostgresClient.On("FetchSuppressions", mock.Anything).Return(tt.suppressions, tt.postgresError)
+
+			handler := notificationHandler.NewListSuppressionHandler(monitoring.NewNop())
+			handler.ServeHTTP(w, r)
+
+			assertDocumented(t, "GET", "/suppressions", w)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+		})
+	}
+}
+
+func TestNewDeleteSuppressionHandler(t *testing.T) {
+	tests := []struct {
+		name                string
+		query               string
+		postgresError       error
+		wantStatusCode      int
+		wantResponseMessage string
+		wantDelete          bool
+	}{
+		{
+			name:           "success",
+			query:          "?address=" + url.QueryEscape("User@Example.com"),
+			wantStatusCode: http.StatusNoContent,
+			wantDelete:     true,
+		},
+		{
+			name:                "no address",
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "address query parameter is required",
+		},
+		{
+			name:                "address not suppressed",
+			query:               "?address=user@example.com",
+			postgresError:       fmt.Errorf("DeleteSuppression: %w", pgx.ErrNoRows),
+			wantStatusCode:      http.StatusNotFound,
+			wantResponseMessage: "The address is not on the suppression list",
+			wantDelete:          true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("DELETE", "/suppressions"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+
+			notificationHandler := New(
+				zap.NewNop(),
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			mockPostgresClient.On("DeleteSuppression", mock.Anything, "user@example.com").Return(tt.postgresError)
+
+			handler := notificationHandler.NewDeleteSuppressionHandler(monitoring.NewNop())
+			handler.ServeHTTP(w, r)
+
+			assertDocumented(t, "DELETE", "/suppressions", w)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+
+			if tt.wantDelete {
+				mockPostgresClient.AssertCalled(t, "DeleteSuppression", mock.Anything, "user@example.com")
+			} else {
+				mockPostgresClient.AssertNotCalled(t, "DeleteSuppression", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestNewBounceHandler(t *testing.T) {
+	// bounceMessage builds a delivery status notification for the notification with the Message-ID.
+	bounceMessage := func(action, status, messageID string, extraRecipients ...string) string {
+		recipients := ""
+		for _, rcpt := range extraRecipients {
+			recipients += "\r\nFinal-Recipient: rfc822; " + rcpt + "\r\n" +
+				"Action: " + action + "\r\n" +
+				"Status: " + status + "\r\n"
+		}
+
+		return "From: MAILER-DAEMON@mx.example.org\r\n" +
+			"Content-Type: multipart/report; report-type=delivery-status; boundary=B\r\n" +
+			"\r\n" +
+			"--B\r\n" +
+			"Content-Type: message/delivery-status\r\n" +
+			"\r\n" +
+			"Reporting-MTA: dns; mx.example.org\r\n" +
+			"\r\n" +
+			"Final-Recipient: rfc822; User@Example.org\r\n" +
+			"Action: " + action + "\r\n" +
+			"Status: " + status + "\r\n" +
+			recipients +
+			"\r\n" +
+			"--B\r\n" +
+			"Content-Type: text/rfc822-headers\r\n" +
+			"\r\n" +
+			"Message-ID: " + messageID + "\r\n" +
+			"\r\n" +
+			"--B--\r\n"
+	}
+
+	const messageID = "<notification.5@example.com>"
+
+	tests := []struct {
+		name                string
+		body                string
+		to                  string
+		fetchError          error
+		updateError         error
+		suppressionError    error
+		wantStatusCode      int
+		wantResponseMessage string
+		wantUpdate          bool
+		wantNotify          bool
+		wantSuppression     bool
+	}{
+		{
+			name:                "hard bounce",
+			body:                bounceMessage("failed", "5.1.1", messageID),
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "{\"message\":\"Bounce processed\",\"id\":5,\"suppressed\":[\"user@example.org\"]}\n",
+			wantUpdate:          true,
+			wantNotify:          true,
+			wantSuppression:     true,
+		},
+		{
+			name:                "other recipients are not suppressed",
+			body:                bounceMessage("failed", "5.1.1", messageID, "victim@example.com", "user@example.org"),
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "{\"message\":\"Bounce processed\",\"id\":5,\"suppressed\":[\"user@example.org\"]}\n",
+			wantUpdate:          true,
+			wantNotify:          true,
+			wantSuppression:     true,
+		},
+		{
+			name:                "only other recipient failed",
+			body:                bounceMessage("failed", "5.1.1", messageID),
+			to:                  "other@example.org",
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "{\"message\":\"Bounce processed\"}\n",
+		},
+		{
+			name:                "soft bounce",
+			body:                bounceMessage("failed", "4.2.2", messageID),
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "{\"message\":\"Bounce processed\",\"id\":5}\n",
+			wantUpdate:          true,
+			wantNotify:          true,
+		},
+		{
+			name:                "delayed delivery",
+			body:                bounceMessage("delayed", "4.4.1", messageID),
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "{\"message\":\"No failed recipients in the report\"}\n",
+		},
+		{
+			name:                "notification not found",
+			body:                bounceMessage("failed", "5.1.1", messageID),
+			fetchError:          fmt.Errorf("FetchByMessageID: %w", pgx.ErrNoRows),
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "{\"message\":\"Bounce processed\"}\n",
+		},
+		{
+			name:                "notification not sent",
+			body:                bounceMessage("failed", "5.1.1", messageID),
+			updateError:         fmt.Errorf("UpdateStatusByMessageID: %w", pgx.ErrNoRows),
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "{\"message\":\"Bounce processed\"}\n",
+			wantUpdate:          true,
+		},
+		{
+			name:                "not a bounce",
+			body:                "Subject: Hello\r\n\r\nHello\r\n",
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "The message is not a valid delivery status notification",
+		},
+		{
+			name:                "cannot fetch notification",
+			body:                bounceMessage("failed", "5.1.1", messageID),
+			fetchError:          fmt.Errorf("connection refused"),
+			wantStatusCode:      http.StatusInternalServerError,
+			wantResponseMessage: http.StatusText(500),
+		},
+		{
+			name:                "cannot update status",
+			body:                bounceMessage("failed", "5.1.1", messageID),
+			updateError:         fmt.Errorf("connection refused"),
+			wantStatusCode:      http.StatusInternalServerError,
+			wantResponseMessage: http.StatusText(500),
+			wantUpdate:          true,
+		},
+		{
+			name:                "cannot suppress address",
+			body:                bounceMessage("failed", "5.1.1", messageID),
+			suppressionError:    fmt.Errorf("connection refused"),
+			wantStatusCode:      http.StatusInternalServerError,
+			wantResponseMessage: http.StatusText(500),
+			wantUpdate:          true,
+			wantNotify:          true,
+			wantSuppression:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/bounces", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			r.Header.Set("content-type", "message/rfc822")
+
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+			mockNotifier := &webhook.MockNotifier{}
+
+			notificationHandler := New(
+				zap.NewNop(),
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				mockNotifier,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			to := "User <user@example.org>"
+			if tt.to != "" {
+				to = tt.to
+			}
+
+			notification := &api.Notification{ID: 5, To: to, MessageID: messageID, Status: api.StatusSent,
+				CallbackURL: "https://example.com/hook"}
+
+			var fetched []*api.Notification
+			if tt.fetchError == nil {
+				fetched = []*api.Notification{notification}
+			}
+
+			var bounced *api.Notification
+			if tt.updateError == nil {
+				bounced = &api.Notification{ID: 5, To: to, MessageID: messageID, Status: api.StatusBounced,
+					CallbackURL: "https://example.com/hook"}
+			}
+
+			mockPostgresClient.On("FetchByMessageID", mock.Anything, messageID).Return(fetched, tt.fetchError)
+			mockPostgresClient.On("UpdateStatusByMessageID", mock.Anything, messageID, api.StatusBounced).Return(bounced, tt.updateError)
+			mockPostgresClient.On("SaveSuppression", mock.Anything, &api.Suppression{
+				Address: "user@example.org",
+				Reason:  api.SuppressionReasonHardBounce,
+				Source:  api.SuppressionSourceBounce,
+			}).Return(tt.suppressionError)
+			mockNotifier.On("Notify", mock.Anything, mock.Anything, mock.Anything)
+
+			handler := notificationHandler.NewBounceHandler(monitoring.NewNop())
+			handler.ServeHTTP(w, r)
+
+			assertDocumented(t, "POST", "/bounces", w)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+
+			if tt.wantUpdate {
+				mockPostgresClient.AssertCalled(t, "UpdateStatusByMessageID", mock.Anything, messageID, api.StatusBounced)
+			} else {
+				mockPostgresClient.AssertNotCalled(t, "UpdateStatusByMessageID", mock.Anything, mock.Anything, mock.Anything)
+			}
+
+			if tt.wantNotify {
+				mockNotifier.AssertCalled(t, "Notify", mock.Anything, "https://example.com/hook",
+					mock.MatchedBy(func(event webhook.Event) bool { return event.ID == 5 && event.Status == api.StatusBounced }))
+			} else {
+				mockNotifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything, mock.Anything)
+			}
+
+			if tt.wantSuppression {
+				mockPostgresClient.AssertNumberOfCalls(t, "SaveSuppression", 1)
+			} else {
+				mockPostgresClient.AssertNotCalled(t, "SaveSuppression", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestNewCreateAPIKeyHandler(t *testing.T) {
+	createdAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name                string
+		body                string
+		postgresError       error
+		wantStatusCode      int
+		wantResponseMessage string
+		wantSave            bool
+	}{
+		{
+			name:           "success",
+			body:           `{"name": " billing ", "scopes": ["send", "read", "send"]}`,
+			wantStatusCode: http.StatusCreated,
+			wantSave:       true,
+		},
+		{
+			name:                "no name",
+			body:                `{"scopes": ["send"]}`,
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "The name of the client is required",
+		},
+		{
+			name:                "unknown scope",
+			body:                `{"name": "billing", "scopes": ["send", "delete"]}`,
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "Scopes must be one or more of send, schedule, read and admin",
+		},
+		{
+			name:                "postgres error",
+			body:                `{"name": "billing", "scopes": ["send"]}`,
+			postgresError:       fmt.Errorf("connection refused"),
+			wantStatusCode:      http.StatusInternalServerError,
+			wantResponseMessage: http.StatusText(500),
+			wantSave:            true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api-keys", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			r.Header.Set("content-type", "application/json")
+
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+
+			notificationHandler := New(
+				zap.NewNop(),
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			var savedHash string
+
+			mockPostgresClient.On("SaveAPIKey", mock.Anything, mock.Anything, mock.Anything).Return(tt.postgresError).Run(func(args mock.Arguments) {
+				key := args.Get(1).(*api.APIKey)
+				key.ID = 3
+				key.CreatedAt = createdAt
+				savedHash = args.String(2)
+			})
+
+			handler := notificationHandler.NewCreateAPIKeyHandler(monitoring.NewNop())
+			handler.ServeHTTP(w, r)
+
+			assertDocumented(t, "POST", "/api-keys", w)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+
+			if !tt.wantSave {
+				mockPostgresClient.AssertNotCalled(t, "SaveAPIKey", mock.Anything, mock.Anything, mock.Anything)
+			}
+
+			if tt.wantStatusCode != http.StatusCreated {
+				assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+				return
+			}
+
+			var got api.APIKey
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+
+			assert.Equal(t, 3, got.ID)
+			assert.Equal(t, "billing", got.Name)
+			assert.Equal(t, []string{api.ScopeSend, api.ScopeRead}, got.Scopes)
+			assert.Equal(t, createdAt, got.CreatedAt)
+			assert.True(t, strings.HasPrefix(got.Key, got.Prefix))
+			assert.Equal(t, auth.HashKey(got.Key), savedHash)
+		})
+	}
+}
+
+func TestNewListAPIKeysHandler(t *testing.T) {
+	createdAt := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	revokedAt := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name                string
+		keys                []*api.APIKey
+		postgresError       error
+		wantStatusCode      int
+		wantResponseMessage string
+	}{
+		{
+			name: "success",
+			keys: []*api.APIKey{
+				{ID: 1, Name: "billing", Prefix: "ntf_abcdefgh", Scopes: []string{api.ScopeSend}, CreatedAt: createdAt},
+				{ID: 2, Name: "reports", Prefix: "ntf_ijklmnop", Scopes: []string{api.ScopeRead}, CreatedAt: createdAt, RevokedAt: &revokedAt},
+			},
+			wantStatusCode: http.StatusOK,
+			wantResponseMessage: "[{\"id\":1,\"name\":\"billing\",\"prefix\":\"ntf_abcdefgh\",\"scopes\":[\"send\"],\"created_at\":\"2025-06-01T12:00:00Z\"}," +
+				"{\"id\":2,\"name\":\"reports\",\"prefix\":\"ntf_ijklmnop\",\"scopes\":[\"read\"],\"created_at\":\"2025-06-01T12:00:00Z\",\"revoked_at\":\"2025-06-02T12:00:00Z\"}]\n",
+		},
+		{
+			name:                "no keys",
+			keys:                []*api.APIKey{},
+			wantStatusCode:      http.StatusOK,
+			wantResponseMessage: "[]\n",
+		},
+		{
+			name:                "postgres error",
+			postgresError:       fmt.Errorf("connection refused"),
+			wantStatusCode:      http.StatusInternalServerError,
+			wantResponseMessage: http.StatusText(500),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api-keys", nil)
+			w := httptest.NewRecorder()
+
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+
+			notificationHandler := New(
+				zap.NewNop(),
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			mockPostgresClient.On("FetchAPIKeys", mock.Anything).Return(tt.keys, tt.postgresError)
+
+			handler := notificationHandler.NewListAPIKeysHandler(monitoring.NewNop())
+			handler.ServeHTTP(w, r)
+
+			assertDocumented(t, "GET", "/api-keys", w)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+		})
+	}
+}
+
+func TestNewRevokeAPIKeyHandler(t *testing.T) {
+	tests := []struct {
+		name                string
+		id                  string
+		postgresError       error
+		wantStatusCode      int
+		wantResponseMessage string
+		wantRevoke          bool
+	}{
+		{
+			name:           "success",
+			id:             "3",
+			wantStatusCode: http.StatusNoContent,
+			wantRevoke:     true,
+		},
+		{
+			name:                "invalid ID",
+			id:                  "abc",
+			wantStatusCode:      http.StatusBadRequest,
+			wantResponseMessage: "invalid API key ID",
+		},
+		{
+			name:                "not found",
+			id:                  "3",
+			postgresError:       fmt.Errorf("RevokeAPIKey: %w", pgx.ErrNoRows),
+			wantStatusCode:      http.StatusNotFound,
+			wantResponseMessage: "The API key does not exist or is already revoked",
+			wantRevoke:          true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("DELETE", "/api-keys/"+tt.id, nil)
+			w := httptest.NewRecorder()
+
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+
+			notificationHandler := New(
+				zap.NewNop(),
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			mockPostgresClient.On("RevokeAPIKey", mock.Anything, 3).Return(tt.postgresError)
+
+			router := chi.NewRouter()
+			router.Delete("/api-keys/{id}", notificationHandler.NewRevokeAPIKeyHandler(monitoring.NewNop()))
+			router.ServeHTTP(w, r)
+
+			assertDocumented(t, "DELETE", "/api-keys/{id}", w)
+
+			assert.Equal(t, tt.wantStatusCode, w.Code)
+			assert.Equal(t, tt.wantResponseMessage, problemDetail(t, w))
+
+			if tt.wantRevoke {
+				mockPostgresClient.AssertCalled(t, "RevokeAPIKey", mock.Anything, 3)
+			} else {
+				mockPostgresClient.AssertNotCalled(t, "RevokeAPIKey", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestRoutesAuthentication(t *testing.T) {
+	sendKey := "ntf_send"
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		pattern    string
+		key        string
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "list without key",
+			method:     "GET",
+			path:       "/list?by=all",
+			pattern:    "/list",
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   problem.CodeUnauthorized,
+		},
+		{
+			name:       "list without the read scope",
+			method:     "GET",
+			path:       "/list?by=all",
+			pattern:    "/list",
+			key:        sendKey,
+			wantStatus: http.StatusForbidden,
+			wantCode:   problem.CodeForbidden,
+		},
+		{
+			name:       "API keys without the admin scope",
+			method:     "GET",
+			path:       "/api-keys",
+			pattern:    "/api-keys",
+			key:        sendKey,
+			wantStatus: http.StatusForbidden,
+			wantCode:   problem.CodeForbidden,
+		},
+		{
+			name:       "API keys with the admin key",
+			method:     "GET",
+			path:       "/api-keys",
+			pattern:    "/api-keys",
+			key:        "admin-secret",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "public OpenAPI document",
+			method:     "GET",
+			path:       "/openapi.json",
+			pattern:    "/openapi.json",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.key != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			w := httptest.NewRecorder()
+
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+			mockPostgresClient.On("FetchAPIKeyByHash", mock.Anything, auth.HashKey(sendKey)).
+				Return(&api.APIKey{ID: 1, Name: "billing", Scopes: []string{api.ScopeSend}}, nil)
+			mockPostgresClient.On("FetchAPIKeys", mock.Anything).Return([]*api.APIKey{}, nil)
+
+			notificationHandler := New(
+				zap.NewNop(),
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			authenticator := auth.New(&auth.Config{AdminKey: "admin-secret"}, mockPostgresClient, monitoring.NewNop(), zap.NewNop())
+
+			router := chi.NewRouter()
+			router.Use(middleware.URLFormat)
+			notificationHandler.Routes(router, authenticator, appMetrics)
+			router.ServeHTTP(w, r)
+
+			assertDocumented(t, tt.method, tt.pattern, w)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+
+			if tt.wantCode != "" {
+				var got problem.Problem
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+				assert.Equal(t, tt.wantCode, got.Code)
+			}
+		})
+	}
+}
+
+func TestRoutesDocumented(t *testing.T) {
+	notificationHandler := New(
+		zap.NewNop(),
+		&SMTPClient.MockEmailSender{},
+		&redisClient.MockRedisClient{},
+		&postgresClient.MockPostgresService{},
+		&decoder.Config{},
+		nil,
+		nil,
+		nil,
+		nil,
+		config.AppTimeouts{},
+		3*time.Second,
+	)
+
+	router := chi.NewRouter()
+	notificationHandler.Routes(router, auth.New(&auth.Config{}, nil, monitoring.NewNop(), zap.NewNop()), appMetrics)
+
+	var routes []string
+
+	err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+
+		// URL format middleware strips the .json extension, so the route of the document is /openapi.
+		if route == "/openapi" {
+			route = "/openapi.json"
+		}
+
+		routes = append(routes, method+" "+route)
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	operations, err := openapi.Operations()
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, operations, routes)
+}
+
+func TestProblemResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		pattern    string
+		body       string
+		wantStatus int
+		wantCode   string
+		wantFields []string
+	}{
+		{
+			name:       "missing fields",
+			method:     "POST",
+			path:       "/send-notification",
+			pattern:    "/send-notification",
+			body:       `{"to": "test@example.com"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   problem.CodeMissingFields,
+			wantFields: []string{"subject", "message"},
+		},
+		{
+			name:       "invalid query parameter",
+			method:     "GET",
+			path:       "/list?by=all&limit=0",
+			pattern:    "/list",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   problem.CodeInvalidQuery,
+			wantFields: []string{"limit"},
+		},
+		{
+			name:       "notification not found",
+			method:     "GET",
+			path:       "/v2/notifications/7",
+			pattern:    "/v2/notifications/{id}",
+			wantStatus: http.StatusNotFound,
+			wantCode:   problem.CodeNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set(middleware.RequestIDHeader, "request-1")
+			w := httptest.NewRecorder()
+
+			mockPostgresClient := &postgresClient.MockPostgresService{}
+			mockPostgresClient.On("FetchById", mock.Anything, 7).Return([]*api.Notification(nil), pgx.ErrNoRows)
+
+			notificationHandler := New(
+				zap.NewNop(),
+				&SMTPClient.MockEmailSender{},
+				&redisClient.MockRedisClient{},
+				mockPostgresClient,
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			router := chi.NewRouter()
+			router.Use(middleware.RequestID)
+			router.Post("/send-notification", notificationHandler.NewSendNotificationHandler(monitoring.NewNop()))
+			router.Get("/list", notificationHandler.NewListNotificationHandler(monitoring.NewNop()))
+			router.Get("/v2/notifications/{id}", notificationHandler.NewGetNotificationHandler(monitoring.NewNop()))
+			router.ServeHTTP(w, r)
+
+			assertDocumented(t, tt.method, tt.pattern, w)
+
+			var got problem.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.wantStatus, got.Status)
+			assert.Equal(t, tt.wantCode, got.Code)
+			assert.Equal(t, tt.wantFields, got.Fields)
+			assert.Equal(t, "request-1", got.RequestID)
+			assert.Equal(t, r.URL.Path, got.Instance)
+		})
+	}
+}
+
+func TestNewIdempotencyMiddleware(t *testing.T) {
+	stored := `{"status":201,"header":{"Content-Type":["application/json"],"Location":["/v2/notifications/7"]},` +
+		`"body":"eyJpZCI6Nywic3RhdHVzIjoic2VudCJ9Cg=="}`
+
+	tests := []struct {
+		name            string
+		key             string
+		identity        *auth.Identity
+		clientGone      bool
+		handlerStatus   int
+		deliveryUnknown bool
+		setupMock       func(m *redisClient.MockRedisClient)
+		wantCalled      bool
+		wantStatus      int
+		wantBody        string
+		wantReplayed    string
+	}{
+		{
+			name:          "without key",
+			handlerStatus: http.StatusCreated,
+			setupMock:     func(m *redisClient.MockRedisClient) {},
+			wantCalled:    true,
+			wantStatus:    http.StatusCreated,
+			wantBody:      "{\"id\":7,\"status\":\"sent\"}\n",
+		},
+		{
+			name:          "first request",
+			key:           "key-1",
+			handlerStatus: http.StatusCreated,
+			setupMock: func(m *redisClient.MockRedisClient) {
+				m.On("ReserveIdempotencyKey", mock.Anything, "idempotency:POST:/v2/notifications:key-1", idempotencyLockTTL).Return(true, nil)
+				m.On("SaveIdempotentResponse", mock.Anything, "idempotency:POST:/v2/notifications:key-1",
+					mock.MatchedBy(func(response []byte) bool { return assert.JSONEq(t, stored, string(response)) }),
+					idempotencyTTL).Return(nil)
+			},
+			wantCalled: true,
+			wantStatus: http.StatusCreated,
+			wantBody:   "{\"id\":7,\"status\":\"sent\"}\n",
+		},
+		{
+			name:          "key of the client",
+			key:           "key-1",
+			identity:      &auth.Identity{Client: "billing", KeyID: 3, Scopes: []string{api.ScopeSend}},
+			handlerStatus: http.StatusCreated,
+			setupMock: func(m *redisClient.MockRedisClient) {
+				m.On("ReserveIdempotencyKey", mock.Anything, "idempotency:key:3:POST:/v2/notifications:key-1", idempotencyLockTTL).Return(true, nil)
+				m.On("SaveIdempotentResponse", mock.Anything, "idempotency:key:3:POST:/v2/notifications:key-1", mock.Anything, idempotencyTTL).
+					Return(nil)
+			},
+			wantCalled: true,
+			wantStatus: http.StatusCreated,
+			wantBody:   "{\"id\":7,\"status\":\"sent\"}\n",
+		},
+		{
+			name:          "client gone after the notification was sent",
+			key:           "key-1",
+			clientGone:    true,
+			handlerStatus: http.StatusCreated,
+			setupMock: func(m *redisClient.MockRedisClient) {
+				m.On("ReserveIdempotencyKey", mock.Anything, "idempotency:POST:/v2/notifications:key-1", idempotencyLockTTL).Return(true, nil)
+				m.On("SaveIdempotentResponse", mock.Anything, "idempotency:POST:/v2/notifications:key-1",
+					mock.MatchedBy(func(response []byte) bool { return assert.JSONEq(t, stored, string(response)) }),
+					idempotencyTTL).Return(nil)
+			},
+			wantCalled: true,
+			wantStatus: http.StatusCreated,
+			wantBody:   "{\"id\":7,\"status\":\"sent\"}\n",
+		},
+		{
+			name: "repeated request",
+			key:  "key-1",
+			setupMock: func(m *redisClient.MockRedisClient) {
+				m.On("ReserveIdempotencyKey", mock.Anything, "idempotency:POST:/v2/notifications:key-1", idempotencyLockTTL).Return(false, nil)
+				m.On("FetchIdempotentResponse", mock.Anything, "idempotency:POST:/v2/notifications:key-1").Return([]byte(stored), nil)
+			},
+			wantStatus:   http.StatusCreated,
+			wantBody:     "{\"id\":7,\"status\":\"sent\"}\n",
+			wantReplayed: "true",
+		},
+		{
+			name: "request in progress",
+			key:  "key-1",
+			setupMock: func(m *redisClient.MockRedisClient) {
+				m.On("ReserveIdempotencyKey", mock.Anything, "idempotency:POST:/v2/notifications:key-1", idempotencyLockTTL).Return(false, nil)
+				m.On("FetchIdempotentResponse", mock.Anything, "idempotency:POST:/v2/notifications:key-1").Return([]byte(nil), nil)
+			},
+			wantStatus: http.StatusConflict,
+			wantBody:   "A request with the same idempotency key is in progress",
+		},
+		{
+			name:          "smtp unavailable",
+			key:           "key-1",
+			handlerStatus: http.StatusServiceUnavailable,
+			setupMock: func(m *redisClient.MockRedisClient) {
+				m.On("ReserveIdempotencyKey", mock.Anything, "idempotency:POST:/v2/notifications:key-1", idempotencyLockTTL).Return(true, nil)
+				m.On("ReleaseIdempotencyKey", mock.Anything, "idempotency:POST:/v2/notifications:key-1").Return(nil)
+			},
+			wantCalled: true,
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   http.StatusText(503),
+		},
+		{
+			name:          "internal error",
+			key:           "key-1",
+			handlerStatus: http.StatusInternalServerError,
+			setupMock: func(m *redisClient.MockRedisClient) {
+				m.On("ReserveIdempotencyKey", mock.Anything, "idempotency:POST:/v2/notifications:key-1", idempotencyLockTTL).Return(true, nil)
+				m.On("ReleaseIdempotencyKey", mock.Anything, "idempotency:POST:/v2/notifications:key-1").Return(nil)
+			},
+			wantCalled: true,
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   http.StatusText(500),
+		},
+		{
+			name:            "delivery result unknown",
+			key:             "key-1",
+			handlerStatus:   http.StatusInternalServerError,
+			deliveryUnknown: true,
+			setupMock: func(m *redisClient.MockRedisClient) {
+				m.On("ReserveIdempotencyKey", mock.Anything, "idempotency:POST:/v2/notifications:key-1", idempotencyLockTTL).Return(true, nil)
+				m.On("SaveIdempotentResponse", mock.Anything, "idempotency:POST:/v2/notifications:key-1",
+					mock.MatchedBy(func(response []byte) bool { return strings.Contains(string(response), `"status":500`) }),
+					idempotencyTTL).Return(nil)
+			},
+			wantCalled: true,
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   http.StatusText(500),
+		},
+		{
+			name:       "key too long",
+			key:        strings.Repeat("k", 256),
+			setupMock:  func(m *redisClient.MockRedisClient) {},
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "Idempotency-Key must not be longer than 255 characters",
+		},
+		{
+			name: "redis error",
+			key:  "key-1",
+			setupMock: func(m *redisClient.MockRedisClient) {
+				m.On("ReserveIdempotencyKey", mock.Anything, "idempotency:POST:/v2/notifications:key-1", idempotencyLockTTL).
+					Return(false, errors.New("connection refused"))
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   http.StatusText(500),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/v2/notifications", strings.NewReader(`{}`))
+
+			if tt.key != "" {
+				r.Header.Set(IdempotencyKeyHeader, tt.key)
+			}
+
+			if tt.identity != nil {
+				r = r.WithContext(auth.NewContext(r.Context(), tt.identity))
+			}
+
+			mockRedisClient := &redisClient.MockRedisClient{}
+			tt.setupMock(mockRedisClient)
+
+			notificationHandler := New(
+				zap.NewNop(),
+				&SMTPClient.MockEmailSender{},
+				mockRedisClient,
+				&postgresClient.MockPostgresService{},
+				&decoder.Config{},
+				nil,
+				nil,
+				nil,
+				nil,
+				config.AppTimeouts{},
+				3*time.Second,
+			)
+
+			called := false
+
+			ctx, cancel := context.WithCancel(r.Context())
+			defer cancel()
+
+			r = r.WithContext(ctx)
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+
+				if tt.clientGone {
+					cancel()
+				}
+
+				if tt.deliveryUnknown {
+					markDeliveryUnknown(r.Context())
+				}
+
+				switch tt.handlerStatus {
+				case http.StatusServiceUnavailable:
+					problem.Write(w, r, http.StatusServiceUnavailable, problem.CodeSMTPUnavailable, http.StatusText(503))
+					return
+
+				case http.StatusInternalServerError:
+					problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, http.StatusText(500))
+					return
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Location", "/v2/notifications/7")
+				w.WriteHeader(tt.handlerStatus)
+				_, _ = w.Write([]byte("{\"id\":7,\"status\":\"sent\"}\n"))
+			})
+
+			notificationHandler.NewIdempotencyMiddleware(monitoring.NewNop())(next).ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantCalled, called)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantBody, problemDetail(t, w))
+			assert.Equal(t, tt.wantReplayed, w.Header().Get(IdempotentReplayedHeader))
+			mockRedisClient.AssertExpectations(t)
+
+			assertDocumented(t, "POST", "/v2/notifications", w)
+		})
+	}
+}
+
+func TestNewIdempotencyMiddlewareRetryAfterError(t *testing.T) {
+	mockRedisClient := &redisClient.MockRedisClient{}
+	mockRedisClient.On("ReserveIdempotencyKey", mock.Anything, "idempotency:POST:/v2/notifications:key-1", idempotencyLockTTL).
+		Return(true, nil).Twice()
+	mockRedisClient.On("ReleaseIdempotencyKey", mock.Anything, "idempotency:POST:/v2/notifications:key-1").Return(nil).Once()
+	mockRedisClient.On("SaveIdempotentResponse", mock.Anything, "idempotency:POST:/v2/notifications:key-1", mock.Anything,
+		idempotencyTTL).Return(nil).Once()
+
+	notificationHandler := New(
+		zap.NewNop(),
+		&SMTPClient.MockEmailSender{},
+		mockRedisClient,
+		&postgresClient.MockPostgresService{},
+		&decoder.Config{},
+		nil,
+		nil,
+		nil,
+		nil,
+		config.AppTimeouts{},
+		3*time.Second,
+	)
+
+	calls := 0
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+
+		if calls == 1 {
+			problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, http.StatusText(500))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/v2/notifications/7")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("{\"id\":7,\"status\":\"sent\"}\n"))
+	})
+
+	handler := notificationHandler.NewIdempotencyMiddleware(monitoring.NewNop())(next)
+
+	for _, wantStatus := range []int{http.StatusInternalServerError, http.StatusCreated} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/v2/notifications", strings.NewReader(`{}`))
+		r.Header.Set(IdempotencyKeyHeader, "key-1")
+
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, wantStatus, w.Code)
+		assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+	}
+
+	assert.Equal(t, 2, calls)
+	mockRedisClient.AssertExpectations(t)
+}
+
+// assertDocumented checks that the response of the route matches the OpenAPI document, see openapi.ValidateResponse.
+func assertDocumented(t *testing.T, method, pattern string, w *httptest.ResponseRecorder) {
+	t.Helper()
+
+	assert.NoError(t, openapi.ValidateResponse(method, pattern, w.Code, w.Header(), w.Body.Bytes()))
+}
+
+// problemDetail returns the detail of the problem+json error response, or the body of other responses.
+func problemDetail(t *testing.T, w *httptest.ResponseRecorder) string {
+	if w.Header().Get("Content-Type") != problem.ContentType {
+		return w.Body.String()
+	}
+
+	var p problem.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+
+	return p.Detail
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+
+	"notification/internal/api/problem"
+	"notification/internal/auth"
+	"notification/internal/monitoring"
+)
+
+const (
+	// IdempotencyKeyHeader is the header with the client-generated key of the request,
+	// the requests with the same key are processed only once, see NewIdempotencyMiddleware.
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader is set on the responses replayed for the repeated requests with the same key.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+const (
+	// idempotencyTTL is the time the response of the request with the idempotency key is stored for.
+	idempotencyTTL = 24 * time.Hour
+
+	// idempotencyLockTTL is the time the idempotency key is reserved for while the request is in progress.
+	idempotencyLockTTL = 5 * time.Minute
+
+	// maxIdempotencyKeyLength is the maximum length of the idempotency key.
+	maxIdempotencyKeyLength = 255
+)
+
+// replayedHeaders are the response headers stored with the response of the request with the idempotency key.
+var replayedHeaders = []string{"Content-Type", "Location", "Retry-After"}
+
+// idempotencyContextKey is the key of the idempotencyState in the request context.
+type idempotencyContextKey struct{}
+
+// idempotencyState is the state of the request with the idempotency key shared between
+// NewIdempotencyMiddleware and the handler.
+// deliveryUnknown is set by the handler if it responds with an error after the email may have been sent.
+type idempotencyState struct {
+	deliveryUnknown bool
+}
+
+// markDeliveryUnknown marks the request of the context as the one whose email may have been sent,
+// so that its error response is stored by NewIdempotencyMiddleware. It does nothing without the idempotency key.
+func markDeliveryUnknown(ctx context.Context) {
+	if state, ok := ctx.Value(idempotencyContextKey{}).(*idempotencyState); ok {
+		state.deliveryUnknown = true
+	}
+}
+
+// idempotentResponse is the response stored for the idempotency key.
+type idempotentResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// NewIdempotencyMiddleware returns a middleware that processes the requests with the same Idempotency-Key header
+// only once. The key is reserved in Redis before the request is processed, and the response is stored for 24 hours
+// and replayed for the repeated requests with the Idempotent-Replayed header. The repeated request received
+// while the first one is in progress is rejected with 409 Conflict. The key is scoped to the client, the method and path,
+// the body of the repeated request is not compared with the first one.
+// The key is released after 5xx responses, so that the request can be retried with the same key, because
+// nothing is sent on these errors except one: the SMTP session broke after the message had been transferred
+// (SMTPClient.ErrDeliveryUnknown). The email may already be sent then, so that 500 Internal Server Error is stored.
+// The other responses are stored even if the client has gone away, so that the client retrying the request
+// after its timeout does not send the email again.
+// Requests without the header are passed through.
+func (nh *NotificationHandler) NewIdempotencyMiddleware(metrics monitoring.Monitoring) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+
+			handlerName := "Idempotency"
+
+			if len(key) > maxIdempotencyKeyLength {
+				problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidIdempotencyKey, "Idempotency-Key must not be longer than 255 characters")
+				metrics.IncError(handlerName)
+				nh.logger.Warn("NewIdempotencyMiddleware: idempotency key is too long", zap.Int("length", len(key)))
+
+				return
+			}
+
+			storageKey := "idempotency:" + r.Method + ":" + r.URL.Path + ":" + key
+			if identity, ok := auth.FromContext(r.Context()); ok {
+				storageKey = "idempotency:" + identity.Principal() + ":" + r.Method + ":" + r.URL.Path + ":" + key
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), nh.timeouts.RedisTimeout+nh.extraTimeout)
+			defer cancel()
+
+			reserved, err := nh.redisClient.ReserveIdempotencyKey(ctx, storageKey, idempotencyLockTTL)
+			if err != nil {
+				problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, http.StatusText(500))
+				metrics.IncError(handlerName)
+				nh.logger.Error("NewIdempotencyMiddleware: Cannot reserve idempotency key", zap.Error(err))
+
+				return
+			}
+
+			if !reserved {
+				nh.replay(ctx, w, r, storageKey, metrics, handlerName)
+				return
+			}
+
+			state := &idempotencyState{}
+			r = r.WithContext(context.WithValue(r.Context(), idempotencyContextKey{}, state))
+
+			var body bytes.Buffer
+
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(&body)
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			// The request context may have ended while the email was being sent, so the response is stored without it.
+			storeCtx, storeCancel := context.WithTimeout(context.WithoutCancel(r.Context()), nh.timeouts.RedisTimeout+nh.extraTimeout)
+			defer storeCancel()
+
+			if status >= http.StatusInternalServerError && !state.deliveryUnknown {
+				if err = nh.redisClient.ReleaseIdempotencyKey(storeCtx, storageKey); err != nil {
+					metrics.IncError(handlerName)
+					nh.logger.Error("NewIdempotencyMiddleware: Cannot release idempotency key", zap.Error(err))
+				}
+
+				return
+			}
+
+			response := idempotentResponse{Status: status, Header: http.Header{}, Body: body.Bytes()}
+
+			for _, name := range replayedHeaders {
+				if value := ww.Header().Get(name); value != "" {
+					response.Header.Set(name, value)
+				}
+			}
+
+			stored, err := json.Marshal(response)
+			if err == nil {
+				err = nh.redisClient.SaveIdempotentResponse(storeCtx, storageKey, stored, idempotencyTTL)
+			}
+
+			if err != nil {
+				metrics.IncError(handlerName)
+				nh.logger.Error("NewIdempotencyMiddleware: Cannot save idempotent response", zap.Error(err))
+
+				return
+			}
+
+			metrics.Observe(handlerName, start)
+			metrics.IncSuccess(handlerName)
+		})
+	}
+}
+
+// replay writes the response stored for the idempotency key,
+// or 409 Conflict if the request with the key is still in progress.
+func (nh *NotificationHandler) replay(ctx context.Context, w http.ResponseWriter, r *http.Request, storageKey string,
+	metrics monitoring.Monitoring, handlerName string) {
+	stored, err := nh.redisClient.FetchIdempotentResponse(ctx, storageKey)
+	if err != nil {
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, http.StatusText(500))
+		metrics.IncError(handlerName)
+		nh.logger.Error("replay: Cannot fetch idempotent response", zap.Error(err))
+
+		return
+	}
+
+	if len(stored) == 0 {
+		problem.Write(w, r, http.StatusConflict, problem.CodeIdempotencyInProgress, "A request with the same idempotency key is in progress")
+		metrics.IncError(handlerName)
+		nh.logger.Warn("replay: request with the idempotency key is in progress")
+
+		return
+	}
+
+	var response idempotentResponse
+	if err = json.Unmarshal(stored, &response); err != nil {
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, http.StatusText(500))
+		metrics.IncError(handlerName)
+		nh.logger.Error("replay: Cannot parse idempotent response", zap.Error(err))
+
+		return
+	}
+
+	for name, values := range response.Header {
+		w.Header()[name] = values
+	}
+
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(response.Status)
+	_, _ = w.Write(response.Body)
+
+	metrics.IncSuccess(handlerName)
+}
@@ -2,19 +2,26 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"go.uber.org/zap"
 
+	"notification/internal/SMTPClient"
 	"notification/internal/api"
 	"notification/internal/api/decoder"
+	"notification/internal/api/problem"
 	"notification/internal/monitoring"
 )
 
 // NewSendNotificationHandler returns an HTTP handler that handles instant email notifications.
-// It decodes and validates the request, sends the email,
-// saves the message to PostgreSQL, and writes a response on success.
+// It decodes and validates the request, then the email is saved to PostgreSQL and sent by service.Service.Send,
+// which stores the sending status with the history of attempts, and writes a response on success.
+// Emails to recipients unsubscribed from the category are saved with the skipped status and not sent,
+// emails to suppressed recipients are saved with the suppressed status and rejected with 409 Conflict.
 func (nh *NotificationHandler) NewSendNotificationHandler(metrics monitoring.Monitoring) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), nh.calculateTimeoutForSend())
@@ -24,31 +31,32 @@ func (nh *NotificationHandler) NewSendNotificationHandler(metrics monitoring.Mon
 
 		handlerName := "SendNotification"
 
-		if nh.checkCtxError(ctx, w, metrics, handlerName) {
+		if nh.checkCtxError(ctx, w, r, metrics, handlerName) {
 			return
 		}
 
-		email, err := decoder.DecodeRequest(nh.logger, r, w, api.KeyForInstantSending)
+		email, err := decoder.DecodeRequest(nh.decoderConfig, nh.logger, r, w, api.KeyForInstantSending)
 		if err != nil {
 			metrics.IncError(handlerName)
 			nh.logger.Error("NewSendNotificationHandler: Failed to decode request", zap.Error(err))
 			return
 		}
 
-		err = nh.sender.SendEmail(ctx, *email)
-		if err != nil {
-			http.Error(w, http.StatusText(500), http.StatusInternalServerError)
-			metrics.IncError(handlerName)
-			nh.logger.Error("NewSendNotificationHandler: Cannot send notification", zap.Error(err))
+		status, err := nh.service.Send(ctx, email)
+
+		id := email.Id
 
+		if nh.processSendError(w, r, id, status, err, metrics, handlerName) {
 			return
 		}
 
-		id, err := nh.postgresClient.SaveEmail(ctx, email)
-		if err != nil {
-			http.Error(w, http.StatusText(500), http.StatusInternalServerError)
-			metrics.IncError(handlerName)
-			nh.logger.Error("NewSendNotificationHandler: Cannot put email in postgres", zap.Error(err))
+		if status == api.StatusSkipped {
+			nh.logger.Info("NewSendNotificationHandler: Recipient has unsubscribed, notification skipped",
+				zap.Int("id", id), zap.String("category", email.Category))
+			nh.writeResponseWithId(w, id, "Recipient has unsubscribed, notification skipped", metrics, handlerName)
+
+			metrics.Observe(handlerName, start)
+			metrics.IncSuccess(handlerName)
 
 			return
 		}
@@ -59,3 +67,54 @@ func (nh *NotificationHandler) NewSendNotificationHandler(metrics monitoring.Mon
 		metrics.IncSuccess(handlerName)
 	}
 }
+
+// processSendError writes the error response for the email that was not sent by service.Service.Send
+// and reports whether the response is written.
+// Suppressed recipients are rejected with 409 Conflict, an open SMTP circuit breaker results in
+// 503 Service Unavailable with the Retry-After header, and permanent SMTP failures in 422 Unprocessable Entity.
+// If the email may have been sent (SMTPClient.ErrDeliveryUnknown), the request is marked for NewIdempotencyMiddleware
+// to store the response.
+func (nh *NotificationHandler) processSendError(w http.ResponseWriter, r *http.Request, id int, status string, err error,
+	metrics monitoring.Monitoring, handlerName string) bool {
+	var circuitOpen *SMTPClient.CircuitOpenError
+
+	switch {
+	case status == "":
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, http.StatusText(500))
+		metrics.IncError(handlerName)
+		nh.logger.Error(handlerName+": Cannot process notification", zap.Error(err))
+
+	case status == api.StatusSuppressed:
+		problem.Write(w, r, http.StatusConflict, problem.CodeRecipientSuppressed, "The recipient is on the suppression list", "to")
+		metrics.IncError(handlerName)
+		nh.logger.Warn(handlerName+": Recipient is suppressed, notification rejected",
+			zap.Int("id", id), zap.Error(ErrRecipientSuppressed))
+
+	case errors.As(err, &circuitOpen):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(circuitOpen.RetryAfter.Seconds()))))
+		problem.Write(w, r, http.StatusServiceUnavailable, problem.CodeSMTPUnavailable, http.StatusText(503))
+		metrics.IncError(handlerName)
+		nh.logger.Warn(handlerName+": SMTP circuit breaker is open", zap.Error(err))
+
+	case errors.Is(err, SMTPClient.ErrPermanentFailure):
+		problem.Write(w, r, http.StatusUnprocessableEntity, problem.CodeRecipientRejected, "The recipient was rejected by the mail server", "to")
+		metrics.IncError(handlerName)
+		nh.logger.Error(handlerName+": Notification rejected permanently", zap.Error(err))
+
+	case errors.Is(err, SMTPClient.ErrDeliveryUnknown):
+		markDeliveryUnknown(r.Context())
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, http.StatusText(500))
+		metrics.IncError(handlerName)
+		nh.logger.Error(handlerName+": Delivery result of notification is unknown", zap.Int("id", id), zap.Error(err))
+
+	case err != nil:
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, http.StatusText(500))
+		metrics.IncError(handlerName)
+		nh.logger.Error(handlerName+": Cannot send notification", zap.Error(err))
+
+	default:
+		return false
+	}
+
+	return true
+}
@@ -4,24 +4,68 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
 
-	"notification/internal/SMTPClient"
+	"notification/internal/api"
+	"notification/internal/api/problem"
+	"notification/internal/api/service"
 	"notification/internal/monitoring"
 )
 
 // ErrInvalidQuery indicates that the query parameters are invalid.
 var ErrInvalidQuery = errors.New("invalid query")
 
+// paramError is ErrInvalidQuery caused by a single query parameter,
+// the name of the parameter is reported in the fields of the error response.
+type paramError struct {
+	param string
+	err   error
+}
+
+// Error returns the description of the invalid query.
+func (e *paramError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped ErrInvalidQuery.
+func (e *paramError) Unwrap() error {
+	return e.err
+}
+
+// invalidParam returns ErrInvalidQuery caused by the query parameter with the description of the problem.
+func invalidParam(param, format string, args ...any) error {
+	return &paramError{param: param, err: fmt.Errorf("%w: "+format, append([]any{ErrInvalidQuery}, args...)...)}
+}
+
+// queryFields returns the name of the query parameter that caused the error, if it is known.
+func queryFields(err error) []string {
+	var pe *paramError
+	if errors.As(err, &pe) {
+		return []string{pe.param}
+	}
+
+	return nil
+}
+
+// listPage is the response of the paginated list of notifications.
+// NextCursor is passed as the cursor query parameter to get the next page, it is empty on the last page.
+type listPage struct {
+	Items      []*api.Notification `json:"items"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
 // NewListNotificationHandler returns an HTTP handler that lists saved email notifications.
 // It selects the appropriate listing method based on query parameters,
 // fetches data from PostgreSQL, and writes the result to the HTTP response on success.
+// The list of all notifications and the list of the notifications of the recipient are paginated, see handlePageQuery.
 func (nh *NotificationHandler) NewListNotificationHandler(metrics monitoring.Monitoring) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), nh.calculateTimeoutForList())
@@ -31,19 +75,31 @@ func (nh *NotificationHandler) NewListNotificationHandler(metrics monitoring.Mon
 
 		handlerName := "ListNotification"
 
-		if nh.checkCtxError(ctx, w, metrics, handlerName) {
+		if nh.checkCtxError(ctx, w, r, metrics, handlerName) {
 			return
 		}
 
 		query := r.URL.Query()
 
-		emails, err := nh.handleQuery(ctx, query)
+		var (
+			res any
+			err error
+		)
+
+		switch query.Get("by") {
+		case "all", "email":
+			res, err = nh.handlePageQuery(ctx, query)
+
+		default:
+			res, err = nh.handleVisibleQuery(ctx, query)
+		}
+
 		if err != nil {
 			nh.processError(ctx, err, handlerName, metrics, w, r)
 			return
 		}
 
-		nh.writeResponse(w, metrics, handlerName, emails)
+		nh.writeResponse(w, metrics, handlerName, res)
 
 		metrics.Observe(handlerName, start)
 		metrics.IncSuccess(handlerName)
@@ -52,37 +108,169 @@ func (nh *NotificationHandler) NewListNotificationHandler(metrics monitoring.Mon
 
 // handleQuery selects and executes the appropriate method to list saved notifications,
 // based on the given query parameters.
-func (nh *NotificationHandler) handleQuery(ctx context.Context, q url.Values) ([]*SMTPClient.EmailMessage, error) {
+func (nh *NotificationHandler) handleQuery(ctx context.Context, q url.Values) ([]*api.Notification, error) {
 	by := q.Get("by")
 
-	mail := q.Get("email")
 	id := q.Get("id")
+	messageID := q.Get("message_id")
 
 	switch by {
 	case "id":
 		intId, err := strconv.Atoi(id)
 		if err != nil {
-			return nil, ErrInvalidQuery
+			return nil, &paramError{param: "id", err: ErrInvalidQuery}
 		}
 
 		return nh.postgresClient.FetchById(ctx, intId)
 
-	case "email":
-		return nh.postgresClient.FetchByEmail(ctx, mail)
+	case "message_id":
+		if messageID == "" {
+			return nil, &paramError{param: "message_id", err: ErrInvalidQuery}
+		}
 
-	case "all":
-		return nh.postgresClient.FetchByAll(ctx)
+		if !strings.HasPrefix(messageID, "<") {
+			messageID = "<" + messageID + ">"
+		}
+
+		return nh.postgresClient.FetchByMessageID(ctx, messageID)
 
 	default:
-		return nil, ErrInvalidQuery
+		return nil, &paramError{param: "by", err: ErrInvalidQuery}
 	}
 }
 
+// handleVisibleQuery returns the notifications selected by handleQuery that are visible to the client of the context,
+// see service.Visible. It returns pgx.ErrNoRows if none of them is visible.
+func (nh *NotificationHandler) handleVisibleQuery(ctx context.Context, q url.Values) ([]*api.Notification, error) {
+	notifications, err := nh.handleQuery(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := notifications[:0]
+
+	for _, notification := range notifications {
+		if service.Visible(ctx, notification) {
+			visible = append(visible, notification)
+		}
+	}
+
+	if len(visible) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
+	return visible, nil
+}
+
+// handlePageQuery returns a single page of all saved notifications, or of the notifications sent to the email
+// query parameter with by=email.
+// The page is selected with the limit, cursor, order, type, status, subject, sent_after and sent_before query parameters.
+func (nh *NotificationHandler) handlePageQuery(ctx context.Context, q url.Values) (*listPage, error) {
+	filter, err := parseListFilter(q)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.Get("by") == "email" {
+		filter.To = q.Get("email")
+
+		if filter.To == "" {
+			return nil, invalidParam("email", "email is required with by=email")
+		}
+	}
+
+	emails, next, err := nh.service.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &listPage{Items: emails}
+
+	if next != 0 {
+		page.NextCursor = service.EncodeCursor(next)
+	}
+
+	return page, nil
+}
+
+// parseListFilter returns the filter of the page of notifications from the query parameters.
+// The notifications are listed from the oldest to the newest, unless the order is desc.
+// The sending time range is given with the RFC 3339 timestamps.
+func parseListFilter(q url.Values) (*api.ListFilter, error) {
+	filter := &api.ListFilter{
+		Type:    q.Get("type"),
+		Status:  q.Get("status"),
+		Subject: q.Get("subject"),
+		Limit:   api.DefaultListLimit,
+	}
+
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > api.MaxListLimit {
+			return nil, invalidParam("limit", "limit must be an integer from 1 to %d", api.MaxListLimit)
+		}
+
+		filter.Limit = n
+	}
+
+	if cursor := q.Get("cursor"); cursor != "" {
+		id, err := service.DecodeCursor(cursor)
+		if err != nil {
+			return nil, invalidParam("cursor", "invalid cursor")
+		}
+
+		filter.AfterID = id
+	}
+
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		return nil, invalidParam("order", "order must be asc or desc")
+	}
+
+	if filter.Type != "" && !api.ValidType(filter.Type) {
+		return nil, invalidParam("type", "unknown type")
+	}
+
+	if filter.Status != "" && !api.ValidStatus(filter.Status) {
+		return nil, invalidParam("status", "unknown status")
+	}
+
+	var err error
+
+	if filter.SentAfter, err = parseQueryTime(q, "sent_after"); err != nil {
+		return nil, err
+	}
+
+	if filter.SentBefore, err = parseQueryTime(q, "sent_before"); err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+// parseQueryTime returns the optional RFC 3339 timestamp of the query parameter.
+func parseQueryTime(q url.Values, name string) (*time.Time, error) {
+	value := q.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, invalidParam(name, "%s must be an RFC 3339 timestamp", name)
+	}
+
+	return &t, nil
+}
+
 // writeResponse sets the Content-Type header to application/json,
 // and writes the provided message as JSON to the HTTP client.
-func (nh *NotificationHandler) writeResponse(w http.ResponseWriter, metrics monitoring.Monitoring, handlerName string, emails []*SMTPClient.EmailMessage) {
+func (nh *NotificationHandler) writeResponse(w http.ResponseWriter, metrics monitoring.Monitoring, handlerName string, res any) {
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(emails); err != nil {
+	if err := json.NewEncoder(w).Encode(res); err != nil {
 		http.Error(w, http.StatusText(500), http.StatusInternalServerError)
 		metrics.IncError(handlerName)
 		nh.logger.Error("NewListNotificationHandler: cannot send response to caller", zap.Error(err))
@@ -95,31 +283,31 @@ func (nh *NotificationHandler) processError(ctx context.Context, err error, hand
 	metrics monitoring.Monitoring, w http.ResponseWriter, r *http.Request) {
 	switch {
 	case errors.Is(err, context.Canceled):
-		http.Error(w, http.StatusText(400), http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeRequestCanceled, http.StatusText(400))
 		metrics.IncCanceled(handlerName)
 		nh.logger.Info("NewListNotificationHandler: Context canceled after handleQuery", zap.Error(ctx.Err()))
 
 		return
 
 	case errors.Is(err, context.DeadlineExceeded):
-		http.Error(w, http.StatusText(500), http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeTimeout, http.StatusText(500))
 		metrics.IncTimeout(handlerName)
 		nh.logger.Info("NewListNotificationHandler: Context deadline exceeded", zap.Error(ctx.Err()))
 
 		return
 
 	case errors.Is(err, pgx.ErrNoRows):
-		http.Error(w, "There are no results for the specified param\n", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeNotFound, "There are no results for the specified param")
 		nh.logger.Warn("NewListNotificationHandler: no rows found", zap.Error(err), zap.String("query", r.URL.RawQuery))
 
 		return
 
 	case errors.Is(err, ErrInvalidQuery):
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, err.Error(), queryFields(err)...)
 		nh.logger.Warn("NewListNotificationHandler: invalid query", zap.Error(err), zap.String("query", r.URL.RawQuery))
 
 	default:
-		http.Error(w, http.StatusText(500), http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, http.StatusText(500))
 		metrics.IncError(handlerName)
 		nh.logger.Error("NewListNotificationHandler: cannot get email from postgres", zap.Error(err))
 
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"notification/internal/api"
+	"notification/internal/api/decoder"
+	"notification/internal/api/problem"
+	"notification/internal/api/service"
+	"notification/internal/auth"
+	"notification/internal/monitoring"
+)
+
+// errInvalidID indicates that the notification ID in the path is not a positive integer.
+var errInvalidID = errors.New("invalid notification ID")
+
+// createdNotification is the response of NewCreateNotificationHandler.
+type createdNotification struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+}
+
+// NewCreateNotificationHandler returns an HTTP handler that creates a notification with the v2 API.
+// The notification is scheduled with service.Service.Schedule if the request has send_at,
+// otherwise it is sent immediately with service.Service.Send, and the errors are reported the same way as in v1.
+// The authenticated client must be granted the schedule scope to schedule and the send scope to send immediately.
+// On success, it responds with 201 Created, the Location of the notification, its ID and status.
+func (nh *NotificationHandler) NewCreateNotificationHandler(metrics monitoring.Monitoring) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		handlerName := "CreateNotification"
+
+		email, err := decoder.DecodeNotification(nh.decoderConfig, nh.logger, r, w)
+		if err != nil {
+			metrics.IncError(handlerName)
+			nh.logger.Error("NewCreateNotificationHandler: Failed to decode request", zap.Error(err))
+			return
+		}
+
+		scope := api.ScopeSend
+		if email.Type == api.KeyForDelayedSending {
+			scope = api.ScopeSchedule
+		}
+
+		if identity, ok := auth.FromContext(r.Context()); ok && !identity.HasScope(scope) {
+			problem.Write(w, r, http.StatusForbidden, problem.CodeForbidden, "The API key does not grant the scope: "+scope)
+			metrics.IncError(handlerName)
+			nh.logger.Warn("NewCreateNotificationHandler: scope not granted", zap.String("client", identity.Client),
+				zap.String("scope", scope))
+
+			return
+		}
+
+		timeout := nh.calculateTimeoutForSend()
+		if email.Type == api.KeyForDelayedSending {
+			timeout = nh.calculateTimeoutForSendViaTime()
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		if nh.checkCtxError(ctx, w, r, metrics, handlerName) {
+			return
+		}
+
+		status := api.StatusPending
+
+		if email.Type == api.KeyForDelayedSending {
+			if err = nh.service.Schedule(ctx, email); err != nil {
+				problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, http.StatusText(500))
+				metrics.IncError(handlerName)
+				nh.logger.Error("NewCreateNotificationHandler: Cannot schedule notification", zap.Error(err))
+
+				return
+			}
+		} else {
+			status, err = nh.service.Send(ctx, email)
+			if nh.processSendError(w, r, email.Id, status, err, metrics, handlerName) {
+				return
+			}
+		}
+
+		w.Header().Set("Location", "/v2/notifications/"+strconv.Itoa(email.Id))
+		nh.writeJSON(w, http.StatusCreated, metrics, handlerName, createdNotification{ID: email.Id, Status: status})
+
+		metrics.Observe(handlerName, start)
+		metrics.IncSuccess(handlerName)
+	}
+}
+
+// NewGetNotificationHandler returns an HTTP handler that writes the notification with the ID from the path.
+func (nh *NotificationHandler) NewGetNotificationHandler(metrics monitoring.Monitoring) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), nh.calculateTimeoutForList())
+		defer cancel()
+
+		start := time.Now()
+
+		handlerName := "GetNotification"
+
+		if nh.checkCtxError(ctx, w, r, metrics, handlerName) {
+			return
+		}
+
+		id, err := parseNotificationID(r)
+		if err != nil {
+			nh.processNotificationError(err, handlerName, metrics, w, r)
+			return
+		}
+
+		notification, err := nh.service.Get(ctx, id)
+		if err != nil {
+			nh.processNotificationError(err, handlerName, metrics, w, r)
+			return
+		}
+
+		nh.writeJSON(w, http.StatusOK, metrics, handlerName, notification)
+
+		metrics.Observe(handlerName, start)
+		metrics.IncSuccess(handlerName)
+	}
+}
+
+// NewListNotificationsHandler returns an HTTP handler that writes a single page of notifications with the v2 API.
+// The page is selected with the same query parameters as the list of all notifications in v1, see parseListFilter.
+func (nh *NotificationHandler) NewListNotificationsHandler(metrics monitoring.Monitoring) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), nh.calculateTimeoutForList())
+		defer cancel()
+
+		start := time.Now()
+
+		handlerName := "ListNotifications"
+
+		if nh.checkCtxError(ctx, w, r, metrics, handlerName) {
+			return
+		}
+
+		page, err := nh.handlePageQuery(ctx, r.URL.Query())
+		if err != nil {
+			nh.processNotificationError(err, handlerName, metrics, w, r)
+			return
+		}
+
+		nh.writeJSON(w, http.StatusOK, metrics, handlerName, page)
+
+		metrics.Observe(handlerName, start)
+		metrics.IncSuccess(handlerName)
+	}
+}
+
+// NewCancelNotificationHandler returns an HTTP handler that cancels the pending scheduled notification
+// with the ID from the path and writes the canceled notification.
+// Notifications sent immediately and notifications that are not pending anymore are rejected with 409 Conflict.
+func (nh *NotificationHandler) NewCancelNotificationHandler(metrics monitoring.Monitoring) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), nh.calculateTimeoutForList())
+		defer cancel()
+
+		start := time.Now()
+
+		handlerName := "CancelNotification"
+
+		if nh.checkCtxError(ctx, w, r, metrics, handlerName) {
+			return
+		}
+
+		id, err := parseNotificationID(r)
+		if err != nil {
+			nh.processNotificationError(err, handlerName, metrics, w, r)
+			return
+		}
+
+		notification, err := nh.service.Cancel(ctx, id)
+		if err != nil {
+			nh.processNotificationError(err, handlerName, metrics, w, r)
+			return
+		}
+
+		nh.logger.Info("NewCancelNotificationHandler: Notification canceled", zap.Int("id", id))
+		nh.writeJSON(w, http.StatusOK, metrics, handlerName, notification)
+
+		metrics.Observe(handlerName, start)
+		metrics.IncSuccess(handlerName)
+	}
+}
+
+// parseNotificationID returns the notification ID from the path.
+func parseNotificationID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil || id <= 0 {
+		return 0, errInvalidID
+	}
+
+	return id, nil
+}
+
+// processNotificationError handles the error returned by the v2 notification handlers
+// and writes the appropriate HTTP response.
+func (nh *NotificationHandler) processNotificationError(err error, handlerName string, metrics monitoring.Monitoring,
+	w http.ResponseWriter, r *http.Request) {
+	switch {
+	case errors.Is(err, errInvalidID):
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, err.Error(), "id")
+		nh.logger.Warn(handlerName+": invalid request", zap.Error(err))
+
+	case errors.Is(err, ErrInvalidQuery):
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, err.Error(), queryFields(err)...)
+		nh.logger.Warn(handlerName+": invalid request", zap.Error(err))
+
+	case errors.Is(err, service.ErrNotFound):
+		problem.Write(w, r, http.StatusNotFound, problem.CodeNotFound, "Notification not found")
+		nh.logger.Warn(handlerName+": notification not found", zap.Error(err))
+
+	case errors.Is(err, service.ErrNotCancelable):
+		problem.Write(w, r, http.StatusConflict, problem.CodeNotCancelable, "Only pending scheduled notifications can be canceled")
+		nh.logger.Warn(handlerName+": notification cannot be canceled", zap.Error(err))
+
+	case errors.Is(err, context.Canceled):
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeRequestCanceled, http.StatusText(400))
+		metrics.IncCanceled(handlerName)
+		nh.logger.Info(handlerName+": Context canceled", zap.Error(err))
+
+	case errors.Is(err, context.DeadlineExceeded):
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeTimeout, http.StatusText(500))
+		metrics.IncTimeout(handlerName)
+		nh.logger.Info(handlerName+": Context deadline exceeded", zap.Error(err))
+
+	default:
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, http.StatusText(500))
+		metrics.IncError(handlerName)
+		nh.logger.Error(handlerName+": cannot access postgres", zap.Error(err))
+	}
+}
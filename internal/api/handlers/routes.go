@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+
+	"notification/internal/api"
+	"notification/internal/api/openapi"
+	"notification/internal/auth"
+	"notification/internal/monitoring"
+)
+
+// Routes registers the endpoints of the service API on the router.
+// The endpoints require the API key granting the scope of the endpoint, see auth.Authenticator.Middleware,
+// except the unsubscribe and tracking endpoints opened from the emails, which are protected by the signed tokens,
+// and the OpenAPI document.
+// The requests sending and scheduling notifications can be made idempotent with the Idempotency-Key header,
+// see NewIdempotencyMiddleware.
+func (nh *NotificationHandler) Routes(router chi.Router, authenticator *auth.Authenticator, metrics *monitoring.AppMetrics) {
+	idempotency := nh.NewIdempotencyMiddleware(metrics.IdempotencyMetrics)
+
+	send := router.With(authenticator.Middleware(api.ScopeSend))
+	schedule := router.With(authenticator.Middleware(api.ScopeSchedule))
+	read := router.With(authenticator.Middleware(api.ScopeRead))
+	admin := router.With(authenticator.Middleware(api.ScopeAdmin))
+
+	send.With(idempotency).Post("/send-notification", nh.NewSendNotificationHandler(metrics.SendNotificationMetrics))
+
+	schedule.With(idempotency).Post("/send-notification-via-time", nh.NewSendNotificationViaTimeHandler(metrics.SendNotificationViaTimeMetrics))
+
+	// The read scope grants access to the notifications of all clients, including /list?by=all.
+	// Only the clients with a tenant are limited to the notifications of their tenant, see service.Visible.
+	read.Get("/list", nh.NewListNotificationHandler(metrics.ListNotificationMetrics))
+
+	router.Post("/unsubscribe/{token}", nh.NewUnsubscribeHandler(metrics.UnsubscribeMetrics))
+
+	admin.Post("/suppressions", nh.NewSaveSuppressionHandler(metrics.SuppressionMetrics))
+
+	admin.Get("/suppressions", nh.NewListSuppressionHandler(metrics.SuppressionMetrics))
+
+	admin.Delete("/suppressions", nh.NewDeleteSuppressionHandler(metrics.SuppressionMetrics))
+
+	// The bounce endpoint has no secret of its own, the mail server forwarding the bounces must use an API key
+	// with the admin scope. The report can only bounce a sent notification and suppress its recipient.
+	admin.Post("/bounces", nh.NewBounceHandler(metrics.BounceMetrics))
+
+	router.Get("/track/open/{token}", nh.NewOpenTrackingHandler(metrics.TrackingMetrics))
+
+	router.Get("/track/click/{token}", nh.NewClickTrackingHandler(metrics.TrackingMetrics))
+
+	read.Get("/notifications/events", nh.NewEventsHandler(metrics.EventsMetrics))
+
+	router.Route("/v2/notifications", func(r chi.Router) {
+		// The scope of the created notification is checked by the handler, once the body is decoded.
+		r.With(authenticator.Middleware(api.ScopeSend, api.ScopeSchedule), idempotency).
+			Post("/", nh.NewCreateNotificationHandler(metrics.NotificationMetrics))
+		r.With(authenticator.Middleware(api.ScopeRead)).Get("/", nh.NewListNotificationsHandler(metrics.NotificationMetrics))
+		r.With(authenticator.Middleware(api.ScopeRead)).Get("/{id}", nh.NewGetNotificationHandler(metrics.NotificationMetrics))
+		r.With(authenticator.Middleware(api.ScopeSchedule)).Delete("/{id}", nh.NewCancelNotificationHandler(metrics.NotificationMetrics))
+	})
+
+	router.Route("/api-keys", func(r chi.Router) {
+		r.Use(authenticator.Middleware(api.ScopeAdmin))
+		r.Post("/", nh.NewCreateAPIKeyHandler(metrics.APIKeyMetrics))
+		r.Get("/", nh.NewListAPIKeysHandler(metrics.APIKeyMetrics))
+		r.Delete("/{id}", nh.NewRevokeAPIKeyHandler(metrics.APIKeyMetrics))
+	})
+
+	// URL format middleware strips the .json extension, so the document is served at /openapi.json.
+	router.Get("/openapi", openapi.Handler())
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"notification/internal/api"
+	"notification/internal/api/decoder"
+	"notification/internal/api/problem"
+	"notification/internal/monitoring"
+	"notification/internal/unsubscribe"
+)
+
+var (
+	// ErrRecipientSuppressed indicates that the notification is rejected, because the recipient is on the suppression list.
+	ErrRecipientSuppressed = errors.New("recipient is on the suppression list")
+
+	// errNoAddress indicates that the address query parameter is missing.
+	errNoAddress = errors.New("address query parameter is required")
+)
+
+// NewSaveSuppressionHandler returns an HTTP handler that adds an address to the suppression list
+// or replaces its existing entry, and writes the saved entry to the HTTP response.
+func (nh *NotificationHandler) NewSaveSuppressionHandler(metrics monitoring.Monitoring) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), nh.calculateTimeoutForList())
+		defer cancel()
+
+		start := time.Now()
+
+		handlerName := "SaveSuppression"
+
+		if nh.checkCtxError(ctx, w, r, metrics, handlerName) {
+			return
+		}
+
+		suppression, err := decoder.DecodeSuppression(nh.logger, r, w)
+		if err != nil {
+			metrics.IncError(handlerName)
+			nh.logger.Error("NewSaveSuppressionHandler: Failed to decode request", zap.Error(err))
+			return
+		}
+
+		if err = nh.postgresClient.SaveSuppression(ctx, suppression); err != nil {
+			nh.processStorageError(err, handlerName, metrics, w, r)
+			return
+		}
+
+		nh.writeJSON(w, http.StatusCreated, metrics, handlerName, suppression)
+
+		metrics.Observe(handlerName, start)
+		metrics.IncSuccess(handlerName)
+	}
+}
+
+// NewListSuppressionHandler returns an HTTP handler that lists the suppression list entries,
+// or the entry of the single address specified with the address query parameter.
+// Expired entries are listed too until they are deleted.
+func (nh *NotificationHandler) NewListSuppressionHandler(metrics monitoring.Monitoring) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), nh.calculateTimeoutForList())
+		defer cancel()
+
+		start := time.Now()
+
+		handlerName := "ListSuppression"
+
+		if nh.checkCtxError(ctx, w, r, metrics, handlerName) {
+			return
+		}
+
+		var suppressions []*api.Suppression
+		var err error
+
+		if address := r.URL.Query().Get("address"); address != "" {
+			var suppression *api.Suppression
+
+			suppression, err = nh.postgresClient.FetchSuppression(ctx, unsubscribe.Recipient(address))
+			suppressions = []*api.Suppression{suppression}
+		} else {
+			suppressions, err = nh.postgresClient.FetchSuppressions(ctx)
+		}
+
+		if err != nil {
+			nh.processStorageError(err, handlerName, metrics, w, r)
+			return
+		}
+
+		nh.writeJSON(w, http.StatusOK, metrics, handlerName, suppressions)
+
+		metrics.Observe(handlerName, start)
+		metrics.IncSuccess(handlerName)
+	}
+}
+
+// NewDeleteSuppressionHandler returns an HTTP handler that removes the address specified
+// with the address query parameter from the suppression list.
+// The address is passed in the query, because URL format middleware would cut the domain of an address in the path.
+func (nh *NotificationHandler) NewDeleteSuppressionHandler(metrics monitoring.Monitoring) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), nh.calculateTimeoutForList())
+		defer cancel()
+
+		start := time.Now()
+
+		handlerName := "DeleteSuppression"
+
+		if nh.checkCtxError(ctx, w, r, metrics, handlerName) {
+			return
+		}
+
+		address := r.URL.Query().Get("address")
+		if address == "" {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidQuery, errNoAddress.Error(), "address")
+			metrics.IncError(handlerName)
+			nh.logger.Warn("NewDeleteSuppressionHandler: invalid query", zap.Error(errNoAddress))
+
+			return
+		}
+
+		if err := nh.postgresClient.DeleteSuppression(ctx, unsubscribe.Recipient(address)); err != nil {
+			nh.processStorageError(err, handlerName, metrics, w, r)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+		metrics.Observe(handlerName, start)
+		metrics.IncSuccess(handlerName)
+	}
+}
+
+// processStorageError handles the error returned by the suppression list, bounce and API key methods of PostgreSQL
+// and writes the appropriate HTTP response.
+func (nh *NotificationHandler) processStorageError(err error, handlerName string, metrics monitoring.Monitoring,
+	w http.ResponseWriter, r *http.Request) {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		problem.Write(w, r, http.StatusNotFound, problem.CodeNotFound, "The address is not on the suppression list")
+		nh.logger.Warn(handlerName+": address is not suppressed", zap.Error(err))
+
+	case errors.Is(err, context.Canceled):
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeRequestCanceled, http.StatusText(400))
+		metrics.IncCanceled(handlerName)
+		nh.logger.Info(handlerName+": Context canceled", zap.Error(err))
+
+	case errors.Is(err, context.DeadlineExceeded):
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeTimeout, http.StatusText(500))
+		metrics.IncTimeout(handlerName)
+		nh.logger.Info(handlerName+": Context deadline exceeded", zap.Error(err))
+
+	default:
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, http.StatusText(500))
+		metrics.IncError(handlerName)
+		nh.logger.Error(handlerName+": cannot access postgres", zap.Error(err))
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"notification/internal/api"
+	"notification/internal/api/problem"
+	"notification/internal/monitoring"
+	"notification/internal/tracking"
+)
+
+// NewOpenTrackingHandler returns an HTTP handler for the tracking pixel of HTML emails.
+// It verifies the token from the URL, records the open event of the notification in PostgreSQL and serves a transparent GIF.
+// The pixel is served even if the event cannot be saved, so that the email is displayed correctly.
+func (nh *NotificationHandler) NewOpenTrackingHandler(metrics monitoring.Monitoring) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), nh.calculateTimeoutForList())
+		defer cancel()
+
+		start := time.Now()
+
+		handlerName := "TrackOpen"
+
+		id, err := nh.tracker.ParseOpen(chi.URLParam(r, "token"))
+		if err != nil {
+			http.NotFound(w, r)
+			metrics.IncError(handlerName)
+			nh.logger.Warn("NewOpenTrackingHandler: invalid token", zap.Error(err))
+
+			return
+		}
+
+		nh.saveTrackingEvent(ctx, id, api.EventOpen, "", metrics, handlerName)
+
+		w.Header().Set("Content-Type", "image/gif")
+		w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate")
+		_, _ = w.Write(tracking.Pixel)
+
+		metrics.Observe(handlerName, start)
+		metrics.IncSuccess(handlerName)
+	}
+}
+
+// NewClickTrackingHandler returns an HTTP handler for the tracking links of HTML emails.
+// It verifies the token from the URL, records the click event of the notification in PostgreSQL
+// and redirects to the original link. The redirect is performed even if the event cannot be saved.
+// Only the signed target of the token is used, so the endpoint cannot be used as an open redirect.
+func (nh *NotificationHandler) NewClickTrackingHandler(metrics monitoring.Monitoring) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), nh.calculateTimeoutForList())
+		defer cancel()
+
+		start := time.Now()
+
+		handlerName := "TrackClick"
+
+		id, target, err := nh.tracker.ParseClick(chi.URLParam(r, "token"))
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidToken, "Invalid tracking link")
+			metrics.IncError(handlerName)
+			nh.logger.Warn("NewClickTrackingHandler: invalid token", zap.Error(err))
+
+			return
+		}
+
+		nh.saveTrackingEvent(ctx, id, api.EventClick, target, metrics, handlerName)
+
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, target, http.StatusFound)
+
+		metrics.Observe(handlerName, start)
+		metrics.IncSuccess(handlerName)
+	}
+}
+
+// saveTrackingEvent records the tracking event of the notification in PostgreSQL.
+// The error is only logged, because the recipient must get the pixel or the link target anyway.
+func (nh *NotificationHandler) saveTrackingEvent(ctx context.Context, id int, eventType, url string,
+	metrics monitoring.Monitoring, handlerName string) {
+	if err := nh.postgresClient.SaveTrackingEvent(ctx, id, eventType, url); err != nil {
+		metrics.IncError(handlerName)
+		nh.logger.Error(handlerName+": Cannot save tracking event", zap.Error(err), zap.Int("id", id))
+	}
+}
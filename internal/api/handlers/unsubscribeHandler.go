@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"notification/internal/api/problem"
+	"notification/internal/monitoring"
+)
+
+// NewUnsubscribeHandler returns an HTTP handler for one-click unsubscribe links (RFC 8058).
+// It verifies the token from the URL and records the opt-out of the recipient from the category in PostgreSQL.
+// The endpoint is public, the signed token is the only proof of the recipient identity.
+func (nh *NotificationHandler) NewUnsubscribeHandler(metrics monitoring.Monitoring) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), nh.calculateTimeoutForList())
+		defer cancel()
+
+		start := time.Now()
+
+		handlerName := "Unsubscribe"
+
+		if nh.checkCtxError(ctx, w, r, metrics, handlerName) {
+			return
+		}
+
+		recipient, category, err := nh.tokens.Parse(chi.URLParam(r, "token"))
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, problem.CodeInvalidToken, "Invalid unsubscribe token")
+			metrics.IncError(handlerName)
+			nh.logger.Warn("NewUnsubscribeHandler: invalid token", zap.Error(err))
+
+			return
+		}
+
+		if err = nh.postgresClient.Unsubscribe(ctx, recipient, category); err != nil {
+			problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, http.StatusText(500))
+			metrics.IncError(handlerName)
+			nh.logger.Error("NewUnsubscribeHandler: Cannot save unsubscribe", zap.Error(err))
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("You have been unsubscribed\n"))
+
+		metrics.Observe(handlerName, start)
+		metrics.IncSuccess(handlerName)
+	}
+}
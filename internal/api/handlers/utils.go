@@ -10,44 +10,60 @@ import (
 	"go.uber.org/zap"
 
 	"notification/internal/SMTPClient"
+	"notification/internal/api/decoder"
+	"notification/internal/api/problem"
+	"notification/internal/api/service"
 	"notification/internal/config"
+	"notification/internal/events"
 	"notification/internal/monitoring"
 	"notification/internal/storage/postgresClient"
 	"notification/internal/storage/redisClient"
+	"notification/internal/tracking"
+	"notification/internal/unsubscribe"
+	"notification/internal/webhook"
 )
 
 // NotificationHandler handles email notification HTTP requests.
 // It manages timeout configurations used for request processing.
 type NotificationHandler struct {
 	logger         *zap.Logger
-	sender         SMTPClient.EmailSender
 	redisClient    redisClient.RedisClient
 	postgresClient postgresClient.PostgresClient
+	decoderConfig  *decoder.Config
+	tokens         *unsubscribe.Tokens
+	tracker        *tracking.Tracker
+	notifier       webhook.Notifier
+	bus            *events.Bus
+	service        *service.Service
 	timeouts       config.AppTimeouts
 	extraTimeout   time.Duration
 }
 
-// New creates and returns a new NotificationHandler instance.
+// New creates and returns a new NotificationHandler instance,
+// the notifications are sent, scheduled, listed and canceled through the shared service.Service.
 func New(logger *zap.Logger, sender SMTPClient.EmailSender, redisClient redisClient.RedisClient,
-	postgresClient postgresClient.PostgresClient, timeouts config.AppTimeouts, extraTimeout time.Duration) *NotificationHandler {
+	postgresClient postgresClient.PostgresClient, decoderConfig *decoder.Config, tokens *unsubscribe.Tokens,
+	tracker *tracking.Tracker, notifier webhook.Notifier, bus *events.Bus,
+	timeouts config.AppTimeouts, extraTimeout time.Duration) *NotificationHandler {
 	return &NotificationHandler{
 		logger:         logger,
-		sender:         sender,
 		redisClient:    redisClient,
 		postgresClient: postgresClient,
+		decoderConfig:  decoderConfig,
+		tokens:         tokens,
+		tracker:        tracker,
+		notifier:       notifier,
+		bus:            bus,
+		service:        service.New(sender, redisClient, postgresClient, notifier, bus, logger),
 		timeouts:       timeouts,
 		extraTimeout:   extraTimeout,
 	}
 }
 
 // calculateTimeoutForSend calculates the total timeout for NewSendNotificationHandler,
-// including SMTP retry delays, PostgreSQL timeout, and additional buffer time.
+// including the longest SMTP retry delays allowed by the backoff policy, PostgreSQL timeout, and additional buffer time.
 func (nh *NotificationHandler) calculateTimeoutForSend() time.Duration {
-	var smtpAllTimeout time.Duration
-
-	for i := 0; i < nh.timeouts.SMTPQuantityOfRetries+1; i++ {
-		smtpAllTimeout += nh.sender.CreatePause(i)
-	}
+	smtpAllTimeout := nh.timeouts.SMTPBackoff.Budget(nh.timeouts.SMTPQuantityOfRetries)
 
 	allTimeout := smtpAllTimeout + nh.timeouts.PostgresTimeout + nh.extraTimeout
 
@@ -61,7 +77,8 @@ func (nh *NotificationHandler) calculateTimeoutForSendViaTime() time.Duration {
 	return allTimeout
 }
 
-// calculateTimeoutForSend calculates the total timeout for NewListNotificationHandler,
+// calculateTimeoutForList calculates the total timeout for NewListNotificationHandler, NewUnsubscribeHandler,
+// the suppression list handlers and the tracking handlers,
 // including PostgreSQL timeout, and additional buffer time.
 func (nh *NotificationHandler) calculateTimeoutForList() time.Duration {
 	allTimeout := nh.timeouts.PostgresTimeout + nh.extraTimeout
@@ -69,19 +86,19 @@ func (nh *NotificationHandler) calculateTimeoutForList() time.Duration {
 }
 
 // checkCtxError checks which one exactly context error (context canceled or deadline exceeded).
-func (nh *NotificationHandler) checkCtxError(ctx context.Context, w http.ResponseWriter,
+func (nh *NotificationHandler) checkCtxError(ctx context.Context, w http.ResponseWriter, r *http.Request,
 	metrics monitoring.Monitoring, handlerName string) bool {
 
 	switch {
 	case errors.Is(ctx.Err(), context.Canceled):
-		http.Error(w, http.StatusText(400), http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeRequestCanceled, http.StatusText(400))
 		metrics.IncCanceled(handlerName)
 		nh.logger.Error(handlerName+": Context canceled before processing started", zap.Error(ctx.Err()))
 
 		return true
 
 	case errors.Is(ctx.Err(), context.DeadlineExceeded):
-		http.Error(w, http.StatusText(500), http.StatusInternalServerError)
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeTimeout, http.StatusText(500))
 		metrics.IncCanceled(handlerName)
 		nh.logger.Error(handlerName+": Context deadline before processing started", zap.Error(ctx.Err()))
 
@@ -113,3 +130,15 @@ func (nh *NotificationHandler) writeResponseWithId(w http.ResponseWriter, id int
 		nh.logger.Error(handlerName+": Cannot send report to caller", zap.Error(err))
 	}
 }
+
+// writeJSON writes the value as JSON with the specified status code.
+func (nh *NotificationHandler) writeJSON(w http.ResponseWriter, statusCode int, metrics monitoring.Monitoring,
+	handlerName string, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		metrics.IncError(handlerName)
+		nh.logger.Error(handlerName+": cannot send response to caller", zap.Error(err))
+	}
+}
@@ -10,6 +10,120 @@ const (
 	KeyForDelayedSending = "delayedSending"
 )
 
+const (
+	// StatusPending is the status of a notification that is saved but not sent yet.
+	StatusPending = "pending"
+
+	// StatusSending is the status of a scheduled notification the worker has taken to send, it cannot be canceled anymore.
+	StatusSending = "sending"
+
+	// StatusSent is the status of a notification accepted by the SMTP server.
+	StatusSent = "sent"
+
+	// StatusFailed is the status of a notification that could not be sent.
+	StatusFailed = "failed"
+
+	// StatusSkipped is the status of a notification that was not sent, because the recipient has unsubscribed.
+	StatusSkipped = "skipped"
+
+	// StatusSuppressed is the status of a notification that was not sent, because the recipient is on the suppression list.
+	StatusSuppressed = "suppressed"
+
+	// StatusBounced is the status of a notification the remote mail server returned with a delivery status notification.
+	StatusBounced = "bounced"
+
+	// StatusCanceled is the status of a scheduled notification that was canceled before it was sent.
+	StatusCanceled = "canceled"
+)
+
+// CategoryTransactional is the category of notifications recipients cannot unsubscribe from.
+// The empty category is treated as transactional.
+const CategoryTransactional = "transactional"
+
+const (
+	// SuppressionReasonHardBounce is the reason of suppressing an address the mail server permanently rejected.
+	SuppressionReasonHardBounce = "hard_bounce"
+
+	// SuppressionReasonComplaint is the reason of suppressing an address whose owner marked an email as spam.
+	SuppressionReasonComplaint = "complaint"
+
+	// SuppressionReasonManual is the reason of suppressing an address on request of an operator.
+	SuppressionReasonManual = "manual"
+)
+
+const (
+	// SuppressionSourceAPI is the source of suppressions added with the suppressions endpoint.
+	SuppressionSourceAPI = "api"
+
+	// SuppressionSourceBounce is the source of suppressions added automatically for hard-bounced addresses.
+	SuppressionSourceBounce = "bounce"
+)
+
+// Suppression is an entry of the suppression list, no emails are sent to the address until the entry expires.
+// Entries without ExpiresAt never expire.
+type Suppression struct {
+	Address   string     `json:"address"`
+	Reason    string     `json:"reason"`
+	Source    string     `json:"source,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+const (
+	// EventOpen is the type of the tracking event recorded when the tracking pixel of the email is loaded.
+	EventOpen = "open"
+
+	// EventClick is the type of the tracking event recorded when a tracked link of the email is followed.
+	EventClick = "click"
+)
+
+// TrackingEvent is an open or click of the tracked email, URL is the target of the followed link.
+type TrackingEvent struct {
+	Type      string    `json:"type"`
+	URL       string    `json:"url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// StatusEvent is the change of the notification status, it is sent with delivery status webhooks
+// and streamed to the event subscribers. Tenant is the tenant of the client that created the notification,
+// the subscribers with a tenant only receive the events of their tenant.
+type StatusEvent struct {
+	ID        int       `json:"id"`
+	Status    string    `json:"status"`
+	To        string    `json:"to"`
+	MessageID string    `json:"message_id,omitempty"`
+	Tenant    string    `json:"tenant,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const (
+	// ScopeSend allows sending notifications immediately.
+	ScopeSend = "send"
+
+	// ScopeSchedule allows scheduling notifications and canceling the scheduled ones.
+	ScopeSchedule = "schedule"
+
+	// ScopeRead allows reading the saved notifications and streaming their status events.
+	ScopeRead = "read"
+
+	// ScopeAdmin allows everything, including the management of the suppression list and API keys.
+	ScopeAdmin = "admin"
+)
+
+// APIKey is an API key issued to a client, the key itself is returned only once, when the key is created,
+// and only its hash is stored. Name identifies the client, it is stored with the notifications created with the key.
+// Prefix is the beginning of the key that helps to tell the keys apart. Revoked and expired keys are rejected.
+type APIKey struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	Key       string     `json:"key,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
 // HttpServer defines the configuration parameters for the HTTP server.
 type HttpServer struct {
 	Host           string        `env:"HTTP_HOST"`
@@ -17,3 +131,98 @@ type HttpServer struct {
 	MonitoringPort string        `env:"HTTP_MONITORING_PORT"`
 	TimeoutExtra   time.Duration `env:"HTTP_TIMEOUT_EXTRA"`
 }
+
+// GRPCServer defines the configuration parameters for the gRPC server.
+// MaxBatchSize is the maximum number of notifications in a single BatchSend stream.
+type GRPCServer struct {
+	Host         string `env:"GRPC_HOST"`
+	Port         string `env:"GRPC_PORT"`
+	MaxBatchSize int    `env:"GRPC_MAX_BATCH_SIZE"`
+}
+
+// Notification is a saved email notification as it is returned by the list endpoints:
+// the email with its ID, its status, the client that created it and its tenant, the time it was saved and the time it was sent.
+type Notification struct {
+	ID       int               `json:"id"`
+	Type     string            `json:"type"`
+	Status   string            `json:"status"`
+	Time     *time.Time        `json:"time,omitempty"`
+	To       string            `json:"to"`
+	Subject  string            `json:"subject"`
+	Message  string            `json:"message"`
+	From     string            `json:"from,omitempty"`
+	FromName string            `json:"from_name,omitempty"`
+	ReplyTo  string            `json:"reply_to,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+
+	MessageID  string   `json:"message_id,omitempty"`
+	InReplyTo  string   `json:"in_reply_to,omitempty"`
+	References []string `json:"references,omitempty"`
+
+	Category string `json:"category,omitempty"`
+
+	HTML   string          `json:"html,omitempty"`
+	Track  bool            `json:"track,omitempty"`
+	Events []TrackingEvent `json:"events,omitempty"`
+
+	CallbackURL string `json:"callback_url,omitempty"`
+
+	Client string `json:"client,omitempty"`
+	Tenant string `json:"tenant,omitempty"`
+
+	CreatedAt time.Time  `json:"created_at"`
+	SentAt    *time.Time `json:"sent_at,omitempty"`
+}
+
+const (
+	// DefaultListLimit is the default number of notifications on a single page of the list.
+	DefaultListLimit = 50
+
+	// MaxListLimit is the maximum number of notifications on a single page of the list.
+	MaxListLimit = 500
+)
+
+// ListFilter selects a single page of saved notifications ordered by ID, that is by the time they were saved.
+// The page starts after the notification with the AfterID ID, the zero AfterID selects the first page.
+// The empty fields do not restrict the notifications: To is the exact recipient, Subject matches a substring of the subject case-insensitively,
+// SentAfter and SentBefore limit the sending time of the notification, the first inclusive and the second exclusive.
+// Tenant limits the notifications to the tenant of the client, it is set by the service, not by the caller.
+type ListFilter struct {
+	To         string
+	Type       string
+	Status     string
+	Subject    string
+	SentAfter  *time.Time
+	SentBefore *time.Time
+	AfterID    int
+	Desc       bool
+	Limit      int
+	Tenant     string
+}
+
+// ValidType reports whether the sending type is one of the notification types.
+func ValidType(sendingType string) bool {
+	return sendingType == KeyForInstantSending || sendingType == KeyForDelayedSending
+}
+
+// ValidStatus reports whether the status is one of the notification statuses.
+func ValidStatus(status string) bool {
+	switch status {
+	case StatusPending, StatusSending, StatusSent, StatusFailed, StatusSkipped, StatusSuppressed, StatusBounced, StatusCanceled:
+		return true
+
+	default:
+		return false
+	}
+}
+
+// ValidScope reports whether the scope is one of the API key scopes.
+func ValidScope(scope string) bool {
+	switch scope {
+	case ScopeSend, ScopeSchedule, ScopeRead, ScopeAdmin:
+		return true
+
+	default:
+		return false
+	}
+}
@@ -0,0 +1,155 @@
+package openapi
+
+import (
+	"bytes"
+	"cmp"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// methods are the HTTP methods of the operations, see document.operation.
+var methods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// raw is the OpenAPI 3 document of the service API.
+//
+//go:embed openapi.json
+var raw []byte
+
+// parse returns the parsed document, it is parsed once on the first use.
+var parse = sync.OnceValues(func() (*document, error) {
+	doc := &document{}
+	if err := json.Unmarshal(raw, doc); err != nil {
+		return nil, fmt.Errorf("parse: cannot parse the document: %w", err)
+	}
+
+	return doc, nil
+})
+
+// Handler returns an HTTP handler that writes the OpenAPI document.
+func Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(raw)
+	}
+}
+
+// ValidateResponse checks that the response of the operation is described in the document,
+// path is the route pattern of the operation, like /v2/notifications/{id}.
+// The status code falls back to the default response, and the JSON bodies are validated against their schemas.
+// It returns ErrUndocumented or ErrSchemaMismatch with the details of the mismatch.
+func ValidateResponse(method, path string, status int, header http.Header, body []byte) error {
+	doc, err := parse()
+	if err != nil {
+		return err
+	}
+
+	op := doc.operation(method, path)
+	if op == nil {
+		return fmt.Errorf("%w: %s %s", ErrUndocumented, method, path)
+	}
+
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		if resp, ok = op.Responses["default"]; !ok {
+			return fmt.Errorf("%w: %s %s: status %d", ErrUndocumented, method, path, status)
+		}
+	}
+
+	if resp.Ref != "" {
+		if resp = doc.Components.Responses[strings.TrimPrefix(resp.Ref, "#/components/responses/")]; resp == nil {
+			return fmt.Errorf("%w: %s %s: unresolved reference", ErrUndocumented, method, path)
+		}
+	}
+
+	if len(resp.Content) == 0 {
+		return nil
+	}
+
+	contentType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+
+	media, ok := resp.Content[contentType]
+	if !ok {
+		return fmt.Errorf("%w: %s %s: status %d: content type %q", ErrUndocumented, method, path, status, contentType)
+	}
+
+	if media.Schema == nil || !isJSON(contentType) {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var v any
+	if err = dec.Decode(&v); err != nil {
+		return fmt.Errorf("%w: %s %s: %v", ErrSchemaMismatch, method, path, err)
+	}
+
+	if err = doc.validate(media.Schema, v, "$"); err != nil {
+		return fmt.Errorf("%s %s: status %d: %w", method, path, status, err)
+	}
+
+	return nil
+}
+
+// Operations returns the documented operations as "METHOD path", like "GET /v2/notifications/{id}", sorted by path.
+// It is used to check that the routes of the router and the document describe the same operations.
+func Operations() ([]string, error) {
+	doc, err := parse()
+	if err != nil {
+		return nil, err
+	}
+
+	var operations []string
+
+	for path := range doc.Paths {
+		for _, method := range methods {
+			if doc.operation(method, path) != nil {
+				operations = append(operations, method+" "+path)
+			}
+		}
+	}
+
+	slices.SortFunc(operations, func(a, b string) int {
+		_, pathA, _ := strings.Cut(a, " ")
+		_, pathB, _ := strings.Cut(b, " ")
+
+		return cmp.Or(strings.Compare(pathA, pathB), strings.Compare(a, b))
+	})
+
+	return operations, nil
+}
+
+// operation returns the operation of the method and path, or nil if it is not documented.
+func (d *document) operation(method, path string) *operation {
+	item := d.Paths[path]
+	if item == nil {
+		return nil
+	}
+
+	switch method {
+	case http.MethodGet:
+		return item.Get
+	case http.MethodPost:
+		return item.Post
+	case http.MethodPut:
+		return item.Put
+	case http.MethodPatch:
+		return item.Patch
+	case http.MethodDelete:
+		return item.Delete
+	default:
+		return nil
+	}
+}
+
+// isJSON reports whether the content type is JSON, including the structured +json types like application/problem+json.
+func isJSON(contentType string) bool {
+	return contentType == "application/json" || strings.HasSuffix(contentType, "+json")
+}
@@ -0,0 +1,238 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/openapi.json", nil)
+
+	Handler()(w, r)
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "3.0.3", got["openapi"])
+	assert.NoError(t, ValidateResponse("GET", "/openapi.json", w.Code, w.Header(), w.Body.Bytes()))
+}
+
+func TestDocument(t *testing.T) {
+	doc, err := parse()
+	require.NoError(t, err)
+
+	for path := range doc.Paths {
+		for _, method := range methods {
+			op := doc.operation(method, path)
+			if op == nil {
+				continue
+			}
+
+			success := false
+
+			for status, resp := range op.Responses {
+				success = success || strings.HasPrefix(status, "2") || strings.HasPrefix(status, "3")
+
+				if resp.Ref != "" {
+					assert.Contains(t, doc.Components.Responses, strings.TrimPrefix(resp.Ref, "#/components/responses/"), "%s %s", method, path)
+					continue
+				}
+
+				for _, media := range resp.Content {
+					if media.Schema != nil {
+						checkRefs(t, doc, media.Schema, method+" "+path)
+					}
+				}
+			}
+
+			assert.True(t, success, "%s %s has no successful response", method, path)
+		}
+	}
+
+	for name, s := range doc.Components.Schemas {
+		checkRefs(t, doc, s, name)
+	}
+}
+
+func TestOperations(t *testing.T) {
+	operations, err := Operations()
+	require.NoError(t, err)
+
+	assert.Contains(t, operations, "GET /openapi.json")
+	assert.Contains(t, operations, "DELETE /v2/notifications/{id}")
+	assert.NotContains(t, operations, "PARAMETERS /v2/notifications/{id}")
+	assert.Equal(t, []string{"GET /v2/notifications", "POST /v2/notifications"},
+		slices.DeleteFunc(slices.Clone(operations), func(op string) bool { return !strings.HasSuffix(op, " /v2/notifications") }))
+}
+
+func TestValidateResponse(t *testing.T) {
+	jsonHeader := http.Header{"Content-Type": {"application/json"}}
+	problemHeader := http.Header{"Content-Type": {"application/problem+json"}}
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		status  int
+		header  http.Header
+		body    string
+		wantErr error
+	}{
+		{
+			name:   "notification",
+			method: "GET",
+			path:   "/v2/notifications/{id}",
+			status: http.StatusOK,
+			header: jsonHeader,
+			body: `{"id":7,"type":"delayedSending","status":"pending","time":"2035-07-13T11:58:00Z","to":"user@example.com",` +
+				`"subject":"Subject","message":"Message","headers":{"X-Campaign":"spring"},"created_at":"2025-07-13T11:57:59Z"}`,
+		},
+		{
+			name:    "missing required property",
+			method:  "GET",
+			path:    "/v2/notifications/{id}",
+			status:  http.StatusOK,
+			header:  jsonHeader,
+			body:    `{"id":7,"type":"delayedSending","status":"pending","to":"user@example.com","subject":"Subject","message":"Message"}`,
+			wantErr: ErrSchemaMismatch,
+		},
+		{
+			name:   "undocumented property",
+			method: "GET",
+			path:   "/v2/notifications/{id}",
+			status: http.StatusOK,
+			header: jsonHeader,
+			body: `{"id":7,"type":"delayedSending","status":"pending","to":"user@example.com","subject":"Subject",` +
+				`"message":"Message","created_at":"2025-07-13T11:57:59Z","priority":1}`,
+			wantErr: ErrSchemaMismatch,
+		},
+		{
+			name:   "unknown status",
+			method: "GET",
+			path:   "/v2/notifications/{id}",
+			status: http.StatusOK,
+			header: jsonHeader,
+			body: `{"id":7,"type":"delayedSending","status":"queued","to":"user@example.com","subject":"Subject",` +
+				`"message":"Message","created_at":"2025-07-13T11:57:59Z"}`,
+			wantErr: ErrSchemaMismatch,
+		},
+		{
+			name:   "invalid timestamp",
+			method: "GET",
+			path:   "/v2/notifications/{id}",
+			status: http.StatusOK,
+			header: jsonHeader,
+			body: `{"id":7,"type":"delayedSending","status":"pending","to":"user@example.com","subject":"Subject",` +
+				`"message":"Message","created_at":"2025-07-13 11:57:59"}`,
+			wantErr: ErrSchemaMismatch,
+		},
+		{
+			name:   "list of notifications",
+			method: "GET",
+			path:   "/list",
+			status: http.StatusOK,
+			header: jsonHeader,
+			body:   `[{"id":1,"type":"instantSending","status":"sent","to":"user@example.com","subject":"S","message":"M","created_at":"2025-07-13T11:57:59Z"}]`,
+		},
+		{
+			name:   "page of notifications",
+			method: "GET",
+			path:   "/list",
+			status: http.StatusOK,
+			header: jsonHeader,
+			body:   `{"items":null,"next_cursor":"MQ"}`,
+		},
+		{
+			name:   "problem",
+			method: "POST",
+			path:   "/v2/notifications",
+			status: http.StatusBadRequest,
+			header: problemHeader,
+			body: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"Request body contains 2 validation errors",` +
+				`"code":"validation_failed","fields":["to","subject"],"errors":[{"code":"invalid_field","detail":"No valid recipient address found","fields":["to"]},` +
+				`{"code":"too_long","detail":"The subject must not be longer than 998 characters","fields":["subject"]}]}`,
+		},
+		{
+			name:    "unknown problem code",
+			method:  "POST",
+			path:    "/v2/notifications",
+			status:  http.StatusBadRequest,
+			header:  problemHeader,
+			body:    `{"type":"about:blank","title":"Bad Request","status":400,"code":"bad_request"}`,
+			wantErr: ErrSchemaMismatch,
+		},
+		{
+			name:    "undocumented content type",
+			method:  "POST",
+			path:    "/v2/notifications",
+			status:  http.StatusBadRequest,
+			header:  http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+			body:    "Bad Request\n",
+			wantErr: ErrUndocumented,
+		},
+		{
+			name:    "undocumented status",
+			method:  "GET",
+			path:    "/v2/notifications",
+			status:  http.StatusTeapot,
+			header:  jsonHeader,
+			wantErr: ErrUndocumented,
+		},
+		{
+			name:    "undocumented path",
+			method:  "GET",
+			path:    "/v3/notifications",
+			status:  http.StatusOK,
+			header:  jsonHeader,
+			wantErr: ErrUndocumented,
+		},
+		{
+			name:   "no content",
+			method: "DELETE",
+			path:   "/suppressions",
+			status: http.StatusNoContent,
+			header: http.Header{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateResponse(tt.method, tt.path, tt.status, tt.header, []byte(tt.body))
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+// checkRefs checks that all references of the schema and its subschemas resolve to the component schemas.
+func checkRefs(t *testing.T, doc *document, s *schema, at string) {
+	if s.Ref != "" {
+		assert.Contains(t, doc.Components.Schemas, strings.TrimPrefix(s.Ref, "#/components/schemas/"), at)
+	}
+
+	for name, prop := range s.Properties {
+		checkRefs(t, doc, prop, at+"."+name)
+	}
+
+	if s.Items != nil {
+		checkRefs(t, doc, s.Items, at+"[]")
+	}
+
+	for _, option := range s.OneOf {
+		checkRefs(t, doc, option, at)
+	}
+}
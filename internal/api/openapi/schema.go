@@ -0,0 +1,176 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// validate checks that the decoded JSON value matches the schema, at is the location of the value in the body.
+// The numbers must be decoded as json.Number.
+func (d *document) validate(s *schema, v any, at string) error {
+	s, err := d.resolve(s, at)
+	if err != nil {
+		return err
+	}
+
+	if v == nil {
+		if s.Nullable {
+			return nil
+		}
+
+		return mismatch(at, "null is not allowed")
+	}
+
+	if len(s.OneOf) > 0 {
+		return d.validateOneOf(s, v, at)
+	}
+
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(v) }) {
+		return mismatch(at, "%v is not one of %v", v, s.Enum)
+	}
+
+	switch s.Type {
+	case "object":
+		return d.validateObject(s, v, at)
+
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			return mismatch(at, "%T is not an array", v)
+		}
+
+		if s.Items == nil {
+			return nil
+		}
+
+		for i, item := range items {
+			if err = d.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return mismatch(at, "%T is not a string", v)
+		}
+
+		if s.Format == "date-time" {
+			if _, err = time.Parse(time.RFC3339, str); err != nil {
+				return mismatch(at, "%q is not an RFC 3339 timestamp", str)
+			}
+		}
+
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return mismatch(at, "%T is not an integer", v)
+		}
+
+		if _, err = n.Int64(); err != nil {
+			return mismatch(at, "%s is not an integer", n)
+		}
+
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return mismatch(at, "%T is not a number", v)
+		}
+
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return mismatch(at, "%T is not a boolean", v)
+		}
+	}
+
+	return nil
+}
+
+// validateObject checks the required and documented properties of the object,
+// the undocumented properties are checked with additionalProperties.
+func (d *document) validateObject(s *schema, v any, at string) error {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return mismatch(at, "%T is not an object", v)
+	}
+
+	for _, name := range s.Required {
+		if _, ok = obj[name]; !ok {
+			return mismatch(at, "required property %q is missing", name)
+		}
+	}
+
+	var additional *schema
+
+	switch string(s.AdditionalProperties) {
+	case "", "true":
+
+	case "false":
+		for name := range obj {
+			if _, ok = s.Properties[name]; !ok {
+				return mismatch(at, "property %q is not documented", name)
+			}
+		}
+
+	default:
+		additional = &schema{}
+		if err := json.Unmarshal(s.AdditionalProperties, additional); err != nil {
+			return mismatch(at, "invalid additionalProperties: %v", err)
+		}
+	}
+
+	for name, value := range obj {
+		prop := s.Properties[name]
+		if prop == nil {
+			prop = additional
+		}
+
+		if prop == nil {
+			continue
+		}
+
+		if err := d.validate(prop, value, at+"."+name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateOneOf checks that the value matches exactly one of the schemas.
+func (d *document) validateOneOf(s *schema, v any, at string) error {
+	matched := 0
+
+	for _, option := range s.OneOf {
+		if d.validate(option, v, at) == nil {
+			matched++
+		}
+	}
+
+	if matched != 1 {
+		return mismatch(at, "value matches %d schemas of oneOf instead of one", matched)
+	}
+
+	return nil
+}
+
+// resolve returns the component schema the schema refers to, or the schema itself if it is not a reference.
+func (d *document) resolve(s *schema, at string) (*schema, error) {
+	if s.Ref == "" {
+		return s, nil
+	}
+
+	resolved := d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	if resolved == nil {
+		return nil, mismatch(at, "unresolved reference %q", s.Ref)
+	}
+
+	return resolved, nil
+}
+
+// mismatch returns ErrSchemaMismatch with the location and the description of the mismatch.
+func mismatch(at, format string, args ...any) error {
+	return fmt.Errorf("%w: %s: %s", ErrSchemaMismatch, at, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,66 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+var (
+	// ErrUndocumented indicates that the path, method, status code or content type of the response
+	// is not described in the document.
+	ErrUndocumented = errors.New("response is not documented")
+
+	// ErrSchemaMismatch indicates that the response body does not match the schema of the document.
+	ErrSchemaMismatch = errors.New("response does not match the schema")
+)
+
+// document is the part of the OpenAPI 3 document used to validate responses.
+type document struct {
+	Paths      map[string]*pathItem `json:"paths"`
+	Components struct {
+		Schemas   map[string]*schema   `json:"schemas"`
+		Responses map[string]*response `json:"responses"`
+	} `json:"components"`
+}
+
+// pathItem is the set of operations of a path.
+type pathItem struct {
+	Get    *operation `json:"get"`
+	Post   *operation `json:"post"`
+	Put    *operation `json:"put"`
+	Patch  *operation `json:"patch"`
+	Delete *operation `json:"delete"`
+}
+
+// operation is the description of the responses of a single method of a path.
+type operation struct {
+	Responses map[string]*response `json:"responses"`
+}
+
+// response is the description of the response with a status code, or a reference to a shared response.
+// The response without content has no body or its body is not described.
+type response struct {
+	Ref     string                `json:"$ref"`
+	Content map[string]*mediaType `json:"content"`
+}
+
+// mediaType is the schema of the response body of a content type.
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+// schema is the subset of the OpenAPI 3.0 schema object supported by the validator.
+// The objects with additionalProperties set to false reject undocumented properties,
+// otherwise additionalProperties is the schema of the undocumented properties.
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Nullable             bool               `json:"nullable"`
+	Enum                 []any              `json:"enum"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	OneOf                []*schema          `json:"oneOf"`
+}
@@ -0,0 +1,67 @@
+package problem
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// New returns the Problem of the request with the status, code, human-readable detail and the offending fields.
+// The title is the text of the status code, and the instance is the path of the request.
+func New(r *http.Request, status int, code, detail string, fields ...string) *Problem {
+	return &Problem{
+		Type:      DefaultType,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		Fields:    fields,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+}
+
+// Write writes the Problem of the request as application/problem+json, see New.
+// Like http.Error, it drops the Content-Length header and disables content type sniffing.
+func Write(w http.ResponseWriter, r *http.Request, status int, code, detail string, fields ...string) {
+	write(w, New(r, status, code, detail, fields...))
+}
+
+// WriteErrors writes the validation errors of the request as a single Problem, see Write.
+// A single error is written with its own code and detail, several errors are written with CodeValidationFailed.
+// In both cases Fields lists the offending fields of all errors, and Errors lists the errors themselves.
+func WriteErrors(w http.ResponseWriter, r *http.Request, status int, errs []Error) {
+	p := New(r, status, CodeValidationFailed, fmt.Sprintf("Request body contains %d validation errors", len(errs)))
+
+	if len(errs) == 1 {
+		p.Code = errs[0].Code
+		p.Detail = errs[0].Detail
+	}
+
+	for _, e := range errs {
+		for _, field := range e.Fields {
+			if !slices.Contains(p.Fields, field) {
+				p.Fields = append(p.Fields, field)
+			}
+		}
+	}
+
+	p.Errors = errs
+
+	write(w, p)
+}
+
+// write writes the Problem to the HTTP client.
+func write(w http.ResponseWriter, p *Problem) {
+	h := w.Header()
+
+	h.Del("Content-Length")
+	h.Set("Content-Type", ContentType)
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+
+	_ = json.NewEncoder(w).Encode(p)
+}
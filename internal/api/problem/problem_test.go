@@ -0,0 +1,116 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		code   string
+		detail string
+		fields []string
+		want   string
+	}{
+		{
+			name:   "with fields",
+			status: http.StatusBadRequest,
+			code:   CodeMissingFields,
+			detail: "Not all fields in the request body are filled in",
+			fields: []string{"subject", "message"},
+			want: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"Not all fields in the request body are filled in",` +
+				`"instance":"/send-notification","code":"missing_fields","fields":["subject","message"],"request_id":"test-1"}`,
+		},
+		{
+			name:   "without fields",
+			status: http.StatusInternalServerError,
+			code:   CodeInternal,
+			detail: "Internal Server Error",
+			want: `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"Internal Server Error",` +
+				`"instance":"/send-notification","code":"internal_error","request_id":"test-1"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/send-notification", nil)
+			r.Header.Set(middleware.RequestIDHeader, "test-1")
+
+			router := chi.NewRouter()
+			router.Use(middleware.RequestID)
+			router.Post("/send-notification", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "100")
+				Write(w, r, tt.status, tt.code, tt.detail, tt.fields...)
+			})
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+			assert.Empty(t, w.Header().Get("Content-Length"))
+			assert.JSONEq(t, tt.want, w.Body.String())
+
+			var got Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			assert.Equal(t, tt.fields, got.Fields)
+		})
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		errs       []Error
+		wantCode   string
+		wantDetail string
+		wantFields []string
+	}{
+		{
+			name:       "single error",
+			errs:       []Error{{Code: CodeMissingFields, Detail: "Not all fields in the request body are filled in", Fields: []string{"subject", "message"}}},
+			wantCode:   CodeMissingFields,
+			wantDetail: "Not all fields in the request body are filled in",
+			wantFields: []string{"subject", "message"},
+		},
+		{
+			name: "several errors",
+			errs: []Error{
+				{Code: CodeMissingFields, Detail: "Not all fields in the request body are filled in", Fields: []string{"message"}},
+				{Code: CodeTooLong, Detail: "The subject must not be longer than 10 characters", Fields: []string{"subject"}},
+				{Code: CodeInvalidField, Detail: "The subject must not contain line breaks", Fields: []string{"subject"}},
+			},
+			wantCode:   CodeValidationFailed,
+			wantDetail: "Request body contains 3 validation errors",
+			wantFields: []string{"message", "subject"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/send-notification", nil)
+
+			WriteErrors(w, r, http.StatusBadRequest, tt.errs)
+
+			var got Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.wantCode, got.Code)
+			assert.Equal(t, tt.wantDetail, got.Detail)
+			assert.Equal(t, tt.wantFields, got.Fields)
+			assert.Equal(t, tt.errs, got.Errors)
+		})
+	}
+}
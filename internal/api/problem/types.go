@@ -0,0 +1,113 @@
+package problem
+
+// ContentType is the media type of the error responses, see RFC 7807 section 3.
+const ContentType = "application/problem+json"
+
+// DefaultType is the problem type used for all errors, the kind of the error is identified by Code,
+// see RFC 7807 section 4.2.
+const DefaultType = "about:blank"
+
+// Codes are the stable machine-readable identifiers of the errors, they never change once published.
+const (
+	// CodeUnsupportedMediaType indicates that the request body is not application/json.
+	CodeUnsupportedMediaType = "unsupported_media_type"
+
+	// CodeEmptyBody indicates that the request body is empty.
+	CodeEmptyBody = "empty_body"
+
+	// CodeMalformedJSON indicates that the request body is not a valid JSON document.
+	CodeMalformedJSON = "malformed_json"
+
+	// CodeInvalidType indicates that a field of the request body has the wrong JSON type.
+	CodeInvalidType = "invalid_type"
+
+	// CodeMissingFields indicates that required fields of the request body are empty.
+	CodeMissingFields = "missing_fields"
+
+	// CodeInvalidField indicates that a field of the request body has an invalid value.
+	CodeInvalidField = "invalid_field"
+
+	// CodeTooLong indicates that a field of the request body exceeds the length limit.
+	CodeTooLong = "too_long"
+
+	// CodeValidationFailed indicates that the request body has several validation errors, they are listed in Errors.
+	CodeValidationFailed = "validation_failed"
+
+	// CodeSenderNotAllowed indicates that the sender is not one of the allowed identities.
+	CodeSenderNotAllowed = "sender_not_allowed"
+
+	// CodeTimeNotInFuture indicates that the sending or expiry time is not in the future.
+	CodeTimeNotInFuture = "time_not_in_future"
+
+	// CodeInvalidQuery indicates that the query or path parameters are invalid.
+	CodeInvalidQuery = "invalid_query"
+
+	// CodeInvalidToken indicates that the unsubscribe or tracking token is invalid.
+	CodeInvalidToken = "invalid_token"
+
+	// CodeInvalidReport indicates that the bounce is not a valid delivery status notification.
+	CodeInvalidReport = "invalid_report"
+
+	// CodeBodyTooLarge indicates that the request body exceeds the size limit.
+	CodeBodyTooLarge = "body_too_large"
+
+	// CodeUnauthorized indicates that the API key is missing, invalid, revoked or expired.
+	CodeUnauthorized = "unauthorized"
+
+	// CodeForbidden indicates that the API key does not grant the scope required by the request.
+	CodeForbidden = "forbidden"
+
+	// CodeNotFound indicates that the requested resource does not exist.
+	CodeNotFound = "not_found"
+
+	// CodeNotCancelable indicates that the notification is not a pending scheduled notification.
+	CodeNotCancelable = "not_cancelable"
+
+	// CodeRecipientSuppressed indicates that the recipient is on the suppression list.
+	CodeRecipientSuppressed = "recipient_suppressed"
+
+	// CodeRecipientRejected indicates that the mail server rejected the recipient permanently.
+	CodeRecipientRejected = "recipient_rejected"
+
+	// CodeSMTPUnavailable indicates that the SMTP circuit breaker is open.
+	CodeSMTPUnavailable = "smtp_unavailable"
+
+	// CodeInvalidIdempotencyKey indicates that the Idempotency-Key header is too long.
+	CodeInvalidIdempotencyKey = "invalid_idempotency_key"
+
+	// CodeIdempotencyInProgress indicates that the request with the same idempotency key is still in progress.
+	CodeIdempotencyInProgress = "idempotency_in_progress"
+
+	// CodeRequestCanceled indicates that the client canceled the request.
+	CodeRequestCanceled = "request_canceled"
+
+	// CodeTimeout indicates that the request was not processed in time.
+	CodeTimeout = "timeout"
+
+	// CodeInternal indicates an unexpected server error.
+	CodeInternal = "internal_error"
+)
+
+// Problem is the error response in the application/problem+json format, see RFC 7807.
+// Code is the stable identifier of the error, Fields lists the offending fields of the request body
+// or query parameters, RequestID is the ID assigned to the request by the request ID middleware.
+// Errors lists every validation error of the request body, see WriteErrors.
+type Problem struct {
+	Type      string   `json:"type"`
+	Title     string   `json:"title"`
+	Status    int      `json:"status"`
+	Detail    string   `json:"detail,omitempty"`
+	Instance  string   `json:"instance,omitempty"`
+	Code      string   `json:"code"`
+	Fields    []string `json:"fields,omitempty"`
+	RequestID string   `json:"request_id,omitempty"`
+	Errors    []Error  `json:"errors,omitempty"`
+}
+
+// Error is a single validation error of the request body with its code, human-readable detail
+// and the offending fields.
+type Error struct {
+	Code   string   `json:"code"`
+	Detail string   `json:"detail"`
+	Fields []string `json:"fields,omitempty"`
+}
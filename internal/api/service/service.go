@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"notification/internal/SMTPClient"
+	"notification/internal/api"
+	"notification/internal/auth"
+	"notification/internal/events"
+	"notification/internal/storage/postgresClient"
+	"notification/internal/storage/redisClient"
+	"notification/internal/unsubscribe"
+	"notification/internal/webhook"
+)
+
+// New creates and returns a new Service instance, the notifier and the bus are optional.
+func New(sender SMTPClient.EmailSender, redisClient redisClient.RedisClient, postgresClient postgresClient.PostgresClient,
+	notifier webhook.Notifier, bus *events.Bus, logger *zap.Logger) *Service {
+	return &Service{
+		sender:         sender,
+		redisClient:    redisClient,
+		postgresClient: postgresClient,
+		notifier:       notifier,
+		bus:            bus,
+		logger:         logger,
+	}
+}
+
+// Send saves the email to PostgreSQL and sends it immediately, the ID of the saved email is set to email.Id.
+// The authenticated client of the context is stored with the email, see setClient.
+// It returns the resulting status of the email: StatusSuppressed or StatusSkipped if the email must not be sent
+// to the recipient, otherwise StatusSent, or StatusFailed together with the sending error.
+// If the email cannot be saved, the status is empty and email.Id is not set.
+// While the SMTP circuit breaker is open, the email is not saved and the status is StatusFailed
+// together with *SMTPClient.CircuitOpenError, so that the client can retry it later.
+func (s *Service) Send(ctx context.Context, email *SMTPClient.EmailMessage) (string, error) {
+	recipientStatus, err := s.recipientStatus(ctx, email)
+	if err != nil {
+		return "", fmt.Errorf("Send: cannot check recipient: %w", err)
+	}
+
+	if recipientStatus == "" {
+		if err = s.sender.CircuitOpen(); err != nil {
+			return api.StatusFailed, err
+		}
+	}
+
+	setClient(ctx, email)
+
+	id, err := s.postgresClient.SaveEmail(ctx, email)
+	if err != nil {
+		return "", fmt.Errorf("Send: cannot save email: %w", err)
+	}
+
+	email.Id = id
+
+	if recipientStatus != "" {
+		s.setStatus(ctx, email, recipientStatus, nil)
+		return recipientStatus, nil
+	}
+
+	attempts, err := s.sender.SendEmail(ctx, *email)
+
+	status := api.StatusSent
+	if err != nil {
+		status = api.StatusFailed
+	}
+
+	s.setStatus(ctx, email, status, attempts)
+
+	return status, err
+}
+
+// Schedule saves the email to PostgreSQL and adds it to Redis to be sent by the worker at email.Time,
+// the ID of the saved email is set to email.Id, and the authenticated client of the context is stored with the email.
+// If the email cannot be added to Redis, it gets the failed status.
+func (s *Service) Schedule(ctx context.Context, email *SMTPClient.EmailMessage) error {
+	setClient(ctx, email)
+
+	id, err := s.postgresClient.SaveEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("Schedule: cannot save email: %w", err)
+	}
+
+	email.Id = id
+
+	if err = s.redisClient.AddDelayedEmail(ctx, email); err != nil {
+		s.setStatus(ctx, email, api.StatusFailed, nil)
+		return fmt.Errorf("Schedule: cannot add email to redis: %w", err)
+	}
+
+	return nil
+}
+
+// setClient sets the name, the API key ID and the tenant of the authenticated client of the context to the email.
+// The email is left unchanged if the context has no client.
+func setClient(ctx context.Context, email *SMTPClient.EmailMessage) {
+	if identity, ok := auth.FromContext(ctx); ok {
+		email.Client = identity.Client
+		email.APIKeyID = identity.KeyID
+		email.Tenant = identity.Tenant
+	}
+}
+
+// Tenant returns the tenant of the authenticated client of the context,
+// it is empty if the context has no client or the client has no tenant.
+func Tenant(ctx context.Context) string {
+	if identity, ok := auth.FromContext(ctx); ok {
+		return identity.Tenant
+	}
+
+	return ""
+}
+
+// Visible reports whether the client of the context can see the notification:
+// the clients with a tenant only see the notifications of their tenant, the other clients see all notifications.
+func Visible(ctx context.Context, notification *api.Notification) bool {
+	tenant := Tenant(ctx)
+
+	return tenant == "" || tenant == notification.Tenant
+}
+
+// Get returns the notification with the specified ID.
+// Returns ErrNotFound if there is no such notification or it is not visible to the client of the context.
+func (s *Service) Get(ctx context.Context, id int) (*api.Notification, error) {
+	notifications, err := s.postgresClient.FetchById(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !Visible(ctx, notifications[0]) {
+		return nil, ErrNotFound
+	}
+
+	return notifications[0], nil
+}
+
+// List returns a single page of notifications selected by the filter and the ID of the first notification
+// of the next page, the ID is 0 on the last page. The clients with a tenant only get the notifications of their tenant.
+func (s *Service) List(ctx context.Context, filter *api.ListFilter) ([]*api.Notification, int, error) {
+	filter.Tenant = Tenant(ctx)
+
+	return s.postgresClient.FetchPage(ctx, filter)
+}
+
+// EncodeCursor returns the opaque cursor of the page starting after the notification with the ID,
+// the ID is returned by List.
+func EncodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+// DecodeCursor returns the ID of the notification the page starts after, see EncodeCursor.
+func DecodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.Atoi(string(raw))
+	if err != nil {
+		return 0, err
+	}
+
+	if id <= 0 {
+		return 0, fmt.Errorf("DecodeCursor: invalid id %d", id)
+	}
+
+	return id, nil
+}
+
+// Cancel sets the canceled status of the pending scheduled notification and returns the canceled notification,
+// the worker drops canceled notifications instead of sending them.
+// Returns ErrNotFound if there is no such notification or it is not visible to the client of the context,
+// and ErrNotCancelable if it is sent immediately or is not pending anymore.
+func (s *Service) Cancel(ctx context.Context, id int) (*api.Notification, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+
+	err := s.postgresClient.CancelEmail(ctx, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotCancelable
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	notification, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Notify(ctx, &SMTPClient.EmailMessage{
+		Id:          notification.ID,
+		To:          notification.To,
+		MessageID:   notification.MessageID,
+		CallbackURL: notification.CallbackURL,
+		Tenant:      notification.Tenant,
+	}, api.StatusCanceled)
+
+	return notification, nil
+}
+
+// Notify publishes the new status of the email to the event bus and sends the delivery status webhook,
+// if webhooks are enabled and the email has a callback URL or the default one is configured.
+func (s *Service) Notify(ctx context.Context, email *SMTPClient.EmailMessage, status string) {
+	event := api.StatusEvent{
+		ID:        email.Id,
+		Status:    status,
+		To:        email.To,
+		MessageID: email.MessageID,
+		Tenant:    email.Tenant,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	s.bus.Publish(ctx, event)
+
+	if s.notifier != nil {
+		s.notifier.Notify(ctx, email.CallbackURL, event)
+	}
+}
+
+// setStatus saves the status of the email with the history of attempts to PostgreSQL
+// and notifies the caller about the new status.
+// The error is only logged, because the email is already sent or rejected at this point.
+// The request context is detached, so that the result is saved even after a timeout.
+func (s *Service) setStatus(ctx context.Context, email *SMTPClient.EmailMessage, status string, attempts []SMTPClient.Attempt) {
+	if err := s.postgresClient.UpdateStatus(context.WithoutCancel(ctx), email.Id, status, attempts); err != nil {
+		s.logger.Error("setStatus: Cannot update email status", zap.Error(err), zap.Int("id", email.Id))
+	}
+
+	s.Notify(ctx, email, status)
+}
+
+// recipientStatus returns the status for the email that must not be sent:
+// StatusSuppressed if the recipient is on the suppression list,
+// StatusSkipped if the recipient has opted out of the category of the email (transactional emails are never skipped).
+// It returns an empty status if the email can be sent.
+func (s *Service) recipientStatus(ctx context.Context, email *SMTPClient.EmailMessage) (string, error) {
+	recipient := unsubscribe.Recipient(email.To)
+
+	suppressed, err := s.postgresClient.IsSuppressed(ctx, recipient)
+	if err != nil {
+		return "", err
+	}
+
+	if suppressed {
+		return api.StatusSuppressed, nil
+	}
+
+	if !unsubscribe.Applies(email.Category) {
+		return "", nil
+	}
+
+	unsubscribed, err := s.postgresClient.IsUnsubscribed(ctx, recipient, email.Category)
+	if err != nil || !unsubscribed {
+		return "", err
+	}
+
+	return api.StatusSkipped, nil
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"notification/internal/SMTPClient"
+	"notification/internal/api"
+	"notification/internal/auth"
+	"notification/internal/storage/postgresClient"
+	"notification/internal/storage/redisClient"
+	"notification/internal/webhook"
+)
+
+func TestSend(t *testing.T) {
+	sendErr := errors.New("smtp error")
+	circuitErr := &SMTPClient.CircuitOpenError{RetryAfter: 20 * time.Second}
+
+	tests := []struct {
+		name         string
+		category     string
+		suppressed   bool
+		unsubscribed bool
+		sendErr      error
+		circuitErr   error
+		wantStatus   string
+		wantErr      error
+		wantSent     bool
+	}{
+		{
+			name:       "sent",
+			wantStatus: api.StatusSent,
+			wantSent:   true,
+		},
+		{
+			name:       "failed",
+			sendErr:    sendErr,
+			wantStatus: api.StatusFailed,
+			wantErr:    sendErr,
+			wantSent:   true,
+		},
+		{
+			name:       "circuit open",
+			circuitErr: circuitErr,
+			wantStatus: api.StatusFailed,
+			wantErr:    circuitErr,
+		},
+		{
+			name:       "suppressed",
+			suppressed: true,
+			wantStatus: api.StatusSuppressed,
+		},
+		{
+			name:         "unsubscribed",
+			category:     "newsletter",
+			unsubscribed: true,
+			wantStatus:   api.StatusSkipped,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			email := &SMTPClient.EmailMessage{
+				Type:        api.KeyForInstantSending,
+				To:          "test@example.com",
+				Subject:     "Subject",
+				Message:     "Message",
+				Category:    tt.category,
+				CallbackURL: "https://example.com/hook",
+			}
+
+			mockPostgres := &postgresClient.MockPostgresService{}
+			mockSender := &SMTPClient.MockEmailSender{}
+			mockNotifier := &webhook.MockNotifier{}
+
+			mockPostgres.On("IsSuppressed", mock.Anything, "test@example.com").Return(tt.suppressed, nil)
+			mockPostgres.On("IsUnsubscribed", mock.Anything, "test@example.com", "newsletter").Return(tt.unsubscribed, nil).Maybe()
+			mockSender.On("CircuitOpen").Return(tt.circuitErr).Maybe()
+
+			wantID := 0
+			if tt.circuitErr == nil {
+				wantID = 7
+
+				mockPostgres.On("SaveEmail", mock.Anything, email).Return(7, nil)
+				mockPostgres.On("UpdateStatus", mock.Anything, 7, tt.wantStatus, mock.Anything).Return(nil)
+				mockSender.On("SendEmail", mock.Anything, mock.Anything).Return(nil, tt.sendErr)
+				mockNotifier.On("Notify", mock.Anything, "https://example.com/hook", mock.MatchedBy(func(event webhook.Event) bool {
+					return event.ID == 7 && event.Status == tt.wantStatus
+				}))
+			}
+
+			s := New(mockSender, nil, mockPostgres, mockNotifier, nil, zap.NewNop())
+
+			status, err := s.Send(ctx, email)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantStatus, status)
+			assert.Equal(t, wantID, email.Id)
+
+			if tt.wantSent {
+				mockSender.AssertCalled(t, "SendEmail", mock.Anything, mock.Anything)
+			} else {
+				mockSender.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything)
+			}
+
+			mockPostgres.AssertExpectations(t)
+			mockNotifier.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	redisErr := errors.New("redis error")
+
+	tests := []struct {
+		name       string
+		identity   *auth.Identity
+		redisErr   error
+		wantClient string
+		wantTenant string
+	}{
+		{name: "scheduled"},
+		{
+			name:       "scheduled by client",
+			identity:   &auth.Identity{Client: "user-1", Scopes: []string{api.ScopeSchedule}, Tenant: "acme"},
+			wantClient: "user-1",
+			wantTenant: "acme",
+		},
+		{name: "redis error", redisErr: redisErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.identity != nil {
+				ctx = auth.NewContext(ctx, tt.identity)
+			}
+
+			email := &SMTPClient.EmailMessage{Type: api.KeyForDelayedSending, To: "test@example.com"}
+
+			mockPostgres := &postgresClient.MockPostgresService{}
+			mockRedis := &redisClient.MockRedisClient{}
+
+			mockPostgres.On("SaveEmail", mock.Anything, email).Return(7, nil)
+			mockPostgres.On("UpdateStatus", mock.Anything, 7, api.StatusFailed, mock.Anything).Return(nil)
+			mockRedis.On("AddDelayedEmail", mock.Anything, email).Return(tt.redisErr)
+
+			s := New(nil, mockRedis, mockPostgres, nil, nil, zap.NewNop())
+
+			err := s.Schedule(ctx, email)
+
+			assert.ErrorIs(t, err, tt.redisErr)
+			assert.Equal(t, 7, email.Id)
+			assert.Equal(t, tt.wantClient, email.Client)
+			assert.Equal(t, tt.wantTenant, email.Tenant)
+
+			if tt.redisErr == nil {
+				mockPostgres.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				mockPostgres.AssertCalled(t, "UpdateStatus", mock.Anything, 7, api.StatusFailed, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	ctx := context.Background()
+
+	mockPostgres := &postgresClient.MockPostgresService{}
+	mockPostgres.On("FetchById", mock.Anything, 7).Return([]*api.Notification{{ID: 7}}, nil)
+	mockPostgres.On("FetchById", mock.Anything, 8).Return([]*api.Notification(nil), pgx.ErrNoRows)
+	mockPostgres.On("FetchById", mock.Anything, 9).Return([]*api.Notification{{ID: 9, Tenant: "acme"}}, nil)
+
+	s := New(nil, nil, mockPostgres, nil, nil, zap.NewNop())
+
+	got, err := s.Get(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, &api.Notification{ID: 7}, got)
+
+	_, err = s.Get(ctx, 8)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// The clients with a tenant do not see the notifications of the other tenants.
+	got, err = s.Get(auth.NewContext(ctx, &auth.Identity{Client: "user-1", Tenant: "acme"}), 9)
+	require.NoError(t, err)
+	assert.Equal(t, 9, got.ID)
+
+	_, err = s.Get(auth.NewContext(ctx, &auth.Identity{Client: "user-1", Tenant: "other"}), 9)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = s.Get(auth.NewContext(ctx, &auth.Identity{Client: "user-1", Tenant: "other"}), 7)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestList(t *testing.T) {
+	mockPostgres := &postgresClient.MockPostgresService{}
+	mockPostgres.On("FetchPage", mock.Anything, &api.ListFilter{Limit: 10}).Return([]*api.Notification{{ID: 1}}, 0, nil)
+	mockPostgres.On("FetchPage", mock.Anything, &api.ListFilter{Limit: 10, Tenant: "acme"}).Return([]*api.Notification{{ID: 2}}, 0, nil)
+
+	s := New(nil, nil, mockPostgres, nil, nil, zap.NewNop())
+
+	got, _, err := s.List(context.Background(), &api.ListFilter{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, got[0].ID)
+
+	ctx := auth.NewContext(context.Background(), &auth.Identity{Client: "user-1", Tenant: "acme"})
+
+	got, _, err = s.List(ctx, &api.ListFilter{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 2, got[0].ID)
+}
+
+func TestCancel(t *testing.T) {
+	canceled := &api.Notification{
+		ID:          7,
+		Type:        api.KeyForDelayedSending,
+		Status:      api.StatusCanceled,
+		To:          "test@example.com",
+		CallbackURL: "https://example.com/hook",
+	}
+
+	tests := []struct {
+		name      string
+		cancelErr error
+		fetched   []*api.Notification
+		fetchErr  error
+		want      *api.Notification
+		wantErr   error
+	}{
+		{
+			name:    "canceled",
+			fetched: []*api.Notification{canceled},
+			want:    canceled,
+		},
+		{
+			name:      "not pending",
+			cancelErr: pgx.ErrNoRows,
+			fetched:   []*api.Notification{{ID: 7, Status: api.StatusSent}},
+			wantErr:   ErrNotCancelable,
+		},
+		{
+			name:     "not found",
+			fetchErr: pgx.ErrNoRows,
+			wantErr:  ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			mockPostgres := &postgresClient.MockPostgresService{}
+			mockNotifier := &webhook.MockNotifier{}
+
+			mockPostgres.On("CancelEmail", mock.Anything, 7).Return(tt.cancelErr)
+			mockPostgres.On("FetchById", mock.Anything, 7).Return(tt.fetched, tt.fetchErr)
+			mockNotifier.On("Notify", mock.Anything, "https://example.com/hook", mock.MatchedBy(func(event webhook.Event) bool {
+				return event.ID == 7 && event.Status == api.StatusCanceled && event.To == "test@example.com"
+			}))
+
+			s := New(nil, nil, mockPostgres, mockNotifier, nil, zap.NewNop())
+
+			got, err := s.Cancel(ctx, 7)
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+
+			if tt.wantErr == nil {
+				mockNotifier.AssertExpectations(t)
+			} else {
+				mockNotifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
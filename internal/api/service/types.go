@@ -0,0 +1,34 @@
+package service
+
+import (
+	"errors"
+
+	"go.uber.org/zap"
+
+	"notification/internal/SMTPClient"
+	"notification/internal/events"
+	"notification/internal/storage/postgresClient"
+	"notification/internal/storage/redisClient"
+	"notification/internal/webhook"
+)
+
+var (
+	// ErrNotFound indicates that there is no notification with the specified ID.
+	ErrNotFound = errors.New("notification not found")
+
+	// ErrNotCancelable indicates that the notification is not a pending scheduled notification and cannot be canceled.
+	ErrNotCancelable = errors.New("notification cannot be canceled")
+)
+
+// Service implements the operations on notifications shared by the v1 and v2 HTTP handlers and the gRPC server:
+// sending, scheduling, fetching, listing and canceling notifications.
+// Every change of the notification status is saved to PostgreSQL, published to the event bus
+// and sent with the delivery status webhook. The notifier and the bus are optional.
+type Service struct {
+	sender         SMTPClient.EmailSender
+	redisClient    redisClient.RedisClient
+	postgresClient postgresClient.PostgresClient
+	notifier       webhook.Notifier
+	bus            *events.Bus
+	logger         *zap.Logger
+}
@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+
+	"notification/internal/api"
+	"notification/internal/api/problem"
+	"notification/internal/monitoring"
+)
+
+// New creates and returns a new Authenticator instance that looks up the API keys in the store,
+// and verifies the JWTs with the JWKS of the identity provider if Config.JWKSURL is set.
+func New(config *Config, keys KeyStore, metrics monitoring.Monitoring, logger *zap.Logger) *Authenticator {
+	a := &Authenticator{
+		keys:    keys,
+		jwks:    newJWKS(config, logger),
+		jwt:     newJWTConfig(config),
+		metrics: metrics,
+		logger:  logger,
+	}
+
+	if config.AdminKey != "" {
+		a.adminHash = HashKey(config.AdminKey)
+	}
+
+	return a
+}
+
+// GenerateKey returns a new random API key, the beginning of the key that is stored in plain text,
+// and the hash of the key, see HashKey.
+func GenerateKey() (key, prefix, hash string, err error) {
+	raw := make([]byte, keyBytes)
+
+	if _, err = rand.Read(raw); err != nil {
+		return "", "", "", fmt.Errorf("GenerateKey: %w", err)
+	}
+
+	key = keyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	return key, key[:displayPrefixLength], HashKey(key), nil
+}
+
+// HashKey returns the hex encoded SHA-256 hash of the API key, only the hashes of the keys are stored.
+// A fast hash is enough, because the generated keys are random and too long to be guessed.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate returns the identity of the client the API key is issued to.
+// Returns ErrNoCredentials for the empty key, ErrInvalidKey if there is no such key,
+// and ErrKeyRevoked or ErrKeyExpired if the key is not valid anymore.
+// If the JWTs are accepted, the key in the form of the JWT is verified as the token, see verifyToken.
+func (a *Authenticator) Authenticate(ctx context.Context, key string) (*Identity, error) {
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	hash := HashKey(key)
+
+	if a.adminHash != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(a.adminHash)) == 1 {
+		return &Identity{Client: AdminClient, Scopes: []string{api.ScopeAdmin}}, nil
+	}
+
+	if a.jwks != nil && isJWT(key) {
+		return a.verifyToken(ctx, key)
+	}
+
+	apiKey, err := a.keys.FetchAPIKeyByHash(ctx, hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrInvalidKey
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Authenticate: cannot fetch API key: %w", err)
+	}
+
+	if apiKey.RevokedAt != nil {
+		return nil, ErrKeyRevoked
+	}
+
+	if apiKey.ExpiresAt != nil && !apiKey.ExpiresAt.After(time.Now()) {
+		return nil, ErrKeyExpired
+	}
+
+	return &Identity{Client: apiKey.Name, KeyID: apiKey.ID, Scopes: apiKey.Scopes}, nil
+}
+
+// Middleware returns a middleware that authenticates the requests with the API key or the JWT, see KeyFromRequest,
+// and passes only the requests of the clients granted any of the scopes, the identity of the client
+// is added to the request context. The request without a valid key is rejected with 401 Unauthorized,
+// and the request of the client without the scopes is rejected with 403 Forbidden.
+func (a *Authenticator) Middleware(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			identity, err := a.Authenticate(r.Context(), KeyFromRequest(r))
+			if err != nil {
+				a.writeError(w, r, err)
+				return
+			}
+
+			if !identity.HasAnyScope(scopes...) {
+				problem.Write(w, r, http.StatusForbidden, problem.CodeForbidden,
+					"The API key does not grant any of the scopes: "+strings.Join(scopes, ", "))
+				a.metrics.IncError("Authorize")
+				a.logger.Warn("Middleware: scope not granted", zap.String("client", identity.Client),
+					zap.Int("key_id", identity.KeyID), zap.Strings("scopes", scopes))
+
+				return
+			}
+
+			a.metrics.Observe("Authenticate", start)
+			a.metrics.IncSuccess("Authenticate")
+
+			next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), identity)))
+		})
+	}
+}
+
+// writeError writes the HTTP response for the error returned by Authenticate.
+func (a *Authenticator) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case IsUnauthorized(err):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="notification"`)
+		problem.Write(w, r, http.StatusUnauthorized, problem.CodeUnauthorized, Detail(err))
+		a.metrics.IncError("Authenticate")
+		a.logger.Warn("Middleware: authentication failed", zap.Error(err))
+
+	case errors.Is(err, context.Canceled):
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeRequestCanceled, http.StatusText(400))
+		a.metrics.IncCanceled("Authenticate")
+		a.logger.Info("Middleware: Context canceled", zap.Error(err))
+
+	case errors.Is(err, context.DeadlineExceeded):
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeTimeout, http.StatusText(500))
+		a.metrics.IncTimeout("Authenticate")
+		a.logger.Info("Middleware: Context deadline exceeded", zap.Error(err))
+
+	default:
+		problem.Write(w, r, http.StatusInternalServerError, problem.CodeInternal, http.StatusText(500))
+		a.metrics.IncError("Authenticate")
+		a.logger.Error("Middleware: cannot authenticate request", zap.Error(err))
+	}
+}
+
+// IsUnauthorized reports whether the error returned by Authenticate means that the credentials are missing or not valid,
+// rather than that they could not be checked.
+func IsUnauthorized(err error) bool {
+	return errors.Is(err, ErrNoCredentials) || errors.Is(err, ErrInvalidKey) ||
+		errors.Is(err, ErrKeyRevoked) || errors.Is(err, ErrKeyExpired) ||
+		errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrTokenExpired)
+}
+
+// Detail returns the human-readable detail of the authentication error returned by Authenticate.
+func Detail(err error) string {
+	switch {
+	case errors.Is(err, ErrNoCredentials):
+		return "The API key is missing"
+
+	case errors.Is(err, ErrKeyRevoked):
+		return "The API key has been revoked"
+
+	case errors.Is(err, ErrKeyExpired):
+		return "The API key has expired"
+
+	case errors.Is(err, ErrInvalidToken):
+		return "The bearer token is invalid"
+
+	case errors.Is(err, ErrTokenExpired):
+		return "The bearer token has expired"
+
+	default:
+		return "The API key is invalid"
+	}
+}
+
+// KeyFromRequest returns the API key or the JWT of the request, sent as the bearer token of the Authorization header,
+// or the API key in the X-API-Key header.
+func KeyFromRequest(r *http.Request) string {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+
+	return r.Header.Get(HeaderAPIKey)
+}
+
+// NewContext returns the copy of the context with the identity of the client.
+func NewContext(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// FromContext returns the identity of the client added to the context by the middleware.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(*Identity)
+	return identity, ok
+}
+
+// HasScope reports whether the client is granted the scope, the admin scope grants all scopes.
+func (i *Identity) HasScope(scope string) bool {
+	return slices.Contains(i.Scopes, scope) || slices.Contains(i.Scopes, api.ScopeAdmin)
+}
+
+// Principal returns the unique key of the client: the ID of the stored API key, the tenant and the subject of the JWT,
+// or AdminClient for the admin key from the configuration. The state kept per client, like the idempotency keys,
+// is scoped by the principal, because different API keys may have the same name and different tenants the same subjects.
+func (i *Identity) Principal() string {
+	switch {
+	case i.KeyID != 0:
+		return "key:" + strconv.Itoa(i.KeyID)
+	case i.Token:
+		return "jwt:" + url.QueryEscape(i.Tenant) + ":" + url.QueryEscape(i.Client)
+	default:
+		return AdminClient
+	}
+}
+
+// HasAnyScope reports whether the client is granted any of the scopes, see HasScope.
+func (i *Identity) HasAnyScope(scopes ...string) bool {
+	return slices.ContainsFunc(scopes, i.HasScope)
+}
@@ -0,0 +1,288 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"notification/internal/api"
+	"notification/internal/api/problem"
+	"notification/internal/monitoring"
+)
+
+const (
+	testAdminKey = "admin-secret"
+	testKey      = "ntf_client-secret"
+)
+
+func TestGenerateKey(t *testing.T) {
+	key, prefix, hash, err := GenerateKey()
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(key, keyPrefix))
+	assert.Len(t, key, len(keyPrefix)+43)
+	assert.Equal(t, key[:displayPrefixLength], prefix)
+	assert.Equal(t, HashKey(key), hash)
+	assert.Len(t, hash, 64)
+
+	other, _, _, err := GenerateKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+}
+
+func TestAuthenticate(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name         string
+		key          string
+		setupMock    func(m *MockKeyStore)
+		wantIdentity *Identity
+		wantErr      error
+	}{
+		{
+			name:    "no key",
+			wantErr: ErrNoCredentials,
+		},
+		{
+			name:         "admin key",
+			key:          testAdminKey,
+			wantIdentity: &Identity{Client: AdminClient, Scopes: []string{api.ScopeAdmin}},
+		},
+		{
+			name: "stored key",
+			key:  testKey,
+			setupMock: func(m *MockKeyStore) {
+				m.On("FetchAPIKeyByHash", mock.Anything, HashKey(testKey)).
+					Return(&api.APIKey{ID: 3, Name: "billing", Scopes: []string{api.ScopeSend}, ExpiresAt: &future}, nil)
+			},
+			wantIdentity: &Identity{Client: "billing", KeyID: 3, Scopes: []string{api.ScopeSend}},
+		},
+		{
+			name: "unknown key",
+			key:  testKey,
+			setupMock: func(m *MockKeyStore) {
+				m.On("FetchAPIKeyByHash", mock.Anything, HashKey(testKey)).Return(nil, pgx.ErrNoRows)
+			},
+			wantErr: ErrInvalidKey,
+		},
+		{
+			name: "revoked key",
+			key:  testKey,
+			setupMock: func(m *MockKeyStore) {
+				m.On("FetchAPIKeyByHash", mock.Anything, HashKey(testKey)).
+					Return(&api.APIKey{ID: 3, Name: "billing", Scopes: []string{api.ScopeSend}, RevokedAt: &past}, nil)
+			},
+			wantErr: ErrKeyRevoked,
+		},
+		{
+			name: "expired key",
+			key:  testKey,
+			setupMock: func(m *MockKeyStore) {
+				m.On("FetchAPIKeyByHash", mock.Anything, HashKey(testKey)).
+					Return(&api.APIKey{ID: 3, Name: "billing", Scopes: []string{api.ScopeSend}, ExpiresAt: &past}, nil)
+			},
+			wantErr: ErrKeyExpired,
+		},
+		{
+			name: "storage error",
+			key:  testKey,
+			setupMock: func(m *MockKeyStore) {
+				m.On("FetchAPIKeyByHash", mock.Anything, HashKey(testKey)).Return(nil, context.DeadlineExceeded)
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := &MockKeyStore{}
+			if tt.setupMock != nil {
+				tt.setupMock(keys)
+			}
+
+			authenticator := New(&Config{AdminKey: testAdminKey}, keys, monitoring.NewNop(), zap.NewNop())
+
+			identity, err := authenticator.Authenticate(context.Background(), tt.key)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, identity)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantIdentity, identity)
+			keys.AssertExpectations(t)
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     map[string]string
+		scopes     []string
+		setupMock  func(m *MockKeyStore)
+		wantStatus int
+		wantCode   string
+		wantClient string
+	}{
+		{
+			name:       "bearer token",
+			header:     map[string]string{"Authorization": "Bearer " + testKey},
+			scopes:     []string{api.ScopeRead},
+			wantStatus: http.StatusOK,
+			wantClient: "reports",
+		},
+		{
+			name:       "API key header",
+			header:     map[string]string{HeaderAPIKey: testKey},
+			scopes:     []string{api.ScopeSend, api.ScopeRead},
+			wantStatus: http.StatusOK,
+			wantClient: "reports",
+		},
+		{
+			name:       "admin key grants every scope",
+			header:     map[string]string{HeaderAPIKey: testAdminKey},
+			scopes:     []string{api.ScopeSchedule},
+			wantStatus: http.StatusOK,
+			wantClient: AdminClient,
+		},
+		{
+			name:       "missing key",
+			header:     map[string]string{},
+			scopes:     []string{api.ScopeRead},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   problem.CodeUnauthorized,
+		},
+		{
+			name:       "basic authorization",
+			header:     map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+			scopes:     []string{api.ScopeRead},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   problem.CodeUnauthorized,
+		},
+		{
+			name:   "unknown key",
+			header: map[string]string{HeaderAPIKey: "ntf_unknown"},
+			scopes: []string{api.ScopeRead},
+			setupMock: func(m *MockKeyStore) {
+				m.On("FetchAPIKeyByHash", mock.Anything, HashKey("ntf_unknown")).Return(nil, pgx.ErrNoRows)
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   problem.CodeUnauthorized,
+		},
+		{
+			name:       "scope not granted",
+			header:     map[string]string{HeaderAPIKey: testKey},
+			scopes:     []string{api.ScopeSend},
+			wantStatus: http.StatusForbidden,
+			wantCode:   problem.CodeForbidden,
+		},
+		{
+			name:   "storage error",
+			header: map[string]string{HeaderAPIKey: "ntf_other"},
+			scopes: []string{api.ScopeRead},
+			setupMock: func(m *MockKeyStore) {
+				m.On("FetchAPIKeyByHash", mock.Anything, HashKey("ntf_other")).Return(nil, errors.New("connection refused"))
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   problem.CodeInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := &MockKeyStore{}
+			keys.On("FetchAPIKeyByHash", mock.Anything, HashKey(testKey)).
+				Return(&api.APIKey{ID: 5, Name: "reports", Scopes: []string{api.ScopeRead}}, nil).Maybe()
+			if tt.setupMock != nil {
+				tt.setupMock(keys)
+			}
+
+			authenticator := New(&Config{AdminKey: testAdminKey}, keys, monitoring.NewNop(), zap.NewNop())
+
+			var gotClient string
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				identity, ok := FromContext(r.Context())
+				require.True(t, ok)
+				gotClient = identity.Client
+			})
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/v2/notifications", nil)
+			for name, value := range tt.header {
+				r.Header.Set(name, value)
+			}
+
+			authenticator.Middleware(tt.scopes...)(next).ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantClient, gotClient)
+
+			if tt.wantCode != "" {
+				var got problem.Problem
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+				assert.Equal(t, tt.wantCode, got.Code)
+			}
+
+			if tt.wantStatus == http.StatusUnauthorized {
+				assert.Equal(t, `Bearer realm="notification"`, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity *Identity
+		scope    string
+		want     bool
+	}{
+		{name: "granted", identity: &Identity{Scopes: []string{api.ScopeSend, api.ScopeRead}}, scope: api.ScopeRead, want: true},
+		{name: "not granted", identity: &Identity{Scopes: []string{api.ScopeSend}}, scope: api.ScopeSchedule, want: false},
+		{name: "admin", identity: &Identity{Scopes: []string{api.ScopeAdmin}}, scope: api.ScopeSchedule, want: true},
+		{name: "no scopes", identity: &Identity{}, scope: api.ScopeRead, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.identity.HasScope(tt.scope))
+		})
+	}
+}
+
+func TestPrincipal(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity *Identity
+		want     string
+	}{
+		{name: "admin key", identity: &Identity{Client: AdminClient}, want: AdminClient},
+		{name: "stored key", identity: &Identity{Client: "billing", KeyID: 3}, want: "key:3"},
+		{name: "stored key named admin", identity: &Identity{Client: AdminClient, KeyID: 4}, want: "key:4"},
+		{name: "jwt", identity: &Identity{Client: "user-1", Tenant: "acme", Token: true}, want: "jwt:acme:user-1"},
+		{name: "jwt subject named admin", identity: &Identity{Client: AdminClient, Token: true}, want: "jwt::admin"},
+		{name: "jwt with separator", identity: &Identity{Client: "b", Tenant: "a:x", Token: true}, want: "jwt:a%3Ax:b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.identity.Principal())
+		})
+	}
+}
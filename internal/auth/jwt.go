@@ -0,0 +1,434 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"notification/internal/api"
+)
+
+// newJWKS creates the JWKS cache from the configuration, applies the default values to the zero fields.
+// It returns nil if the JWKS URL is not set.
+func newJWKS(config *Config, logger *zap.Logger) *jwks {
+	if config.JWKSURL == "" {
+		return nil
+	}
+
+	refreshInterval := config.JWKSRefreshInterval
+	if refreshInterval == 0 {
+		refreshInterval = DefaultJWKSRefreshInterval
+	}
+
+	timeout := config.JWKSTimeout
+	if timeout == 0 {
+		timeout = DefaultJWKSTimeout
+	}
+
+	return &jwks{
+		url:             config.JWKSURL,
+		refreshInterval: refreshInterval,
+		client:          &http.Client{Timeout: timeout},
+		logger:          logger,
+	}
+}
+
+// newJWTConfig returns the configuration of the JWT claims checks, applies the default claim names.
+func newJWTConfig(config *Config) jwtConfig {
+	c := jwtConfig{
+		issuer:      config.JWTIssuer,
+		audience:    config.JWTAudience,
+		scopeClaim:  config.JWTScopeClaim,
+		scopePrefix: config.JWTScopePrefix,
+		tenantClaim: config.JWTTenantClaim,
+	}
+
+	if c.scopeClaim == "" {
+		c.scopeClaim = DefaultScopeClaim
+	}
+
+	if c.tenantClaim == "" {
+		c.tenantClaim = DefaultTenantClaim
+	}
+
+	return c
+}
+
+// isJWT reports whether the bearer token has the form of the JWT in the compact serialization,
+// the API keys never contain dots.
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// verifyToken verifies the signature and the claims of the JWT and returns the identity of its subject.
+// Returns ErrInvalidToken if the token is not accepted, ErrTokenExpired if it has expired,
+// and ErrJWKSUnavailable if the keys of the identity provider cannot be fetched.
+func (a *Authenticator) verifyToken(ctx context.Context, token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %w", ErrInvalidToken, err)
+	}
+
+	if header.Alg != AlgRS256 && header.Alg != AlgES256 {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %w", ErrInvalidToken, err)
+	}
+
+	key, err := a.jwks.key(ctx, header.Kid)
+	if errors.Is(err, errUnknownKey) {
+		return nil, fmt.Errorf("%w: %w %q", ErrInvalidToken, err, header.Kid)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = key.verify(header.Alg, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	var claims registeredClaims
+	if err = decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: claims: %w", ErrInvalidToken, err)
+	}
+
+	if err = a.jwt.check(&claims, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var custom map[string]json.RawMessage
+	if err = decodeSegment(parts[1], &custom); err != nil {
+		return nil, fmt.Errorf("%w: claims: %w", ErrInvalidToken, err)
+	}
+
+	var tenant string
+	if raw, ok := custom[a.jwt.tenantClaim]; ok {
+		if err = json.Unmarshal(raw, &tenant); err != nil {
+			return nil, fmt.Errorf("%w: %s claim is not a string", ErrInvalidToken, a.jwt.tenantClaim)
+		}
+	}
+
+	return &Identity{Client: claims.Subject, Scopes: a.jwt.scopes(custom[a.jwt.scopeClaim]), Tenant: tenant, Token: true}, nil
+}
+
+// check checks the registered claims of the JWT: the token must have the subject and the expiration time,
+// must not be used before its nbf time, and must be issued by the issuer to the audience, if they are configured.
+func (c *jwtConfig) check(claims *registeredClaims, now time.Time) error {
+	if claims.Subject == "" {
+		return fmt.Errorf("%w: no sub claim", ErrInvalidToken)
+	}
+
+	if claims.ExpiresAt == nil {
+		return fmt.Errorf("%w: no exp claim", ErrInvalidToken)
+	}
+
+	if now.Add(-clockSkew).After(unixTime(*claims.ExpiresAt)) {
+		return ErrTokenExpired
+	}
+
+	if claims.NotBefore != nil && now.Add(clockSkew).Before(unixTime(*claims.NotBefore)) {
+		return fmt.Errorf("%w: token is not valid yet", ErrInvalidToken)
+	}
+
+	if c.issuer != "" && claims.Issuer != c.issuer {
+		return fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, claims.Issuer)
+	}
+
+	if c.audience != "" && !slices.Contains(claims.Audience, c.audience) {
+		return fmt.Errorf("%w: token is not issued to %q", ErrInvalidToken, c.audience)
+	}
+
+	return nil
+}
+
+// scopes maps the scope claim of the JWT to the scopes: the values with the scope prefix are taken
+// with the prefix removed, and the values that are not scopes are ignored.
+// The claim is a space-separated string (RFC 8693 section 4.2) or an array of strings.
+func (c *jwtConfig) scopes(raw json.RawMessage) []string {
+	var values []string
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		values = strings.Fields(s)
+	} else if err = json.Unmarshal(raw, &values); err != nil {
+		return nil
+	}
+
+	var scopes []string
+
+	for _, value := range values {
+		scope, ok := strings.CutPrefix(value, c.scopePrefix)
+		if ok && api.ValidScope(scope) && !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes
+}
+
+// key returns the key of the JWKS with the key ID. The JWKS is fetched again once the refresh interval has passed,
+// and when the key ID is unknown, because the identity provider has rotated its keys, see refresh.
+// The concurrent requests share a single fetch, a request stops waiting for it when its context is done.
+// Returns errUnknownKey if there is no key with the key ID, and ErrJWKSUnavailable if no keys have been fetched yet.
+func (j *jwks) key(ctx context.Context, kid string) (*verificationKey, error) {
+	j.mu.Lock()
+	key, ok := j.keys[kid]
+	fresh := time.Since(j.fetchedAt) < j.refreshInterval
+	j.mu.Unlock()
+
+	if ok && fresh {
+		return key, nil
+	}
+
+	var err error
+
+	select {
+	case res := <-j.group.DoChan(j.url, func() (any, error) { return nil, j.refresh() }):
+		err = res.Err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: %w", ErrJWKSUnavailable, ctx.Err())
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.keys == nil {
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrJWKSUnavailable, err)
+		}
+
+		return nil, ErrJWKSUnavailable
+	}
+
+	if key, ok = j.keys[kid]; ok {
+		return key, nil
+	}
+
+	return nil, errUnknownKey
+}
+
+// refresh fetches the JWKS, but not more often than once per minRefetchInterval.
+// The fetch is not bound to the context of any request, so that a canceled request cannot fail it for the others,
+// it is limited by the timeout of the HTTP client. If the JWKS cannot be fetched, the cached keys are used,
+// the error is only returned if there are no cached keys.
+func (j *jwks) refresh() error {
+	j.mu.Lock()
+
+	now := time.Now()
+	if now.Sub(j.checkedAt) < minRefetchInterval {
+		j.mu.Unlock()
+		return nil
+	}
+
+	j.checkedAt = now
+	cached := j.keys != nil
+
+	j.mu.Unlock()
+
+	err := j.fetch(context.Background())
+	if err != nil && cached {
+		j.logger.Warn("refresh: cannot refresh JWKS, using cached keys", zap.Error(err))
+		return nil
+	}
+
+	return err
+}
+
+// fetch requests the JWKS and replaces the cached keys with its signature keys.
+// The keys of unsupported types and curves are skipped, the JWKS without any supported key is an error.
+func (j *jwks) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return fmt.Errorf("fetch: cannot create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch: unexpected status %d", resp.StatusCode)
+	}
+
+	var set jwkSet
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxJWKSSize)).Decode(&set); err != nil {
+		return fmt.Errorf("fetch: cannot decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]*verificationKey, len(set.Keys))
+
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.verificationKey()
+		if err != nil {
+			j.logger.Warn("fetch: skipping JWKS key", zap.String("kid", k.Kid), zap.Error(err))
+			continue
+		}
+
+		keys[k.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return errors.New("fetch: JWKS has no supported signature keys")
+	}
+
+	j.mu.Lock()
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	j.mu.Unlock()
+
+	j.logger.Info("fetch: JWKS fetched", zap.Int("keys", len(keys)))
+
+	return nil
+}
+
+// verificationKey returns the public key of the JWK and the algorithm of the key:
+// RS256 for the RSA keys and ES256 for the EC keys on the P-256 curve.
+func (k *jwk) verificationKey() (*verificationKey, error) {
+	switch k.Kty {
+	case "RSA":
+		if k.Alg != "" && k.Alg != AlgRS256 {
+			return nil, fmt.Errorf("verificationKey: unsupported algorithm %q", k.Alg)
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("verificationKey: modulus: %w", err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("verificationKey: invalid exponent")
+		}
+
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("verificationKey: RSA key shorter than %d bits", minRSAKeyBits)
+		}
+
+		return &verificationKey{alg: AlgRS256, key: key}, nil
+
+	case "EC":
+		if k.Alg != "" && k.Alg != AlgES256 {
+			return nil, fmt.Errorf("verificationKey: unsupported algorithm %q", k.Alg)
+		}
+
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("verificationKey: unsupported curve %q", k.Crv)
+		}
+
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("verificationKey: invalid coordinates")
+		}
+
+		// crypto/ecdh rejects the points that are not on the curve.
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, fmt.Errorf("verificationKey: %w", err)
+		}
+
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+
+		return &verificationKey{alg: AlgES256, key: key}, nil
+
+	default:
+		return nil, fmt.Errorf("verificationKey: unsupported key type %q", k.Kty)
+	}
+}
+
+// verify verifies the signature of the signing input of the JWT, the algorithm must be the algorithm of the key.
+func (k *verificationKey) verify(alg, signingInput string, signature []byte) error {
+	if alg != k.alg {
+		return fmt.Errorf("verify: key is not for %s", alg)
+	}
+
+	digest := sha256.Sum256([]byte(signingInput))
+
+	switch key := k.key.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return fmt.Errorf("verify: %w", err)
+		}
+
+	case *ecdsa.PublicKey:
+		// The ES256 signature is the concatenation of r and s (RFC 7518 section 3.4).
+		if len(signature) != 64 {
+			return errors.New("verify: invalid signature length")
+		}
+
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+
+		if !ecdsa.Verify(key, digest[:], r, s) {
+			return errors.New("verify: invalid signature")
+		}
+
+	default:
+		return fmt.Errorf("verify: unsupported key type %T", key)
+	}
+
+	return nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, the single string is the audience of one.
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("audience: %w", err)
+	}
+
+	*a = list
+
+	return nil
+}
+
+// decodeSegment decodes the base64url encoded JSON segment of the JWT.
+func decodeSegment(segment string, v any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, v)
+}
+
+// unixTime returns the time of the NumericDate claim, the number of seconds since the Unix epoch.
+func unixTime(seconds float64) time.Time {
+	return time.UnixMilli(int64(seconds * 1000))
+}
@@ -0,0 +1,535 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"notification/internal/api"
+	"notification/internal/monitoring"
+)
+
+// testJWKSServer is a local identity provider serving the JWKS of its current keys.
+type testJWKSServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	keys    []jwk
+	status  int
+	fetches atomic.Int32
+}
+
+// newTestJWKSServer starts the JWKS server with the keys.
+func newTestJWKSServer(t *testing.T, keys ...jwk) *testJWKSServer {
+	s := &testJWKSServer{keys: keys, status: http.StatusOK}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.status != http.StatusOK {
+			w.WriteHeader(s.status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(jwkSet{Keys: s.keys})
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// set replaces the keys and the response status of the server.
+func (s *testJWKSServer) set(status int, keys ...jwk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status = status
+	s.keys = keys
+}
+
+// rsaJWK returns the JWK of the RSA public key.
+func rsaJWK(kid string, key *rsa.PublicKey) jwk {
+	return jwk{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+// ecJWK returns the JWK of the P-256 public key.
+func ecJWK(kid string, key *ecdsa.PrivateKey) jwk {
+	public, _ := key.PublicKey.ECDH()
+	raw := public.Bytes()
+
+	return jwk{
+		Kty: "EC",
+		Kid: kid,
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(raw[1:33]),
+		Y:   base64.RawURLEncoding.EncodeToString(raw[33:]),
+	}
+}
+
+// signToken returns the JWT with the claims signed with the private key.
+func signToken(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		require.NoError(t, err)
+
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// testClaims returns the valid claims of the token issued to the notification service.
+func testClaims(overrides map[string]any) map[string]any {
+	claims := map[string]any{
+		"iss":       "https://id.example.com",
+		"sub":       "user-1",
+		"aud":       "notification",
+		"exp":       time.Now().Add(time.Hour).Unix(),
+		"scope":     "openid notification:send notification:read",
+		"tenant_id": "acme",
+	}
+
+	for name, value := range overrides {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+
+		claims[name] = value
+	}
+
+	return claims
+}
+
+// newJWTAuthenticator returns the Authenticator accepting the JWTs with the keys of the JWKS server.
+func newJWTAuthenticator(server *testJWKSServer) *Authenticator {
+	return New(&Config{
+		AdminKey:       testAdminKey,
+		JWKSURL:        server.URL,
+		JWTIssuer:      "https://id.example.com",
+		JWTAudience:    "notification",
+		JWTScopePrefix: "notification:",
+	}, &MockKeyStore{}, monitoring.NewNop(), zap.NewNop())
+}
+
+func TestVerifyToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	otherRSAKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	server := newTestJWKSServer(t, rsaJWK("rsa-1", &rsaKey.PublicKey), ecJWK("ec-1", ecKey), jwk{Kty: "oct", Kid: "hmac-1"})
+
+	tests := []struct {
+		name         string
+		token        func() string
+		wantIdentity *Identity
+		wantErr      error
+	}{
+		{
+			name: "RS256",
+			token: func() string {
+				return signToken(t, AlgRS256, "rsa-1", rsaKey, testClaims(nil))
+			},
+			wantIdentity: &Identity{Client: "user-1", Scopes: []string{api.ScopeSend, api.ScopeRead}, Tenant: "acme", Token: true},
+		},
+		{
+			name: "ES256 with scope array and audience array",
+			token: func() string {
+				return signToken(t, AlgES256, "ec-1", ecKey, testClaims(map[string]any{
+					"scope": []string{"notification:schedule", "notification:schedule", "notification:unknown", "admin"},
+					"aud":   []string{"other", "notification"},
+				}))
+			},
+			wantIdentity: &Identity{Client: "user-1", Scopes: []string{api.ScopeSchedule}, Tenant: "acme", Token: true},
+		},
+		{
+			name: "without tenant and scopes",
+			token: func() string {
+				return signToken(t, AlgRS256, "rsa-1", rsaKey, testClaims(map[string]any{"scope": nil, "tenant_id": nil}))
+			},
+			wantIdentity: &Identity{Client: "user-1", Token: true},
+		},
+		{
+			name: "expired",
+			token: func() string {
+				return signToken(t, AlgRS256, "rsa-1", rsaKey, testClaims(map[string]any{"exp": time.Now().Add(-2 * clockSkew).Unix()}))
+			},
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name: "expired within clock skew",
+			token: func() string {
+				return signToken(t, AlgRS256, "rsa-1", rsaKey, testClaims(map[string]any{"exp": time.Now().Add(-clockSkew / 2).Unix()}))
+			},
+			wantIdentity: &Identity{Client: "user-1", Scopes: []string{api.ScopeSend, api.ScopeRead}, Tenant: "acme", Token: true},
+		},
+		{
+			name: "not valid yet",
+			token: func() string {
+				return signToken(t, AlgRS256, "rsa-1", rsaKey, testClaims(map[string]any{"nbf": time.Now().Add(2 * clockSkew).Unix()}))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "no expiration time",
+			token: func() string {
+				return signToken(t, AlgRS256, "rsa-1", rsaKey, testClaims(map[string]any{"exp": nil}))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "no subject",
+			token: func() string {
+				return signToken(t, AlgRS256, "rsa-1", rsaKey, testClaims(map[string]any{"sub": nil}))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "wrong issuer",
+			token: func() string {
+				return signToken(t, AlgRS256, "rsa-1", rsaKey, testClaims(map[string]any{"iss": "https://evil.example.com"}))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "wrong audience",
+			token: func() string {
+				return signToken(t, AlgRS256, "rsa-1", rsaKey, testClaims(map[string]any{"aud": "billing"}))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "tenant is not a string",
+			token: func() string {
+				return signToken(t, AlgRS256, "rsa-1", rsaKey, testClaims(map[string]any{"tenant_id": 42}))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "signed with other key",
+			token: func() string {
+				return signToken(t, AlgRS256, "rsa-1", otherRSAKey, testClaims(nil))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "tampered claims",
+			token: func() string {
+				token := signToken(t, AlgRS256, "rsa-1", rsaKey, testClaims(nil))
+				forged := signToken(t, AlgRS256, "rsa-1", otherRSAKey, testClaims(map[string]any{"scope": "notification:admin"}))
+
+				return forged[:strings.LastIndex(forged, ".")] + token[strings.LastIndex(token, "."):]
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "algorithm of other key",
+			token: func() string {
+				return signToken(t, AlgES256, "rsa-1", ecKey, testClaims(nil))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "unsigned",
+			token: func() string {
+				header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"rsa-1"}`))
+				payload, _ := json.Marshal(testClaims(nil))
+
+				return header + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "HMAC",
+			token: func() string {
+				header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","kid":"hmac-1"}`))
+				payload, _ := json.Marshal(testClaims(nil))
+
+				return header + "." + base64.RawURLEncoding.EncodeToString(payload) + ".c2lnbmF0dXJl"
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "unknown key ID",
+			token: func() string {
+				return signToken(t, AlgRS256, "rsa-2", rsaKey, testClaims(nil))
+			},
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "malformed",
+			token: func() string {
+				return "not.a.token"
+			},
+			wantErr: ErrInvalidToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newJWTAuthenticator(server)
+
+			identity, err := authenticator.Authenticate(t.Context(), tt.token())
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.True(t, IsUnauthorized(err))
+				assert.Nil(t, identity)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantIdentity, identity)
+		})
+	}
+}
+
+func TestJWKSRotation(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	server := newTestJWKSServer(t, rsaJWK("key-1", &oldKey.PublicKey))
+	authenticator := newJWTAuthenticator(server)
+
+	oldToken := signToken(t, AlgRS256, "key-1", oldKey, testClaims(nil))
+	newToken := signToken(t, AlgES256, "key-2", newKey, testClaims(nil))
+
+	// The JWKS is fetched once and cached.
+	for range 3 {
+		_, err = authenticator.Authenticate(t.Context(), oldToken)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, int32(1), server.fetches.Load())
+
+	// The identity provider rotates the keys, the unknown key ID triggers a new fetch,
+	// but not before minRefetchInterval has passed since the last one.
+	server.set(http.StatusOK, ecJWK("key-2", newKey))
+
+	_, err = authenticator.Authenticate(t.Context(), newToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Equal(t, int32(1), server.fetches.Load())
+
+	authenticator.jwks.checkedAt = time.Now().Add(-minRefetchInterval)
+
+	identity, err := authenticator.Authenticate(t.Context(), newToken)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", identity.Client)
+	assert.Equal(t, int32(2), server.fetches.Load())
+
+	// The retired key is not accepted anymore.
+	_, err = authenticator.Authenticate(t.Context(), oldToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.Equal(t, int32(2), server.fetches.Load())
+
+	// The cached keys are used if the JWKS cannot be refreshed.
+	server.set(http.StatusInternalServerError)
+	authenticator.jwks.fetchedAt = time.Now().Add(-DefaultJWKSRefreshInterval)
+	authenticator.jwks.checkedAt = time.Now().Add(-minRefetchInterval)
+
+	_, err = authenticator.Authenticate(t.Context(), newToken)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), server.fetches.Load())
+}
+
+func TestJWKSUnavailable(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	server := newTestJWKSServer(t)
+	server.set(http.StatusServiceUnavailable)
+
+	authenticator := newJWTAuthenticator(server)
+	token := signToken(t, AlgES256, "key-1", key, testClaims(nil))
+
+	_, err = authenticator.Authenticate(t.Context(), token)
+	assert.ErrorIs(t, err, ErrJWKSUnavailable)
+	assert.False(t, IsUnauthorized(err))
+
+	// The failed fetch is not repeated for each request.
+	_, err = authenticator.Authenticate(t.Context(), token)
+	assert.ErrorIs(t, err, ErrJWKSUnavailable)
+	assert.Equal(t, int32(1), server.fetches.Load())
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/v2/notifications", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	authenticator.Middleware(api.ScopeRead)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request must not be passed")
+	})).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestJWKSCanceledRequest(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	server := newTestJWKSServer(t, ecJWK("key-1", key))
+	authenticator := newJWTAuthenticator(server)
+	token := signToken(t, AlgES256, "key-1", key, testClaims(nil))
+
+	// The server holds the response until the first request is canceled.
+	server.mu.Lock()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	errs := make(chan error, 1)
+
+	go func() {
+		_, err := authenticator.Authenticate(ctx, token)
+		errs <- err
+	}()
+
+	require.Eventually(t, func() bool { return server.fetches.Load() == 1 }, time.Second, time.Millisecond)
+	cancel()
+
+	err = <-errs
+	assert.ErrorIs(t, err, ErrJWKSUnavailable)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	server.mu.Unlock()
+
+	// The fetch started by the canceled request completes and serves the next requests.
+	identity, err := authenticator.Authenticate(t.Context(), token)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", identity.Client)
+	assert.Equal(t, int32(1), server.fetches.Load())
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	server := newTestJWKSServer(t, ecJWK("key-1", key))
+	authenticator := newJWTAuthenticator(server)
+
+	tests := []struct {
+		name       string
+		scope      string
+		wantStatus int
+	}{
+		{name: "scope granted", scope: api.ScopeSend, wantStatus: http.StatusOK},
+		{name: "scope not granted", scope: api.ScopeAdmin, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *Identity
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("GET", "/v2/notifications", nil)
+			r.Header.Set("Authorization", "Bearer "+signToken(t, AlgES256, "key-1", key, testClaims(nil)))
+
+			authenticator.Middleware(tt.scope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = FromContext(r.Context())
+			})).ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+
+			if tt.wantStatus == http.StatusOK {
+				require.NotNil(t, got)
+				assert.Equal(t, "acme", got.Tenant)
+			}
+		})
+	}
+}
+
+func TestJWKVerificationKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	shortKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	offCurve := ecJWK("ec", ecKey)
+	offCurve.Y = offCurve.X
+
+	p384 := ecJWK("ec", ecKey)
+	p384.Crv = "P-384"
+
+	rsaWithAlg := rsaJWK("rsa", &rsaKey.PublicKey)
+	rsaWithAlg.Alg = "PS256"
+
+	tests := []struct {
+		name    string
+		key     jwk
+		wantAlg string
+	}{
+		{name: "RSA", key: rsaJWK("rsa", &rsaKey.PublicKey), wantAlg: AlgRS256},
+		{name: "EC", key: ecJWK("ec", ecKey), wantAlg: AlgES256},
+		{name: "short RSA key", key: rsaJWK("rsa", &shortKey.PublicKey)},
+		{name: "other RSA algorithm", key: rsaWithAlg},
+		{name: "point not on curve", key: offCurve},
+		{name: "other curve", key: p384},
+		{name: "symmetric key", key: jwk{Kty: "oct", Kid: "hmac"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := tt.key.verificationKey()
+
+			if tt.wantAlg == "" {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAlg, key.alg)
+		})
+	}
+}
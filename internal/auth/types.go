@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+
+	"notification/internal/api"
+	"notification/internal/monitoring"
+)
+
+// HeaderAPIKey is the header with the API key, the key can also be sent as the bearer token of the Authorization header.
+const HeaderAPIKey = "X-API-Key"
+
+// AdminClient is the client name of the requests authenticated with the admin key from the configuration.
+const AdminClient = "admin"
+
+const (
+	// keyPrefix prefixes the generated API keys, so that leaked keys are easy to recognize.
+	keyPrefix = "ntf_"
+
+	// keyBytes is the number of random bytes of the generated API keys.
+	keyBytes = 32
+
+	// displayPrefixLength is the length of the beginning of the key that is stored in plain text.
+	displayPrefixLength = 12
+)
+
+const (
+	// AlgRS256 is the RSASSA-PKCS1-v1_5 with SHA-256 signature algorithm of the JWTs.
+	AlgRS256 = "RS256"
+
+	// AlgES256 is the ECDSA P-256 with SHA-256 signature algorithm of the JWTs.
+	AlgES256 = "ES256"
+)
+
+const (
+	// DefaultJWKSRefreshInterval is how long the fetched JWKS is cached if Config.JWKSRefreshInterval is not set.
+	DefaultJWKSRefreshInterval = 10 * time.Minute
+
+	// DefaultJWKSTimeout is the timeout of the JWKS request if Config.JWKSTimeout is not set.
+	DefaultJWKSTimeout = 5 * time.Second
+
+	// DefaultScopeClaim is the claim with the scopes of the JWT if Config.JWTScopeClaim is not set.
+	DefaultScopeClaim = "scope"
+
+	// DefaultTenantClaim is the claim with the tenant ID of the JWT if Config.JWTTenantClaim is not set.
+	DefaultTenantClaim = "tenant_id"
+)
+
+const (
+	// minRefetchInterval is the shortest interval between the JWKS requests made for the unknown key IDs,
+	// so that the tokens with made-up key IDs cannot flood the identity provider.
+	minRefetchInterval = 30 * time.Second
+
+	// clockSkew is the allowed difference between the clocks of the service and the identity provider.
+	clockSkew = time.Minute
+
+	// minRSAKeyBits is the minimum size of the RSA keys of the JWKS.
+	minRSAKeyBits = 2048
+
+	// maxJWKSSize is the maximum size of the JWKS response.
+	maxJWKSSize = 1 << 20
+)
+
+var (
+	// ErrNoCredentials indicates that the request has no API key.
+	ErrNoCredentials = errors.New("Authenticate: no API key")
+
+	// ErrInvalidKey indicates that there is no API key with the hash of the key.
+	ErrInvalidKey = errors.New("Authenticate: invalid API key")
+
+	// ErrKeyRevoked indicates that the API key has been revoked.
+	ErrKeyRevoked = errors.New("Authenticate: API key revoked")
+
+	// ErrKeyExpired indicates that the API key has expired.
+	ErrKeyExpired = errors.New("Authenticate: API key expired")
+
+	// ErrInvalidToken indicates that the JWT is malformed, its signature is not valid or its claims are not accepted.
+	ErrInvalidToken = errors.New("Authenticate: invalid token")
+
+	// ErrTokenExpired indicates that the JWT has expired.
+	ErrTokenExpired = errors.New("Authenticate: token expired")
+
+	// ErrJWKSUnavailable indicates that the JWKS cannot be fetched and there are no cached keys.
+	ErrJWKSUnavailable = errors.New("key: JWKS unavailable")
+
+	// errUnknownKey indicates that the JWKS has no key with the key ID of the JWT.
+	errUnknownKey = errors.New("key: unknown key ID")
+)
+
+// Config defines the configuration of the API authentication.
+// AdminKey is the key with the admin scope that is not stored in PostgreSQL,
+// it is used to create the first API keys, if it is empty, only the stored keys are accepted.
+//
+// The JWTs issued by the identity provider are accepted as bearer tokens if JWKSURL is set,
+// they are verified with the keys of the JWKS fetched from the URL and cached for JWKSRefreshInterval.
+// JWTIssuer and JWTAudience, if set, must match the iss and aud claims. The scopes are taken from the JWTScopeClaim claim,
+// a space-separated string or an array, only the values with JWTScopePrefix are mapped to the scopes with the prefix removed.
+// The tenant ID is taken from the JWTTenantClaim claim. The default values are used for the zero fields.
+type Config struct {
+	AdminKey string `env:"AUTH_ADMIN_KEY"`
+
+	JWKSURL             string        `env:"AUTH_JWKS_URL"`
+	JWKSRefreshInterval time.Duration `env:"AUTH_JWKS_REFRESH_INTERVAL"`
+	JWKSTimeout         time.Duration `env:"AUTH_JWKS_TIMEOUT"`
+	JWTIssuer           string        `env:"AUTH_JWT_ISSUER"`
+	JWTAudience         string        `env:"AUTH_JWT_AUDIENCE"`
+	JWTScopeClaim       string        `env:"AUTH_JWT_SCOPE_CLAIM"`
+	JWTScopePrefix      string        `env:"AUTH_JWT_SCOPE_PREFIX"`
+	JWTTenantClaim      string        `env:"AUTH_JWT_TENANT_CLAIM"`
+}
+
+// KeyStore looks up the API keys by the SHA-256 hash of the key.
+// Returns pgx.ErrNoRows if there is no key with the hash.
+type KeyStore interface {
+	FetchAPIKeyByHash(ctx context.Context, hash string) (*api.APIKey, error)
+}
+
+// Identity is the authenticated client of the request: the name of the client, the ID of its API key,
+// the scopes granted by the key and the tenant of the client. KeyID is 0 for the admin key from the configuration
+// and for the JWTs, whose client is the subject of the token. Only the JWTs have the tenant, Token is set for them.
+// The client name is not unique, see Principal.
+type Identity struct {
+	Client string
+	KeyID  int
+	Scopes []string
+	Tenant string
+	Token  bool
+}
+
+// Authenticator checks the API keys of the requests against the hashes of the stored keys,
+// and the JWTs against the JWKS of the identity provider, if it is configured.
+type Authenticator struct {
+	keys      KeyStore
+	adminHash string
+	jwks      *jwks
+	jwt       jwtConfig
+	metrics   monitoring.Monitoring
+	logger    *zap.Logger
+}
+
+// jwtConfig is the configuration of the JWT claims checks, see Config.
+type jwtConfig struct {
+	issuer      string
+	audience    string
+	scopeClaim  string
+	scopePrefix string
+	tenantClaim string
+}
+
+// jwks fetches the JSON Web Key Set of the identity provider and caches its keys by the key ID.
+// fetchedAt is the time of the last successful fetch, checkedAt is the time of the last attempt.
+// The concurrent fetches are merged by the group, mu guards the keys and the times.
+type jwks struct {
+	url             string
+	refreshInterval time.Duration
+	client          *http.Client
+	logger          *zap.Logger
+
+	group singleflight.Group
+
+	mu        sync.Mutex
+	keys      map[string]*verificationKey
+	fetchedAt time.Time
+	checkedAt time.Time
+}
+
+// verificationKey is the public key of the JWKS and the algorithm of the signatures it verifies.
+type verificationKey struct {
+	alg string
+	key crypto.PublicKey
+}
+
+// jwkSet is the JSON Web Key Set (RFC 7517 section 5).
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// jwk is a single JSON Web Key (RFC 7517 section 4) with the parameters of the RSA and EC public keys (RFC 7518 section 6).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// tokenHeader is the JOSE header of the JWT.
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// registeredClaims are the registered claims of the JWT checked by the Authenticator (RFC 7519 section 4.1),
+// the times are the numbers of seconds since the Unix epoch.
+type registeredClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+}
+
+// audience is the aud claim, a single string or an array of strings.
+type audience []string
+
+// contextKey is the key of the Identity in the request context.
+type contextKey struct{}
+
+// MockKeyStore is a mock implementation of the KeyStore interface.
+type MockKeyStore struct {
+	mock.Mock
+}
+
+// FetchAPIKeyByHash is a mock implementation.
+func (mks *MockKeyStore) FetchAPIKeyByHash(ctx context.Context, hash string) (*api.APIKey, error) {
+	args := mks.Called(ctx, hash)
+	key, _ := args.Get(0).(*api.APIKey)
+	return key, args.Error(1)
+}
@@ -0,0 +1,120 @@
+package backoff
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// New creates and returns a new Policy with the specified base pause.
+// If jitter strategy and max pause are not set in the configuration, the default values are applied.
+func New(base time.Duration, config Config) Policy {
+	p := Policy{
+		Base:       base,
+		MaxPause:   config.MaxPause,
+		MaxElapsed: config.MaxElapsed,
+		Jitter:     config.Jitter,
+	}
+
+	if p.Jitter == "" {
+		p.Jitter = DefaultJitter
+	}
+
+	if p.MaxPause == 0 {
+		p.MaxPause = DefaultMaxPause
+	}
+
+	if p.MaxPause < p.Base {
+		p.MaxPause = p.Base
+	}
+
+	return p
+}
+
+// Pause calculates the delay before the retry attempt (starting from 1).
+// prev is the pause made before the previous attempt, it is used only by the decorrelated jitter.
+func (p Policy) Pause(attempt int, prev time.Duration) time.Duration {
+	if attempt < 1 || p.Base <= 0 {
+		return 0
+	}
+
+	switch p.Jitter {
+	case JitterFull:
+		return randomBetween(0, p.Ceiling(attempt))
+
+	case JitterEqual:
+		c := p.Ceiling(attempt)
+		return c/2 + randomBetween(0, c-c/2)
+
+	case JitterDecorrelated:
+		if prev < p.Base {
+			prev = p.Base
+		}
+
+		return randomBetween(p.Base, p.capped(3*float64(prev)))
+
+	default:
+		return p.Ceiling(attempt)
+	}
+}
+
+// Ceiling returns the longest pause the policy can choose before the retry attempt.
+func (p Policy) Ceiling(attempt int) time.Duration {
+	if attempt < 1 || p.Base <= 0 {
+		return 0
+	}
+
+	factor := 2.0
+	exp := float64(attempt - 1)
+
+	if p.Jitter == JitterDecorrelated {
+		factor = 3
+		exp = float64(attempt)
+	}
+
+	return p.capped(float64(p.Base) * math.Pow(factor, exp))
+}
+
+// Budget returns the longest total time the policy can pause during the specified number of retries,
+// limited by MaxElapsed.
+func (p Policy) Budget(retries int) time.Duration {
+	var budget time.Duration
+
+	for i := 1; i <= retries; i++ {
+		budget += p.Ceiling(i)
+
+		if p.MaxElapsed > 0 && budget >= p.MaxElapsed {
+			return p.MaxElapsed
+		}
+	}
+
+	return budget
+}
+
+// Exceeded reports whether making the pause would exceed MaxElapsed since the first attempt started at start.
+func (p Policy) Exceeded(start time.Time, pause time.Duration) bool {
+	return p.MaxElapsed > 0 && time.Since(start)+pause > p.MaxElapsed
+}
+
+// capped converts the pause to time.Duration, limiting it with MaxPause.
+// Float values are used to avoid overflow on large attempt numbers.
+func (p Policy) capped(pause float64) time.Duration {
+	if p.MaxPause > 0 && pause > float64(p.MaxPause) {
+		return p.MaxPause
+	}
+
+	if pause > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return time.Duration(pause)
+}
+
+// randomBetween returns a random duration in the range [low, high].
+func randomBetween(low, high time.Duration) time.Duration {
+	if high <= low {
+		return low
+	}
+
+	return low + rand.N(high-low+1)
+}
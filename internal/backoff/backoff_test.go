@@ -0,0 +1,237 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	p := New(3*time.Second, Config{})
+
+	assert.Equal(t, 3*time.Second, p.Base)
+	assert.Equal(t, DefaultJitter, p.Jitter)
+	assert.Equal(t, DefaultMaxPause, p.MaxPause)
+	assert.Equal(t, time.Duration(0), p.MaxElapsed)
+
+	p = New(2*time.Minute, Config{Jitter: JitterEqual, MaxPause: time.Minute, MaxElapsed: 5 * time.Minute})
+
+	assert.Equal(t, JitterEqual, p.Jitter)
+	assert.Equal(t, 2*time.Minute, p.MaxPause)
+	assert.Equal(t, 5*time.Minute, p.MaxElapsed)
+}
+
+func TestJitterSetValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Jitter
+		wantErr bool
+	}{
+		{name: "none", value: "none", want: JitterNone},
+		{name: "full", value: "full", want: JitterFull},
+		{name: "equal", value: "equal", want: JitterEqual},
+		{name: "decorrelated", value: "decorrelated", want: JitterDecorrelated},
+		{name: "empty", value: "", want: DefaultJitter},
+		{name: "unknown", value: "random", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var j Jitter
+
+			err := j.SetValue(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, j)
+		})
+	}
+}
+
+func TestPauseWithoutJitter(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     time.Duration
+		maxPause time.Duration
+		attempt  int
+		want     time.Duration
+	}{
+		{
+			name:    "first attempt",
+			base:    3 * time.Second,
+			attempt: 1,
+			want:    3 * time.Second,
+		},
+		{
+			name:    "third attempt",
+			base:    3 * time.Second,
+			attempt: 3,
+			want:    12 * time.Second,
+		},
+		{
+			name:    "sub-second base",
+			base:    300 * time.Millisecond,
+			attempt: 2,
+			want:    600 * time.Millisecond,
+		},
+		{
+			name:     "capped with max pause",
+			base:     3 * time.Second,
+			maxPause: 10 * time.Second,
+			attempt:  4,
+			want:     10 * time.Second,
+		},
+		{
+			name:    "huge attempt does not overflow",
+			base:    3 * time.Second,
+			attempt: 1000,
+			want:    DefaultMaxPause,
+		},
+		{
+			name:    "zero attempt",
+			base:    3 * time.Second,
+			attempt: 0,
+			want:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(tt.base, Config{Jitter: JitterNone, MaxPause: tt.maxPause})
+
+			assert.Equal(t, tt.want, p.Pause(tt.attempt, 0))
+		})
+	}
+}
+
+func TestPauseWithJitter(t *testing.T) {
+	const base = 100 * time.Millisecond
+	const maxPause = 2 * time.Second
+
+	tests := []struct {
+		name   string
+		jitter Jitter
+		min    func(attempt int, prev time.Duration) time.Duration
+		max    func(attempt int, prev time.Duration) time.Duration
+	}{
+		{
+			name:   "full",
+			jitter: JitterFull,
+			min:    func(int, time.Duration) time.Duration { return 0 },
+			max: func(attempt int, _ time.Duration) time.Duration {
+				return min(maxPause, base<<(attempt-1))
+			},
+		},
+		{
+			name:   "equal",
+			jitter: JitterEqual,
+			min: func(attempt int, _ time.Duration) time.Duration {
+				return min(maxPause, base<<(attempt-1)) / 2
+			},
+			max: func(attempt int, _ time.Duration) time.Duration {
+				return min(maxPause, base<<(attempt-1))
+			},
+		},
+		{
+			name:   "decorrelated",
+			jitter: JitterDecorrelated,
+			min:    func(int, time.Duration) time.Duration { return base },
+			max: func(_ int, prev time.Duration) time.Duration {
+				return min(maxPause, 3*max(base, prev))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(base, Config{Jitter: tt.jitter, MaxPause: maxPause})
+
+			for run := 0; run < 100; run++ {
+				var prev time.Duration
+
+				for attempt := 1; attempt <= 8; attempt++ {
+					pause := p.Pause(attempt, prev)
+
+					assert.GreaterOrEqual(t, pause, tt.min(attempt, prev))
+					assert.LessOrEqual(t, pause, tt.max(attempt, prev))
+					assert.LessOrEqual(t, pause, p.Ceiling(attempt))
+
+					prev = pause
+				}
+			}
+		})
+	}
+}
+
+func TestPauseIsRandomized(t *testing.T) {
+	p := New(time.Second, Config{Jitter: JitterFull})
+
+	seen := make(map[time.Duration]struct{})
+	for i := 0; i < 20; i++ {
+		seen[p.Pause(3, 0)] = struct{}{}
+	}
+
+	assert.Greater(t, len(seen), 1)
+}
+
+func TestBudget(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		retries int
+		want    time.Duration
+	}{
+		{
+			name:    "zero policy",
+			policy:  Policy{},
+			retries: 3,
+			want:    0,
+		},
+		{
+			name:    "sum of ceilings",
+			policy:  New(time.Second, Config{Jitter: JitterFull}),
+			retries: 3,
+			want:    7 * time.Second,
+		},
+		{
+			name:    "capped pauses",
+			policy:  New(time.Second, Config{Jitter: JitterEqual, MaxPause: 2 * time.Second}),
+			retries: 4,
+			want:    7 * time.Second,
+		},
+		{
+			name:    "decorrelated",
+			policy:  New(time.Second, Config{Jitter: JitterDecorrelated}),
+			retries: 2,
+			want:    12 * time.Second,
+		},
+		{
+			name:    "limited with max elapsed",
+			policy:  New(time.Second, Config{Jitter: JitterNone, MaxElapsed: 5 * time.Second}),
+			retries: 10,
+			want:    5 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.policy.Budget(tt.retries))
+		})
+	}
+}
+
+func TestExceeded(t *testing.T) {
+	p := New(time.Second, Config{MaxElapsed: 10 * time.Second})
+
+	assert.False(t, p.Exceeded(time.Now(), 5*time.Second))
+	assert.True(t, p.Exceeded(time.Now().Add(-8*time.Second), 5*time.Second))
+
+	unlimited := New(time.Second, Config{})
+	assert.False(t, unlimited.Exceeded(time.Now().Add(-time.Hour), time.Hour))
+}
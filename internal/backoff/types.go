@@ -0,0 +1,65 @@
+package backoff
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// JitterNone disables jitter, pauses grow exactly as Base * 2^(attempt-1).
+	JitterNone Jitter = "none"
+
+	// JitterFull chooses the pause uniformly between zero and the exponential pause.
+	JitterFull Jitter = "full"
+
+	// JitterEqual keeps half of the exponential pause and randomizes the other half.
+	JitterEqual Jitter = "equal"
+
+	// JitterDecorrelated chooses the pause uniformly between Base and three times the previous pause.
+	JitterDecorrelated Jitter = "decorrelated"
+)
+
+const (
+	// DefaultJitter is the default value for Jitter.
+	DefaultJitter = JitterFull
+
+	// DefaultMaxPause is the default value for MaxPause.
+	DefaultMaxPause = 1 * time.Minute
+)
+
+// Jitter is the strategy used to randomize the retry pauses,
+// so that clients failed at the same time do not retry in lockstep.
+type Jitter string
+
+// SetValue implements the cleanenv.Setter interface and validates the jitter strategy.
+func (j *Jitter) SetValue(s string) error {
+	switch v := Jitter(s); v {
+	case JitterNone, JitterFull, JitterEqual, JitterDecorrelated:
+		*j = v
+		return nil
+
+	case "":
+		*j = DefaultJitter
+		return nil
+
+	default:
+		return fmt.Errorf("Jitter: unknown jitter strategy %q", s)
+	}
+}
+
+// Config defines the configuration parameters of the retry backoff policy.
+// MaxElapsed limits the total time spent on retries, zero means no limit.
+type Config struct {
+	Jitter     Jitter        `env:"BACKOFF_JITTER"`
+	MaxPause   time.Duration `env:"BACKOFF_MAX_PAUSE"`
+	MaxElapsed time.Duration `env:"BACKOFF_MAX_ELAPSED"`
+}
+
+// Policy calculates exponential pauses between retries, randomized with Jitter and capped with MaxPause.
+// The zero Policy never pauses.
+type Policy struct {
+	Base       time.Duration
+	MaxPause   time.Duration
+	MaxElapsed time.Duration
+	Jitter     Jitter
+}
@@ -0,0 +1,132 @@
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// Parse parses the raw delivery status notification (RFC 3464): a multipart/report message
+// with the delivery-status part and, optionally, the original message or its headers.
+// It returns ErrNotDSN if the message is not a delivery status notification.
+func Parse(raw []byte) (*Report, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("Parse: cannot read message: %w", err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || !strings.EqualFold(params["report-type"], "delivery-status") {
+		return nil, ErrNotDSN
+	}
+
+	report := &Report{}
+	hasStatus := false
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Parse: cannot read report part: %w", err)
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+
+		switch partType {
+		case "message/delivery-status", "message/global-delivery-status":
+			if report.Recipients, err = parseDeliveryStatus(part); err != nil {
+				return nil, err
+			}
+
+			hasStatus = true
+
+		case "message/rfc822", "text/rfc822-headers", "message/global", "message/global-headers":
+			report.MessageID = originalMessageID(part)
+		}
+	}
+
+	if !hasStatus {
+		return nil, ErrNotDSN
+	}
+
+	return report, nil
+}
+
+// parseDeliveryStatus parses the per-message and per-recipient field groups of the delivery-status part.
+// Only the groups with the Final-Recipient field describe recipients.
+func parseDeliveryStatus(r io.Reader) ([]Recipient, error) {
+	tp := textproto.NewReader(bufio.NewReader(r))
+
+	var recipients []Recipient
+
+	for {
+		fields, err := tp.ReadMIMEHeader()
+
+		if final := fields.Get("Final-Recipient"); final != "" {
+			recipients = append(recipients, Recipient{
+				Address:        strings.ToLower(typedValue(final)),
+				Action:         strings.ToLower(strings.TrimSpace(fields.Get("Action"))),
+				Status:         firstField(fields.Get("Status")),
+				DiagnosticCode: typedValue(fields.Get("Diagnostic-Code")),
+			})
+		}
+
+		if errors.Is(err, io.EOF) {
+			return recipients, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("parseDeliveryStatus: cannot read delivery status fields: %w", err)
+		}
+	}
+}
+
+// originalMessageID returns the Message-ID of the original message returned in the report,
+// or an empty string if it cannot be found.
+func originalMessageID(r io.Reader) string {
+	headers, err := textproto.NewReader(bufio.NewReader(r)).ReadMIMEHeader()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return ""
+	}
+
+	id := strings.TrimSpace(headers.Get("Message-ID"))
+	if id == "" {
+		return ""
+	}
+
+	if !strings.HasPrefix(id, "<") {
+		id = "<" + id + ">"
+	}
+
+	return id
+}
+
+// typedValue returns the value of the field in the form "type; value", like "rfc822; user@example.com".
+func typedValue(field string) string {
+	if _, value, found := strings.Cut(field, ";"); found {
+		return strings.TrimSpace(value)
+	}
+
+	return strings.TrimSpace(field)
+}
+
+// firstField returns the status code without the trailing comment, like "5.1.1" for "5.1.1 (user unknown)".
+func firstField(field string) string {
+	if fields := strings.Fields(field); len(fields) > 0 {
+		return fields[0]
+	}
+
+	return ""
+}
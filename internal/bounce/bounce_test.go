@@ -0,0 +1,168 @@
+package bounce
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dsn builds a delivery status notification with the specified delivery-status fields and returned part.
+func dsn(status, returnedType, returned string) string {
+	msg := "From: MAILER-DAEMON@mx.example.com\r\n" +
+		"To: noreply@example.com\r\n" +
+		"Subject: Undelivered Mail Returned to Sender\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/report; report-type=delivery-status; boundary=\"BOUNDARY\"\r\n" +
+		"\r\n" +
+		"--BOUNDARY\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"Your message could not be delivered.\r\n" +
+		"--BOUNDARY\r\n" +
+		"Content-Type: message/delivery-status\r\n" +
+		"\r\n" +
+		status +
+		"--BOUNDARY\r\n"
+
+	if returnedType != "" {
+		msg += "Content-Type: " + returnedType + "\r\n" +
+			"\r\n" +
+			returned +
+			"--BOUNDARY\r\n"
+	}
+
+	return strings.TrimSuffix(msg, "\r\n") + "--\r\n"
+}
+
+func TestParse(t *testing.T) {
+	const perMessage = "Reporting-MTA: dns; mx.example.com\r\n" +
+		"Arrival-Date: Mon, 2 Jun 2025 12:00:00 +0000\r\n" +
+		"\r\n"
+
+	const returnedHeaders = "From: noreply@example.com\r\n" +
+		"To: user@example.org\r\n" +
+		"Message-ID: <notification.42@example.com>\r\n" +
+		"Subject: Hello\r\n" +
+		"\r\n"
+
+	tests := []struct {
+		name    string
+		raw     string
+		want    *Report
+		wantErr error
+	}{
+		{
+			name: "hard bounce with original headers",
+			raw: dsn(perMessage+
+				"Final-Recipient: rfc822; User@Example.org\r\n"+
+				"Action: failed\r\n"+
+				"Status: 5.1.1\r\n"+
+				"Diagnostic-Code: smtp; 550 5.1.1 User unknown\r\n"+
+				"\r\n", "text/rfc822-headers", returnedHeaders),
+			want: &Report{
+				MessageID: "<notification.42@example.com>",
+				Recipients: []Recipient{{
+					Address:        "user@example.org",
+					Action:         "failed",
+					Status:         "5.1.1",
+					DiagnosticCode: "550 5.1.1 User unknown",
+				}},
+			},
+		},
+		{
+			name: "soft bounce with original message",
+			raw: dsn(perMessage+
+				"Original-Recipient: rfc822; user@example.org\r\n"+
+				"Final-Recipient: rfc822; user@example.org\r\n"+
+				"Action: Failed\r\n"+
+				"Status: 4.2.2 (mailbox full)\r\n", "message/rfc822", returnedHeaders+"Hello!\r\n"),
+			want: &Report{
+				MessageID: "<notification.42@example.com>",
+				Recipients: []Recipient{{
+					Address: "user@example.org",
+					Action:  "failed",
+					Status:  "4.2.2",
+				}},
+			},
+		},
+		{
+			name: "several recipients without original message",
+			raw: dsn(perMessage+
+				"Final-Recipient: rfc822; first@example.org\r\n"+
+				"Action: delayed\r\n"+
+				"Status: 4.4.1\r\n"+
+				"\r\n"+
+				"Final-Recipient: rfc822; second@example.org\r\n"+
+				"Action: failed\r\n"+
+				"Status: 5.7.1\r\n"+
+				"\r\n", "", ""),
+			want: &Report{
+				Recipients: []Recipient{
+					{Address: "first@example.org", Action: "delayed", Status: "4.4.1"},
+					{Address: "second@example.org", Action: "failed", Status: "5.7.1"},
+				},
+			},
+		},
+		{
+			name: "Message-ID without angle brackets",
+			raw: dsn(perMessage+
+				"Final-Recipient: rfc822; user@example.org\r\n"+
+				"Action: failed\r\n"+
+				"Status: 5.0.0\r\n", "text/rfc822-headers", "Message-ID: notification.42@example.com\r\n\r\n"),
+			want: &Report{
+				MessageID:  "<notification.42@example.com>",
+				Recipients: []Recipient{{Address: "user@example.org", Action: "failed", Status: "5.0.0"}},
+			},
+		},
+		{
+			name:    "not a report",
+			raw:     "From: user@example.org\r\nContent-Type: text/plain\r\n\r\nHello\r\n",
+			wantErr: ErrNotDSN,
+		},
+		{
+			name: "report without delivery status",
+			raw: "Content-Type: multipart/report; report-type=delivery-status; boundary=B\r\n\r\n" +
+				"--B\r\nContent-Type: text/plain\r\n\r\nHello\r\n--B--\r\n",
+			wantErr: ErrNotDSN,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.raw))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRecipient(t *testing.T) {
+	tests := []struct {
+		name       string
+		recipient  Recipient
+		wantFailed bool
+		wantHard   bool
+	}{
+		{name: "hard bounce", recipient: Recipient{Action: "failed", Status: "5.1.1"}, wantFailed: true, wantHard: true},
+		{name: "soft bounce", recipient: Recipient{Action: "failed", Status: "4.2.2"}, wantFailed: true},
+		{name: "delayed", recipient: Recipient{Action: "delayed", Status: "4.4.1"}},
+		{name: "delivered", recipient: Recipient{Action: "delivered", Status: "2.0.0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantFailed, tt.recipient.Failed())
+			assert.Equal(t, tt.wantHard, tt.recipient.Hard())
+		})
+	}
+
+	report := &Report{Recipients: []Recipient{tests[0].recipient, tests[2].recipient, tests[1].recipient}}
+	assert.Equal(t, []Recipient{tests[0].recipient, tests[1].recipient}, report.Failed())
+}
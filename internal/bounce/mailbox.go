@@ -0,0 +1,113 @@
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ReadMaildir returns the raw messages stored in the new and cur subdirectories of the maildir, ordered by file name.
+func ReadMaildir(dir string) ([][]byte, error) {
+	var paths []string
+
+	for _, sub := range []string{"new", "cur"} {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("ReadMaildir: %w", err)
+		}
+
+		for _, e := range entries {
+			if e.Type().IsRegular() && !strings.HasPrefix(e.Name(), ".") {
+				paths = append(paths, filepath.Join(dir, sub, e.Name()))
+			}
+		}
+	}
+
+	sort.Slice(paths, func(i, j int) bool {
+		return filepath.Base(paths[i]) < filepath.Base(paths[j])
+	})
+
+	messages := make([][]byte, 0, len(paths))
+
+	for _, p := range paths {
+		msg, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("ReadMaildir: %w", err)
+		}
+
+		messages = append(messages, msg)
+	}
+
+	return messages, nil
+}
+
+// ReadMbox splits the mbox stream into raw messages.
+// Each message starts with a "From " separator line, lines quoted as ">From " are unquoted (mboxrd format),
+// and the blank line separating the messages is removed.
+func ReadMbox(r io.Reader) ([][]byte, error) {
+	br := bufio.NewReader(r)
+
+	var messages [][]byte
+	var current *bytes.Buffer
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+
+		msg := current.Bytes()
+		switch {
+		case bytes.HasSuffix(msg, []byte("\r\n\r\n")):
+			msg = msg[:len(msg)-2]
+		case bytes.HasSuffix(msg, []byte("\n\n")):
+			msg = msg[:len(msg)-1]
+		}
+
+		messages = append(messages, msg)
+	}
+
+	for {
+		line, err := br.ReadString('\n')
+
+		if line != "" {
+			switch {
+			case strings.HasPrefix(line, "From "):
+				flush()
+				current = &bytes.Buffer{}
+
+			case current == nil:
+				if strings.TrimSpace(line) != "" {
+					return nil, ErrInvalidMbox
+				}
+
+			case strings.HasPrefix(strings.TrimLeft(line, ">"), "From ") && strings.HasPrefix(line, ">"):
+				current.WriteString(line[1:])
+
+			default:
+				current.WriteString(line)
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("ReadMbox: %w", err)
+		}
+	}
+
+	flush()
+
+	return messages, nil
+}
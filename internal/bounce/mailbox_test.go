@@ -0,0 +1,90 @@
+package bounce
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadMaildir(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"new/0002.host":     "second",
+		"cur/0001.host:2,S": "first",
+		"cur/.hidden":       "hidden",
+		"tmp/0003.host":     "not delivered yet",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	messages, err := ReadMaildir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("first"), []byte("second")}, messages)
+
+	messages, err = ReadMaildir(t.TempDir())
+	require.NoError(t, err)
+	assert.Empty(t, messages)
+}
+
+func TestReadMbox(t *testing.T) {
+	tests := []struct {
+		name    string
+		mbox    string
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "two messages",
+			mbox: "From MAILER-DAEMON Mon Jun  2 12:00:00 2025\n" +
+				"Subject: first\n\nBody\n>From the archive\n>>From quoted\n\n" +
+				"From MAILER-DAEMON Mon Jun  2 12:01:00 2025\n" +
+				"Subject: second\n\nBody\n",
+			want: []string{
+				"Subject: first\n\nBody\nFrom the archive\n>From quoted\n",
+				"Subject: second\n\nBody\n",
+			},
+		},
+		{
+			name: "leading blank lines",
+			mbox: "\n\nFrom MAILER-DAEMON Mon Jun  2 12:00:00 2025\nSubject: only\n\nBody",
+			want: []string{"Subject: only\n\nBody"},
+		},
+		{
+			name: "empty",
+			mbox: "",
+		},
+		{
+			name:    "no From line",
+			mbox:    "Subject: broken\n\nBody\n",
+			wantErr: ErrInvalidMbox,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, err := ReadMbox(strings.NewReader(tt.mbox))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+
+			var got []string
+			for _, m := range messages {
+				got = append(got, string(m))
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
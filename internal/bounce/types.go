@@ -0,0 +1,54 @@
+package bounce
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrNotDSN indicates that the message is not a delivery status notification (RFC 3464).
+	ErrNotDSN = errors.New("Parse: message is not a delivery status notification")
+
+	// ErrInvalidMbox indicates that the mbox file does not start with a "From " separator line.
+	ErrInvalidMbox = errors.New("ReadMbox: mbox must start with a From line")
+)
+
+// Report is a parsed delivery status notification.
+// MessageID is the Message-ID of the original message, it is empty if the original headers are not returned.
+type Report struct {
+	MessageID  string
+	Recipients []Recipient
+}
+
+// Recipient is the delivery status of a single recipient of the original message, see RFC 3464 section 2.3.
+// Address is the lowercased address from the Final-Recipient field,
+// Status is the status code like 5.1.1 and DiagnosticCode is the response of the remote server.
+type Recipient struct {
+	Address        string
+	Action         string
+	Status         string
+	DiagnosticCode string
+}
+
+// Failed reports whether the message could not be delivered to the recipient.
+func (r Recipient) Failed() bool {
+	return r.Action == "failed"
+}
+
+// Hard reports whether the delivery failed permanently, so that the address must not be used again.
+func (r Recipient) Hard() bool {
+	return r.Failed() && strings.HasPrefix(r.Status, "5.")
+}
+
+// Failed returns the recipients the message could not be delivered to.
+func (r *Report) Failed() []Recipient {
+	var failed []Recipient
+
+	for _, rcpt := range r.Recipients {
+		if rcpt.Failed() {
+			failed = append(failed, rcpt)
+		}
+	}
+
+	return failed
+}
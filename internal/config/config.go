@@ -2,38 +2,56 @@ package config
 
 import (
 	"fmt"
+	"net/mail"
+	"strings"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 
 	"notification/internal/SMTPClient"
 	"notification/internal/api"
+	"notification/internal/api/decoder"
+	"notification/internal/auth"
+	"notification/internal/backoff"
+	"notification/internal/events"
 	"notification/internal/logger"
 	"notification/internal/storage/postgresClient"
 	"notification/internal/storage/redisClient"
+	"notification/internal/webhook"
+	"notification/internal/worker"
 )
 
 // Config defines configuration parameters for the notification-service application,
-// including HTTP server setting, SMTP/PostreSQL/Redis credentials, logger optional and calculate timeouts.
+// including HTTP and gRPC server settings, SMTP/PostreSQL/Redis credentials, API authentication, logger optional and calculate timeouts.
 type Config struct {
 	HttpServer  api.HttpServer
+	GRPCServer  api.GRPCServer
+	Decoder     decoder.Config
 	SMTP        SMTPClient.Config
 	Redis       redisClient.Config
 	Postgres    postgresClient.Config
 	Logger      logger.Config
+	Worker      worker.Config
+	Webhook     webhook.Config
+	Events      events.Config
+	Auth        auth.Config
 	AppTimeouts AppTimeouts
 }
 
 // AppTimeouts defines timeouts used across the application,
 // derived from external service configurations (SMTP, Redis, Postgres).
+// SMTPBackoff is the retry backoff policy shared by the SMTP client and the worker.
 type AppTimeouts struct {
 	SMTPPauseForRetries   time.Duration
 	SMTPQuantityOfRetries int
+	SMTPBackoff           backoff.Policy
 	RedisTimeout          time.Duration
 	PostgresTimeout       time.Duration
 }
 
 // New loads the configuration from the specified file path and initializes computed timeout values.
+// If the Message-ID domain is not set, the domain of the sender email is used.
+// The decoder is told whether webhooks are enabled, so that it rejects the callback URLs when they are not.
 // Returns a fully filled Config instance or an error if loading fails.
 func New(path string) (*Config, error) {
 	var cfg Config
@@ -44,9 +62,28 @@ func New(path string) (*Config, error) {
 
 	cfg.AppTimeouts = setAppTimeouts(&cfg)
 
+	if cfg.Postgres.MessageIDDomain == "" {
+		cfg.Postgres.MessageIDDomain = senderDomain(cfg.SMTP.SenderEmail)
+	}
+
+	cfg.Decoder.Webhooks = cfg.Webhook.Secret != ""
+	cfg.Decoder.Tracking = cfg.SMTP.Tracking.Secret != ""
+
 	return &cfg, nil
 }
 
+// senderDomain returns the domain part of the sender email address, or an empty string if it has none.
+func senderDomain(sender string) string {
+	addr, err := mail.ParseAddress(sender)
+	if err != nil {
+		return ""
+	}
+
+	_, domain, _ := strings.Cut(addr.Address, "@")
+
+	return domain
+}
+
 // setAppTimeouts calculates effective timeout settings based on the provided raw configuration.
 // It applies default values when specific parameters are missing.
 func setAppTimeouts(cfg *Config) AppTimeouts {
@@ -64,6 +101,8 @@ func setAppTimeouts(cfg *Config) AppTimeouts {
 		c.SMTPPauseForRetries = cfg.SMTP.BasicRetryPause
 	}
 
+	c.SMTPBackoff = backoff.New(c.SMTPPauseForRetries, cfg.SMTP.Backoff)
+
 	if cfg.Redis.Timeout == 0 {
 		c.RedisTimeout = redisClient.DefaultRedisTimeout
 	} else {
@@ -0,0 +1,218 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.uber.org/zap"
+
+	"notification/internal/api"
+	"notification/internal/backoff"
+	"notification/internal/monitoring"
+	"notification/internal/unsubscribe"
+)
+
+// New creates and returns a new Bus instance, the broker is optional.
+// If the channel, the buffer size and the reconnect pause are not set in the configuration, the default values are applied.
+func New(config *Config, broker Broker, metrics monitoring.Monitoring, logger *zap.Logger) *Bus {
+	if config.Channel == "" {
+		config.Channel = DefaultChannel
+	}
+
+	if config.BufferSize == 0 {
+		config.BufferSize = DefaultBufferSize
+	}
+
+	if config.ReconnectPause == 0 {
+		config.ReconnectPause = DefaultReconnectPause
+	}
+
+	return &Bus{
+		subscribers: make(map[*subscription]struct{}),
+		broker:      broker,
+		channel:     config.Channel,
+		bufferSize:  config.BufferSize,
+		backoff:     backoff.New(config.ReconnectPause, backoff.Config{}),
+		metrics:     metrics,
+		logger:      logger,
+	}
+}
+
+// Run receives the events published by all service instances from the broker and delivers them
+// to the local subscribers until ctx is done. If the subscription fails or breaks, the channel is subscribed again
+// after the backoff pause, the pauses start over once the subscription succeeds.
+// It returns immediately if there is no broker.
+func (b *Bus) Run(ctx context.Context) {
+	if b == nil || b.broker == nil {
+		return
+	}
+
+	var pause time.Duration
+
+	for attempt := 1; ; attempt++ {
+		messages, err := b.broker.Subscribe(ctx, b.channel)
+
+		switch {
+		case err == nil:
+			b.logger.Info("Run: subscribed to events", zap.String("channel", b.channel))
+
+			attempt, pause = 1, 0
+			b.receive(messages)
+
+			if ctx.Err() == nil {
+				b.metrics.IncError("Events")
+				b.logger.Warn("Run: event subscription closed", zap.String("channel", b.channel))
+			}
+
+		case ctx.Err() == nil:
+			b.metrics.IncError("Events")
+			b.logger.Error("Run: cannot subscribe to events", zap.Error(err), zap.String("channel", b.channel))
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		pause = b.backoff.Pause(attempt, pause)
+
+		select {
+		case <-time.After(pause):
+			continue
+		case <-ctx.Done():
+		}
+
+		break
+	}
+
+	b.logger.Info("Run: event bus stopped")
+}
+
+// receive delivers the events of the subscription to the local subscribers until the channel of messages is closed.
+func (b *Bus) receive(messages <-chan string) {
+	for message := range messages {
+		var event api.StatusEvent
+
+		if err := json.Unmarshal([]byte(message), &event); err != nil {
+			b.metrics.IncError("Events")
+			b.logger.Error("receive: cannot decode event", zap.Error(err), zap.String("message", message))
+
+			continue
+		}
+
+		b.deliver(event)
+	}
+}
+
+// Publish sends the event to the subscribers of all service instances.
+// If the event cannot be published to the broker, it is delivered to the local subscribers only.
+func (b *Bus) Publish(ctx context.Context, event api.StatusEvent) {
+	if b == nil {
+		return
+	}
+
+	if b.broker == nil {
+		b.deliver(event)
+		return
+	}
+
+	message, err := json.Marshal(event)
+	if err == nil {
+		err = b.broker.Publish(context.WithoutCancel(ctx), b.channel, message)
+	}
+
+	if err != nil {
+		b.metrics.IncError("Events")
+		b.logger.Error("Publish: cannot publish event, delivered locally", zap.Error(err), zap.Int("id", event.ID))
+
+		b.deliver(event)
+
+		return
+	}
+
+	b.metrics.IncSuccess("Events")
+}
+
+// Subscribe returns the channel of the events matching the filter and the function which cancels the subscription.
+// The channel is closed when the subscription is canceled or the bus is closed.
+func (b *Bus) Subscribe(filter Filter) (<-chan api.StatusEvent, func()) {
+	filter.Recipient = unsubscribe.Recipient(filter.Recipient)
+
+	sub := &subscription{
+		filter: filter,
+		events: make(chan api.StatusEvent, b.bufferSize),
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		close(sub.events)
+	} else {
+		b.subscribers[sub] = struct{}{}
+	}
+	b.mu.Unlock()
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subscribers[sub]; ok {
+			delete(b.subscribers, sub)
+			close(sub.events)
+		}
+	}
+
+	return sub.events, cancel
+}
+
+// Close cancels all subscriptions, so that the event streams are finished on shutdown.
+// The subscriptions made after Close are canceled immediately.
+func (b *Bus) Close() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+
+	for sub := range b.subscribers {
+		delete(b.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// deliver sends the event to the local subscribers matching it.
+// The event is dropped for subscribers whose buffer is full, so that a slow subscriber does not block the others.
+func (b *Bus) deliver(event api.StatusEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		if !sub.filter.Matches(event) {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+
+		default:
+			b.metrics.IncError("Events")
+			b.logger.Warn("deliver: subscriber is too slow, event dropped", zap.Int("id", event.ID))
+		}
+	}
+}
+
+// Matches reports whether the event passes the filter.
+// The recipient of the filter must be normalized with unsubscribe.Recipient.
+func (f Filter) Matches(event api.StatusEvent) bool {
+	if f.ID != 0 && f.ID != event.ID {
+		return false
+	}
+
+	if f.Tenant != "" && f.Tenant != event.Tenant {
+		return false
+	}
+
+	return f.Recipient == "" || f.Recipient == unsubscribe.Recipient(event.To)
+}
@@ -0,0 +1,214 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"notification/internal/api"
+	"notification/internal/monitoring"
+)
+
+func TestFilterMatches(t *testing.T) {
+	event := api.StatusEvent{ID: 1, Status: api.StatusSent, To: "User <User@example.com>", Tenant: "acme"}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{name: "empty filter", filter: Filter{}, want: true},
+		{name: "same id", filter: Filter{ID: 1}, want: true},
+		{name: "other id", filter: Filter{ID: 2}, want: false},
+		{name: "same recipient", filter: Filter{Recipient: "user@example.com"}, want: true},
+		{name: "other recipient", filter: Filter{Recipient: "other@example.com"}, want: false},
+		{name: "same id and other recipient", filter: Filter{ID: 1, Recipient: "other@example.com"}, want: false},
+		{name: "same tenant", filter: Filter{Tenant: "acme"}, want: true},
+		{name: "other tenant", filter: Filter{ID: 1, Tenant: "other"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.filter.Matches(event))
+		})
+	}
+}
+
+func TestPublishLocal(t *testing.T) {
+	bus := New(&Config{}, nil, monitoring.NewNop(), zap.NewNop())
+
+	all, cancelAll := bus.Subscribe(Filter{})
+	defer cancelAll()
+
+	byRecipient, cancelByRecipient := bus.Subscribe(Filter{Recipient: "User@Example.com"})
+	defer cancelByRecipient()
+
+	byID, cancelByID := bus.Subscribe(Filter{ID: 2})
+	defer cancelByID()
+
+	first := api.StatusEvent{ID: 1, Status: api.StatusSent, To: "user@example.com"}
+	second := api.StatusEvent{ID: 2, Status: api.StatusFailed, To: "other@example.com"}
+
+	bus.Publish(context.Background(), first)
+	bus.Publish(context.Background(), second)
+
+	assert.Equal(t, first, <-all)
+	assert.Equal(t, second, <-all)
+	assert.Equal(t, first, <-byRecipient)
+	assert.Equal(t, second, <-byID)
+
+	assert.Empty(t, byRecipient)
+	assert.Empty(t, byID)
+
+	cancelAll()
+	cancelAll()
+
+	_, ok := <-all
+	assert.False(t, ok)
+}
+
+func TestClose(t *testing.T) {
+	bus := New(&Config{}, nil, monitoring.NewNop(), zap.NewNop())
+
+	events, cancel := bus.Subscribe(Filter{})
+	defer cancel()
+
+	bus.Close()
+
+	_, ok := <-events
+	assert.False(t, ok)
+
+	events, cancel = bus.Subscribe(Filter{})
+	defer cancel()
+
+	_, ok = <-events
+	assert.False(t, ok)
+
+	bus.Publish(context.Background(), api.StatusEvent{ID: 1})
+}
+
+func TestPublishDropsForSlowSubscriber(t *testing.T) {
+	bus := New(&Config{BufferSize: 1}, nil, monitoring.NewNop(), zap.NewNop())
+
+	events, cancel := bus.Subscribe(Filter{})
+	defer cancel()
+
+	bus.Publish(context.Background(), api.StatusEvent{ID: 1})
+	bus.Publish(context.Background(), api.StatusEvent{ID: 2})
+
+	assert.Equal(t, 1, (<-events).ID)
+	assert.Empty(t, events)
+}
+
+func TestPublishBroker(t *testing.T) {
+	event := api.StatusEvent{ID: 1, Status: api.StatusSent, To: "user@example.com"}
+
+	message, err := json.Marshal(event)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		brokerError error
+		wantLocal   bool
+	}{
+		{
+			name:      "published to broker",
+			wantLocal: false,
+		},
+		{
+			name:        "broker error",
+			brokerError: fmt.Errorf("connection refused"),
+			wantLocal:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := &MockBroker{}
+			broker.On("Publish", mock.Anything, DefaultChannel, message).Return(tt.brokerError)
+
+			bus := New(&Config{}, broker, monitoring.NewNop(), zap.NewNop())
+
+			events, cancel := bus.Subscribe(Filter{})
+			defer cancel()
+
+			bus.Publish(context.Background(), event)
+
+			broker.AssertCalled(t, "Publish", mock.Anything, DefaultChannel, message)
+
+			if tt.wantLocal {
+				assert.Equal(t, event, <-events)
+			} else {
+				assert.Empty(t, events)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	first := api.StatusEvent{ID: 1, Status: api.StatusBounced, To: "user@example.com"}
+	second := api.StatusEvent{ID: 2, Status: api.StatusSent, To: "user@example.com"}
+
+	firstMessage, err := json.Marshal(first)
+	require.NoError(t, err)
+
+	secondMessage, err := json.Marshal(second)
+	require.NoError(t, err)
+
+	closed := make(chan string, 2)
+	closed <- "invalid"
+	closed <- string(firstMessage)
+	close(closed)
+
+	open := make(chan string, 1)
+	open <- string(secondMessage)
+
+	broker := &MockBroker{}
+	broker.On("Subscribe", mock.Anything, "channel").Return(nil, fmt.Errorf("connection refused")).Once()
+	broker.On("Subscribe", mock.Anything, "channel").Return((<-chan string)(closed), nil).Once()
+	broker.On("Subscribe", mock.Anything, "channel").Return((<-chan string)(open), nil).Once()
+
+	bus := New(&Config{Channel: "channel", ReconnectPause: time.Millisecond}, broker, monitoring.NewNop(), zap.NewNop())
+
+	events, cancel := bus.Subscribe(Filter{})
+	defer cancel()
+
+	ctx, cancelRun := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		bus.Run(ctx)
+		close(done)
+	}()
+
+	for _, want := range []api.StatusEvent{first, second} {
+		select {
+		case got := <-events:
+			assert.Equal(t, want, got)
+		case <-time.After(time.Second):
+			t.Fatal("event was not delivered in time")
+		}
+	}
+
+	cancelRun()
+	close(open)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("event bus was not stopped in time")
+	}
+
+	broker.AssertNumberOfCalls(t, "Subscribe", 3)
+
+	var disabled *Bus
+	disabled.Run(context.Background())
+	disabled.Publish(context.Background(), first)
+}
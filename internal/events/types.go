@@ -0,0 +1,89 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+
+	"notification/internal/api"
+	"notification/internal/backoff"
+	"notification/internal/monitoring"
+)
+
+const (
+	// DefaultChannel is the default value for Channel.
+	DefaultChannel = "notification-events"
+
+	// DefaultBufferSize is the default value for BufferSize.
+	DefaultBufferSize = 64
+
+	// DefaultReconnectPause is the default value for ReconnectPause.
+	DefaultReconnectPause = 1 * time.Second
+)
+
+// Config defines the configuration of the event bus.
+// Channel is the Redis pub/sub channel used to fan out the events between service instances,
+// BufferSize is the number of events kept for a slow subscriber before the new events are dropped.
+// ReconnectPause is the base pause of the exponential backoff between the attempts to subscribe to the channel.
+type Config struct {
+	Channel        string        `env:"EVENTS_CHANNEL"`
+	BufferSize     int           `env:"EVENTS_BUFFER_SIZE"`
+	ReconnectPause time.Duration `env:"EVENTS_RECONNECT_PAUSE"`
+}
+
+// Broker exchanges the events between service instances, it is implemented by the Redis client.
+type Broker interface {
+	Publish(context.Context, string, []byte) error
+	Subscribe(context.Context, string) (<-chan string, error)
+}
+
+// Filter selects the events delivered to the subscriber.
+// The zero ID and the empty recipient match all notifications, the empty tenant matches the notifications of all tenants.
+type Filter struct {
+	ID        int
+	Recipient string
+	Tenant    string
+}
+
+// Bus delivers the status events published by the handlers and the worker to the subscribers.
+// With the broker, the events are published to the Redis channel and delivered to the subscribers
+// of all service instances by Run, without the broker they are delivered to the local subscribers only.
+// The nil *Bus is valid: it drops published events.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*subscription]struct{}
+	closed      bool
+	broker      Broker
+	channel     string
+	bufferSize  int
+	backoff     backoff.Policy
+	metrics     monitoring.Monitoring
+	logger      *zap.Logger
+}
+
+// subscription is a single subscriber of the bus.
+type subscription struct {
+	filter Filter
+	events chan api.StatusEvent
+}
+
+// MockBroker is a mock implementation of the Broker interface.
+type MockBroker struct {
+	mock.Mock
+}
+
+// Publish is a mock implementation.
+func (mb *MockBroker) Publish(ctx context.Context, channel string, message []byte) error {
+	args := mb.Called(ctx, channel, message)
+	return args.Error(0)
+}
+
+// Subscribe is a mock implementation.
+func (mb *MockBroker) Subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	args := mb.Called(ctx, channel)
+	messages, _ := args.Get(0).(<-chan string)
+	return messages, args.Error(1)
+}
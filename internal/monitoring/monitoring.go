@@ -11,23 +11,30 @@ const (
 	StatusError    = "error"
 	StatusCanceled = "canceled"
 	StatusTimeout  = "timeout"
+
+	StatusTransient = "transient_error"
+	StatusPermanent = "permanent_error"
 )
 
 // Monitoring defines an interface for recording operational metrics such as,
-// counters by status and execution durations.
+// counters by status, execution durations and current state of components.
 type Monitoring interface {
 	IncSuccess(operation string)
 	IncError(operation string)
 	IncCanceled(operation string)
 	IncTimeout(operation string)
+	IncTransient(operation string)
+	IncPermanent(operation string)
 	Observe(operation string, start time.Time)
+	SetState(target string, value float64)
 }
 
 // Metrics implements a Monitoring interface,
-// contains the counting operations and observing execution durations.
+// contains the counting operations, observing execution durations and the state gauge.
 type Metrics struct {
 	Counter  *prometheus.CounterVec
 	Duration *prometheus.HistogramVec
+	State    *prometheus.GaugeVec
 }
 
 // AppMetrics contains the named metrics group for different components in notification-service.
@@ -39,6 +46,17 @@ type AppMetrics struct {
 	ListNotificationMetrics        *Metrics
 	SendNotificationMetrics        *Metrics
 	SendNotificationViaTimeMetrics *Metrics
+	UnsubscribeMetrics             *Metrics
+	SuppressionMetrics             *Metrics
+	BounceMetrics                  *Metrics
+	TrackingMetrics                *Metrics
+	WebhookMetrics                 *Metrics
+	EventsMetrics                  *Metrics
+	NotificationMetrics            *Metrics
+	IdempotencyMetrics             *Metrics
+	GRPCMetrics                    *Metrics
+	AuthMetrics                    *Metrics
+	APIKeyMetrics                  *Metrics
 }
 
 // NewAppMetrics creates and returns a new AppMetrics instance.
@@ -51,10 +69,22 @@ func NewAppMetrics() *AppMetrics {
 		ListNotificationMetrics:        New("ListNotification"),
 		SendNotificationMetrics:        New("SendNotification"),
 		SendNotificationViaTimeMetrics: New("SendNotificationViaTime"),
+		UnsubscribeMetrics:             New("Unsubscribe"),
+		SuppressionMetrics:             New("Suppression"),
+		BounceMetrics:                  New("Bounce"),
+		TrackingMetrics:                New("Tracking"),
+		WebhookMetrics:                 New("Webhook"),
+		EventsMetrics:                  New("Events"),
+		NotificationMetrics:            New("Notification"),
+		IdempotencyMetrics:             New("Idempotency"),
+		GRPCMetrics:                    New("GRPC"),
+		AuthMetrics:                    New("Auth"),
+		APIKeyMetrics:                  New("APIKey"),
 	}
 }
 
-// New creates and returns a new named Metrics instance, includes the counter and histogram for operation time duration.
+// New creates and returns a new named Metrics instance, includes the counter, histogram for operation time duration
+// and gauge for the state of component parts.
 func New(name string) *Metrics {
 	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: name + "_operations_total",
@@ -69,11 +99,18 @@ func New(name string) *Metrics {
 	},
 		[]string{"operation"})
 
-	prometheus.MustRegister(counter, duration)
+	state := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: name + "_state",
+		Help: "Current state of " + name + " component parts",
+	},
+		[]string{"target"})
+
+	prometheus.MustRegister(counter, duration, state)
 
 	return &Metrics{
 		Counter:  counter,
 		Duration: duration,
+		State:    state,
 	}
 }
 
@@ -97,12 +134,27 @@ func (m *Metrics) IncTimeout(operation string) {
 	m.Counter.WithLabelValues(operation, StatusTimeout).Inc()
 }
 
+// IncTransient increments the transient error status for the specified name operation.
+func (m *Metrics) IncTransient(operation string) {
+	m.Counter.WithLabelValues(operation, StatusTransient).Inc()
+}
+
+// IncPermanent increments the permanent error status for the specified name operation.
+func (m *Metrics) IncPermanent(operation string) {
+	m.Counter.WithLabelValues(operation, StatusPermanent).Inc()
+}
+
 // Observe records the execution time duration using specified start time for the specified name operation.
 func (m *Metrics) Observe(operation string, start time.Time) {
 	duration := time.Since(start).Seconds()
 	m.Duration.WithLabelValues(operation).Observe(duration)
 }
 
+// SetState sets the current state value for the specified target.
+func (m *Metrics) SetState(target string, value float64) {
+	m.State.WithLabelValues(target).Set(value)
+}
+
 // NopMetrics is a no-op implementation of the Monitoring interface.
 type NopMetrics struct{}
 
@@ -123,5 +175,14 @@ func (nm *NopMetrics) IncCanceled(operation string) {}
 // IncTimeout is a no-op implementation.
 func (nm *NopMetrics) IncTimeout(operation string) {}
 
+// IncTransient is a no-op implementation.
+func (nm *NopMetrics) IncTransient(operation string) {}
+
+// IncPermanent is a no-op implementation.
+func (nm *NopMetrics) IncPermanent(operation string) {}
+
 // Observe is a no-op implementation.
 func (nm *NopMetrics) Observe(operation string, start time.Time) {}
+
+// SetState is a no-op implementation.
+func (nm *NopMetrics) SetState(target string, value float64) {}
@@ -13,6 +13,7 @@ func TestNew(t *testing.T) {
 
 	require.NotNil(t, m.Counter)
 	require.NotNil(t, m.Duration)
+	require.NotNil(t, m.State)
 }
 
 func TestNewAppMetrics(t *testing.T) {
@@ -27,6 +28,17 @@ func TestNewAppMetrics(t *testing.T) {
 	require.NotNil(t, m.ListNotificationMetrics)
 	require.NotNil(t, m.SendNotificationMetrics)
 	require.NotNil(t, m.SendNotificationViaTimeMetrics)
+	require.NotNil(t, m.UnsubscribeMetrics)
+	require.NotNil(t, m.SuppressionMetrics)
+	require.NotNil(t, m.BounceMetrics)
+	require.NotNil(t, m.TrackingMetrics)
+	require.NotNil(t, m.WebhookMetrics)
+	require.NotNil(t, m.EventsMetrics)
+	require.NotNil(t, m.NotificationMetrics)
+	require.NotNil(t, m.IdempotencyMetrics)
+	require.NotNil(t, m.GRPCMetrics)
+	require.NotNil(t, m.AuthMetrics)
+	require.NotNil(t, m.APIKeyMetrics)
 }
 
 func TestInc(t *testing.T) {
@@ -65,6 +77,20 @@ func TestInc(t *testing.T) {
 			},
 			status: StatusTimeout,
 		},
+		{
+			name: "transient",
+			typeInc: func() {
+				m.IncTransient("operation")
+			},
+			status: StatusTransient,
+		},
+		{
+			name: "permanent",
+			typeInc: func() {
+				m.IncPermanent("operation")
+			},
+			status: StatusPermanent,
+		},
 	}
 
 	for _, tt := range tests {
@@ -92,6 +118,16 @@ func TestObserve(t *testing.T) {
 	require.NotEqual(t, 0, count)
 }
 
+func TestSetState(t *testing.T) {
+	m := New("testSetState")
+
+	m.SetState("target", 1)
+	require.Equal(t, float64(1), testutil.ToFloat64(m.State.WithLabelValues("target")))
+
+	m.SetState("target", 0)
+	require.Equal(t, float64(0), testutil.ToFloat64(m.State.WithLabelValues("target")))
+}
+
 func TestNop(t *testing.T) {
 	m := NewNop()
 
@@ -99,5 +135,8 @@ func TestNop(t *testing.T) {
 	m.IncError("operation")
 	m.IncCanceled("operation")
 	m.IncTimeout("operation")
+	m.IncTransient("operation")
+	m.IncPermanent("operation")
 	m.Observe("operation", time.Now())
+	m.SetState("target", 1)
 }
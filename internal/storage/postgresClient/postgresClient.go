@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-migrate/migrate"
@@ -12,11 +13,14 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 
 	"notification/internal/SMTPClient"
+	"notification/internal/api"
 	"notification/internal/monitoring"
+	"notification/internal/webhook"
 )
 
 // New creates and returns a new PostgresService instance, applies default timeout if not set,
@@ -26,6 +30,10 @@ func New(ctx context.Context, config *Config, metrics monitoring.Monitoring, log
 		config.Timeout = DefaultPostgresTimeout
 	}
 
+	if config.MessageIDDomain == "" {
+		config.MessageIDDomain = DefaultMessageIDDomain
+	}
+
 	url := buildURL(config)
 	dsn := buildDSN(config)
 
@@ -41,14 +49,17 @@ func New(ctx context.Context, config *Config, metrics monitoring.Monitoring, log
 	}
 
 	return &PostgresService{
-		pool:    pool,
-		metrics: metrics,
-		logger:  logger,
-		timeout: config.Timeout,
+		pool:            pool,
+		metrics:         metrics,
+		logger:          logger,
+		timeout:         config.Timeout,
+		messageIDDomain: config.MessageIDDomain,
 	}, nil
 }
 
 // SaveEmail inserts the given email message into the database and returns its generated ID.
+// The client that created the email and its tenant are stored with it, the zero APIKeyID is stored as NULL.
+// The Message-ID derived from the ID and the configured domain is stored together with the email and set to email.MessageID.
 func (ps *PostgresService) SaveEmail(ctx context.Context, email *SMTPClient.EmailMessage) (int, error) {
 	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
 	defer cancel()
@@ -57,13 +68,25 @@ func (ps *PostgresService) SaveEmail(ctx context.Context, email *SMTPClient.Emai
 
 	var id int
 
+	var headers map[string]string
+	if len(email.Headers) > 0 {
+		headers = email.Headers
+	}
+
+	var messageID string
+
 	err := ps.pool.QueryRow(ctx, queryForSaveEmail,
-		email.Type, email.Time, email.To, email.Subject, email.Message).Scan(&id)
+		email.Type, email.Time, email.To, email.Subject, email.Message,
+		email.From, email.FromName, email.ReplyTo, headers,
+		email.InReplyTo, email.References, email.Category, email.HTML, email.Track, email.CallbackURL, ps.messageIDDomain,
+		email.Client, email.APIKeyID, email.Tenant).Scan(&id, &messageID)
 
 	if err != nil {
 		return 0, ps.processError("SaveEmail", err)
 	}
 
+	email.MessageID = messageID
+
 	ps.metrics.Observe("SaveEmail", start)
 
 	ps.metrics.IncSuccess("SaveEmail")
@@ -77,41 +100,48 @@ func (ps *PostgresService) SaveEmail(ctx context.Context, email *SMTPClient.Emai
 	return id, nil
 }
 
-// FetchById returns a list of emails by a unique ID.
-func (ps *PostgresService) FetchById(ctx context.Context, id int) ([]*SMTPClient.EmailMessage, error) {
+// FetchByMessageID returns a list of notifications by a Message-ID.
+func (ps *PostgresService) FetchByMessageID(ctx context.Context, messageID string) ([]*api.Notification, error) {
 	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
 	defer cancel()
 
 	start := time.Now()
 
-	var sendingType, to, subject, message string
-	var sendingTime *time.Time
+	res, err := scanNotification(ps.pool.QueryRow(ctx, queryForFetchByMessageID, messageID))
+	if err != nil {
+		return nil, ps.processError("FetchByMessageID", err)
+	}
+
+	ps.metrics.Observe("FetchByMessageID", start)
+	ps.metrics.IncSuccess("FetchByMessageID")
+
+	ps.logger.Info("FetchByMessageID: successfully fetched email by Message-ID", zap.String("message_id", messageID))
+
+	return []*api.Notification{res}, nil
+}
+
+// FetchById returns a list of notifications by a unique ID.
+func (ps *PostgresService) FetchById(ctx context.Context, id int) ([]*api.Notification, error) {
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
+	defer cancel()
 
-	row := ps.pool.QueryRow(ctx, queryForFetchById, id)
+	start := time.Now()
 
-	err := row.Scan(&sendingType, &sendingTime, &to, &subject, &message)
+	res, err := scanNotification(ps.pool.QueryRow(ctx, queryForFetchById, id))
 	if err != nil {
 		return nil, ps.processError("FetchById", err)
 	}
 
-	res := &SMTPClient.EmailMessage{
-		Type:    sendingType,
-		Time:    sendingTime,
-		To:      to,
-		Subject: subject,
-		Message: message,
-	}
-
 	ps.metrics.Observe("FetchById", start)
 	ps.metrics.IncSuccess("FetchById")
 
 	ps.logger.Info("FetchById: successfully fetched email by id", zap.Int("id", id))
 
-	return []*SMTPClient.EmailMessage{res}, nil
+	return []*api.Notification{res}, nil
 }
 
-// FetchByEmail returns a list of emails by a recipient email address.
-func (ps *PostgresService) FetchByEmail(ctx context.Context, email string) ([]*SMTPClient.EmailMessage, error) {
+// FetchByEmail returns a list of notifications by a recipient email address.
+func (ps *PostgresService) FetchByEmail(ctx context.Context, email string) ([]*api.Notification, error) {
 	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
 	defer cancel()
 
@@ -137,31 +167,181 @@ func (ps *PostgresService) FetchByEmail(ctx context.Context, email string) ([]*S
 	return res, nil
 }
 
-// FetchByAll returns a list of all saved entries.
-func (ps *PostgresService) FetchByAll(ctx context.Context) ([]*SMTPClient.EmailMessage, error) {
+// FetchPage returns a single page of saved notifications selected by the filter.
+// It also returns the ID the next page starts after, which is zero for the last page.
+// Unlike the other fetch methods, an empty page is not an error.
+func (ps *PostgresService) FetchPage(ctx context.Context, filter *api.ListFilter) ([]*api.Notification, int, error) {
 	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
 	defer cancel()
 
 	start := time.Now()
 
-	rows, err := ps.pool.Query(ctx, queryForFetchByAll)
+	limit := filter.Limit
+	if limit <= 0 || limit > api.MaxListLimit {
+		limit = api.DefaultListLimit
+	}
+
+	query, args := buildPageQuery(filter, limit+1)
+
+	rows, err := ps.pool.Query(ctx, query, args...)
 	if err != nil {
-		return nil, ps.processError("FetchByAll", err)
+		return nil, 0, ps.processError("FetchPage", err)
 	}
 
 	defer rows.Close()
 
-	res, err := ps.processRows(rows)
+	emails := make([]*api.Notification, 0, limit+1)
+
+	for rows.Next() {
+		email, err := scanNotification(rows)
+		if err != nil {
+			return nil, 0, ps.processError("FetchPage", err)
+		}
+
+		emails = append(emails, email)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, ps.processError("FetchPage", err)
+	}
+
+	next := 0
+
+	if len(emails) > limit {
+		emails = emails[:limit]
+		next = emails[limit-1].ID
+	}
+
+	ps.metrics.Observe("FetchPage", start)
+	ps.metrics.IncSuccess("FetchPage")
+
+	ps.logger.Info("FetchPage: successfully fetched page of emails", zap.Int("count", len(emails)), zap.Int("next", next))
+
+	return emails, next, nil
+}
+
+// UpdateStatus sets the status of the email by its ID and saves the history of sending attempts in a single transaction.
+// The status of the canceled email is never changed.
+// Returns pgx.ErrNoRows if the email does not exist or has been canceled.
+func (ps *PostgresService) UpdateStatus(ctx context.Context, id int, status string, attempts []SMTPClient.Attempt) error {
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	tx, err := ps.pool.Begin(ctx)
 	if err != nil {
-		return nil, err
+		return ps.processError("UpdateStatus", err)
 	}
+	defer tx.Rollback(ctx)
 
-	ps.metrics.Observe("FetchByAll", start)
-	ps.metrics.IncSuccess("FetchByAll")
+	var sentAt *time.Time
+	if status == api.StatusSent {
+		now := time.Now().UTC()
+		sentAt = &now
+	}
 
-	ps.logger.Info("FetchByAll: successfully fetched email by al")
+	tag, err := tx.Exec(ctx, queryForUpdateStatus, id, status, sentAt)
+	if err != nil {
+		return ps.processError("UpdateStatus", err)
+	}
 
-	return res, nil
+	if tag.RowsAffected() == 0 {
+		return ps.processError("UpdateStatus", pgx.ErrNoRows)
+	}
+
+	batch := &pgx.Batch{}
+	for _, a := range attempts {
+		batch.Queue(queryForSaveAttempt,
+			id, a.Number, a.Provider, a.Class, a.Code, a.Error, a.StartedAt.UTC(), a.Duration.Milliseconds())
+	}
+
+	if err = tx.SendBatch(ctx, batch).Close(); err != nil {
+		return ps.processError("UpdateStatus", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return ps.processError("UpdateStatus", err)
+	}
+
+	ps.metrics.Observe("UpdateStatus", start)
+	ps.metrics.IncSuccess("UpdateStatus")
+
+	ps.logger.Info(
+		"UpdateStatus: successfully updated email status",
+		zap.Int("id", id),
+		zap.String("status", status),
+		zap.Int("attempts", len(attempts)),
+	)
+
+	return nil
+}
+
+// UpdateStatusByMessageID sets the status of the sent email by its Message-ID and returns the updated email.
+// Only the sent email is updated, so that a report for an email that was never delivered, or was already
+// processed, does not change its status.
+// Returns pgx.ErrNoRows if there is no sent email with the Message-ID.
+func (ps *PostgresService) UpdateStatusByMessageID(ctx context.Context, messageID, status string) (*api.Notification, error) {
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	email, err := scanNotification(ps.pool.QueryRow(ctx, queryForUpdateStatusByMessageID, messageID, status))
+	if err != nil {
+		return nil, ps.processError("UpdateStatusByMessageID", err)
+	}
+
+	ps.metrics.Observe("UpdateStatusByMessageID", start)
+	ps.metrics.IncSuccess("UpdateStatusByMessageID")
+
+	ps.logger.Info(
+		"UpdateStatusByMessageID: successfully updated email status",
+		zap.Int("id", email.ID),
+		zap.String("message_id", messageID),
+		zap.String("status", status),
+	)
+
+	return email, nil
+}
+
+// CancelEmail sets the canceled status of the pending scheduled email.
+// Returns pgx.ErrNoRows if there is no such email or it is not pending anymore.
+func (ps *PostgresService) CancelEmail(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	if err := ps.pool.QueryRow(ctx, queryForCancelEmail, id).Scan(&id); err != nil {
+		return ps.processError("CancelEmail", err)
+	}
+
+	ps.metrics.Observe("CancelEmail", start)
+	ps.metrics.IncSuccess("CancelEmail")
+
+	ps.logger.Info("CancelEmail: successfully canceled email", zap.Int("id", id))
+
+	return nil
+}
+
+// ClaimEmail sets the sending status of the pending email before it is sent by the worker,
+// the email with the sending status cannot be canceled.
+// Returns pgx.ErrNoRows if there is no such email or it is not pending anymore, e.g. it has been canceled.
+func (ps *PostgresService) ClaimEmail(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	if err := ps.pool.QueryRow(ctx, queryForClaimEmail, id).Scan(&id); err != nil {
+		return ps.processError("ClaimEmail", err)
+	}
+
+	ps.metrics.Observe("ClaimEmail", start)
+	ps.metrics.IncSuccess("ClaimEmail")
+
+	return nil
 }
 
 // Close closes a connections pool.
@@ -197,30 +377,19 @@ func (ps *PostgresService) processError(funcName string, err error) error {
 	}
 }
 
-// processRows parses pgx.Rows and converts each row into an SMTPClient.EmailMessage.
+// processRows parses pgx.Rows and converts each row into an api.Notification.
 // Returns pgx.ErrNoRows if no records were found.
-func (ps *PostgresService) processRows(rows pgx.Rows) ([]*SMTPClient.EmailMessage, error) {
-	var emails []*SMTPClient.EmailMessage
+func (ps *PostgresService) processRows(rows pgx.Rows) ([]*api.Notification, error) {
+	var emails []*api.Notification
 
 	for rows.Next() {
-		var sendingType, to, subject, message string
-		var sendingTime *time.Time
-
-		err := rows.Scan(&sendingType, &sendingTime, &to, &subject, &message)
+		email, err := scanNotification(rows)
 		if err != nil {
 			ps.metrics.IncError("processRows")
 			ps.logger.Error("processRows: failed to fetch email", zap.Error(err))
 			return nil, fmt.Errorf("processRows: failed to fetch email: %w", err)
 		}
 
-		email := &SMTPClient.EmailMessage{
-			Type:    sendingType,
-			Time:    sendingTime,
-			To:      to,
-			Subject: subject,
-			Message: message,
-		}
-
 		emails = append(emails, email)
 	}
 
@@ -238,6 +407,442 @@ func (ps *PostgresService) processRows(rows pgx.Rows) ([]*SMTPClient.EmailMessag
 	return emails, nil
 }
 
+// Unsubscribe records the opt-out of the recipient from the category of emails.
+func (ps *PostgresService) Unsubscribe(ctx context.Context, recipient, category string) error {
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	if _, err := ps.pool.Exec(ctx, queryForUnsubscribe, recipient, category); err != nil {
+		return ps.processError("Unsubscribe", err)
+	}
+
+	ps.metrics.Observe("Unsubscribe", start)
+	ps.metrics.IncSuccess("Unsubscribe")
+
+	ps.logger.Info("Unsubscribe: successfully unsubscribed recipient",
+		zap.String("recipient", recipient), zap.String("category", category))
+
+	return nil
+}
+
+// IsUnsubscribed reports whether the recipient has opted out of the category of emails.
+func (ps *PostgresService) IsUnsubscribed(ctx context.Context, recipient, category string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	var unsubscribed bool
+
+	if err := ps.pool.QueryRow(ctx, queryForIsUnsubscribed, recipient, category).Scan(&unsubscribed); err != nil {
+		return false, ps.processError("IsUnsubscribed", err)
+	}
+
+	ps.metrics.Observe("IsUnsubscribed", start)
+	ps.metrics.IncSuccess("IsUnsubscribed")
+
+	return unsubscribed, nil
+}
+
+// SaveSuppression adds the address to the suppression list, replacing the existing entry of the address,
+// and sets the creation time of the entry to suppression.CreatedAt.
+func (ps *PostgresService) SaveSuppression(ctx context.Context, suppression *api.Suppression) error {
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	var expiresAt *time.Time
+	if suppression.ExpiresAt != nil {
+		t := suppression.ExpiresAt.UTC()
+		expiresAt = &t
+	}
+
+	err := ps.pool.QueryRow(ctx, queryForSaveSuppression,
+		suppression.Address, suppression.Reason, suppression.Source, expiresAt).Scan(&suppression.CreatedAt)
+	if err != nil {
+		return ps.processError("SaveSuppression", err)
+	}
+
+	ps.metrics.Observe("SaveSuppression", start)
+	ps.metrics.IncSuccess("SaveSuppression")
+
+	ps.logger.Info("SaveSuppression: successfully suppressed address",
+		zap.String("address", suppression.Address), zap.String("reason", suppression.Reason))
+
+	return nil
+}
+
+// FetchSuppression returns the suppression list entry of the address, including an expired one.
+// Returns pgx.ErrNoRows if the address is not on the suppression list.
+func (ps *PostgresService) FetchSuppression(ctx context.Context, address string) (*api.Suppression, error) {
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	res, err := scanSuppression(ps.pool.QueryRow(ctx, queryForFetchSuppression, address))
+	if err != nil {
+		return nil, ps.processError("FetchSuppression", err)
+	}
+
+	ps.metrics.Observe("FetchSuppression", start)
+	ps.metrics.IncSuccess("FetchSuppression")
+
+	return res, nil
+}
+
+// FetchSuppressions returns all suppression list entries, the newest first.
+// Unlike the email listing methods, it returns an empty list if there are no entries.
+func (ps *PostgresService) FetchSuppressions(ctx context.Context) ([]*api.Suppression, error) {
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	rows, err := ps.pool.Query(ctx, queryForFetchSuppressions)
+	if err != nil {
+		return nil, ps.processError("FetchSuppressions", err)
+	}
+
+	defer rows.Close()
+
+	res := []*api.Suppression{}
+
+	for rows.Next() {
+		suppression, err := scanSuppression(rows)
+		if err != nil {
+			return nil, ps.processError("FetchSuppressions", err)
+		}
+
+		res = append(res, suppression)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ps.processError("FetchSuppressions", err)
+	}
+
+	ps.metrics.Observe("FetchSuppressions", start)
+	ps.metrics.IncSuccess("FetchSuppressions")
+
+	return res, nil
+}
+
+// DeleteSuppression removes the address from the suppression list.
+// Returns pgx.ErrNoRows if the address is not on the suppression list.
+func (ps *PostgresService) DeleteSuppression(ctx context.Context, address string) error {
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	tag, err := ps.pool.Exec(ctx, queryForDeleteSuppression, address)
+	if err != nil {
+		return ps.processError("DeleteSuppression", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ps.processError("DeleteSuppression", pgx.ErrNoRows)
+	}
+
+	ps.metrics.Observe("DeleteSuppression", start)
+	ps.metrics.IncSuccess("DeleteSuppression")
+
+	ps.logger.Info("DeleteSuppression: successfully removed address from suppression list", zap.String("address", address))
+
+	return nil
+}
+
+// IsSuppressed reports whether the address is on the suppression list and the entry has not expired.
+func (ps *PostgresService) IsSuppressed(ctx context.Context, address string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	var suppressed bool
+
+	if err := ps.pool.QueryRow(ctx, queryForIsSuppressed, address).Scan(&suppressed); err != nil {
+		return false, ps.processError("IsSuppressed", err)
+	}
+
+	ps.metrics.Observe("IsSuppressed", start)
+	ps.metrics.IncSuccess("IsSuppressed")
+
+	return suppressed, nil
+}
+
+// SaveTrackingEvent records the open or click event of the email by its ID, url is the target of the clicked link.
+// Returns pgx.ErrNoRows if the email does not exist.
+func (ps *PostgresService) SaveTrackingEvent(ctx context.Context, id int, eventType, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	if _, err := ps.pool.Exec(ctx, queryForSaveTrackingEvent, id, eventType, url); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == codeForeignKeyViolation {
+			err = pgx.ErrNoRows
+		}
+
+		return ps.processError("SaveTrackingEvent", err)
+	}
+
+	ps.metrics.Observe("SaveTrackingEvent", start)
+	ps.metrics.IncSuccess("SaveTrackingEvent")
+
+	ps.logger.Info("SaveTrackingEvent: successfully saved tracking event",
+		zap.Int("id", id), zap.String("type", eventType), zap.String("url", url))
+
+	return nil
+}
+
+// SaveWebhookAttempt saves a single delivery attempt of the webhook of the email.
+// It implements the webhook.Recorder interface.
+func (ps *PostgresService) SaveWebhookAttempt(ctx context.Context, attempt *webhook.Attempt) error {
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	_, err := ps.pool.Exec(ctx, queryForSaveWebhookAttempt, attempt.EmailID, attempt.URL, attempt.Status, attempt.Number,
+		attempt.Code, attempt.Error, attempt.StartedAt.UTC(), attempt.Duration.Milliseconds())
+	if err != nil {
+		return ps.processError("SaveWebhookAttempt", err)
+	}
+
+	ps.metrics.Observe("SaveWebhookAttempt", start)
+	ps.metrics.IncSuccess("SaveWebhookAttempt")
+
+	return nil
+}
+
+// SaveAPIKey inserts the API key with the hash of the key, and sets the ID and the creation time of the key.
+func (ps *PostgresService) SaveAPIKey(ctx context.Context, key *api.APIKey, hash string) error {
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	var expiresAt *time.Time
+	if key.ExpiresAt != nil {
+		t := key.ExpiresAt.UTC()
+		expiresAt = &t
+	}
+
+	err := ps.pool.QueryRow(ctx, queryForSaveAPIKey, key.Name, key.Prefix, hash, key.Scopes, expiresAt).
+		Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return ps.processError("SaveAPIKey", err)
+	}
+
+	ps.metrics.Observe("SaveAPIKey", start)
+	ps.metrics.IncSuccess("SaveAPIKey")
+
+	ps.logger.Info("SaveAPIKey: successfully saved API key",
+		zap.Int("id", key.ID), zap.String("name", key.Name), zap.Strings("scopes", key.Scopes))
+
+	return nil
+}
+
+// FetchAPIKeyByHash returns the API key by the hash of the key, including a revoked or expired one.
+// Returns pgx.ErrNoRows if there is no key with the hash.
+// It implements the auth.KeyStore interface.
+func (ps *PostgresService) FetchAPIKeyByHash(ctx context.Context, hash string) (*api.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	res, err := scanAPIKey(ps.pool.QueryRow(ctx, queryForFetchAPIKeyByHash, hash))
+	if err != nil {
+		return nil, ps.processError("FetchAPIKeyByHash", err)
+	}
+
+	ps.metrics.Observe("FetchAPIKeyByHash", start)
+	ps.metrics.IncSuccess("FetchAPIKeyByHash")
+
+	return res, nil
+}
+
+// FetchAPIKeys returns all API keys in the order they were created, including the revoked ones.
+// It returns an empty list if there are no keys.
+func (ps *PostgresService) FetchAPIKeys(ctx context.Context) ([]*api.APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	rows, err := ps.pool.Query(ctx, queryForFetchAPIKeys)
+	if err != nil {
+		return nil, ps.processError("FetchAPIKeys", err)
+	}
+
+	defer rows.Close()
+
+	res := []*api.APIKey{}
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, ps.processError("FetchAPIKeys", err)
+		}
+
+		res = append(res, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, ps.processError("FetchAPIKeys", err)
+	}
+
+	ps.metrics.Observe("FetchAPIKeys", start)
+	ps.metrics.IncSuccess("FetchAPIKeys")
+
+	return res, nil
+}
+
+// RevokeAPIKey sets the revocation time of the API key, the revoked key is kept to identify the notifications created with it.
+// Returns pgx.ErrNoRows if there is no such key or it is already revoked.
+func (ps *PostgresService) RevokeAPIKey(ctx context.Context, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, ps.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	tag, err := ps.pool.Exec(ctx, queryForRevokeAPIKey, id)
+	if err != nil {
+		return ps.processError("RevokeAPIKey", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ps.processError("RevokeAPIKey", pgx.ErrNoRows)
+	}
+
+	ps.metrics.Observe("RevokeAPIKey", start)
+	ps.metrics.IncSuccess("RevokeAPIKey")
+
+	ps.logger.Info("RevokeAPIKey: successfully revoked API key", zap.Int("id", id))
+
+	return nil
+}
+
+// scanAPIKey scans a single row selected with apiKeyColumns into APIKey.
+func scanAPIKey(row pgx.Row) (*api.APIKey, error) {
+	key := &api.APIKey{}
+
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Scopes, &key.CreatedAt, &key.ExpiresAt, &key.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// scanSuppression scans a single row selected with suppressionColumns into Suppression.
+func scanSuppression(row pgx.Row) (*api.Suppression, error) {
+	suppression := &api.Suppression{}
+
+	err := row.Scan(&suppression.Address, &suppression.Reason, &suppression.Source,
+		&suppression.CreatedAt, &suppression.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return suppression, nil
+}
+
+// scanNotification scans a single row selected with emailColumns into Notification.
+func scanNotification(row pgx.Row) (*api.Notification, error) {
+	email := &api.Notification{}
+
+	err := row.Scan(&email.ID, &email.Type, &email.Status, &email.Time, &email.To, &email.Subject, &email.Message,
+		&email.From, &email.FromName, &email.ReplyTo, &email.Headers,
+		&email.MessageID, &email.InReplyTo, &email.References, &email.Category, &email.HTML, &email.Track, &email.Events,
+		&email.CallbackURL, &email.Client, &email.Tenant, &email.CreatedAt, &email.SentAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(email.Events) == 0 {
+		email.Events = nil
+	}
+
+	return email, nil
+}
+
+// buildPageQuery adds the conditions of the filter, the order and the limit to queryForFetchPage,
+// and returns the query with its arguments.
+// The page is selected with the keyset on the ID, so that its cost does not depend on the number of the previous pages.
+func buildPageQuery(filter *api.ListFilter, limit int) (string, []any) {
+	var (
+		conditions []string
+		args       []any
+	)
+
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.To != "" {
+		add(`"to" = $%d`, filter.To)
+	}
+
+	if filter.Type != "" {
+		add("type = $%d", filter.Type)
+	}
+
+	if filter.Status != "" {
+		add("status = $%d", filter.Status)
+	}
+
+	if filter.Subject != "" {
+		add("subject ILIKE '%%' || $%d || '%%'", likeEscaper.Replace(filter.Subject))
+	}
+
+	if filter.SentAfter != nil {
+		add("sent_at >= $%d", filter.SentAfter.UTC())
+	}
+
+	if filter.SentBefore != nil {
+		add("sent_at < $%d", filter.SentBefore.UTC())
+	}
+
+	if filter.Tenant != "" {
+		add("tenant = $%d", filter.Tenant)
+	}
+
+	order := "ASC"
+
+	if filter.Desc {
+		order = "DESC"
+	}
+
+	if filter.AfterID > 0 {
+		if filter.Desc {
+			add("id < $%d", filter.AfterID)
+		} else {
+			add("id > $%d", filter.AfterID)
+		}
+	}
+
+	query := queryForFetchPage
+
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, limit)
+
+	return fmt.Sprintf("%s ORDER BY id %s LIMIT $%d", query, order, len(args)), args
+}
+
+// likeEscaper escapes the wildcards of the LIKE pattern, so that the substring is matched literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
 // buildURL creates a PostgreSQL URL by specified parameters on Config, for perform migrations.
 func buildURL(config *Config) string {
 	url := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
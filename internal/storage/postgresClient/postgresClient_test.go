@@ -2,6 +2,7 @@ package postgresClient
 
 import (
 	"context"
+	"fmt"
 	"math"
 	"testing"
 	"time"
@@ -16,6 +17,7 @@ import (
 	"notification/internal/SMTPClient"
 	"notification/internal/api"
 	"notification/internal/monitoring"
+	"notification/internal/webhook"
 )
 
 const pathToTestMigrations = "file://../../../database/migrations"
@@ -64,24 +66,26 @@ func TestSaveEmail(t *testing.T) {
 			gotId, err := postgresService.SaveEmail(ctx, tt.wantEmail)
 			require.NoError(t, err)
 
-			q := `SELECT type, time, "to", subject, message FROM schema_emails.emails WHERE subject = $1`
+			q := `SELECT type, time, "to", subject, message, message_id FROM schema_emails.emails WHERE subject = $1`
 			row := postgresService.pool.QueryRow(ctx, q, tt.wantEmail.Subject)
 
-			var sendingType, to, subject, message string
+			var sendingType, to, subject, message, messageID string
 			var sendingTime *time.Time
 
-			err = row.Scan(&sendingType, &sendingTime, &to, &subject, &message)
+			err = row.Scan(&sendingType, &sendingTime, &to, &subject, &message, &messageID)
 			require.NoError(t, err)
 
 			gotEmail := &SMTPClient.EmailMessage{
-				Type:    sendingType,
-				Time:    sendingTime,
-				To:      to,
-				Subject: subject,
-				Message: message,
+				Type:      sendingType,
+				Time:      sendingTime,
+				To:        to,
+				Subject:   subject,
+				Message:   message,
+				MessageID: messageID,
 			}
 
 			assert.Equal(t, tt.wantId, gotId)
+			assert.Equal(t, fmt.Sprintf("<notification.%d@example.com>", gotId), gotEmail.MessageID)
 			assert.Equal(t, tt.wantEmail, gotEmail)
 		})
 	}
@@ -163,12 +167,14 @@ func TestFetchById(t *testing.T) {
 	testTime, err := time.ParseInLocation("2006-01-02 15:04:05", "2035-07-13 21:58:00", time.UTC)
 	require.NoError(t, err)
 
+	createdAt := time.Date(2025, 7, 13, 10, 0, 0, 0, time.UTC)
+
 	tests := []struct {
 		name      string
 		setup     func(insertSQL string)
 		insertSQL string
 		id        int
-		want      []*SMTPClient.EmailMessage
+		want      []*api.Notification
 		wantErr   error
 	}{
 		{
@@ -177,14 +183,17 @@ func TestFetchById(t *testing.T) {
 				clearData()
 				insertData(insertSQL)
 			},
-			insertSQL: `INSERT INTO schema_emails.emails (id ,type, time, "to", subject, message) VALUES
-			(1,'instantSending', null, 'to', 'subject', 'message');`,
+			insertSQL: `INSERT INTO schema_emails.emails (id ,type, time, "to", subject, message, created_at) VALUES
+			(1,'instantSending', null, 'to', 'subject', 'message', '2025-07-13 10:00:00');`,
 			id: 1,
-			want: []*SMTPClient.EmailMessage{{
-				Type:    api.KeyForInstantSending,
-				To:      "to",
-				Subject: "subject",
-				Message: "message",
+			want: []*api.Notification{{
+				ID:        1,
+				Type:      api.KeyForInstantSending,
+				Status:    api.StatusPending,
+				To:        "to",
+				Subject:   "subject",
+				Message:   "message",
+				CreatedAt: createdAt,
 			}},
 			wantErr: nil,
 		},
@@ -194,15 +203,18 @@ func TestFetchById(t *testing.T) {
 				clearData()
 				insertData(insertSQL)
 			},
-			insertSQL: `INSERT INTO schema_emails.emails (id ,type, time, "to", subject, message) VALUES
-			(2,'delayedSending', '2035-07-13 21:58:00', 'to', 'subject', 'message');`,
+			insertSQL: `INSERT INTO schema_emails.emails (id ,type, time, "to", subject, message, created_at) VALUES
+			(2,'delayedSending', '2035-07-13 21:58:00', 'to', 'subject', 'message', '2025-07-13 10:00:00');`,
 			id: 2,
-			want: []*SMTPClient.EmailMessage{{
-				Type:    api.KeyForDelayedSending,
-				Time:    &testTime,
-				To:      "to",
-				Subject: "subject",
-				Message: "message",
+			want: []*api.Notification{{
+				ID:        2,
+				Type:      api.KeyForDelayedSending,
+				Status:    api.StatusPending,
+				Time:      &testTime,
+				To:        "to",
+				Subject:   "subject",
+				Message:   "message",
+				CreatedAt: createdAt,
 			}},
 			wantErr: nil,
 		},
@@ -247,6 +259,279 @@ func TestFetchById(t *testing.T) {
 	}
 }
 
+func TestFetchByMessageID(t *testing.T) {
+	ctx := context.Background()
+
+	postgresService := upPostgres("postgres-for-test-FetchByMessageID", t)
+
+	first := &SMTPClient.EmailMessage{
+		Type:    api.KeyForInstantSending,
+		To:      "to",
+		Subject: "subject",
+		Message: "message",
+	}
+
+	firstID, err := postgresService.SaveEmail(ctx, first)
+	require.NoError(t, err)
+
+	reply := &SMTPClient.EmailMessage{
+		Type:       api.KeyForInstantSending,
+		To:         "to",
+		Subject:    "Re: subject",
+		Message:    "message",
+		InReplyTo:  first.MessageID,
+		References: []string{first.MessageID},
+	}
+
+	replyID, err := postgresService.SaveEmail(ctx, reply)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		messageID string
+		want      []*api.Notification
+		wantErr   error
+	}{
+		{
+			name:      "first email",
+			messageID: first.MessageID,
+			want: []*api.Notification{{
+				ID:        firstID,
+				Type:      api.KeyForInstantSending,
+				Status:    api.StatusPending,
+				To:        "to",
+				Subject:   "subject",
+				Message:   "message",
+				MessageID: first.MessageID,
+			}},
+		},
+		{
+			name:      "reply",
+			messageID: reply.MessageID,
+			want: []*api.Notification{{
+				ID:         replyID,
+				Type:       api.KeyForInstantSending,
+				Status:     api.StatusPending,
+				To:         "to",
+				Subject:    "Re: subject",
+				Message:    "message",
+				MessageID:  reply.MessageID,
+				InReplyTo:  first.MessageID,
+				References: []string{first.MessageID},
+			}},
+		},
+		{
+			name:      "Message-ID not exists",
+			messageID: "<notification.0@example.com>",
+			wantErr:   pgx.ErrNoRows,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := postgresService.FetchByMessageID(ctx, tt.messageID)
+
+			for _, notification := range got {
+				assert.False(t, notification.CreatedAt.IsZero())
+				notification.CreatedAt = time.Time{}
+			}
+
+			assert.Equal(t, tt.want, got)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestUpdateStatusByMessageID(t *testing.T) {
+	ctx := context.Background()
+
+	postgresService := upPostgres("postgres-for-test-UpdateStatusByMessageID", t)
+
+	email := &SMTPClient.EmailMessage{
+		Type:    api.KeyForInstantSending,
+		To:      "to",
+		Subject: "subject",
+		Message: "message",
+	}
+
+	id, err := postgresService.SaveEmail(ctx, email)
+	require.NoError(t, err)
+
+	_, err = postgresService.UpdateStatusByMessageID(ctx, email.MessageID, api.StatusBounced)
+	assert.ErrorIs(t, err, pgx.ErrNoRows, "email that is not sent yet")
+
+	require.NoError(t, postgresService.UpdateStatus(ctx, id, api.StatusSent, nil))
+
+	got, err := postgresService.UpdateStatusByMessageID(ctx, email.MessageID, api.StatusBounced)
+	require.NoError(t, err)
+	assert.Equal(t, id, got.ID)
+	assert.Equal(t, "to", got.To)
+	assert.Equal(t, api.StatusBounced, got.Status)
+
+	var status string
+	require.NoError(t, postgresService.pool.QueryRow(ctx, "SELECT status FROM schema_emails.emails WHERE id = $1", id).Scan(&status))
+	assert.Equal(t, api.StatusBounced, status)
+
+	_, err = postgresService.UpdateStatusByMessageID(ctx, email.MessageID, api.StatusBounced)
+	assert.ErrorIs(t, err, pgx.ErrNoRows, "email that is already bounced")
+
+	_, err = postgresService.UpdateStatusByMessageID(ctx, "<notification.0@example.com>", api.StatusBounced)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func TestCancelEmail(t *testing.T) {
+	ctx := context.Background()
+
+	postgresService := upPostgres("postgres-for-test-CancelEmail", t)
+
+	sendAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	scheduled, err := postgresService.SaveEmail(ctx, &SMTPClient.EmailMessage{
+		Type:    api.KeyForDelayedSending,
+		Time:    &sendAt,
+		To:      "to",
+		Subject: "subject",
+		Message: "message",
+	})
+	require.NoError(t, err)
+
+	instant, err := postgresService.SaveEmail(ctx, &SMTPClient.EmailMessage{
+		Type:    api.KeyForInstantSending,
+		To:      "to",
+		Subject: "subject",
+		Message: "message",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, postgresService.CancelEmail(ctx, scheduled))
+
+	tests := []struct {
+		name string
+		id   int
+	}{
+		{name: "already canceled", id: scheduled},
+		{name: "instant email", id: instant},
+		{name: "unknown email", id: 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, postgresService.CancelEmail(ctx, tt.id), pgx.ErrNoRows)
+		})
+	}
+
+	assert.ErrorIs(t, postgresService.ClaimEmail(ctx, scheduled), pgx.ErrNoRows)
+	assert.ErrorIs(t, postgresService.UpdateStatus(ctx, scheduled, api.StatusSent, nil), pgx.ErrNoRows)
+
+	var status string
+	require.NoError(t, postgresService.pool.QueryRow(ctx, "SELECT status FROM schema_emails.emails WHERE id = $1", scheduled).Scan(&status))
+	assert.Equal(t, api.StatusCanceled, status)
+}
+
+func TestClaimEmail(t *testing.T) {
+	ctx := context.Background()
+
+	postgresService := upPostgres("postgres-for-test-ClaimEmail", t)
+
+	sendAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	id, err := postgresService.SaveEmail(ctx, &SMTPClient.EmailMessage{
+		Type:    api.KeyForDelayedSending,
+		Time:    &sendAt,
+		To:      "to",
+		Subject: "subject",
+		Message: "message",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, postgresService.ClaimEmail(ctx, id))
+
+	// The cancellation landing between the check and the send of the worker is rejected.
+	assert.ErrorIs(t, postgresService.CancelEmail(ctx, id), pgx.ErrNoRows)
+	assert.ErrorIs(t, postgresService.ClaimEmail(ctx, id), pgx.ErrNoRows)
+
+	require.NoError(t, postgresService.UpdateStatus(ctx, id, api.StatusSent, nil))
+
+	var status string
+	require.NoError(t, postgresService.pool.QueryRow(ctx, "SELECT status FROM schema_emails.emails WHERE id = $1", id).Scan(&status))
+	assert.Equal(t, api.StatusSent, status)
+
+	assert.ErrorIs(t, postgresService.ClaimEmail(ctx, 1000), pgx.ErrNoRows)
+}
+
+func TestSaveTrackingEvent(t *testing.T) {
+	ctx := context.Background()
+
+	postgresService := upPostgres("postgres-for-test-SaveTrackingEvent", t)
+
+	email := &SMTPClient.EmailMessage{
+		Type:    api.KeyForInstantSending,
+		To:      "to",
+		Subject: "subject",
+		Message: "message",
+		HTML:    "<p>message</p>",
+		Track:   true,
+	}
+
+	id, err := postgresService.SaveEmail(ctx, email)
+	require.NoError(t, err)
+
+	require.NoError(t, postgresService.SaveTrackingEvent(ctx, id, api.EventOpen, ""))
+	require.NoError(t, postgresService.SaveTrackingEvent(ctx, id, api.EventClick, "https://example.com"))
+
+	got, err := postgresService.FetchById(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+
+	assert.Equal(t, email.HTML, got[0].HTML)
+	assert.True(t, got[0].Track)
+	require.Len(t, got[0].Events, 2)
+	assert.Equal(t, api.EventOpen, got[0].Events[0].Type)
+	assert.Equal(t, api.EventClick, got[0].Events[1].Type)
+	assert.Equal(t, "https://example.com", got[0].Events[1].URL)
+	assert.False(t, got[0].Events[1].CreatedAt.IsZero())
+
+	err = postgresService.SaveTrackingEvent(ctx, id+1, api.EventOpen, "")
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func TestSaveWebhookAttempt(t *testing.T) {
+	ctx := context.Background()
+
+	postgresService := upPostgres("postgres-for-test-SaveWebhookAttempt", t)
+
+	email := &SMTPClient.EmailMessage{
+		Type:        api.KeyForInstantSending,
+		To:          "to",
+		Subject:     "subject",
+		Message:     "message",
+		CallbackURL: "https://example.com/hook",
+	}
+
+	id, err := postgresService.SaveEmail(ctx, email)
+	require.NoError(t, err)
+
+	got, err := postgresService.FetchById(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, email.CallbackURL, got[0].CallbackURL)
+
+	attempts := []*webhook.Attempt{
+		{EmailID: id, URL: email.CallbackURL, Status: api.StatusSent, Number: 1, Code: 503,
+			Error: "callback is unavailable", StartedAt: time.Now(), Duration: time.Second},
+		{EmailID: id, URL: email.CallbackURL, Status: api.StatusSent, Number: 2, Code: 200,
+			StartedAt: time.Now(), Duration: time.Second},
+	}
+
+	for _, attempt := range attempts {
+		require.NoError(t, postgresService.SaveWebhookAttempt(ctx, attempt))
+	}
+
+	var count int
+	require.NoError(t, postgresService.pool.QueryRow(ctx,
+		"SELECT count(*) FROM schema_emails.webhook_attempts WHERE email_id = $1 AND error IS NULL", id).Scan(&count))
+	assert.Equal(t, 1, count)
+}
+
 func TestFetchByIdWithContext(t *testing.T) {
 	postgresService := upPostgres("postgres-for-test-TestFetchByIdWithContext", t)
 
@@ -254,7 +539,7 @@ func TestFetchByIdWithContext(t *testing.T) {
 		name    string
 		setup   func() context.Context
 		id      int
-		want    []*SMTPClient.EmailMessage
+		want    []*api.Notification
 		wantErr error
 	}{
 		{
@@ -313,12 +598,14 @@ func TestFetchByEmail(t *testing.T) {
 	testTime, err := time.ParseInLocation("2006-01-02 15:04:05", "2035-07-13 21:58:00", time.UTC)
 	require.NoError(t, err)
 
+	createdAt := time.Date(2025, 7, 13, 10, 0, 0, 0, time.UTC)
+
 	tests := []struct {
 		name      string
 		setup     func(insertSQL string)
 		insertSQL string
 		email     string
-		want      []*SMTPClient.EmailMessage
+		want      []*api.Notification
 		wantErr   error
 	}{
 		{
@@ -327,14 +614,17 @@ func TestFetchByEmail(t *testing.T) {
 				clearData()
 				insertData(insertSQL)
 			},
-			insertSQL: `INSERT INTO schema_emails.emails (id ,type, time, "to", subject, message) VALUES 
-        	(1,'instantSending', null, 'to', 'subject', 'message');`,
+			insertSQL: `INSERT INTO schema_emails.emails (id ,type, time, "to", subject, message, created_at) VALUES 
+        	(1,'instantSending', null, 'to', 'subject', 'message', '2025-07-13 10:00:00');`,
 			email: "to",
-			want: []*SMTPClient.EmailMessage{{
-				Type:    api.KeyForInstantSending,
-				To:      "to",
-				Subject: "subject",
-				Message: "message",
+			want: []*api.Notification{{
+				ID:        1,
+				Type:      api.KeyForInstantSending,
+				Status:    api.StatusPending,
+				To:        "to",
+				Subject:   "subject",
+				Message:   "message",
+				CreatedAt: createdAt,
 			}},
 			wantErr: nil,
 		},
@@ -345,14 +635,17 @@ func TestFetchByEmail(t *testing.T) {
 				clearData()
 				insertData(insertSQL)
 			},
-			insertSQL: `INSERT INTO schema_emails.emails (id ,type, time, "to", subject, message) VALUES 
-        	(2,'delayedSending', '2035-07-13 21:58:00', 'to1', 'subject', 'message');`,
-			want: []*SMTPClient.EmailMessage{{
-				Type:    api.KeyForDelayedSending,
-				Time:    &testTime,
-				To:      "to1",
-				Subject: "subject",
-				Message: "message",
+			insertSQL: `INSERT INTO schema_emails.emails (id ,type, time, "to", subject, message, created_at) VALUES 
+        	(2,'delayedSending', '2035-07-13 21:58:00', 'to1', 'subject', 'message', '2025-07-13 10:00:00');`,
+			want: []*api.Notification{{
+				ID:        2,
+				Type:      api.KeyForDelayedSending,
+				Status:    api.StatusPending,
+				Time:      &testTime,
+				To:        "to1",
+				Subject:   "subject",
+				Message:   "message",
+				CreatedAt: createdAt,
 			}},
 			wantErr: nil,
 		},
@@ -363,22 +656,28 @@ func TestFetchByEmail(t *testing.T) {
 				clearData()
 				insertData(insertSQL)
 			},
-			insertSQL: `INSERT INTO schema_emails.emails (id ,type, time, "to", subject, message) VALUES 
-			(1,'instantSending', null, 'common', 'subject', 'message'),
-        	(2,'delayedSending', '2035-07-13 21:58:00', 'common', 'subject', 'message');`,
-			want: []*SMTPClient.EmailMessage{
+			insertSQL: `INSERT INTO schema_emails.emails (id ,type, time, "to", subject, message, created_at) VALUES 
+			(1,'instantSending', null, 'common', 'subject', 'message', '2025-07-13 10:00:00'),
+        	(2,'delayedSending', '2035-07-13 21:58:00', 'common', 'subject', 'message', '2025-07-13 10:00:00');`,
+			want: []*api.Notification{
 				{
-					Type:    api.KeyForInstantSending,
-					To:      "common",
-					Subject: "subject",
-					Message: "message",
+					ID:        1,
+					Type:      api.KeyForInstantSending,
+					Status:    api.StatusPending,
+					To:        "common",
+					Subject:   "subject",
+					Message:   "message",
+					CreatedAt: createdAt,
 				},
 				{
-					Type:    api.KeyForDelayedSending,
-					Time:    &testTime,
-					To:      "common",
-					Subject: "subject",
-					Message: "message",
+					ID:        2,
+					Type:      api.KeyForDelayedSending,
+					Status:    api.StatusPending,
+					Time:      &testTime,
+					To:        "common",
+					Subject:   "subject",
+					Message:   "message",
+					CreatedAt: createdAt,
 				},
 			},
 			wantErr: nil,
@@ -413,7 +712,7 @@ func TestFetchByEmailWithContext(t *testing.T) {
 		name    string
 		setup   func() context.Context
 		email   string
-		want    []*SMTPClient.EmailMessage
+		want    []*api.Notification
 		wantErr error
 	}{
 		{
@@ -454,124 +753,320 @@ func TestFetchByEmailWithContext(t *testing.T) {
 	}
 }
 
-func TestFetchByAll(t *testing.T) {
+func TestFetchPage(t *testing.T) {
 	ctx := context.Background()
 
-	postgresService := upPostgres("postgres-for-test-FetchByAll", t)
-
-	insertData := func(insertSQL string) {
-		_, err := postgresService.pool.Exec(ctx, insertSQL)
-		require.NoError(t, err)
-	}
-
-	clearData := func() {
-		_, err := postgresService.pool.Exec(ctx, `DELETE FROM schema_emails.emails`)
-		require.NoError(t, err)
-	}
+	postgresService := upPostgres("postgres-for-test-FetchPage", t)
 
-	testTime, err := time.ParseInLocation("2006-01-02 15:04:05", "2035-07-13 21:58:00", time.UTC)
+	_, err := postgresService.pool.Exec(ctx, `INSERT INTO schema_emails.emails (id, type, time, "to", subject, message, status, sent_at, tenant) VALUES
+	(1, 'instantSending', null, 'to', 'Weekly news', 'message', 'sent', '2025-07-01 10:00:00', 'acme'),
+	(2, 'delayedSending', '2035-07-13 21:58:00', 'to', 'Reminder', 'message', 'pending', null, ''),
+	(3, 'instantSending', null, 'to', 'Daily NEWS', 'message', 'sent', '2025-07-02 10:00:00', 'other'),
+	(4, 'instantSending', null, 'to', '100% news_', 'message', 'failed', null, 'acme')`)
 	require.NoError(t, err)
 
+	sentAfter := time.Date(2025, 7, 2, 0, 0, 0, 0, time.UTC)
+
 	tests := []struct {
-		name      string
-		setup     func(insertSQL string)
-		insertSQL string
-		want      []*SMTPClient.EmailMessage
-		wantErr   error
+		name     string
+		filter   api.ListFilter
+		wantIDs  []int
+		wantNext int
 	}{
 		{
-			name: "success",
-			setup: func(insertSQL string) {
-				clearData()
-				insertData(insertSQL)
-			},
-			insertSQL: `INSERT INTO schema_emails.emails (id ,type, time, "to", subject, message) VALUES
-			(1,'instantSending', null, 'to', 'subject', 'message'),
-			(2,'delayedSending', '2035-07-13 21:58:00', 'to', 'subject', 'message');`,
-			want: []*SMTPClient.EmailMessage{
-				{
-					Type:    api.KeyForInstantSending,
-					To:      "to",
-					Subject: "subject",
-					Message: "message",
-				},
-				{
-					Type:    api.KeyForDelayedSending,
-					Time:    &testTime,
-					To:      "to",
-					Subject: "subject",
-					Message: "message",
-				},
-			},
-			wantErr: nil,
+			name:     "first page",
+			filter:   api.ListFilter{Limit: 2},
+			wantIDs:  []int{1, 2},
+			wantNext: 2,
 		},
 		{
-			name: "entry not exists",
-			setup: func(insertSQL string) {
-				clearData()
-			},
-			want:    nil,
-			wantErr: pgx.ErrNoRows,
+			name:    "last page",
+			filter:  api.ListFilter{Limit: 2, AfterID: 2},
+			wantIDs: []int{3, 4},
+		},
+		{
+			name:     "descending",
+			filter:   api.ListFilter{Limit: 2, AfterID: 4, Desc: true},
+			wantIDs:  []int{3, 2},
+			wantNext: 2,
+		},
+		{
+			name:    "by type",
+			filter:  api.ListFilter{Type: api.KeyForDelayedSending},
+			wantIDs: []int{2},
+		},
+		{
+			name:    "by status",
+			filter:  api.ListFilter{Status: api.StatusSent},
+			wantIDs: []int{1, 3},
+		},
+		{
+			name:    "by subject",
+			filter:  api.ListFilter{Subject: "news"},
+			wantIDs: []int{1, 3, 4},
+		},
+		{
+			name:    "by subject with wildcards",
+			filter:  api.ListFilter{Subject: "0% news_"},
+			wantIDs: []int{4},
+		},
+		{
+			name:    "by sending time",
+			filter:  api.ListFilter{SentAfter: &sentAfter},
+			wantIDs: []int{3},
+		},
+		{
+			name:    "by tenant",
+			filter:  api.ListFilter{Tenant: "acme"},
+			wantIDs: []int{1, 4},
+		},
+		{
+			name:    "empty",
+			filter:  api.ListFilter{Status: api.StatusBounced},
+			wantIDs: []int{},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tt.setup(tt.insertSQL)
+			got, next, err := postgresService.FetchPage(ctx, &tt.filter)
+			require.NoError(t, err)
 
-			got, err := postgresService.FetchByAll(ctx)
+			ids := make([]int, 0, len(got))
+			for _, email := range got {
+				ids = append(ids, email.ID)
+			}
 
-			assert.Equal(t, tt.want, got)
-			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantIDs, ids)
+			assert.Equal(t, tt.wantNext, next)
 		})
 	}
 }
 
-func TestFetchByAllWithContext(t *testing.T) {
-	postgresService := upPostgres("postgres-for-test-TestFetchByAllWithContext", t)
+func TestFetchPageWithContext(t *testing.T) {
+	postgresService := upPostgres("postgres-for-test-TestFetchPageWithContext", t)
 
 	tests := []struct {
 		name    string
 		setup   func() context.Context
-		want    []*SMTPClient.EmailMessage
 		wantErr error
 	}{
 		{
-			name: "timeout exceeded for instant sending",
+			name: "timeout exceeded",
 			setup: func() context.Context {
 				ctx, _ := context.WithTimeout(context.Background(), 1*time.Millisecond)
 				time.Sleep(2 * time.Millisecond)
 
 				return ctx
 			},
-			want:    nil,
 			wantErr: context.DeadlineExceeded,
 		},
 		{
-			name: "context canceled for instant sending",
+			name: "context canceled",
 			setup: func() context.Context {
 				ctx, cancel := context.WithCancel(context.Background())
 				cancel()
 
 				return ctx
 			},
-			want:    nil,
 			wantErr: context.Canceled,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ctx := tt.setup()
+			got, _, err := postgresService.FetchPage(tt.setup(), &api.ListFilter{})
 
-			got, err := postgresService.FetchByAll(ctx)
+			assert.Nil(t, got)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestBuildPageQuery(t *testing.T) {
+	sentAfter := time.Date(2025, 7, 1, 3, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+	tests := []struct {
+		name      string
+		filter    api.ListFilter
+		wantWhere string
+		wantArgs  []any
+	}{
+		{
+			name:      "no filter",
+			wantWhere: ` ORDER BY id ASC LIMIT $1`,
+			wantArgs:  []any{11},
+		},
+		{
+			name: "all filters",
+			filter: api.ListFilter{
+				To:        "user@example.com",
+				Type:      api.KeyForInstantSending,
+				Status:    api.StatusSent,
+				Subject:   "50%_off",
+				SentAfter: &sentAfter,
+				AfterID:   7,
+				Desc:      true,
+			},
+			wantWhere: ` WHERE "to" = $1 AND type = $2 AND status = $3 AND subject ILIKE '%' || $4 || '%' AND sent_at >= $5` +
+				` AND id < $6 ORDER BY id DESC LIMIT $7`,
+			wantArgs: []any{"user@example.com", api.KeyForInstantSending, api.StatusSent, `50\%\_off`, sentAfter.UTC(), 7, 11},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := buildPageQuery(&tt.filter, 11)
 
+			assert.Equal(t, queryForFetchPage+tt.wantWhere, query)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	ctx := context.Background()
+
+	postgresService := upPostgres("postgres-for-test-Unsubscribe", t)
+
+	require.NoError(t, postgresService.Unsubscribe(ctx, "user@example.com", "newsletter"))
+	require.NoError(t, postgresService.Unsubscribe(ctx, "user@example.com", "newsletter"))
+
+	tests := []struct {
+		name      string
+		recipient string
+		category  string
+		want      bool
+	}{
+		{name: "unsubscribed", recipient: "user@example.com", category: "newsletter", want: true},
+		{name: "other category", recipient: "user@example.com", category: "promo", want: false},
+		{name: "other recipient", recipient: "other@example.com", category: "newsletter", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := postgresService.IsUnsubscribed(ctx, tt.recipient, tt.category)
+			require.NoError(t, err)
 			assert.Equal(t, tt.want, got)
-			assert.ErrorIs(t, err, tt.wantErr)
 		})
 	}
 }
 
+func TestSuppressions(t *testing.T) {
+	ctx := context.Background()
+
+	postgresService := upPostgres("postgres-for-test-Suppressions", t)
+
+	expired := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	suppressions := []*api.Suppression{
+		{Address: "bounced@example.com", Reason: api.SuppressionReasonHardBounce, Source: api.SuppressionSourceBounce},
+		{Address: "expired@example.com", Reason: api.SuppressionReasonManual, Source: api.SuppressionSourceAPI, ExpiresAt: &expired},
+		{Address: "temporary@example.com", Reason: api.SuppressionReasonComplaint, Source: api.SuppressionSourceAPI, ExpiresAt: &future},
+	}
+
+	for _, s := range suppressions {
+		require.NoError(t, postgresService.SaveSuppression(ctx, s))
+		assert.False(t, s.CreatedAt.IsZero())
+	}
+
+	tests := []struct {
+		name    string
+		address string
+		want    bool
+	}{
+		{name: "suppressed", address: "bounced@example.com", want: true},
+		{name: "expired", address: "expired@example.com", want: false},
+		{name: "not expired yet", address: "temporary@example.com", want: true},
+		{name: "not suppressed", address: "other@example.com", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := postgresService.IsSuppressed(ctx, tt.address)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	got, err := postgresService.FetchSuppression(ctx, "bounced@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, api.SuppressionReasonHardBounce, got.Reason)
+	assert.Equal(t, api.SuppressionSourceBounce, got.Source)
+	assert.Nil(t, got.ExpiresAt)
+
+	all, err := postgresService.FetchSuppressions(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	require.NoError(t, postgresService.SaveSuppression(ctx,
+		&api.Suppression{Address: "expired@example.com", Reason: api.SuppressionReasonManual, Source: api.SuppressionSourceAPI}))
+
+	suppressed, err := postgresService.IsSuppressed(ctx, "expired@example.com")
+	require.NoError(t, err)
+	assert.True(t, suppressed)
+
+	require.NoError(t, postgresService.DeleteSuppression(ctx, "bounced@example.com"))
+	assert.ErrorIs(t, postgresService.DeleteSuppression(ctx, "bounced@example.com"), pgx.ErrNoRows)
+
+	_, err = postgresService.FetchSuppression(ctx, "bounced@example.com")
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func TestAPIKeys(t *testing.T) {
+	ctx := context.Background()
+
+	postgresService := upPostgres("postgres-for-test-APIKeys", t)
+
+	future := time.Now().Add(time.Hour)
+
+	keys := []*api.APIKey{
+		{Name: "billing", Prefix: "ntf_billing1", Scopes: []string{api.ScopeSend, api.ScopeRead}},
+		{Name: "reports", Prefix: "ntf_reports1", Scopes: []string{api.ScopeRead}, ExpiresAt: &future},
+	}
+
+	for i, key := range keys {
+		require.NoError(t, postgresService.SaveAPIKey(ctx, key, fmt.Sprintf("hash-%d", i)))
+		assert.Equal(t, i+1, key.ID)
+		assert.False(t, key.CreatedAt.IsZero())
+	}
+
+	assert.Error(t, postgresService.SaveAPIKey(ctx, &api.APIKey{Name: "invalid", Prefix: "ntf_invalid1",
+		Scopes: []string{"delete"}}, "hash-invalid"))
+
+	got, err := postgresService.FetchAPIKeyByHash(ctx, "hash-0")
+	require.NoError(t, err)
+	assert.Equal(t, "billing", got.Name)
+	assert.Equal(t, []string{api.ScopeSend, api.ScopeRead}, got.Scopes)
+	assert.Nil(t, got.ExpiresAt)
+	assert.Nil(t, got.RevokedAt)
+
+	_, err = postgresService.FetchAPIKeyByHash(ctx, "hash-unknown")
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	email := &SMTPClient.EmailMessage{Type: api.KeyForInstantSending, To: "to", Subject: "subject", Message: "message",
+		Client: "billing", APIKeyID: got.ID, Tenant: "acme"}
+
+	id, err := postgresService.SaveEmail(ctx, email)
+	require.NoError(t, err)
+
+	notifications, err := postgresService.FetchById(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "billing", notifications[0].Client)
+	assert.Equal(t, "acme", notifications[0].Tenant)
+
+	require.NoError(t, postgresService.RevokeAPIKey(ctx, got.ID))
+	assert.ErrorIs(t, postgresService.RevokeAPIKey(ctx, got.ID), pgx.ErrNoRows)
+	assert.ErrorIs(t, postgresService.RevokeAPIKey(ctx, 100), pgx.ErrNoRows)
+
+	all, err := postgresService.FetchAPIKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.NotNil(t, all[0].RevokedAt)
+	assert.NotNil(t, all[1].ExpiresAt)
+}
+
 func upPostgres(name string, t *testing.T) *PostgresService {
 	ctx := context.Background()
 
@@ -584,6 +1079,8 @@ func upPostgres(name string, t *testing.T) *PostgresService {
 		Database: "root",
 		MaxConns: 5,
 		MinConns: 10,
+
+		MessageIDDomain: "example.com",
 	}
 
 	req := testcontainers.ContainerRequest{
@@ -1,16 +1,108 @@
 package postgresClient
 
 const (
-	// queryForSaveEmail inserts a new email into the database and returns its ID.
-	queryForSaveEmail = `INSERT INTO schema_emails.emails (type, time, "to", subject, message)
-	VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	// queryForSaveEmail inserts a new email into the database and returns its ID and Message-ID.
+	// The ID is taken from the sequence beforehand, so that the Message-ID derived from it is stored in the same statement.
+	queryForSaveEmail = `WITH next AS (SELECT nextval(pg_get_serial_sequence('schema_emails.emails', 'id')) AS id)
+	INSERT INTO schema_emails.emails
+	(id, type, time, "to", subject, message, from_address, from_name, reply_to, headers, in_reply_to, message_references, category,
+	html, track, callback_url, client, api_key_id, tenant, message_id)
+	SELECT id, $1::text, $2::timestamp, $3::text, $4::text, $5::text, $6::text, $7::text, $8::text, $9::jsonb, $10::text, $11::text[],
+	$12::text, $13::text, $14::boolean, $15::text, $17::text, NULLIF($18::integer, 0), $19::text,
+	'<notification.' || id || '@' || $16::text || '>' FROM next
+	RETURNING id, message_id`
+
+	// emailColumns is the list of columns scanned by scanNotification, the tracking events of the email are aggregated into a JSON array.
+	emailColumns = `id, type, status, time, "to", subject, message, from_address, from_name, reply_to, headers,
+	COALESCE(message_id, ''), in_reply_to, message_references, category, html, track,
+	(SELECT COALESCE(json_agg(json_build_object('type', e.type, 'url', e.url, 'created_at', e.created_at AT TIME ZONE 'utc')
+	ORDER BY e.created_at, e.id), '[]') FROM schema_emails.tracking_events e WHERE e.email_id = emails.id),
+	callback_url, client, tenant, created_at, sent_at`
 
 	// queryForFetchById selects a single email by its ID.
-	queryForFetchById = `SELECT type, time, "to", subject, message FROM schema_emails.emails WHERE id = $1`
+	queryForFetchById = `SELECT ` + emailColumns + ` FROM schema_emails.emails WHERE id = $1`
+
+	// queryForFetchByMessageID selects a single email by its Message-ID.
+	queryForFetchByMessageID = `SELECT ` + emailColumns + ` FROM schema_emails.emails WHERE message_id = $1`
 
 	// queryForFetchByEmail selects all emails sent to a specific recipient.
-	queryForFetchByEmail = `SELECT type, time, "to", subject, message FROM schema_emails.emails WHERE "to" = $1`
+	queryForFetchByEmail = `SELECT ` + emailColumns + ` FROM schema_emails.emails WHERE "to" = $1`
+
+	// queryForFetchPage selects the emails, the filter, the order and the limit are added by buildPageQuery.
+	queryForFetchPage = `SELECT ` + emailColumns + ` FROM schema_emails.emails`
+
+	// queryForUpdateStatus sets the status of the email that is not canceled, and the sending time for sent emails.
+	queryForUpdateStatus = `UPDATE schema_emails.emails SET status = $2, sent_at = COALESCE($3, sent_at)
+	WHERE id = $1 AND status <> 'canceled'`
+
+	// queryForUpdateStatusByMessageID sets the status of the sent email by its Message-ID and returns the email.
+	queryForUpdateStatusByMessageID = `UPDATE schema_emails.emails SET status = $2
+	WHERE message_id = $1 AND status = 'sent' RETURNING ` + emailColumns
+
+	// queryForCancelEmail sets the canceled status of the pending scheduled email and returns its ID.
+	queryForCancelEmail = `UPDATE schema_emails.emails SET status = 'canceled'
+		WHERE id = $1 AND status = 'pending' AND type = 'delayedSending' RETURNING id`
+
+	// queryForClaimEmail sets the sending status of the pending email and returns its ID,
+	// so that the email cannot be canceled while it is being sent.
+	queryForClaimEmail = `UPDATE schema_emails.emails SET status = 'sending' WHERE id = $1 AND status = 'pending' RETURNING id`
+
+	// queryForUnsubscribe records the opt-out of the recipient from the category, repeated opt-outs are ignored.
+	queryForUnsubscribe = `INSERT INTO schema_emails.unsubscribes (recipient, category) VALUES ($1, $2)
+	ON CONFLICT (recipient, category) DO NOTHING`
+
+	// queryForIsUnsubscribed checks whether the recipient has opted out of the category.
+	queryForIsUnsubscribed = `SELECT EXISTS (SELECT 1 FROM schema_emails.unsubscribes WHERE recipient = $1 AND category = $2)`
+
+	// suppressionColumns is the list of columns scanned by scanSuppression.
+	suppressionColumns = `address, reason, source, created_at, expires_at`
+
+	// queryForSaveSuppression adds the address to the suppression list or replaces the existing entry.
+	queryForSaveSuppression = `INSERT INTO schema_emails.suppressions (address, reason, source, expires_at) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (address) DO UPDATE SET reason = EXCLUDED.reason, source = EXCLUDED.source, expires_at = EXCLUDED.expires_at,
+	created_at = now() AT TIME ZONE 'utc'
+	RETURNING created_at`
+
+	// queryForFetchSuppression selects the suppression list entry of the address.
+	queryForFetchSuppression = `SELECT ` + suppressionColumns + ` FROM schema_emails.suppressions WHERE address = $1`
+
+	// queryForFetchSuppressions selects all suppression list entries, the newest first.
+	queryForFetchSuppressions = `SELECT ` + suppressionColumns + ` FROM schema_emails.suppressions ORDER BY created_at DESC, address`
+
+	// queryForDeleteSuppression removes the address from the suppression list.
+	queryForDeleteSuppression = `DELETE FROM schema_emails.suppressions WHERE address = $1`
+
+	// queryForIsSuppressed checks whether the address is on the suppression list and the entry has not expired.
+	queryForIsSuppressed = `SELECT EXISTS (SELECT 1 FROM schema_emails.suppressions
+	WHERE address = $1 AND (expires_at IS NULL OR expires_at > now() AT TIME ZONE 'utc'))`
+
+	// apiKeyColumns is the list of columns scanned by scanAPIKey.
+	apiKeyColumns = `id, name, prefix, scopes, created_at, expires_at, revoked_at`
+
+	// queryForSaveAPIKey inserts a new API key and returns its ID and creation time.
+	queryForSaveAPIKey = `INSERT INTO schema_emails.api_keys (name, prefix, key_hash, scopes, expires_at) VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at`
+
+	// queryForFetchAPIKeyByHash selects the API key by the hash of the key.
+	queryForFetchAPIKeyByHash = `SELECT ` + apiKeyColumns + ` FROM schema_emails.api_keys WHERE key_hash = $1`
+
+	// queryForFetchAPIKeys selects all API keys in the order they were created.
+	queryForFetchAPIKeys = `SELECT ` + apiKeyColumns + ` FROM schema_emails.api_keys ORDER BY id`
+
+	// queryForRevokeAPIKey sets the revocation time of the API key that is not revoked yet.
+	queryForRevokeAPIKey = `UPDATE schema_emails.api_keys SET revoked_at = now() AT TIME ZONE 'utc'
+	WHERE id = $1 AND revoked_at IS NULL`
+
+	// queryForSaveTrackingEvent inserts a single open or click event of the email.
+	queryForSaveTrackingEvent = `INSERT INTO schema_emails.tracking_events (email_id, type, url) VALUES ($1, $2, $3)`
+
+	// queryForSaveWebhookAttempt inserts a single delivery attempt of the webhook of the email.
+	queryForSaveWebhookAttempt = `INSERT INTO schema_emails.webhook_attempts
+	(email_id, url, status, attempt, code, error, started_at, duration_ms)
+	VALUES ($1, $2, $3, $4, NULLIF($5, 0), NULLIF($6, ''), $7, $8)`
 
-	// queryForFetchByAll selects all emails from the table.
-	queryForFetchByAll = `SELECT type, time, "to", subject, message FROM schema_emails.emails`
+	// queryForSaveAttempt inserts a single sending attempt of the email.
+	queryForSaveAttempt = `INSERT INTO schema_emails.attempts
+	(email_id, attempt, provider, class, code, error, started_at, duration_ms)
+	VALUES ($1, $2, $3, $4, NULLIF($5, 0), NULLIF($6, ''), $7, $8)`
 )
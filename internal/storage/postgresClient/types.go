@@ -9,14 +9,23 @@ import (
 	"go.uber.org/zap"
 
 	"notification/internal/SMTPClient"
+	"notification/internal/api"
 	"notification/internal/monitoring"
+	"notification/internal/webhook"
 )
 
 // DefaultPostgresTimeout defines the default timeout for PostgreSQL operations.
 const DefaultPostgresTimeout = 3 * time.Second
 
+// DefaultMessageIDDomain defines the default domain of generated Message-IDs.
+const DefaultMessageIDDomain = "localhost"
+
+// codeForeignKeyViolation is the SQLSTATE code of the foreign key violation error.
+const codeForeignKeyViolation = "23503"
+
 // Config defines the configuration parameters for the PostgresService,
 // including credentials and timeout configuration.
+// MessageIDDomain is the right part of the Message-IDs generated for saved emails.
 type Config struct {
 	Host     string        `env:"POSTGRES_HOST"`
 	Port     string        `env:"POSTGRES_PORT"`
@@ -26,23 +35,44 @@ type Config struct {
 	Timeout  time.Duration `env:"POSTGRES_TIMEOUT"`
 	MaxConns int           `env:"POSTGRES_MAX_CONNECTIONS"`
 	MinConns int           `env:"POSTGRES_MIN_CONNECTIONS"`
+
+	MessageIDDomain string `env:"MESSAGE_ID_DOMAIN"`
 }
 
 // PostgresService implements the PostgresClient interface.
 // It provides methods for storing and retrieving emails using a PostgreSQL database.
 type PostgresService struct {
-	pool    *pgxpool.Pool
-	metrics monitoring.Monitoring
-	logger  *zap.Logger
-	timeout time.Duration
+	pool            *pgxpool.Pool
+	metrics         monitoring.Monitoring
+	logger          *zap.Logger
+	timeout         time.Duration
+	messageIDDomain string
 }
 
 // PostgresClient defines an interface for storing and retrieving emails in a PostgreSQL database.
 type PostgresClient interface {
 	SaveEmail(context.Context, *SMTPClient.EmailMessage) (int, error)
-	FetchById(context.Context, int) ([]*SMTPClient.EmailMessage, error)
-	FetchByEmail(context.Context, string) ([]*SMTPClient.EmailMessage, error)
-	FetchByAll(context.Context) ([]*SMTPClient.EmailMessage, error)
+	FetchById(context.Context, int) ([]*api.Notification, error)
+	FetchByMessageID(context.Context, string) ([]*api.Notification, error)
+	FetchByEmail(context.Context, string) ([]*api.Notification, error)
+	FetchPage(context.Context, *api.ListFilter) ([]*api.Notification, int, error)
+	UpdateStatus(context.Context, int, string, []SMTPClient.Attempt) error
+	UpdateStatusByMessageID(context.Context, string, string) (*api.Notification, error)
+	CancelEmail(context.Context, int) error
+	ClaimEmail(context.Context, int) error
+	Unsubscribe(context.Context, string, string) error
+	IsUnsubscribed(context.Context, string, string) (bool, error)
+	SaveSuppression(context.Context, *api.Suppression) error
+	FetchSuppression(context.Context, string) (*api.Suppression, error)
+	FetchSuppressions(context.Context) ([]*api.Suppression, error)
+	DeleteSuppression(context.Context, string) error
+	IsSuppressed(context.Context, string) (bool, error)
+	SaveTrackingEvent(context.Context, int, string, string) error
+	SaveWebhookAttempt(context.Context, *webhook.Attempt) error
+	SaveAPIKey(context.Context, *api.APIKey, string) error
+	FetchAPIKeyByHash(context.Context, string) (*api.APIKey, error)
+	FetchAPIKeys(context.Context) ([]*api.APIKey, error)
+	RevokeAPIKey(context.Context, int) error
 	Close()
 }
 
@@ -59,21 +89,132 @@ func (mps *MockPostgresService) SaveEmail(ctx context.Context, email *SMTPClient
 }
 
 // FetchById is a mock implementation.
-func (mps *MockPostgresService) FetchById(ctx context.Context, id int) ([]*SMTPClient.EmailMessage, error) {
+func (mps *MockPostgresService) FetchById(ctx context.Context, id int) ([]*api.Notification, error) {
 	args := mps.Called(ctx, id)
-	return args.Get(0).([]*SMTPClient.EmailMessage), args.Error(1)
+	return args.Get(0).([]*api.Notification), args.Error(1)
+}
+
+// FetchByMessageID is a mock implementation.
+func (mps *MockPostgresService) FetchByMessageID(ctx context.Context, messageID string) ([]*api.Notification, error) {
+	args := mps.Called(ctx, messageID)
+	return args.Get(0).([]*api.Notification), args.Error(1)
 }
 
 // FetchByEmail is a mock implementation.
-func (mps *MockPostgresService) FetchByEmail(ctx context.Context, email string) ([]*SMTPClient.EmailMessage, error) {
+func (mps *MockPostgresService) FetchByEmail(ctx context.Context, email string) ([]*api.Notification, error) {
 	args := mps.Called(ctx, email)
-	return args.Get(0).([]*SMTPClient.EmailMessage), args.Error(1)
+	return args.Get(0).([]*api.Notification), args.Error(1)
+}
+
+// FetchPage is a mock implementation.
+func (mps *MockPostgresService) FetchPage(ctx context.Context, filter *api.ListFilter) ([]*api.Notification, int, error) {
+	args := mps.Called(ctx, filter)
+	return args.Get(0).([]*api.Notification), args.Int(1), args.Error(2)
+}
+
+// UpdateStatus is a mock implementation.
+func (mps *MockPostgresService) UpdateStatus(ctx context.Context, id int, status string, attempts []SMTPClient.Attempt) error {
+	args := mps.Called(ctx, id, status, attempts)
+	return args.Error(0)
+}
+
+// UpdateStatusByMessageID is a mock implementation.
+func (mps *MockPostgresService) UpdateStatusByMessageID(ctx context.Context, messageID, status string) (*api.Notification, error) {
+	args := mps.Called(ctx, messageID, status)
+	email, _ := args.Get(0).(*api.Notification)
+	return email, args.Error(1)
 }
 
-// FetchByAll is a mock implementation.
-func (mps *MockPostgresService) FetchByAll(ctx context.Context) ([]*SMTPClient.EmailMessage, error) {
+// CancelEmail is a mock implementation.
+func (mps *MockPostgresService) CancelEmail(ctx context.Context, id int) error {
+	args := mps.Called(ctx, id)
+	return args.Error(0)
+}
+
+// ClaimEmail is a mock implementation.
+func (mps *MockPostgresService) ClaimEmail(ctx context.Context, id int) error {
+	args := mps.Called(ctx, id)
+	return args.Error(0)
+}
+
+// Unsubscribe is a mock implementation.
+func (mps *MockPostgresService) Unsubscribe(ctx context.Context, recipient, category string) error {
+	args := mps.Called(ctx, recipient, category)
+	return args.Error(0)
+}
+
+// IsUnsubscribed is a mock implementation.
+func (mps *MockPostgresService) IsUnsubscribed(ctx context.Context, recipient, category string) (bool, error) {
+	args := mps.Called(ctx, recipient, category)
+	return args.Bool(0), args.Error(1)
+}
+
+// SaveSuppression is a mock implementation.
+func (mps *MockPostgresService) SaveSuppression(ctx context.Context, suppression *api.Suppression) error {
+	args := mps.Called(ctx, suppression)
+	return args.Error(0)
+}
+
+// FetchSuppression is a mock implementation.
+func (mps *MockPostgresService) FetchSuppression(ctx context.Context, address string) (*api.Suppression, error) {
+	args := mps.Called(ctx, address)
+	suppression, _ := args.Get(0).(*api.Suppression)
+	return suppression, args.Error(1)
+}
+
+// FetchSuppressions is a mock implementation.
+func (mps *MockPostgresService) FetchSuppressions(ctx context.Context) ([]*api.Suppression, error) {
 	args := mps.Called(ctx)
-	return args.Get(0).([]*SMTPClient.EmailMessage), args.Error(1)
+	return args.Get(0).([]*api.Suppression), args.Error(1)
+}
+
+// DeleteSuppression is a mock implementation.
+func (mps *MockPostgresService) DeleteSuppression(ctx context.Context, address string) error {
+	args := mps.Called(ctx, address)
+	return args.Error(0)
+}
+
+// IsSuppressed is a mock implementation.
+func (mps *MockPostgresService) IsSuppressed(ctx context.Context, address string) (bool, error) {
+	args := mps.Called(ctx, address)
+	return args.Bool(0), args.Error(1)
+}
+
+// SaveTrackingEvent is a mock implementation.
+func (mps *MockPostgresService) SaveTrackingEvent(ctx context.Context, id int, eventType, url string) error {
+	args := mps.Called(ctx, id, eventType, url)
+	return args.Error(0)
+}
+
+// SaveWebhookAttempt is a mock implementation.
+func (mps *MockPostgresService) SaveWebhookAttempt(ctx context.Context, attempt *webhook.Attempt) error {
+	args := mps.Called(ctx, attempt)
+	return args.Error(0)
+}
+
+// SaveAPIKey is a mock implementation.
+func (mps *MockPostgresService) SaveAPIKey(ctx context.Context, key *api.APIKey, hash string) error {
+	args := mps.Called(ctx, key, hash)
+	return args.Error(0)
+}
+
+// FetchAPIKeyByHash is a mock implementation.
+func (mps *MockPostgresService) FetchAPIKeyByHash(ctx context.Context, hash string) (*api.APIKey, error) {
+	args := mps.Called(ctx, hash)
+	key, _ := args.Get(0).(*api.APIKey)
+	return key, args.Error(1)
+}
+
+// FetchAPIKeys is a mock implementation.
+func (mps *MockPostgresService) FetchAPIKeys(ctx context.Context) ([]*api.APIKey, error) {
+	args := mps.Called(ctx)
+	return args.Get(0).([]*api.APIKey), args.Error(1)
+}
+
+// RevokeAPIKey is a mock implementation.
+func (mps *MockPostgresService) RevokeAPIKey(ctx context.Context, id int) error {
+	args := mps.Called(ctx, id)
+	return args.Error(0)
 }
 
 // Close is a mock implementation.
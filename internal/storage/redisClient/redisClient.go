@@ -106,6 +106,141 @@ func (rc *RedisCluster) CheckRedis(ctx context.Context) ([]string, error) {
 	return res, nil
 }
 
+// Publish sends the message to the subscribers of the Redis channel on all service instances.
+func (rc *RedisCluster) Publish(ctx context.Context, channel string, message []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, rc.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	if err := rc.cluster.Publish(ctx, channel, message).Err(); err != nil {
+		return rc.processContextError("Publish", err)
+	}
+
+	rc.metrics.Observe("Publish", start)
+	rc.metrics.IncSuccess("Publish")
+
+	return nil
+}
+
+// Subscribe subscribes to the Redis channel and returns the channel of received messages.
+// The subscription is closed and the returned channel is closed when ctx is done.
+func (rc *RedisCluster) Subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	pubsub := rc.cluster.Subscribe(ctx, channel)
+
+	receiveCtx, cancel := context.WithTimeout(ctx, rc.timeout)
+	defer cancel()
+
+	if _, err := pubsub.Receive(receiveCtx); err != nil {
+		_ = pubsub.Close()
+		return nil, rc.processContextError("Subscribe", err)
+	}
+
+	rc.logger.Info("Subscribe: subscribed to redis channel", zap.String("channel", channel))
+
+	messages := make(chan string)
+
+	go func() {
+		defer close(messages)
+		defer pubsub.Close()
+
+		received := pubsub.Channel()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case msg, ok := <-received:
+				if !ok {
+					return
+				}
+
+				select {
+				case messages <- msg.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return messages, nil
+}
+
+// ReserveIdempotencyKey stores the empty response for the idempotency key, if the key is not stored yet,
+// and reports whether it was stored. The reservation expires after ttl, so that the key of a request
+// interrupted by a crash can be used again.
+func (rc *RedisCluster) ReserveIdempotencyKey(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, rc.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	reserved, err := rc.cluster.SetNX(ctx, key, "", ttl).Result()
+	if err != nil {
+		return false, rc.processContextError("ReserveIdempotencyKey", err)
+	}
+
+	rc.metrics.Observe("ReserveIdempotencyKey", start)
+	rc.metrics.IncSuccess("ReserveIdempotencyKey")
+
+	return reserved, nil
+}
+
+// FetchIdempotentResponse returns the response stored for the idempotency key.
+// The response is empty while the request with the key is in progress, or if the key is not stored.
+func (rc *RedisCluster) FetchIdempotentResponse(ctx context.Context, key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, rc.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	response, err := rc.cluster.Get(ctx, key).Bytes()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, rc.processContextError("FetchIdempotentResponse", err)
+	}
+
+	rc.metrics.Observe("FetchIdempotentResponse", start)
+	rc.metrics.IncSuccess("FetchIdempotentResponse")
+
+	return response, nil
+}
+
+// SaveIdempotentResponse stores the response of the request with the idempotency key for ttl.
+func (rc *RedisCluster) SaveIdempotentResponse(ctx context.Context, key string, response []byte, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, rc.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	if err := rc.cluster.Set(ctx, key, response, ttl).Err(); err != nil {
+		return rc.processContextError("SaveIdempotentResponse", err)
+	}
+
+	rc.metrics.Observe("SaveIdempotentResponse", start)
+	rc.metrics.IncSuccess("SaveIdempotentResponse")
+
+	return nil
+}
+
+// ReleaseIdempotencyKey deletes the idempotency key, so that the request with the key can be retried.
+func (rc *RedisCluster) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, rc.timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	if err := rc.cluster.Del(ctx, key).Err(); err != nil {
+		return rc.processContextError("ReleaseIdempotencyKey", err)
+	}
+
+	rc.metrics.Observe("ReleaseIdempotencyKey", start)
+	rc.metrics.IncSuccess("ReleaseIdempotencyKey")
+
+	return nil
+}
+
 // Close shuts down all Redis Cluster nodes.
 func (rc *RedisCluster) Close() error {
 	ctx, cancel := context.WithTimeout(context.Background(), rc.shutdownTimeout)
@@ -144,11 +279,25 @@ func (rc *RedisCluster) parseAndConvertData(email *SMTPClient.EmailMessage) ([]b
 	t := strconv.FormatInt(unixTime, 10)
 
 	jsonStruct := SMTPClient.TempEmailMessage{
-		Type:    email.Type,
-		Time:    t,
-		To:      email.To,
-		Subject: email.Subject,
-		Message: email.Message,
+		Id:          email.Id,
+		Type:        email.Type,
+		Time:        t,
+		To:          email.To,
+		Subject:     email.Subject,
+		Message:     email.Message,
+		From:        email.From,
+		FromName:    email.FromName,
+		ReplyTo:     email.ReplyTo,
+		Headers:     email.Headers,
+		MessageID:   email.MessageID,
+		InReplyTo:   email.InReplyTo,
+		References:  email.References,
+		Category:    email.Category,
+		HTML:        email.HTML,
+		Track:       email.Track,
+		CallbackURL: email.CallbackURL,
+		Tenant:      email.Tenant,
+		Retry:       email.Retry,
 	}
 
 	jsonEmail, err := json.Marshal(jsonStruct)
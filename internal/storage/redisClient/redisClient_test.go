@@ -239,6 +239,121 @@ func TestFailedConnection(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to connect to redis")
 }
 
+func TestPublish(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+	}{
+		{
+			name: "success",
+		},
+		{
+			name:    "redis error",
+			err:     fmt.Errorf("connection refused"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock := redismock.NewClusterMock()
+			rc := RedisCluster{
+				cluster: db,
+				metrics: monitoring.NewNop(),
+				logger:  zap.NewNop(),
+				timeout: time.Second,
+			}
+
+			if tt.err != nil {
+				mock.ExpectPublish("events", []byte("message")).SetErr(tt.err)
+			} else {
+				mock.ExpectPublish("events", []byte("message")).SetVal(1)
+			}
+
+			err := rc.Publish(context.Background(), "events", []byte("message"))
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	db, mock := redismock.NewClusterMock()
+	rc := RedisCluster{
+		cluster: db,
+		metrics: monitoring.NewNop(),
+		logger:  zap.NewNop(),
+		timeout: time.Second,
+	}
+
+	ctx := context.Background()
+
+	mock.ExpectSetNX("idempotency:key", "", time.Minute).SetVal(true)
+	mock.ExpectSetNX("idempotency:key", "", time.Minute).SetVal(false)
+	mock.ExpectGet("idempotency:key").SetVal("")
+	mock.ExpectSet("idempotency:key", []byte("response"), time.Hour).SetVal("OK")
+	mock.ExpectGet("idempotency:key").SetVal("response")
+	mock.ExpectDel("idempotency:key").SetVal(1)
+	mock.ExpectGet("idempotency:key").RedisNil()
+	mock.ExpectSetNX("idempotency:key", "", time.Minute).SetErr(fmt.Errorf("connection refused"))
+
+	reserved, err := rc.ReserveIdempotencyKey(ctx, "idempotency:key", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	reserved, err = rc.ReserveIdempotencyKey(ctx, "idempotency:key", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, reserved)
+
+	response, err := rc.FetchIdempotentResponse(ctx, "idempotency:key")
+	require.NoError(t, err)
+	assert.Empty(t, response)
+
+	require.NoError(t, rc.SaveIdempotentResponse(ctx, "idempotency:key", []byte("response"), time.Hour))
+
+	response, err = rc.FetchIdempotentResponse(ctx, "idempotency:key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("response"), response)
+
+	require.NoError(t, rc.ReleaseIdempotencyKey(ctx, "idempotency:key"))
+
+	response, err = rc.FetchIdempotentResponse(ctx, "idempotency:key")
+	require.NoError(t, err)
+	assert.Empty(t, response)
+
+	_, err = rc.ReserveIdempotencyKey(ctx, "idempotency:key", time.Minute)
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSubscribe(t *testing.T) {
+	addrs := upRedisCluster(context.Background(), "TestSubscribe", 3, t)
+
+	rc, err := New(context.Background(), &Config{Addrs: addrs}, monitoring.NewNop(), zap.NewNop())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	messages, err := rc.Subscribe(ctx, "events")
+	require.NoError(t, err)
+
+	require.NoError(t, rc.Publish(context.Background(), "events", []byte("message")))
+
+	select {
+	case got := <-messages:
+		assert.Equal(t, "message", got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not received in time")
+	}
+
+	cancel()
+
+	_, ok := <-messages
+	assert.False(t, ok)
+}
+
 func TestClose(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -296,6 +411,35 @@ func TestParseAndConvertTime(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name: "success with sender identity and custom headers",
+			email: &SMTPClient.EmailMessage{
+				Type:     api.KeyForDelayedSending,
+				Time:     &testTime,
+				To:       "test@gmail.com",
+				Subject:  "subject",
+				Message:  "message",
+				From:     "support@example.com",
+				FromName: "Support",
+				ReplyTo:  "help@example.com",
+				Headers:  map[string]string{"X-Campaign": "spring"},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "success with thread",
+			email: &SMTPClient.EmailMessage{
+				Type:       api.KeyForDelayedSending,
+				Time:       &testTime,
+				To:         "test@gmail.com",
+				Subject:    "subject",
+				Message:    "message",
+				MessageID:  "<notification.2@example.com>",
+				InReplyTo:  "<notification.1@example.com>",
+				References: []string{"<notification.1@example.com>"},
+			},
+			wantErr: nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -315,6 +459,13 @@ func TestParseAndConvertTime(t *testing.T) {
 			assert.Equal(t, tt.email.To, resultStruct.To)
 			assert.Equal(t, tt.email.Subject, resultStruct.Subject)
 			assert.Equal(t, tt.email.Message, resultStruct.Message)
+			assert.Equal(t, tt.email.From, resultStruct.From)
+			assert.Equal(t, tt.email.FromName, resultStruct.FromName)
+			assert.Equal(t, tt.email.ReplyTo, resultStruct.ReplyTo)
+			assert.Equal(t, tt.email.Headers, resultStruct.Headers)
+			assert.Equal(t, tt.email.MessageID, resultStruct.MessageID)
+			assert.Equal(t, tt.email.InReplyTo, resultStruct.InReplyTo)
+			assert.Equal(t, tt.email.References, resultStruct.References)
 
 		})
 	}
@@ -35,10 +35,18 @@ type RedisCluster struct {
 	shutdownTimeout time.Duration
 }
 
-// RedisClient defines an interface for saving and retrieving emails in a Redis database.
+// RedisClient defines an interface for saving and retrieving emails in a Redis database,
+// for exchanging messages between service instances with Redis pub/sub,
+// and for storing the responses of the requests with idempotency keys.
 type RedisClient interface {
 	AddDelayedEmail(context.Context, *SMTPClient.EmailMessage) error
 	CheckRedis(context.Context) ([]string, error)
+	Publish(context.Context, string, []byte) error
+	Subscribe(context.Context, string) (<-chan string, error)
+	ReserveIdempotencyKey(context.Context, string, time.Duration) (bool, error)
+	FetchIdempotentResponse(context.Context, string) ([]byte, error)
+	SaveIdempotentResponse(context.Context, string, []byte, time.Duration) error
+	ReleaseIdempotencyKey(context.Context, string) error
 	Close() error
 }
 
@@ -60,6 +68,44 @@ func (mrc *MockRedisClient) CheckRedis(ctx context.Context) ([]string, error) {
 	return args.Get(0).([]string), args.Error(1)
 }
 
+// Publish is a mock implementation.
+func (mrc *MockRedisClient) Publish(ctx context.Context, channel string, message []byte) error {
+	args := mrc.Called(ctx, channel, message)
+	return args.Error(0)
+}
+
+// Subscribe is a mock implementation.
+func (mrc *MockRedisClient) Subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	args := mrc.Called(ctx, channel)
+	messages, _ := args.Get(0).(<-chan string)
+	return messages, args.Error(1)
+}
+
+// ReserveIdempotencyKey is a mock implementation.
+func (mrc *MockRedisClient) ReserveIdempotencyKey(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	args := mrc.Called(ctx, key, ttl)
+	return args.Bool(0), args.Error(1)
+}
+
+// FetchIdempotentResponse is a mock implementation.
+func (mrc *MockRedisClient) FetchIdempotentResponse(ctx context.Context, key string) ([]byte, error) {
+	args := mrc.Called(ctx, key)
+	response, _ := args.Get(0).([]byte)
+	return response, args.Error(1)
+}
+
+// SaveIdempotentResponse is a mock implementation.
+func (mrc *MockRedisClient) SaveIdempotentResponse(ctx context.Context, key string, response []byte, ttl time.Duration) error {
+	args := mrc.Called(ctx, key, response, ttl)
+	return args.Error(0)
+}
+
+// ReleaseIdempotencyKey is a mock implementation.
+func (mrc *MockRedisClient) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	args := mrc.Called(ctx, key)
+	return args.Error(0)
+}
+
 // Close is a mock implementation.
 func (mrc *MockRedisClient) Close() error {
 	args := mrc.Called()
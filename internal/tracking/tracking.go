@@ -0,0 +1,170 @@
+package tracking
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// kindOpen prefixes the payload of tracking pixel tokens.
+	kindOpen = 'o'
+
+	// kindClick prefixes the payload of tracking link tokens.
+	kindClick = 'c'
+)
+
+// Pixel is the transparent 1x1 GIF image served by the tracking pixel endpoint.
+var Pixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// New creates and returns a new Tracker instance.
+// It returns nil if the secret is not set, so that tracking is disabled.
+func New(config *Config) (*Tracker, error) {
+	if config.Secret == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(config.BaseURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, ErrConfig
+	}
+
+	return &Tracker{
+		secret:  []byte(config.Secret),
+		baseURL: strings.TrimRight(config.BaseURL, "/"),
+	}, nil
+}
+
+// Instrument rewrites the absolute http(s) links of the HTML body of the notification with the ID
+// to go through the click tracking endpoint, and adds the tracking pixel before the closing body tag
+// or at the end of the body. Links to the service itself are left unchanged.
+// The body is returned unchanged if tracking is disabled or the notification has no ID.
+func (t *Tracker) Instrument(id int, body string) string {
+	if t == nil || id <= 0 {
+		return body
+	}
+
+	body = linkPattern.ReplaceAllStringFunc(body, func(tag string) string {
+		m := linkPattern.FindStringSubmatch(tag)
+
+		target := html.UnescapeString(m[3])
+		if strings.HasPrefix(target, t.baseURL+"/") {
+			return tag
+		}
+
+		return m[1] + m[2] + t.ClickURL(id, target) + m[2]
+	})
+
+	pixel := `<img src="` + t.OpenURL(id) + `" width="1" height="1" alt="" style="border:0;width:1px;height:1px">`
+
+	if loc := bodyEndPattern.FindStringIndex(body); loc != nil {
+		return body[:loc[0]] + pixel + body[loc[0]:]
+	}
+
+	return body + pixel
+}
+
+// OpenURL returns the URL of the tracking pixel of the notification.
+func (t *Tracker) OpenURL(id int) string {
+	return t.baseURL + "/track/open/" + t.token(openPayload(id))
+}
+
+// ClickURL returns the tracking link of the notification redirecting to the target URL.
+func (t *Tracker) ClickURL(id int, target string) string {
+	return t.baseURL + "/track/click/" + t.token(clickPayload(id, target))
+}
+
+// ParseOpen verifies the tracking pixel token and returns the ID of the notification.
+func (t *Tracker) ParseOpen(token string) (int, error) {
+	payload, err := t.verify(token, kindOpen)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := strconv.Atoi(string(payload))
+	if err != nil || id <= 0 {
+		return 0, ErrInvalidToken
+	}
+
+	return id, nil
+}
+
+// ParseClick verifies the tracking link token and returns the ID of the notification and the target URL.
+func (t *Tracker) ParseClick(token string) (int, string, error) {
+	payload, err := t.verify(token, kindClick)
+	if err != nil {
+		return 0, "", err
+	}
+
+	idBytes, target, found := bytes.Cut(payload, []byte{0})
+	if !found {
+		return 0, "", ErrInvalidToken
+	}
+
+	id, err := strconv.Atoi(string(idBytes))
+	if err != nil || id <= 0 {
+		return 0, "", ErrInvalidToken
+	}
+
+	u, err := url.Parse(string(target))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return 0, "", ErrInvalidToken
+	}
+
+	return id, string(target), nil
+}
+
+// openPayload returns the payload of the tracking pixel token.
+func openPayload(id int) []byte {
+	return append([]byte{kindOpen}, strconv.Itoa(id)...)
+}
+
+// clickPayload returns the payload of the tracking link token.
+func clickPayload(id int, target string) []byte {
+	payload := append([]byte{kindClick}, strconv.Itoa(id)...)
+	payload = append(payload, 0)
+
+	return append(payload, target...)
+}
+
+// token returns the URL safe token with the payload and its signature.
+// The token contains no dots, so it is not affected by URL format middleware.
+func (t *Tracker) token(payload []byte) string {
+	return base64.RawURLEncoding.EncodeToString(append(payload, t.sign(payload)...))
+}
+
+// verify checks the signature and the kind of the token, and returns its payload without the kind prefix.
+func (t *Tracker) verify(token string, kind byte) ([]byte, error) {
+	if t == nil {
+		return nil, ErrInvalidToken
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) <= sha256.Size+1 {
+		return nil, ErrInvalidToken
+	}
+
+	payload, mac := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	if !hmac.Equal(mac, t.sign(payload)) || payload[0] != kind {
+		return nil, ErrInvalidToken
+	}
+
+	return payload[1:], nil
+}
+
+// sign returns the HMAC-SHA256 signature of the payload.
+func (t *Tracker) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write(payload)
+
+	return mac.Sum(nil)
+}
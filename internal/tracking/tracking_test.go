@@ -0,0 +1,134 @@
+package tracking
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantNil bool
+		wantErr error
+	}{
+		{
+			name:    "disabled without secret",
+			config:  Config{BaseURL: "https://notify.example.com"},
+			wantNil: true,
+		},
+		{
+			name:   "enabled",
+			config: Config{Secret: "secret", BaseURL: "https://notify.example.com/"},
+		},
+		{
+			name:    "missing base URL",
+			config:  Config{Secret: "secret"},
+			wantErr: ErrConfig,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, err := New(&tt.config)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantNil, tracker == nil)
+		})
+	}
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	tracker, err := New(&Config{Secret: "secret", BaseURL: "https://notify.example.com"})
+	require.NoError(t, err)
+
+	openURL := tracker.OpenURL(42)
+	require.True(t, strings.HasPrefix(openURL, "https://notify.example.com/track/open/"))
+
+	openToken := strings.TrimPrefix(openURL, "https://notify.example.com/track/open/")
+	assert.NotContains(t, openToken, ".")
+
+	id, err := tracker.ParseOpen(openToken)
+	require.NoError(t, err)
+	assert.Equal(t, 42, id)
+
+	clickURL := tracker.ClickURL(42, "https://example.com/page?a=1&b=2")
+	require.True(t, strings.HasPrefix(clickURL, "https://notify.example.com/track/click/"))
+
+	clickToken := strings.TrimPrefix(clickURL, "https://notify.example.com/track/click/")
+
+	id, target, err := tracker.ParseClick(clickToken)
+	require.NoError(t, err)
+	assert.Equal(t, 42, id)
+	assert.Equal(t, "https://example.com/page?a=1&b=2", target)
+
+	_, err = tracker.ParseOpen(clickToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, _, err = tracker.ParseClick(openToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	other, err := New(&Config{Secret: "other", BaseURL: "https://notify.example.com"})
+	require.NoError(t, err)
+
+	_, err = other.ParseOpen(openToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	var disabled *Tracker
+
+	_, err = disabled.ParseOpen(openToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = tracker.ParseOpen("invalid")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestInstrument(t *testing.T) {
+	tracker, err := New(&Config{Secret: "secret", BaseURL: "https://notify.example.com"})
+	require.NoError(t, err)
+
+	body := `<html><body><p>Hello</p>` +
+		`<a href="https://example.com/a?x=1&amp;y=2">A</a> ` +
+		`<A class="btn" HREF='http://example.com/b'>B</A> ` +
+		`<a href="mailto:support@example.com">Mail</a> ` +
+		`<a href="https://notify.example.com/unsubscribe/token">Unsubscribe</a>` +
+		`</body></html>`
+
+	got := tracker.Instrument(7, body)
+
+	clicks := regexp.MustCompile(`https://notify\.example\.com/track/click/([A-Za-z0-9_-]+)`).FindAllStringSubmatch(got, -1)
+	require.Len(t, clicks, 2)
+
+	var targets []string
+	for _, m := range clicks {
+		id, target, err := tracker.ParseClick(m[1])
+		require.NoError(t, err)
+		assert.Equal(t, 7, id)
+
+		targets = append(targets, target)
+	}
+
+	assert.Equal(t, []string{"https://example.com/a?x=1&y=2", "http://example.com/b"}, targets)
+
+	assert.Contains(t, got, `<A class="btn" HREF='https://notify.example.com/track/click/`)
+	assert.Contains(t, got, `href="mailto:support@example.com"`)
+	assert.Contains(t, got, `href="https://notify.example.com/unsubscribe/token"`)
+	assert.True(t, strings.HasSuffix(got, `style="border:0;width:1px;height:1px"></body></html>`))
+	assert.Contains(t, got, tracker.OpenURL(7))
+
+	withoutBody := tracker.Instrument(7, "<p>Hello</p>")
+	assert.Equal(t, `<p>Hello</p><img src="`+tracker.OpenURL(7)+`" width="1" height="1" alt="" style="border:0;width:1px;height:1px">`,
+		withoutBody)
+
+	var disabled *Tracker
+	assert.Equal(t, body, disabled.Instrument(7, body))
+	assert.Equal(t, body, tracker.Instrument(0, body))
+}
@@ -0,0 +1,37 @@
+package tracking
+
+import (
+	"errors"
+	"regexp"
+)
+
+var (
+	// ErrInvalidToken indicates that the tracking token is malformed or its signature does not match.
+	ErrInvalidToken = errors.New("Parse: invalid tracking token")
+
+	// ErrConfig indicates that the secret is set, but the base URL of the tracking endpoints is missing or invalid.
+	ErrConfig = errors.New("New: tracking base URL must be an absolute http(s) URL")
+)
+
+// Config defines the configuration of open and click tracking.
+// Secret is the key used to sign the tracking links, if it is empty, tracking is disabled for all emails.
+// BaseURL is the public URL of the service, the tracking endpoints are BaseURL/track/open/{token}
+// and BaseURL/track/click/{token}.
+type Config struct {
+	Secret  string `env:"TRACKING_SECRET"`
+	BaseURL string `env:"TRACKING_BASE_URL"`
+}
+
+// Tracker adds the tracking pixel and tracking links to HTML emails and verifies the tracking tokens.
+// The nil *Tracker is valid: it leaves emails unchanged and rejects all tokens.
+type Tracker struct {
+	secret  []byte
+	baseURL string
+}
+
+// linkPattern matches absolute http(s) links in href attributes of anchor tags.
+// The groups are the part of the tag before the link, the quote and the link itself.
+var linkPattern = regexp.MustCompile(`(?i)(<a\s[^>]*?\bhref\s*=\s*)(["'])(https?://[^"'\s>]+)["']`)
+
+// bodyEndPattern matches the closing body tag, the tracking pixel is inserted before it.
+var bodyEndPattern = regexp.MustCompile(`(?i)</body\s*>`)
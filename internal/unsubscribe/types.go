@@ -0,0 +1,31 @@
+package unsubscribe
+
+import (
+	"errors"
+)
+
+// OneClick is the value of the List-Unsubscribe-Post header, see RFC 8058 section 3.1.
+const OneClick = "List-Unsubscribe=One-Click"
+
+var (
+	// ErrInvalidToken indicates that the unsubscribe token is malformed or its signature does not match.
+	ErrInvalidToken = errors.New("Parse: invalid unsubscribe token")
+
+	// ErrConfig indicates that the secret is set, but the base URL of the unsubscribe endpoint is missing or invalid.
+	ErrConfig = errors.New("New: unsubscribe base URL must be an absolute http(s) URL")
+)
+
+// Config defines the configuration of unsubscribe links.
+// Secret is the key used to sign the tokens, if it is empty, the List-Unsubscribe headers are not added.
+// BaseURL is the public URL of the service, the unsubscribe endpoint is BaseURL/unsubscribe/{token}.
+type Config struct {
+	Secret  string `env:"UNSUBSCRIBE_SECRET"`
+	BaseURL string `env:"UNSUBSCRIBE_BASE_URL"`
+}
+
+// Tokens issues and verifies signed unsubscribe tokens identifying the recipient and the category of emails.
+// The nil *Tokens is valid: it issues no headers and rejects all tokens.
+type Tokens struct {
+	secret  []byte
+	baseURL string
+}
@@ -0,0 +1,97 @@
+package unsubscribe
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"notification/internal/api"
+)
+
+// New creates and returns a new Tokens instance.
+// It returns nil if the secret is not set, so that unsubscribe links are disabled.
+func New(config *Config) (*Tokens, error) {
+	if config.Secret == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(config.BaseURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, ErrConfig
+	}
+
+	return &Tokens{
+		secret:  []byte(config.Secret),
+		baseURL: strings.TrimRight(config.BaseURL, "/"),
+	}, nil
+}
+
+// Applies reports whether recipients can unsubscribe from the category.
+// Transactional emails (the empty category) are always sent.
+func Applies(category string) bool {
+	return category != "" && category != api.CategoryTransactional
+}
+
+// Recipient returns the normalized address of the recipient used to record opt-outs.
+// If the address cannot be parsed, it is only lowercased.
+func Recipient(to string) string {
+	if addr, err := mail.ParseAddress(to); err == nil {
+		to = addr.Address
+	}
+
+	return strings.ToLower(strings.TrimSpace(to))
+}
+
+// Token returns the token identifying the recipient and the category.
+// The token is URL safe and contains no dots, so it is not affected by URL format middleware.
+func (t *Tokens) Token(recipient, category string) string {
+	payload := []byte(Recipient(recipient) + "\x00" + category)
+
+	return base64.RawURLEncoding.EncodeToString(append(payload, t.sign(payload)...))
+}
+
+// Parse verifies the token and returns the recipient and the category it identifies.
+func (t *Tokens) Parse(token string) (recipient, category string, err error) {
+	if t == nil {
+		return "", "", ErrInvalidToken
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) <= sha256.Size {
+		return "", "", ErrInvalidToken
+	}
+
+	payload, mac := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	if !hmac.Equal(mac, t.sign(payload)) {
+		return "", "", ErrInvalidToken
+	}
+
+	recipientBytes, categoryBytes, found := bytes.Cut(payload, []byte{0})
+	if !found || len(recipientBytes) == 0 || !Applies(string(categoryBytes)) {
+		return "", "", ErrInvalidToken
+	}
+
+	return string(recipientBytes), string(categoryBytes), nil
+}
+
+// Header returns the value of the List-Unsubscribe header for the recipient and the category,
+// or an empty string if unsubscribe links are disabled or the category is transactional.
+func (t *Tokens) Header(recipient, category string) string {
+	if t == nil || !Applies(category) {
+		return ""
+	}
+
+	return "<" + t.baseURL + "/unsubscribe/" + t.Token(recipient, category) + ">"
+}
+
+// sign returns the HMAC-SHA256 signature of the payload.
+func (t *Tokens) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write(payload)
+
+	return mac.Sum(nil)
+}
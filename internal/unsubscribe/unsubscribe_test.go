@@ -0,0 +1,125 @@
+package unsubscribe
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantNil bool
+		wantErr error
+	}{
+		{
+			name:    "disabled without secret",
+			config:  Config{BaseURL: "https://notify.example.com"},
+			wantNil: true,
+		},
+		{
+			name:   "enabled",
+			config: Config{Secret: "secret", BaseURL: "https://notify.example.com/"},
+		},
+		{
+			name:    "missing base URL",
+			config:  Config{Secret: "secret"},
+			wantErr: ErrConfig,
+		},
+		{
+			name:    "relative base URL",
+			config:  Config{Secret: "secret", BaseURL: "/notify"},
+			wantErr: ErrConfig,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := New(&tt.config)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantNil, tokens == nil)
+		})
+	}
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	tokens, err := New(&Config{Secret: "secret", BaseURL: "https://notify.example.com"})
+	require.NoError(t, err)
+
+	token := tokens.Token("John <John.Doe@Example.com>", "newsletter")
+	assert.NotContains(t, token, ".")
+
+	recipient, category, err := tokens.Parse(token)
+	require.NoError(t, err)
+	assert.Equal(t, "john.doe@example.com", recipient)
+	assert.Equal(t, "newsletter", category)
+}
+
+func TestParseInvalidToken(t *testing.T) {
+	tokens, err := New(&Config{Secret: "secret", BaseURL: "https://notify.example.com"})
+	require.NoError(t, err)
+
+	other, err := New(&Config{Secret: "other", BaseURL: "https://notify.example.com"})
+	require.NoError(t, err)
+
+	valid := tokens.Token("user@example.com", "newsletter")
+
+	tampered := []byte(valid)
+	tampered[0] ^= 1
+
+	tests := []struct {
+		name   string
+		tokens *Tokens
+		token  string
+	}{
+		{name: "empty", tokens: tokens, token: ""},
+		{name: "not base64", tokens: tokens, token: "not a token"},
+		{name: "too short", tokens: tokens, token: "YWJj"},
+		{name: "tampered", tokens: tokens, token: string(tampered)},
+		{name: "signed with another secret", tokens: other, token: valid},
+		{name: "transactional category", tokens: tokens, token: tokens.Token("user@example.com", "")},
+		{name: "disabled", tokens: nil, token: valid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := tt.tokens.Parse(tt.token)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestHeader(t *testing.T) {
+	tokens, err := New(&Config{Secret: "secret", BaseURL: "https://notify.example.com/"})
+	require.NoError(t, err)
+
+	header := tokens.Header("user@example.com", "newsletter")
+	assert.True(t, strings.HasPrefix(header, "<https://notify.example.com/unsubscribe/"))
+	assert.True(t, strings.HasSuffix(header, ">"))
+
+	token := strings.TrimSuffix(strings.TrimPrefix(header, "<https://notify.example.com/unsubscribe/"), ">")
+	recipient, category, err := tokens.Parse(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user@example.com", recipient)
+	assert.Equal(t, "newsletter", category)
+
+	assert.Empty(t, tokens.Header("user@example.com", ""))
+	assert.Empty(t, tokens.Header("user@example.com", "transactional"))
+
+	var disabled *Tokens
+	assert.Empty(t, disabled.Header("user@example.com", "newsletter"))
+}
+
+func TestApplies(t *testing.T) {
+	assert.False(t, Applies(""))
+	assert.False(t, Applies("transactional"))
+	assert.True(t, Applies("newsletter"))
+}
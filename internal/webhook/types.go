@@ -0,0 +1,137 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+
+	"notification/internal/api"
+	"notification/internal/backoff"
+	"notification/internal/monitoring"
+)
+
+const (
+	// DefaultTimeout is the default value for Timeout.
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultMaxRetries is the default value for MaxRetries.
+	DefaultMaxRetries = 5
+
+	// DefaultBasicRetryPause is the default value for BasicRetryPause.
+	DefaultBasicRetryPause = 1 * time.Second
+)
+
+const (
+	// HeaderSignature is the header with the hex encoded HMAC-SHA256 signature of the event,
+	// calculated over the timestamp, a dot and the request body.
+	HeaderSignature = "X-Notification-Signature"
+
+	// HeaderTimestamp is the header with the Unix time the event was signed at.
+	HeaderTimestamp = "X-Notification-Timestamp"
+
+	// signaturePrefix prefixes the signature in HeaderSignature.
+	signaturePrefix = "sha256="
+)
+
+var (
+	// ErrConfig indicates that the default callback URL is not an absolute http(s) URL.
+	ErrConfig = errors.New("New: webhook URL must be an absolute http(s) URL")
+
+	// ErrRejected indicates that the callback responded with a status code that is not retried.
+	ErrRejected = errors.New("deliver: webhook rejected by the callback")
+
+	// ErrUnavailable indicates that the callback responded with a status code that may disappear on retry.
+	ErrUnavailable = errors.New("deliver: callback is unavailable")
+
+	// ErrForbiddenAddress indicates that the callback URL resolves to an address that is not public.
+	ErrForbiddenAddress = errors.New("deliver: callback address is not public")
+)
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, it is not routable on the internet.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Config defines the configuration of delivery status webhooks.
+// Secret is the key used to sign the events, if it is empty, webhooks are disabled.
+// URL is the callback URL used for notifications without their own callback URL, it is optional.
+// Timeout limits a single delivery attempt, failed attempts are retried up to MaxRetries times
+// with exponential pauses growing from BasicRetryPause with full jitter.
+type Config struct {
+	Secret          string        `env:"WEBHOOK_SECRET"`
+	URL             string        `env:"WEBHOOK_URL"`
+	Timeout         time.Duration `env:"WEBHOOK_TIMEOUT"`
+	MaxRetries      int           `env:"WEBHOOK_MAX_RETRIES"`
+	BasicRetryPause time.Duration `env:"WEBHOOK_BASIC_RETRY_PAUSE"`
+}
+
+// Event is the body of the webhook sent after each change of the notification status.
+type Event = api.StatusEvent
+
+// Attempt describes a single delivery attempt of the webhook.
+// Code is the status code of the callback response, it is zero if no response was received.
+type Attempt struct {
+	EmailID   int
+	URL       string
+	Status    string
+	Number    int
+	Code      int
+	Error     string
+	StartedAt time.Time
+	Duration  time.Duration
+}
+
+// Recorder saves the delivery attempts of webhooks.
+type Recorder interface {
+	SaveWebhookAttempt(context.Context, *Attempt) error
+}
+
+// Notifier sends delivery status events to callers.
+type Notifier interface {
+	Notify(ctx context.Context, url string, event Event)
+}
+
+// Dispatcher implements the Notifier interface.
+// It signs the events and delivers them asynchronously, retrying failed attempts with backoff.
+// The default URL is called with client, the callback URLs of the notifications with callbackClient,
+// which only connects to public addresses.
+// The nil *Dispatcher is valid and sends nothing.
+type Dispatcher struct {
+	client         *http.Client
+	callbackClient *http.Client
+	secret         []byte
+	defaultURL     string
+	maxRetries     int
+	backoff        backoff.Policy
+	recorder       Recorder
+	metrics        monitoring.Monitoring
+	logger         *zap.Logger
+	wg             sync.WaitGroup
+	done           chan struct{}
+	closeOnce      sync.Once
+}
+
+// MockNotifier is a mock implementation of the Notifier interface.
+type MockNotifier struct {
+	mock.Mock
+}
+
+// Notify is a mock implementation.
+func (mn *MockNotifier) Notify(ctx context.Context, url string, event Event) {
+	mn.Called(ctx, url, event)
+}
+
+// MockRecorder is a mock implementation of the Recorder interface.
+type MockRecorder struct {
+	mock.Mock
+}
+
+// SaveWebhookAttempt is a mock implementation.
+func (mr *MockRecorder) SaveWebhookAttempt(ctx context.Context, attempt *Attempt) error {
+	args := mr.Called(ctx, attempt)
+	return args.Error(0)
+}
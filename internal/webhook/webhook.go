@@ -0,0 +1,307 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"notification/internal/backoff"
+	"notification/internal/monitoring"
+)
+
+// New creates and returns a new Dispatcher instance, the delivery attempts are saved with the recorder.
+// It returns nil if the secret is not set, so that webhooks are disabled.
+// The callback URLs of the notifications are only called at public addresses, see dialPublic,
+// the default URL is set by the operator and may be in the internal network.
+// If the timeout, max retries and basic retry pause are not set in the configuration, the default values are applied.
+func New(config *Config, recorder Recorder, metrics monitoring.Monitoring, logger *zap.Logger) (*Dispatcher, error) {
+	if config.Secret == "" {
+		return nil, nil
+	}
+
+	if config.URL != "" && !ValidURL(config.URL) {
+		return nil, ErrConfig
+	}
+
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	if config.MaxRetries == 0 {
+		config.MaxRetries = DefaultMaxRetries
+	}
+
+	if config.BasicRetryPause == 0 {
+		config.BasicRetryPause = DefaultBasicRetryPause
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{Timeout: config.Timeout, Control: dialPublic}).DialContext
+
+	return &Dispatcher{
+		client:         &http.Client{Timeout: config.Timeout},
+		callbackClient: &http.Client{Timeout: config.Timeout, Transport: transport},
+		secret:         []byte(config.Secret),
+		defaultURL:     config.URL,
+		maxRetries:     config.MaxRetries,
+		backoff:        backoff.New(config.BasicRetryPause, backoff.Config{}),
+		recorder:       recorder,
+		metrics:        metrics,
+		logger:         logger,
+		done:           make(chan struct{}),
+	}, nil
+}
+
+// ValidURL reports whether the callback URL is an absolute http(s) URL.
+func ValidURL(callbackURL string) bool {
+	u, err := url.Parse(callbackURL)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+// ValidCallbackURL reports whether the callback URL of the notification is an absolute http(s) URL
+// that does not point to the local host or the internal network: localhost and the IP addresses
+// that are not public are rejected. The addresses of the host names are checked when the webhook is sent.
+func ValidCallbackURL(callbackURL string) bool {
+	if !ValidURL(callbackURL) {
+		return false
+	}
+
+	u, _ := url.Parse(callbackURL)
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return publicAddr(addr)
+	}
+
+	return true
+}
+
+// publicAddr reports whether the IP address is a public unicast address,
+// the loopback, private, link-local, shared (RFC 6598), multicast and unspecified addresses are not public.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+}
+
+// dialPublic is the net.Dialer control function of the callback client, it rejects the connections
+// to the addresses that are not public. It is called with the resolved address of each connection,
+// so the host name of the validated callback URL cannot be pointed to the internal network later (DNS rebinding).
+func dialPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !publicAddr(addr) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+
+	return nil
+}
+
+// Notify sends the event to the callback URL in the background, the default URL is used if callbackURL is empty.
+// Nothing is sent if webhooks are disabled or there is no callback URL.
+// The delivery is not canceled together with ctx, it is only stopped by Close.
+func (d *Dispatcher) Notify(ctx context.Context, callbackURL string, event Event) {
+	if d == nil {
+		return
+	}
+
+	client := d.callbackClient
+
+	if callbackURL == "" {
+		callbackURL = d.defaultURL
+		client = d.client
+	}
+
+	if callbackURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		d.metrics.IncError("Webhook")
+		d.logger.Error("Notify: cannot encode event", zap.Error(err), zap.Int("id", event.ID))
+		return
+	}
+
+	d.wg.Add(1)
+
+	go func() {
+		defer d.wg.Done()
+
+		d.deliver(context.WithoutCancel(ctx), client, callbackURL, event, body)
+	}()
+}
+
+// Close stops retrying the pending deliveries and waits until the attempts in progress are finished or ctx is done.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	if d == nil {
+		return nil
+	}
+
+	d.closeOnce.Do(func() { close(d.done) })
+
+	finished := make(chan struct{})
+
+	go func() {
+		d.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deliver sends the event and retries failed attempts with backoff while retries are left.
+// Each attempt is saved with the recorder, rejected events and forbidden addresses are not retried.
+func (d *Dispatcher) deliver(ctx context.Context, client *http.Client, callbackURL string, event Event, body []byte) {
+	start := time.Now()
+
+	var pause time.Duration
+
+	for number := 1; ; number++ {
+		attempt, err := d.send(ctx, client, callbackURL, body)
+		attempt.EmailID = event.ID
+		attempt.Status = event.Status
+		attempt.Number = number
+
+		d.record(ctx, &attempt)
+
+		if attempt.Error == "" {
+			d.metrics.Observe("Webhook", start)
+			d.metrics.IncSuccess("Webhook")
+			d.logger.Info("deliver: webhook delivered",
+				zap.Int("id", event.ID), zap.String("status", event.Status), zap.Int("attempts", number))
+
+			return
+		}
+
+		if attempt.Code != 0 && !retryable(attempt.Code) || errors.Is(err, ErrForbiddenAddress) || number > d.maxRetries {
+			d.metrics.IncError("Webhook")
+			d.logger.Error("deliver: webhook not delivered", zap.String("error", attempt.Error),
+				zap.Int("id", event.ID), zap.String("status", event.Status), zap.Int("attempts", number))
+
+			return
+		}
+
+		pause = d.backoff.Pause(number, pause)
+
+		select {
+		case <-time.After(pause):
+
+		case <-d.done:
+			d.metrics.IncCanceled("Webhook")
+			d.logger.Warn("deliver: dispatcher closed, webhook retries stopped",
+				zap.Int("id", event.ID), zap.String("status", event.Status), zap.Int("attempts", number))
+
+			return
+		}
+	}
+}
+
+// send makes a single delivery attempt of the signed event with the client and returns it with its error.
+func (d *Dispatcher) send(ctx context.Context, client *http.Client, callbackURL string, body []byte) (Attempt, error) {
+	attempt := Attempt{URL: callbackURL, StartedAt: time.Now().UTC()}
+
+	err := d.post(ctx, client, callbackURL, body, &attempt.Code)
+
+	attempt.Duration = time.Since(attempt.StartedAt)
+
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+
+	return attempt, err
+}
+
+// post sends the signed body to the callback URL with the client and sets the status code of the response to code.
+func (d *Dispatcher) post(ctx context.Context, client *http.Client, callbackURL string, body []byte, code *int) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, signaturePrefix+Sign(d.secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	*code = resp.StatusCode
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+
+	case retryable(resp.StatusCode):
+		return fmt.Errorf("%w: %s", ErrUnavailable, resp.Status)
+
+	default:
+		return fmt.Errorf("%w: %s", ErrRejected, resp.Status)
+	}
+}
+
+// record saves the delivery attempt, the error is only logged, because it must not stop the delivery.
+func (d *Dispatcher) record(ctx context.Context, attempt *Attempt) {
+	if d.recorder == nil {
+		return
+	}
+
+	if err := d.recorder.SaveWebhookAttempt(ctx, attempt); err != nil {
+		d.metrics.IncError("Webhook")
+		d.logger.Error("record: cannot save webhook attempt", zap.Error(err), zap.Int("id", attempt.EmailID))
+	}
+}
+
+// retryable reports whether the status code of the callback response may change on retry.
+func retryable(code int) bool {
+	return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+}
+
+// Sign returns the hex encoded HMAC-SHA256 signature of the timestamp and the body,
+// callers verify the webhooks by comparing it with HeaderSignature without the "sha256=" prefix.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
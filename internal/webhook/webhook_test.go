@@ -0,0 +1,297 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"notification/internal/monitoring"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantNil bool
+		wantErr error
+	}{
+		{
+			name:    "disabled",
+			config:  Config{URL: "https://example.com/hook"},
+			wantNil: true,
+		},
+		{
+			name:   "without default URL",
+			config: Config{Secret: "secret"},
+		},
+		{
+			name:   "with default URL",
+			config: Config{Secret: "secret", URL: "https://example.com/hook"},
+		},
+		{
+			name:    "invalid default URL",
+			config:  Config{Secret: "secret", URL: "example.com/hook"},
+			wantNil: true,
+			wantErr: ErrConfig,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(&tt.config, nil, monitoring.NewNop(), zap.NewNop())
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantNil, got == nil)
+
+			if got != nil {
+				assert.Equal(t, DefaultMaxRetries, got.maxRetries)
+				assert.Equal(t, DefaultTimeout, got.client.Timeout)
+			}
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	event := Event{
+		ID:        1,
+		Status:    "sent",
+		To:        "user@example.com",
+		MessageID: "<notification.1@example.com>",
+		CreatedAt: time.Date(2025, 7, 13, 11, 58, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name         string
+		codes        []int
+		wantRequests int
+		wantCodes    []int
+		wantErrors   []bool
+	}{
+		{
+			name:         "delivered",
+			codes:        []int{http.StatusNoContent},
+			wantRequests: 1,
+			wantCodes:    []int{http.StatusNoContent},
+			wantErrors:   []bool{false},
+		},
+		{
+			name:         "delivered after retries",
+			codes:        []int{http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusOK},
+			wantRequests: 3,
+			wantCodes:    []int{http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusOK},
+			wantErrors:   []bool{true, true, false},
+		},
+		{
+			name:         "rejected",
+			codes:        []int{http.StatusBadRequest},
+			wantRequests: 1,
+			wantCodes:    []int{http.StatusBadRequest},
+			wantErrors:   []bool{true},
+		},
+		{
+			name:         "retries exhausted",
+			codes:        []int{http.StatusBadGateway},
+			wantRequests: 3,
+			wantCodes:    []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway},
+			wantErrors:   []bool{true, true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(requests.Add(1))
+
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+
+				timestamp := r.Header.Get(HeaderTimestamp)
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				assert.Equal(t, "sha256="+Sign([]byte("secret"), timestamp, body), r.Header.Get(HeaderSignature))
+
+				var got Event
+				require.NoError(t, json.Unmarshal(body, &got))
+				assert.Equal(t, event, got)
+
+				w.WriteHeader(tt.codes[min(n, len(tt.codes))-1])
+			}))
+			defer server.Close()
+
+			recorder := &MockRecorder{}
+			recorder.On("SaveWebhookAttempt", mock.Anything, mock.Anything).Return(nil)
+
+			dispatcher, err := New(&Config{Secret: "secret", MaxRetries: 2, BasicRetryPause: time.Millisecond},
+				recorder, monitoring.NewNop(), zap.NewNop())
+			require.NoError(t, err)
+
+			allowLoopback(dispatcher)
+
+			dispatcher.Notify(context.Background(), server.URL, event)
+
+			require.Eventually(t, func() bool { return int(requests.Load()) == tt.wantRequests }, time.Second, time.Millisecond)
+			require.NoError(t, dispatcher.Close(context.Background()))
+
+			assert.Equal(t, tt.wantRequests, int(requests.Load()))
+			require.Len(t, recorder.Calls, tt.wantRequests)
+
+			for i, call := range recorder.Calls {
+				attempt := call.Arguments.Get(1).(*Attempt)
+
+				assert.Equal(t, event.ID, attempt.EmailID)
+				assert.Equal(t, event.Status, attempt.Status)
+				assert.Equal(t, server.URL, attempt.URL)
+				assert.Equal(t, i+1, attempt.Number)
+				assert.Equal(t, tt.wantCodes[i], attempt.Code)
+				assert.Equal(t, tt.wantErrors[i], attempt.Error != "")
+			}
+		})
+	}
+}
+
+func TestNotifyDefaultURL(t *testing.T) {
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	dispatcher, err := New(&Config{Secret: "secret", URL: server.URL}, nil, monitoring.NewNop(), zap.NewNop())
+	require.NoError(t, err)
+
+	dispatcher.Notify(context.Background(), "", Event{ID: 1, Status: "sent"})
+	require.NoError(t, dispatcher.Close(context.Background()))
+
+	assert.Equal(t, 1, int(requests.Load()))
+
+	dispatcher, err = New(&Config{Secret: "secret"}, nil, monitoring.NewNop(), zap.NewNop())
+	require.NoError(t, err)
+
+	dispatcher.Notify(context.Background(), "", Event{ID: 1, Status: "sent"})
+	require.NoError(t, dispatcher.Close(context.Background()))
+
+	assert.Equal(t, 1, int(requests.Load()))
+
+	var disabled *Dispatcher
+	disabled.Notify(context.Background(), server.URL, Event{ID: 1, Status: "sent"})
+	assert.NoError(t, disabled.Close(context.Background()))
+}
+
+func TestCloseStopsRetries(t *testing.T) {
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	dispatcher, err := New(&Config{Secret: "secret", BasicRetryPause: time.Hour}, nil, monitoring.NewNop(), zap.NewNop())
+	require.NoError(t, err)
+
+	allowLoopback(dispatcher)
+
+	dispatcher.Notify(context.Background(), server.URL, Event{ID: 1, Status: "failed"})
+
+	require.Eventually(t, func() bool { return requests.Load() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.NoError(t, dispatcher.Close(ctx))
+	assert.Equal(t, 1, int(requests.Load()))
+}
+
+func TestNotifyForbiddenAddress(t *testing.T) {
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	recorder := &MockRecorder{}
+	recorder.On("SaveWebhookAttempt", mock.Anything, mock.Anything).Return(nil)
+
+	dispatcher, err := New(&Config{Secret: "secret", MaxRetries: 2, BasicRetryPause: time.Millisecond},
+		recorder, monitoring.NewNop(), zap.NewNop())
+	require.NoError(t, err)
+
+	// The host name resolves to the loopback address of the test server, as after DNS rebinding.
+	dispatcher.Notify(context.Background(), strings.Replace(server.URL, "127.0.0.1", "localhost", 1), Event{ID: 1, Status: "sent"})
+	require.NoError(t, dispatcher.Close(context.Background()))
+
+	assert.Zero(t, requests.Load())
+	require.Len(t, recorder.Calls, 1)
+
+	attempt := recorder.Calls[0].Arguments.Get(1).(*Attempt)
+	assert.Equal(t, 1, attempt.Number)
+	assert.Zero(t, attempt.Code)
+	assert.Contains(t, attempt.Error, ErrForbiddenAddress.Error())
+}
+
+func TestSign(t *testing.T) {
+	got := Sign([]byte("secret"), "1700000000", []byte(`{"id":1}`))
+
+	assert.Len(t, got, 64)
+	assert.Equal(t, got, Sign([]byte("secret"), "1700000000", []byte(`{"id":1}`)))
+	assert.NotEqual(t, got, Sign([]byte("other"), "1700000000", []byte(`{"id":1}`)))
+	assert.NotEqual(t, got, Sign([]byte("secret"), "1700000001", []byte(`{"id":1}`)))
+	assert.False(t, strings.HasPrefix(got, "sha256="))
+}
+
+func TestValidURL(t *testing.T) {
+	assert.True(t, ValidURL("https://example.com/hook"))
+	assert.True(t, ValidURL("http://localhost:8081/hook"))
+	assert.False(t, ValidURL("ftp://example.com/hook"))
+	assert.False(t, ValidURL("/hook"))
+	assert.False(t, ValidURL(""))
+}
+
+func TestValidCallbackURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{url: "https://example.com/hook", want: true},
+		{url: "http://93.184.215.14:8080/hook", want: true},
+		{url: "https://[2606:2800:21f:cb07:6820:80da:af6b:8b2c]/hook", want: true},
+		{url: "example.com/hook", want: false},
+		{url: "http://localhost:8081/hook", want: false},
+		{url: "http://api.LOCALHOST./hook", want: false},
+		{url: "http://127.0.0.1/hook", want: false},
+		{url: "http://[::1]/hook", want: false},
+		{url: "http://[::ffff:127.0.0.1]/hook", want: false},
+		{url: "http://169.254.169.254/latest/meta-data", want: false},
+		{url: "http://10.0.0.5/hook", want: false},
+		{url: "http://172.16.0.1/hook", want: false},
+		{url: "http://192.168.1.1/hook", want: false},
+		{url: "http://100.64.0.1/hook", want: false},
+		{url: "http://[fd00::1]/hook", want: false},
+		{url: "http://0.0.0.0/hook", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			assert.Equal(t, tt.want, ValidCallbackURL(tt.url))
+		})
+	}
+}
+
+// allowLoopback lets the dispatcher call the callback URLs of the test servers listening on the loopback address.
+func allowLoopback(dispatcher *Dispatcher) {
+	dispatcher.callbackClient = dispatcher.client
+}
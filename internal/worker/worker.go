@@ -6,28 +6,64 @@ import (
 	"errors"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 
 	"notification/internal/SMTPClient"
+	"notification/internal/api"
+	"notification/internal/backoff"
+	"notification/internal/events"
 	"notification/internal/monitoring"
+	"notification/internal/storage/postgresClient"
 	"notification/internal/storage/redisClient"
+	"notification/internal/unsubscribe"
+	"notification/internal/webhook"
 )
 
-// Worker periodically polls Redis for scheduled email entries and sends them using an SMTP client.
+// DefaultMaxRetries is the default value for MaxRetries.
+const DefaultMaxRetries = 3
+
+// Config defines the configuration parameters for the Worker.
+// MaxRetries is the number of times an email failed with a transient error is rescheduled.
+type Config struct {
+	MaxRetries int `env:"WORKER_MAX_RETRIES"`
+}
+
+// Worker periodically polls Redis for scheduled email entries, sends them using an SMTP client
+// and saves the sending status to PostgreSQL.
+// Emails failed with a transient error are rescheduled according to the retry backoff policy.
+// Status changes are published to the event bus, callers are notified about the final status of each email
+// with delivery status webhooks.
 type Worker struct {
 	rc           redisClient.RedisClient
+	pc           postgresClient.PostgresClient
 	sender       SMTPClient.EmailSender
+	notifier     webhook.Notifier
+	bus          *events.Bus
+	backoff      backoff.Policy
+	maxRetries   int
 	metrics      monitoring.Monitoring
 	logger       *zap.Logger
 	tickDuration time.Duration
 }
 
 // New creates and returns a new Worker instance.
-func New(rc redisClient.RedisClient, sender SMTPClient.EmailSender, tickDuration time.Duration, metrics monitoring.Monitoring, logger *zap.Logger) *Worker {
+// If MaxRetries is not set in the configuration, the default value is applied.
+func New(rc redisClient.RedisClient, pc postgresClient.PostgresClient, sender SMTPClient.EmailSender,
+	notifier webhook.Notifier, bus *events.Bus, config *Config, retryPolicy backoff.Policy, tickDuration time.Duration, metrics monitoring.Monitoring, logger *zap.Logger) *Worker {
+	if config.MaxRetries == 0 {
+		config.MaxRetries = DefaultMaxRetries
+	}
+
 	return &Worker{
 		rc:           rc,
+		pc:           pc,
 		sender:       sender,
+		notifier:     notifier,
+		bus:          bus,
+		backoff:      retryPolicy,
+		maxRetries:   config.MaxRetries,
 		tickDuration: tickDuration,
 		metrics:      metrics,
 		logger:       logger,
@@ -104,6 +140,11 @@ func (w *Worker) Run(ctx context.Context) error {
 
 // processEntries handles a batch of entries received from Redis.
 // It decodes each entry and sends the corresponding email using the SMTP client.
+// If the SMTP circuit breaker is open, the email is postponed instead of being dropped,
+// if sending failed with a transient error, the email is retried later while retries are left.
+// Emails to recipients unsubscribed from the category are not sent and get the skipped status,
+// emails to recipients on the suppression list get the suppressed status.
+// Each email is claimed with the sending status before it is sent, canceled emails are dropped.
 func (w *Worker) processEntries(ctx context.Context, entries []string) error {
 	for _, entry := range entries {
 		select {
@@ -123,12 +164,93 @@ func (w *Worker) processEntries(ctx context.Context, entries []string) error {
 			}
 
 			res := SMTPClient.EmailMessage{
-				To:      email.To,
-				Subject: email.Subject,
-				Message: email.Message,
+				Id:          email.Id,
+				To:          email.To,
+				Subject:     email.Subject,
+				Message:     email.Message,
+				From:        email.From,
+				FromName:    email.FromName,
+				ReplyTo:     email.ReplyTo,
+				Headers:     email.Headers,
+				MessageID:   email.MessageID,
+				InReplyTo:   email.InReplyTo,
+				References:  email.References,
+				Category:    email.Category,
+				HTML:        email.HTML,
+				Track:       email.Track,
+				CallbackURL: email.CallbackURL,
+				Tenant:      email.Tenant,
+			}
+
+			claimed, err := w.claim(ctx, email)
+			if err != nil {
+				w.metrics.IncError("Worker")
+				w.logger.Error("processEntries: failed to claim message", zap.Error(err), zap.Any("email", email))
+
+				if ctx.Err() == nil && !w.retry(ctx, email) {
+					w.updateStatus(ctx, email, api.StatusFailed, nil)
+				}
+
+				continue
+			}
+
+			if !claimed {
+				w.logger.Info("processEntries: notification has been canceled, message dropped", zap.Any("email", email))
+
+				continue
+			}
+
+			recipientStatus, err := w.recipientStatus(ctx, email)
+			if err != nil {
+				w.metrics.IncError("Worker")
+				w.logger.Error("processEntries: failed to check recipient", zap.Error(err), zap.Any("email", email))
+
+				status := api.StatusFailed
+				if ctx.Err() == nil && w.retry(ctx, email) {
+					status = api.StatusPending
+				}
+
+				w.updateStatus(ctx, email, status, nil)
+
+				continue
 			}
 
-			if err := w.sender.SendEmail(ctx, res); err != nil {
+			switch recipientStatus {
+			case api.StatusSuppressed:
+				w.updateStatus(ctx, email, api.StatusSuppressed, nil)
+				w.logger.Warn("processEntries: recipient is on the suppression list, message rejected", zap.Any("email", email))
+
+				continue
+
+			case api.StatusSkipped:
+				w.updateStatus(ctx, email, api.StatusSkipped, nil)
+				w.logger.Info("processEntries: recipient has unsubscribed, message skipped", zap.Any("email", email))
+
+				continue
+			}
+
+			attempts, err := w.sender.SendEmail(ctx, res)
+
+			var circuitOpen *SMTPClient.CircuitOpenError
+			if errors.As(err, &circuitOpen) {
+				w.postpone(ctx, email, circuitOpen.RetryAfter)
+				w.updateStatus(ctx, email, api.StatusPending, nil)
+				continue
+			}
+
+			if err != nil && ctx.Err() == nil && isTransient(err) && w.retry(ctx, email) {
+				w.updateStatus(ctx, email, api.StatusPending, attempts)
+				continue
+			}
+
+			status := api.StatusSent
+			if err != nil {
+				status = api.StatusFailed
+			}
+
+			w.updateStatus(ctx, email, status, attempts)
+
+			if err != nil {
 				w.metrics.IncError("Worker")
 				w.logger.Error("processEntries: failed to send message", zap.Error(err), zap.Any("email", email))
 				continue
@@ -140,3 +262,171 @@ func (w *Worker) processEntries(ctx context.Context, entries []string) error {
 
 	return nil
 }
+
+// claim sets the sending status of the pending email, so that it cannot be canceled while it is being sent.
+// It returns false if the email is not pending anymore, because it has been canceled.
+// Entries without ID are always claimed, their status is not tracked.
+func (w *Worker) claim(ctx context.Context, email SMTPClient.TempEmailMessage) (bool, error) {
+	if email.Id == 0 {
+		return true, nil
+	}
+
+	err := w.pc.ClaimEmail(ctx, email.Id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// recipientStatus returns StatusSuppressed if the recipient is on the suppression list,
+// StatusSkipped if the recipient has opted out of the category of the email,
+// and an empty status if the email can be sent.
+func (w *Worker) recipientStatus(ctx context.Context, email SMTPClient.TempEmailMessage) (string, error) {
+	recipient := unsubscribe.Recipient(email.To)
+
+	suppressed, err := w.pc.IsSuppressed(ctx, recipient)
+	if err != nil {
+		return "", err
+	}
+
+	if suppressed {
+		return api.StatusSuppressed, nil
+	}
+
+	if !unsubscribe.Applies(email.Category) {
+		return "", nil
+	}
+
+	unsubscribed, err := w.pc.IsUnsubscribed(ctx, recipient, email.Category)
+	if err != nil || !unsubscribed {
+		return "", err
+	}
+
+	return api.StatusSkipped, nil
+}
+
+// postpone puts the email back to Redis to be sent again after the specified delay.
+func (w *Worker) postpone(ctx context.Context, email SMTPClient.TempEmailMessage, delay time.Duration) {
+	sendAt, err := w.reschedule(ctx, email, delay, email.Retry)
+	if err != nil {
+		w.metrics.IncError("Worker")
+		w.logger.Error("postpone: failed to postpone message", zap.Error(err), zap.Any("email", email))
+		return
+	}
+
+	w.logger.Warn("postpone: SMTP circuit breaker is open, message postponed", zap.Time("time", sendAt), zap.Any("email", email))
+}
+
+// retry reschedules the email failed with a transient error after the pause chosen by the backoff policy.
+// It returns false if the retries are exhausted, the max elapsed retry time would be exceeded
+// or the email cannot be put back to Redis.
+func (w *Worker) retry(ctx context.Context, email SMTPClient.TempEmailMessage) bool {
+	state := SMTPClient.RetryState{Start: time.Now().UTC()}
+	if email.Retry != nil {
+		state = *email.Retry
+	}
+
+	if state.Number >= w.maxRetries {
+		w.logger.Warn("retry: retries exhausted", zap.Int("retries", state.Number), zap.Any("email", email))
+		return false
+	}
+
+	pause := w.backoff.Pause(state.Number+1, state.Pause)
+
+	if w.backoff.Exceeded(state.Start, pause) {
+		w.logger.Warn("retry: max elapsed retry time exceeded", zap.Time("start", state.Start), zap.Any("email", email))
+		return false
+	}
+
+	state.Number++
+	state.Pause = pause
+
+	sendAt, err := w.reschedule(ctx, email, pause, &state)
+	if err != nil {
+		w.metrics.IncError("Worker")
+		w.logger.Error("retry: failed to reschedule message", zap.Error(err), zap.Any("email", email))
+		return false
+	}
+
+	w.logger.Warn(
+		"retry: failed to send message, retry scheduled",
+		zap.Int("retry", state.Number),
+		zap.Time("time", sendAt),
+		zap.Any("email", email),
+	)
+
+	return true
+}
+
+// reschedule puts the email back to Redis to be sent after the specified delay, rounded up to whole seconds.
+// It returns the new sending time.
+func (w *Worker) reschedule(ctx context.Context, email SMTPClient.TempEmailMessage, delay time.Duration,
+	retry *SMTPClient.RetryState) (time.Time, error) {
+	sendAt := time.Now().Add(delay).Truncate(time.Second).Add(time.Second).UTC()
+
+	res := &SMTPClient.EmailMessage{
+		Id:          email.Id,
+		Type:        api.KeyForDelayedSending,
+		Time:        &sendAt,
+		To:          email.To,
+		Subject:     email.Subject,
+		Message:     email.Message,
+		From:        email.From,
+		FromName:    email.FromName,
+		ReplyTo:     email.ReplyTo,
+		Headers:     email.Headers,
+		MessageID:   email.MessageID,
+		InReplyTo:   email.InReplyTo,
+		References:  email.References,
+		Category:    email.Category,
+		HTML:        email.HTML,
+		Track:       email.Track,
+		CallbackURL: email.CallbackURL,
+		Tenant:      email.Tenant,
+		Retry:       retry,
+	}
+
+	return sendAt, w.rc.AddDelayedEmail(ctx, res)
+}
+
+// isTransient reports whether the sending error may disappear on retry.
+// Permanent rejections, invalid addresses and the messages the provider may have accepted are never retried.
+func isTransient(err error) bool {
+	return !errors.Is(err, SMTPClient.ErrPermanentFailure) &&
+		!errors.Is(err, SMTPClient.ErrDeliveryUnknown) &&
+		!errors.Is(err, SMTPClient.ErrNoValidRecipientAddress) &&
+		!errors.Is(err, SMTPClient.ErrNoValidSenderAddress)
+}
+
+// updateStatus saves the status of the email with the history of attempts to PostgreSQL,
+// publishes it to the event bus and notifies the caller about the final status,
+// webhooks are not sent for emails pending a retry.
+// Entries without ID were scheduled before IDs were stored in Redis, their status is not tracked.
+func (w *Worker) updateStatus(ctx context.Context, email SMTPClient.TempEmailMessage, status string, attempts []SMTPClient.Attempt) {
+	if email.Id == 0 {
+		return
+	}
+
+	if err := w.pc.UpdateStatus(context.WithoutCancel(ctx), email.Id, status, attempts); err != nil {
+		w.metrics.IncError("Worker")
+		w.logger.Error("updateStatus: cannot update email status", zap.Error(err), zap.Int("id", email.Id))
+	}
+
+	event := api.StatusEvent{
+		ID:        email.Id,
+		Status:    status,
+		To:        email.To,
+		MessageID: email.MessageID,
+		Tenant:    email.Tenant,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	w.bus.Publish(ctx, event)
+
+	if w.notifier == nil || status == api.StatusPending {
+		return
+	}
+
+	w.notifier.Notify(ctx, email.CallbackURL, event)
+}
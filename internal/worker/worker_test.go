@@ -3,17 +3,24 @@ package worker
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
 	"notification/internal/SMTPClient"
+	"notification/internal/api"
+	"notification/internal/backoff"
 	"notification/internal/monitoring"
+	"notification/internal/storage/postgresClient"
 	"notification/internal/storage/redisClient"
+	"notification/internal/webhook"
 )
 
 func TestWorker(t *testing.T) {
@@ -95,7 +102,12 @@ func TestWorker(t *testing.T) {
 
 		wrk := New(
 			mockRedis,
+			newMockPostgres(),
 			mockSender,
+			nil,
+			nil,
+			&Config{},
+			backoff.Policy{},
 			100*time.Millisecond,
 			monitoring.NewNop(),
 			zap.NewNop(),
@@ -105,7 +117,7 @@ func TestWorker(t *testing.T) {
 			To:      "test1@example.com",
 			Subject: "Test1",
 			Message: "Test message1",
-		}).Return(nil).Run(func(args mock.Arguments) {
+		}).Return(nil, nil).Run(func(args mock.Arguments) {
 			wg.Done()
 		})
 
@@ -113,7 +125,7 @@ func TestWorker(t *testing.T) {
 			To:      "test2@example.com",
 			Subject: "Test2",
 			Message: "Test message2",
-		}).Return(nil).Run(func(args mock.Arguments) {
+		}).Return(nil, nil).Run(func(args mock.Arguments) {
 			wg.Done()
 		})
 
@@ -147,19 +159,25 @@ func TestWorker(t *testing.T) {
 
 			mockRedis.On("CheckRedis", mock.Anything).
 				Return(tt.redisResponse, tt.redisError)
+			mockRedis.On("AddDelayedEmail", mock.Anything, mock.Anything).Return(nil).Maybe()
 
 			wg := &sync.WaitGroup{}
 			if tt.wantSendCalled {
 				wg.Add(1)
 				mockSender.On("SendEmail", mock.Anything, *tt.wantEmail).
-					Return(tt.emailError).Run(func(args mock.Arguments) {
+					Return(nil, tt.emailError).Run(func(args mock.Arguments) {
 					wg.Done()
 				})
 			}
 
 			wrk := New(
 				mockRedis,
+				newMockPostgres(),
 				mockSender,
+				nil,
+				nil,
+				&Config{},
+				backoff.Policy{},
 				100*time.Millisecond,
 				monitoring.NewNop(),
 				zap.NewNop(),
@@ -201,6 +219,477 @@ func TestWorker(t *testing.T) {
 	}
 }
 
+func TestWorkerUpdatesStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		sendError  error
+		wantStatus string
+	}{
+		{
+			name:       "sent",
+			sendError:  nil,
+			wantStatus: api.StatusSent,
+		},
+		{
+			name:       "failed permanently",
+			sendError:  fmt.Errorf("SendEmail: %w", SMTPClient.ErrPermanentFailure),
+			wantStatus: api.StatusFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			mockRedis := &redisClient.MockRedisClient{}
+			mockPostgres := newMockPostgres()
+			mockSender := &SMTPClient.MockEmailSender{}
+
+			mockRedis.On("CheckRedis", mock.Anything).Return(
+				[]string{`{"id":7,"type":"delayedSending","time":"1764687845","to":"test@example.com","subject":"Test","message":"Test message"}`},
+				nil,
+			).Once()
+			mockRedis.On("CheckRedis", mock.Anything).Return([]string{}, nil)
+
+			attempts := []SMTPClient.Attempt{{Number: 1, Provider: "primary", Class: SMTPClient.AttemptSuccess}}
+
+			mockSender.On("SendEmail", mock.Anything, SMTPClient.EmailMessage{
+				Id:      7,
+				To:      "test@example.com",
+				Subject: "Test",
+				Message: "Test message",
+			}).Return(attempts, tt.sendError)
+
+			updated := make(chan struct{})
+
+			mockPostgres.On("UpdateStatus", mock.Anything, 7, tt.wantStatus, attempts).Return(nil).Run(func(args mock.Arguments) {
+				close(updated)
+			})
+
+			wrk := New(mockRedis, mockPostgres, mockSender, nil, nil, &Config{}, backoff.Policy{}, 100*time.Millisecond,
+				monitoring.NewNop(), zap.NewNop())
+
+			go func() {
+				err := wrk.Run(ctx)
+				require.NoError(t, err)
+			}()
+
+			select {
+			case <-updated:
+			case <-time.After(1 * time.Second):
+				t.Fatal("UpdateStatus was not called in time")
+			}
+		})
+	}
+}
+
+func TestWorkerNotifiesCaller(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockRedis := &redisClient.MockRedisClient{}
+	mockPostgres := newMockPostgres()
+	mockSender := &SMTPClient.MockEmailSender{}
+	mockNotifier := &webhook.MockNotifier{}
+
+	mockRedis.On("CheckRedis", mock.Anything).Return(
+		[]string{`{"id":7,"type":"delayedSending","time":"1764687845","to":"test@example.com","subject":"Test","message":"Test message",` +
+			`"message_id":"<notification.7@example.com>","callback_url":"https://example.com/hook"}`},
+		nil,
+	).Once()
+	mockRedis.On("CheckRedis", mock.Anything).Return([]string{}, nil)
+
+	mockSender.On("SendEmail", mock.Anything, mock.Anything).Return(nil, nil)
+	mockPostgres.On("UpdateStatus", mock.Anything, 7, api.StatusSent, mock.Anything).Return(nil)
+
+	notified := make(chan webhook.Event, 1)
+
+	mockNotifier.On("Notify", mock.Anything, "https://example.com/hook", mock.Anything).Run(func(args mock.Arguments) {
+		notified <- args.Get(2).(webhook.Event)
+	})
+
+	wrk := New(mockRedis, mockPostgres, mockSender, mockNotifier, nil, &Config{}, backoff.Policy{}, 100*time.Millisecond,
+		monitoring.NewNop(), zap.NewNop())
+
+	go func() {
+		err := wrk.Run(ctx)
+		require.NoError(t, err)
+	}()
+
+	select {
+	case event := <-notified:
+		assert.Equal(t, 7, event.ID)
+		assert.Equal(t, api.StatusSent, event.Status)
+		assert.Equal(t, "test@example.com", event.To)
+		assert.Equal(t, "<notification.7@example.com>", event.MessageID)
+		assert.False(t, event.CreatedAt.IsZero())
+
+	case <-time.After(1 * time.Second):
+		t.Fatal("Notify was not called in time")
+	}
+}
+
+func TestWorkerChecksRecipient(t *testing.T) {
+	tests := []struct {
+		name         string
+		suppressed   bool
+		unsubscribed bool
+		wantStatus   string
+	}{
+		{
+			name:         "unsubscribed recipient is skipped",
+			unsubscribed: true,
+			wantStatus:   api.StatusSkipped,
+		},
+		{
+			name:       "suppressed recipient is rejected",
+			suppressed: true,
+			wantStatus: api.StatusSuppressed,
+		},
+		{
+			name:         "subscribed recipient is sent",
+			unsubscribed: false,
+			wantStatus:   api.StatusSent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			mockRedis := &redisClient.MockRedisClient{}
+			mockPostgres := &postgresClient.MockPostgresService{}
+			mockSender := &SMTPClient.MockEmailSender{}
+
+			mockRedis.On("CheckRedis", mock.Anything).Return(
+				[]string{`{"id":7,"type":"delayedSending","time":"1764687845","to":"Test@example.com","subject":"Test","message":"Test message","category":"newsletter"}`},
+				nil,
+			).Once()
+			mockRedis.On("CheckRedis", mock.Anything).Return([]string{}, nil)
+
+			mockPostgres.On("ClaimEmail", mock.Anything, 7).Return(nil)
+			mockPostgres.On("IsSuppressed", mock.Anything, "test@example.com").Return(tt.suppressed, nil)
+			mockPostgres.On("IsUnsubscribed", mock.Anything, "test@example.com", "newsletter").Return(tt.unsubscribed, nil)
+			mockSender.On("SendEmail", mock.Anything, mock.Anything).Return(nil, nil)
+
+			updated := make(chan struct{})
+
+			mockPostgres.On("UpdateStatus", mock.Anything, 7, tt.wantStatus, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				close(updated)
+			})
+
+			wrk := New(mockRedis, mockPostgres, mockSender, nil, nil, &Config{}, backoff.Policy{}, 100*time.Millisecond,
+				monitoring.NewNop(), zap.NewNop())
+
+			go func() {
+				err := wrk.Run(ctx)
+				require.NoError(t, err)
+			}()
+
+			select {
+			case <-updated:
+			case <-time.After(1 * time.Second):
+				t.Fatal("UpdateStatus was not called in time")
+			}
+
+			if tt.wantStatus != api.StatusSent {
+				mockSender.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestWorkerSkipsCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockRedis := &redisClient.MockRedisClient{}
+	mockPostgres := &postgresClient.MockPostgresService{}
+	mockSender := &SMTPClient.MockEmailSender{}
+
+	checked := make(chan struct{})
+
+	mockRedis.On("CheckRedis", mock.Anything).Return(
+		[]string{`{"id":7,"type":"delayedSending","time":"1764687845","to":"test@example.com","subject":"Test","message":"Test message"}`},
+		nil,
+	).Once()
+	mockRedis.On("CheckRedis", mock.Anything).Return([]string{}, nil).Run(func(args mock.Arguments) {
+		select {
+		case <-checked:
+		default:
+			close(checked)
+		}
+	})
+
+	mockPostgres.On("ClaimEmail", mock.Anything, 7).Return(pgx.ErrNoRows)
+
+	wrk := New(mockRedis, mockPostgres, mockSender, nil, nil, &Config{}, backoff.Policy{}, 100*time.Millisecond,
+		monitoring.NewNop(), zap.NewNop())
+
+	go func() {
+		err := wrk.Run(ctx)
+		require.NoError(t, err)
+	}()
+
+	select {
+	case <-checked:
+	case <-time.After(1 * time.Second):
+		t.Fatal("CheckRedis was not called again in time")
+	}
+
+	mockSender.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything)
+	mockPostgres.AssertNotCalled(t, "IsSuppressed", mock.Anything, mock.Anything)
+	mockPostgres.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWorkerCancelRace(t *testing.T) {
+	tests := []struct {
+		name            string
+		cancelBeforeRun bool
+		wantClaimErr    error
+		wantCancelErr   error
+		wantStatus      string
+	}{
+		{
+			name:            "canceled before the worker claims the email",
+			cancelBeforeRun: true,
+			wantClaimErr:    pgx.ErrNoRows,
+			wantStatus:      api.StatusCanceled,
+		},
+		{
+			name:          "canceled between the check and the send",
+			wantCancelErr: pgx.ErrNoRows,
+			wantStatus:    api.StatusSent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			mockRedis := &redisClient.MockRedisClient{}
+			mockSender := &SMTPClient.MockEmailSender{}
+			store := newStatusPostgres()
+
+			mockRedis.On("CheckRedis", mock.Anything).Return(
+				[]string{`{"id":7,"type":"delayedSending","time":"1764687845","to":"test@example.com","subject":"Test","message":"Test message"}`},
+				nil,
+			).Once()
+			mockRedis.On("CheckRedis", mock.Anything).Return([]string{}, nil)
+
+			canceled := make(chan error, 1)
+			updated := make(chan string, 1)
+
+			store.On("IsSuppressed", mock.Anything, "test@example.com").Return(false, nil).Run(func(args mock.Arguments) {
+				canceled <- store.CancelEmail(ctx, 7)
+			})
+			store.On("UpdateStatus", mock.Anything, 7, mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				updated <- args.String(2)
+			})
+			mockSender.On("SendEmail", mock.Anything, mock.Anything).Return(nil, nil)
+
+			if tt.cancelBeforeRun {
+				require.NoError(t, store.CancelEmail(ctx, 7))
+			}
+
+			wrk := New(mockRedis, store, mockSender, nil, nil, &Config{}, backoff.Policy{}, 100*time.Millisecond,
+				monitoring.NewNop(), zap.NewNop())
+
+			go func() {
+				err := wrk.Run(ctx)
+				require.NoError(t, err)
+			}()
+
+			select {
+			case err := <-store.claims:
+				assert.ErrorIs(t, err, tt.wantClaimErr)
+			case <-time.After(1 * time.Second):
+				t.Fatal("ClaimEmail was not called in time")
+			}
+
+			if tt.wantClaimErr != nil {
+				time.Sleep(200 * time.Millisecond)
+
+				mockSender.AssertNotCalled(t, "SendEmail", mock.Anything, mock.Anything)
+				store.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+				store.mu.Lock()
+				assert.Equal(t, tt.wantStatus, store.status)
+				store.mu.Unlock()
+
+				return
+			}
+
+			select {
+			case status := <-updated:
+				assert.Equal(t, tt.wantStatus, status)
+			case <-time.After(1 * time.Second):
+				t.Fatal("UpdateStatus was not called in time")
+			}
+
+			assert.ErrorIs(t, <-canceled, tt.wantCancelErr)
+			mockSender.AssertCalled(t, "SendEmail", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestWorkerRetriesTransientFailure(t *testing.T) {
+	policy := backoff.New(10*time.Second, backoff.Config{Jitter: backoff.JitterNone, MaxElapsed: time.Hour})
+	identity := `"from":"support@example.com","from_name":"Support","reply_to":"help@example.com","headers":{"X-Campaign":"spring"},` +
+		`"message_id":"<notification.7@example.com>","in_reply_to":"<notification.6@example.com>","references":["<notification.6@example.com>"],`
+
+	tests := []struct {
+		name       string
+		entry      string
+		wantRetry  *SMTPClient.RetryState
+		wantStatus string
+	}{
+		{
+			name:       "first failure is rescheduled",
+			entry:      `{"id":7,"type":"delayedSending","time":"1764687845",` + identity + `"to":"test@example.com","subject":"Test","message":"Test message"}`,
+			wantRetry:  &SMTPClient.RetryState{Number: 1, Pause: 10 * time.Second},
+			wantStatus: api.StatusPending,
+		},
+		{
+			name: "next failure doubles the pause",
+			entry: `{"id":7,"type":"delayedSending","time":"1764687845",` + identity + `"to":"test@example.com","subject":"Test","message":"Test message",` +
+				`"retry":{"number":1,"pause":10000000000,"start":"` + time.Now().UTC().Format(time.RFC3339) + `"}}`,
+			wantRetry:  &SMTPClient.RetryState{Number: 2, Pause: 20 * time.Second},
+			wantStatus: api.StatusPending,
+		},
+		{
+			name: "retries exhausted",
+			entry: `{"id":7,"type":"delayedSending","time":"1764687845","to":"test@example.com","subject":"Test","message":"Test message",` +
+				`"retry":{"number":2,"pause":20000000000,"start":"` + time.Now().UTC().Format(time.RFC3339) + `"}}`,
+			wantStatus: api.StatusFailed,
+		},
+		{
+			name: "max elapsed time exceeded",
+			entry: `{"id":7,"type":"delayedSending","time":"1764687845","to":"test@example.com","subject":"Test","message":"Test message",` +
+				`"retry":{"number":1,"pause":10000000000,"start":"` + time.Now().Add(-time.Hour).UTC().Format(time.RFC3339) + `"}}`,
+			wantStatus: api.StatusFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			mockRedis := &redisClient.MockRedisClient{}
+			mockPostgres := newMockPostgres()
+			mockSender := &SMTPClient.MockEmailSender{}
+
+			mockRedis.On("CheckRedis", mock.Anything).Return([]string{tt.entry}, nil).Once()
+			mockRedis.On("CheckRedis", mock.Anything).Return([]string{}, nil)
+
+			mockSender.On("SendEmail", mock.Anything, mock.Anything).Return(nil, errors.New("connection refused"))
+
+			rescheduled := make(chan *SMTPClient.EmailMessage, 1)
+			mockRedis.On("AddDelayedEmail", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				rescheduled <- args.Get(1).(*SMTPClient.EmailMessage)
+			}).Maybe()
+
+			updated := make(chan struct{})
+			mockPostgres.On("UpdateStatus", mock.Anything, 7, tt.wantStatus, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+				close(updated)
+			})
+
+			wrk := New(mockRedis, mockPostgres, mockSender, nil, nil, &Config{MaxRetries: 2}, policy, 100*time.Millisecond,
+				monitoring.NewNop(), zap.NewNop())
+
+			go func() {
+				err := wrk.Run(ctx)
+				require.NoError(t, err)
+			}()
+
+			select {
+			case <-updated:
+			case <-time.After(1 * time.Second):
+				t.Fatal("UpdateStatus was not called in time")
+			}
+
+			if tt.wantRetry == nil {
+				mockRedis.AssertNotCalled(t, "AddDelayedEmail", mock.Anything, mock.Anything)
+				return
+			}
+
+			email := <-rescheduled
+
+			require.NotNil(t, email.Retry)
+			assert.Equal(t, tt.wantRetry.Number, email.Retry.Number)
+			assert.Equal(t, tt.wantRetry.Pause, email.Retry.Pause)
+			assert.WithinDuration(t, time.Now(), email.Retry.Start, time.Minute)
+			require.NotNil(t, email.Time)
+			assert.WithinDuration(t, time.Now().Add(tt.wantRetry.Pause), *email.Time, 2*time.Second)
+			assert.Equal(t, 7, email.Id)
+			assert.Equal(t, "support@example.com", email.From)
+			assert.Equal(t, "Support", email.FromName)
+			assert.Equal(t, "help@example.com", email.ReplyTo)
+			assert.Equal(t, map[string]string{"X-Campaign": "spring"}, email.Headers)
+			assert.Equal(t, "<notification.7@example.com>", email.MessageID)
+			assert.Equal(t, "<notification.6@example.com>", email.InReplyTo)
+			assert.Equal(t, []string{"<notification.6@example.com>"}, email.References)
+		})
+	}
+}
+
+func TestWorkerPostponesWhenCircuitOpen(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockRedis := &redisClient.MockRedisClient{}
+	mockSender := &SMTPClient.MockEmailSender{}
+
+	mockRedis.On("CheckRedis", mock.Anything).Return(
+		[]string{`{"type":"delayedSending","time":"1764687845","to":"test@example.com","subject":"Test","message":"Test message"}`},
+		nil,
+	).Once()
+	mockRedis.On("CheckRedis", mock.Anything).Return([]string{}, nil)
+
+	mockSender.On("SendEmail", mock.Anything, mock.Anything).
+		Return(nil, &SMTPClient.CircuitOpenError{RetryAfter: time.Minute})
+
+	postponed := make(chan *SMTPClient.EmailMessage, 1)
+
+	mockRedis.On("AddDelayedEmail", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		postponed <- args.Get(1).(*SMTPClient.EmailMessage)
+	})
+
+	wrk := New(
+		mockRedis,
+		newMockPostgres(),
+		mockSender,
+		nil,
+		nil,
+		&Config{},
+		backoff.Policy{},
+		100*time.Millisecond,
+		monitoring.NewNop(),
+		zap.NewNop(),
+	)
+
+	go func() {
+		err := wrk.Run(ctx)
+		require.NoError(t, err)
+	}()
+
+	select {
+	case email := <-postponed:
+		require.NotNil(t, email.Time)
+		require.WithinDuration(t, time.Now().Add(time.Minute), *email.Time, 2*time.Second)
+		require.Equal(t, "test@example.com", email.To)
+		require.Equal(t, "Test", email.Subject)
+		require.Equal(t, "Test message", email.Message)
+
+	case <-time.After(1 * time.Second):
+		t.Fatal("email was not postponed in time")
+	}
+}
+
 func TestWorkerContextCancel(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -211,7 +700,12 @@ func TestWorkerContextCancel(t *testing.T) {
 
 	wrk := New(
 		mockRedis,
+		newMockPostgres(),
 		mockSender,
+		nil,
+		nil,
+		&Config{},
+		backoff.Policy{},
 		100*time.Millisecond,
 		monitoring.NewNop(),
 		zap.NewNop(),
@@ -225,3 +719,60 @@ func TestWorkerContextCancel(t *testing.T) {
 	err := wrk.Run(ctx)
 	require.NoError(t, err)
 }
+
+// newMockPostgres returns the PostgreSQL mock claiming all emails
+// and all recipients as not suppressed.
+func newMockPostgres() *postgresClient.MockPostgresService {
+	mockPostgres := &postgresClient.MockPostgresService{}
+	mockPostgres.On("ClaimEmail", mock.Anything, mock.Anything).Return(nil)
+	mockPostgres.On("IsSuppressed", mock.Anything, mock.Anything).Return(false, nil)
+
+	return mockPostgres
+}
+
+// statusPostgres is the PostgreSQL mock keeping the status of a single email,
+// it claims and cancels the email only while it is pending, the same way the queries do.
+// The result of each claim is sent to claims.
+type statusPostgres struct {
+	*postgresClient.MockPostgresService
+
+	mu     sync.Mutex
+	status string
+	claims chan error
+}
+
+// newStatusPostgres returns the statusPostgres with the pending email.
+func newStatusPostgres() *statusPostgres {
+	return &statusPostgres{
+		MockPostgresService: &postgresClient.MockPostgresService{},
+		status:              api.StatusPending,
+		claims:              make(chan error, 1),
+	}
+}
+
+// ClaimEmail sets the sending status of the pending email.
+func (p *statusPostgres) ClaimEmail(ctx context.Context, id int) error {
+	err := p.transition(api.StatusSending)
+	p.claims <- err
+
+	return err
+}
+
+// CancelEmail sets the canceled status of the pending email.
+func (p *statusPostgres) CancelEmail(ctx context.Context, id int) error {
+	return p.transition(api.StatusCanceled)
+}
+
+// transition sets the status of the email if it is pending, otherwise returns pgx.ErrNoRows.
+func (p *statusPostgres) transition(status string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.status != api.StatusPending {
+		return pgx.ErrNoRows
+	}
+
+	p.status = status
+
+	return nil
+}
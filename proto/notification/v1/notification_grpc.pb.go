@@ -0,0 +1,343 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: notification/v1/notification.proto
+
+package notificationv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	NotificationService_Send_FullMethodName      = "/notification.v1.NotificationService/Send"
+	NotificationService_Schedule_FullMethodName  = "/notification.v1.NotificationService/Schedule"
+	NotificationService_Get_FullMethodName       = "/notification.v1.NotificationService/Get"
+	NotificationService_List_FullMethodName      = "/notification.v1.NotificationService/List"
+	NotificationService_Cancel_FullMethodName    = "/notification.v1.NotificationService/Cancel"
+	NotificationService_BatchSend_FullMethodName = "/notification.v1.NotificationService/BatchSend"
+)
+
+// NotificationServiceClient is the client API for NotificationService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// NotificationService sends and schedules email notifications, and fetches, lists and cancels them.
+// It shares the service layer, validation, metrics and logging with the HTTP API v2 (/v2/notifications).
+// Errors are returned with the gRPC status codes, the status details contain google.rpc.ErrorInfo
+// with the problem code of the HTTP API as the reason (for example, missing_fields or recipient_suppressed),
+// and google.rpc.BadRequest with the offending fields for the invalid requests.
+// The calls are authenticated with the API key or the JWT sent as "authorization: Bearer <token>" metadata,
+// or with the API key sent as "x-api-key" metadata, and require the same scopes as the HTTP API.
+type NotificationServiceClient interface {
+	// Send saves the notification and sends it immediately.
+	Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*CreatedNotification, error)
+	// Schedule saves the notification to be sent at send_at by the worker.
+	Schedule(ctx context.Context, in *ScheduleRequest, opts ...grpc.CallOption) (*CreatedNotification, error)
+	// Get returns the notification with the ID.
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Notification, error)
+	// List returns a single page of notifications.
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	// Cancel cancels the pending scheduled notification and returns it with the canceled status.
+	Cancel(ctx context.Context, in *CancelRequest, opts ...grpc.CallOption) (*Notification, error)
+	// BatchSend sends or schedules every streamed notification as it is received,
+	// and returns the result of each notification when the client closes the stream.
+	// An invalid or rejected notification does not stop the batch, its error is reported in the result.
+	// The notifications over the batch size limit are rejected with the body_too_large code without being processed.
+	// If the call fails, the results of the notifications processed so far are attached to the status details
+	// as BatchSendResponse.
+	BatchSend(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[BatchSendRequest, BatchSendResponse], error)
+}
+
+type notificationServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNotificationServiceClient(cc grpc.ClientConnInterface) NotificationServiceClient {
+	return &notificationServiceClient{cc}
+}
+
+func (c *notificationServiceClient) Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*CreatedNotification, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreatedNotification)
+	err := c.cc.Invoke(ctx, NotificationService_Send_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) Schedule(ctx context.Context, in *ScheduleRequest, opts ...grpc.CallOption) (*CreatedNotification, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreatedNotification)
+	err := c.cc.Invoke(ctx, NotificationService_Schedule_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Notification, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Notification)
+	err := c.cc.Invoke(ctx, NotificationService_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, NotificationService_List_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) Cancel(ctx context.Context, in *CancelRequest, opts ...grpc.CallOption) (*Notification, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Notification)
+	err := c.cc.Invoke(ctx, NotificationService_Cancel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *notificationServiceClient) BatchSend(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[BatchSendRequest, BatchSendResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &NotificationService_ServiceDesc.Streams[0], NotificationService_BatchSend_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BatchSendRequest, BatchSendResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NotificationService_BatchSendClient = grpc.ClientStreamingClient[BatchSendRequest, BatchSendResponse]
+
+// NotificationServiceServer is the server API for NotificationService service.
+// All implementations must embed UnimplementedNotificationServiceServer
+// for forward compatibility.
+//
+// NotificationService sends and schedules email notifications, and fetches, lists and cancels them.
+// It shares the service layer, validation, metrics and logging with the HTTP API v2 (/v2/notifications).
+// Errors are returned with the gRPC status codes, the status details contain google.rpc.ErrorInfo
+// with the problem code of the HTTP API as the reason (for example, missing_fields or recipient_suppressed),
+// and google.rpc.BadRequest with the offending fields for the invalid requests.
+// The calls are authenticated with the API key or the JWT sent as "authorization: Bearer <token>" metadata,
+// or with the API key sent as "x-api-key" metadata, and require the same scopes as the HTTP API.
+type NotificationServiceServer interface {
+	// Send saves the notification and sends it immediately.
+	Send(context.Context, *SendRequest) (*CreatedNotification, error)
+	// Schedule saves the notification to be sent at send_at by the worker.
+	Schedule(context.Context, *ScheduleRequest) (*CreatedNotification, error)
+	// Get returns the notification with the ID.
+	Get(context.Context, *GetRequest) (*Notification, error)
+	// List returns a single page of notifications.
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	// Cancel cancels the pending scheduled notification and returns it with the canceled status.
+	Cancel(context.Context, *CancelRequest) (*Notification, error)
+	// BatchSend sends or schedules every streamed notification as it is received,
+	// and returns the result of each notification when the client closes the stream.
+	// An invalid or rejected notification does not stop the batch, its error is reported in the result.
+	// The notifications over the batch size limit are rejected with the body_too_large code without being processed.
+	// If the call fails, the results of the notifications processed so far are attached to the status details
+	// as BatchSendResponse.
+	BatchSend(grpc.ClientStreamingServer[BatchSendRequest, BatchSendResponse]) error
+	mustEmbedUnimplementedNotificationServiceServer()
+}
+
+// UnimplementedNotificationServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedNotificationServiceServer struct{}
+
+func (UnimplementedNotificationServiceServer) Send(context.Context, *SendRequest) (*CreatedNotification, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Send not implemented")
+}
+func (UnimplementedNotificationServiceServer) Schedule(context.Context, *ScheduleRequest) (*CreatedNotification, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Schedule not implemented")
+}
+func (UnimplementedNotificationServiceServer) Get(context.Context, *GetRequest) (*Notification, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedNotificationServiceServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedNotificationServiceServer) Cancel(context.Context, *CancelRequest) (*Notification, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Cancel not implemented")
+}
+func (UnimplementedNotificationServiceServer) BatchSend(grpc.ClientStreamingServer[BatchSendRequest, BatchSendResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method BatchSend not implemented")
+}
+func (UnimplementedNotificationServiceServer) mustEmbedUnimplementedNotificationServiceServer() {}
+func (UnimplementedNotificationServiceServer) testEmbeddedByValue()                             {}
+
+// UnsafeNotificationServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NotificationServiceServer will
+// result in compilation errors.
+type UnsafeNotificationServiceServer interface {
+	mustEmbedUnimplementedNotificationServiceServer()
+}
+
+func RegisterNotificationServiceServer(s grpc.ServiceRegistrar, srv NotificationServiceServer) {
+	// If the following call pancis, it indicates UnimplementedNotificationServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&NotificationService_ServiceDesc, srv)
+}
+
+func _NotificationService_Send_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).Send(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_Send_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).Send(ctx, req.(*SendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_Schedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).Schedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_Schedule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).Schedule(ctx, req.(*ScheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_Cancel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NotificationServiceServer).Cancel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NotificationService_Cancel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NotificationServiceServer).Cancel(ctx, req.(*CancelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NotificationService_BatchSend_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(NotificationServiceServer).BatchSend(&grpc.GenericServerStream[BatchSendRequest, BatchSendResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NotificationService_BatchSendServer = grpc.ClientStreamingServer[BatchSendRequest, BatchSendResponse]
+
+// NotificationService_ServiceDesc is the grpc.ServiceDesc for NotificationService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var NotificationService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "notification.v1.NotificationService",
+	HandlerType: (*NotificationServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Send",
+			Handler:    _NotificationService_Send_Handler,
+		},
+		{
+			MethodName: "Schedule",
+			Handler:    _NotificationService_Schedule_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _NotificationService_Get_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _NotificationService_List_Handler,
+		},
+		{
+			MethodName: "Cancel",
+			Handler:    _NotificationService_Cancel_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BatchSend",
+			Handler:       _NotificationService_BatchSend_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "notification/v1/notification.proto",
+}